@@ -0,0 +1,142 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package glean
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	textscanner "text/scanner"
+)
+
+// ParseBNF reads a grammar written in a simple BNF from r, and adds its
+// rules to rules. Each BNF rule gives a target symbol, a colon, and one or
+// more alternatives separated by |, and ends with a semicolon:
+//
+//	Sum : Sum Plus Term | Term ;
+//	Terms : | Terms Term ;
+//
+// Each alternative, a sequence of symbols which may be empty, becomes a
+// rule for the target. A symbol is a Go identifier, possibly qualified by
+// a package name, as in ast.Expr, a list symbol such as []Term, or an
+// optional symbol such as *Term. A target may have several BNF rules.
+// Comments are written as in Go.
+//
+// The rules are named from their targets and the order of the target's
+// alternatives in the input: Sum_0, Sum_1 and so on. There are no rule
+// functions, so the program using a parser written for the grammar must
+// supply functions of these names.
+//
+// An error is returned for input that is not valid BNF, beginning with the
+// line and column of the problem, or if rules.AddRule fails.
+func ParseBNF(r io.Reader, rules RuleAdder) error {
+	p := bnfParser{rules: rules, counts: make(map[Symbol]int)}
+	p.scanner.Init(r)
+	p.scanner.Mode = textscanner.ScanIdents | textscanner.ScanComments | textscanner.SkipComments
+	p.scanner.Error = func(s *textscanner.Scanner, msg string) {
+		if p.err == nil {
+			p.err = fmt.Errorf("%d:%d: %s", s.Line, s.Column, msg)
+		}
+	}
+	p.next()
+	for p.err == nil && p.token != textscanner.EOF {
+		p.parseRule()
+	}
+	return p.err
+}
+
+// A bnfParser holds the state of ParseBNF.
+type bnfParser struct {
+	rules   RuleAdder
+	scanner textscanner.Scanner
+	token   rune           // The current token
+	counts  map[Symbol]int // The number of rules added for each target
+	err     error          // The first error found
+}
+
+// next moves on to the next token.
+func (p *bnfParser) next() {
+	p.token = p.scanner.Scan()
+}
+
+// fail records an error at the current token, unless one has been found.
+func (p *bnfParser) fail(expected string) {
+	if p.err != nil {
+		return
+	}
+	found := strconv.Quote(p.scanner.TokenText())
+	if p.token == textscanner.EOF {
+		found = "end of input"
+	}
+	p.err = fmt.Errorf("%d:%d: expected %s, found %s", p.scanner.Line, p.scanner.Column, expected, found)
+}
+
+// expect consumes the token t, or records an error if it is not next.
+func (p *bnfParser) expect(t rune, expected string) {
+	if p.token != t {
+		p.fail(expected)
+		return
+	}
+	p.next()
+}
+
+// parseRule parses one BNF rule, adding a rule for each alternative.
+func (p *bnfParser) parseRule() {
+	if p.token != textscanner.Ident {
+		p.fail("target symbol")
+		return
+	}
+	target := p.parseSymbol()
+	p.expect(':', "':' after "+string(target))
+	for p.err == nil {
+		var items []Symbol
+		for p.err == nil && p.token != '|' && p.token != ';' {
+			items = append(items, p.parseSymbol())
+		}
+		if p.err != nil {
+			return
+		}
+		name := string(target) + "_" + strconv.Itoa(p.counts[target])
+		p.counts[target]++
+		if e := p.rules.AddRule(name, target, items); e != nil {
+			p.err = e
+			return
+		}
+		if p.token == ';' {
+			p.next()
+			return
+		}
+		p.next() // '|'
+	}
+}
+
+// parseSymbol parses a symbol: an identifier, possibly qualified, or a
+// list or optional symbol.
+func (p *bnfParser) parseSymbol() Symbol {
+	if p.token == '*' {
+		p.next()
+		return OptionalOf(p.parseSymbol())
+	}
+	if p.token == '[' {
+		p.next()
+		p.expect(']', "']' after '['")
+		return ListOf(p.parseSymbol())
+	}
+	if p.token != textscanner.Ident {
+		p.fail("symbol, '|' or ';'")
+		return ""
+	}
+	s := Symbol(p.scanner.TokenText())
+	p.next()
+	if p.token == '.' {
+		p.next()
+		if p.token != textscanner.Ident {
+			p.fail("identifier after '.'")
+			return ""
+		}
+		s += Symbol("." + p.scanner.TokenText())
+		p.next()
+	}
+	return s
+}
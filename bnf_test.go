@@ -0,0 +1,71 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package glean
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseBNF(t *testing.T) {
+	var rs ruleStringer
+	e := ParseBNF(strings.NewReader(`
+// A sum of terms
+Sum : Sum Plus Term | Term ;
+Term : int
+     | Open Sum Close   /* parenthesized */
+     | ast.Ident Open Args Close ;
+Args : | []Sum ;
+Term : Minus Term ;
+Call : ast.Ident Open *Args Close ;
+`), &rs)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expectGrammar(t, &rs, `Args_0 Args []
+Args_1 Args [[]Sum]
+Call_0 Call [ast.Ident Open *Args Close]
+Sum_0 Sum [Sum Plus Term]
+Sum_1 Sum [Term]
+Term_0 Term [int]
+Term_1 Term [Open Sum Close]
+Term_2 Term [ast.Ident Open Args Close]
+Term_3 Term [Minus Term]`)
+}
+
+func TestParseBNFErrors(t *testing.T) {
+	for _, c := range []struct {
+		input, expect string
+	}{
+		{"Sum Plus Term ;", `1:5: expected ':' after Sum, found "Plus"`},
+		{"Sum : Sum Plus Term", "1:20: expected symbol, '|' or ';', found end of input"},
+		{"Sum : Sum + Term ;", `1:11: expected symbol, '|' or ';', found "+"`},
+		{"Sum : ;\n: Term ;", `2:1: expected target symbol, found ":"`},
+		{"Sum : [Term] ;", `1:8: expected ']' after '[', found "Term"`},
+		{"Sum : ast. ;", `1:12: expected identifier after '.', found ";"`},
+		{"Sum : 'x ;", "1:7: expected symbol, '|' or ';', found \"'\""},
+	} {
+		var rs ruleStringer
+		e := ParseBNF(strings.NewReader(c.input), &rs)
+		if e == nil || e.Error() != c.expect {
+			t.Errorf("input %q:\nexpected error: %s\ngot: %v", c.input, c.expect, e)
+		}
+	}
+
+	failure := errors.New("no more rules")
+	e := ParseBNF(strings.NewReader("Sum : Term ; Term : int ;"), failingAdder{failure})
+	if e != failure {
+		t.Error("wrong error from AddRule:", e)
+	}
+}
+
+// A failingAdder fails to add any rule.
+type failingAdder struct {
+	err error
+}
+
+func (f failingAdder) AddRule(name string, target Symbol, items []Symbol) error {
+	return f.err
+}
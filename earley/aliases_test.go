@@ -0,0 +1,91 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test rules sharing one reducer through an alias directive
+func TestAliases(t *testing.T) {
+	parse, e := gleantest.Build(t, aliasesMainText, "Expr", earley.Options{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, test := range []struct {
+		input  []string
+		output string
+	}{
+		{[]string{"1", "+", "2"}, "3"},
+		{[]string{"7", "-", "2", "*", "3"}, "15"},
+		{[]string{"7", "*"}, "unexpected end of input"},
+	} {
+		out, e := parse(test.input...)
+		if e != nil {
+			t.Fatal(e, out)
+		}
+		if out != test.output+"\n" {
+			t.Errorf("wrong output for %q: expected %s, got %s", test.input, test.output, out)
+		}
+	}
+}
+
+var aliasesMainText = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type Expr int
+
+type Operator interface {
+	apply(x, y Expr) Expr
+}
+
+type Plus struct{}
+type Minus struct{}
+type Times struct{}
+
+func (Plus) apply(x, y Expr) Expr  { return x + y }
+func (Minus) apply(x, y Expr) Expr { return x - y }
+func (Times) apply(x, y Expr) Expr { return x * y }
+
+func RuleInt(i int) Expr { return Expr(i) }
+
+//glean:alias Operator Plus Minus Times
+func RuleBinary(x Expr, op Operator, y int) Expr { return op.apply(x, Expr(y)) }
+
+func main() {
+	var tokens []interface{}
+	for _, a := range os.Args[1:] {
+		switch a {
+		case "+":
+			tokens = append(tokens, Plus{})
+		case "-":
+			tokens = append(tokens, Minus{})
+		case "*":
+			tokens = append(tokens, Times{})
+		default:
+			i, e := strconv.Atoi(a)
+			if e != nil {
+				panic(e)
+			}
+			tokens = append(tokens, i)
+		}
+	}
+
+	x, e := _glean_Parse(tokens)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(x)
+}
+`
@@ -0,0 +1,109 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test tokens that may match more than one terminal symbol
+func TestAlternatives(t *testing.T) {
+	var options earley.Options
+	options.Classifier = true
+	options.Alternatives = true
+	parse, e := gleantest.Build(t, alternativesMainText, "Stmt", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	for _, test := range []struct {
+		input  []string
+		output string
+	}{
+		{[]string{"if", "x"}, "if x"},
+		{[]string{"if", "=", "y"}, "if := y"},
+		{[]string{"if"}, "expression if"},
+		{[]string{"junk"}, "expression junk"},
+		{[]string{"x", "x"}, `unexpected token: "x"`},
+		{[]string{"return"}, "ambiguous: [RuleExpr RuleReturn]"},
+	} {
+		out, e := parse(test.input...)
+		if e != nil {
+			t.Fatal(e, out)
+		}
+		if out != test.output+"\n" {
+			t.Errorf("wrong output for %q:\nexpected: %s\ngot: %s", test.input, test.output, out)
+		}
+	}
+
+	options.Classifier = false
+	if _, e := gleantest.Build(t, alternativesMainText, "Stmt", options); e == nil {
+		t.Error("no error for Alternatives without Classifier")
+	}
+}
+
+var alternativesMainText = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Stmt string
+type Name = string
+type Assign = string
+type If = string
+type Return = string
+
+func RuleIf(_ If, n Name) Stmt                { return Stmt("if " + n) }
+func RuleAssign(x Name, _ Assign, y Name) Stmt { return Stmt(x + " := " + y) }
+func RuleExpr(n Name) Stmt                     { return Stmt("expression " + n) }
+func RuleReturn(_ Return) Stmt                 { return Stmt("return") }
+
+func main() {
+	var tokens []interface{}
+	for _, a := range os.Args[1:] {
+		tokens = append(tokens, a)
+	}
+
+	classify := func(t interface{}) int {
+		if t.(string) == "=" {
+			return _glean_TerminalAssign
+		}
+		return _glean_TerminalName
+	}
+	alternatives := func(t interface{}) []int {
+		switch t.(string) {
+		case "if":
+			return []int{_glean_TerminalIf}
+		case "return":
+			return []int{_glean_TerminalReturn, _glean_TerminalName}
+		case "junk":
+			return []int{-1, 99}
+		}
+		return nil
+	}
+
+	s, e := _glean_Parse(tokens, classify, alternatives)
+	if a, ok := e.(gleanerrors.Ambiguous); ok {
+		// The order of the rules of an ambiguity depends on the parse
+		names := []string{a.Rule1.Name, a.Rule2.Name}
+		sort.Strings(names)
+		fmt.Println("ambiguous:", names)
+		return
+	}
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(s)
+}
+`
@@ -0,0 +1,281 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test the policies for choosing among ambiguous parses
+func TestAmbiguityPolicy(t *testing.T) {
+	for _, policy := range []struct {
+		ambiguity earley.Ambiguity
+		outputs   []string
+	}{
+		{earley.AmbiguityLeftmost, []string{"7 0", "5 1", "3 3", "6 1", "-5 2"}},
+		{earley.AmbiguityRightmost, []string{"7 0", "9 1", "9 3", "6 1", "-5 2"}},
+		{earley.AmbiguityLongest, []string{"7 0", "5 1", "3 3", "6 1", "-3 1"}},
+	} {
+		tmp := t.TempDir()
+
+		mainGo := filepath.Join(tmp, "main.go")
+		if e := os.WriteFile(mainGo, []byte(ambiguityMainText), 0444); e != nil {
+			t.Fatal(e)
+		}
+
+		var g earley.Grammar
+		g.Options.Ambiguity = policy.ambiguity
+		addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+			if e := g.AddRule(name, target, items); e != nil {
+				t.Fatal(e)
+			}
+		}
+		addrule("RuleInt", "Diff", "int")
+		addrule("RuleSubtract", "Diff", "Diff", "Minus", "Diff")
+		addrule("RuleDouble", "Diff", "int", "Twice")
+		addrule("RuleTwice", "Diff", "Diff", "Twice")
+		parserText, e := g.WriteParser("Diff", "main", "_")
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		parserGo := filepath.Join(tmp, "parser.go")
+		if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+			t.Fatal(e)
+		}
+
+		for n, input := range []string{"7", "10 - 3 - 2", "10 - 3 - 2 - 1 - 1", "3 *", "1 - 3 *"} {
+			args := append([]string{"run", mainGo, parserGo}, strings.Split(input, " ")...)
+			out, e := exec.Command("go", args...).CombinedOutput()
+			if e != nil {
+				t.Fatal(e, string(out))
+			}
+			if expect := policy.outputs[n]; string(out) != expect+"\n" {
+				t.Errorf("policy %d, input %q: expected %s, got %s", policy.ambiguity, input, expect, out)
+			}
+		}
+	}
+
+	var g earley.Grammar
+	g.Options.Ambiguity = earley.AmbiguityLongest + 1
+	g.AddRule("RuleInt", "Diff", []glean.Symbol{"int"})
+	if _, e := g.WriteParser("Diff", "main", "_"); e == nil {
+		t.Error("no error for unknown ambiguity policy")
+	}
+}
+
+var ambiguityMainText = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type Diff int
+type Minus struct{}
+type Twice struct{}
+
+func RuleInt(i int) Diff { return Diff(i) }
+func RuleSubtract(x Diff, _ Minus, y Diff) Diff { return x - y }
+func RuleDouble(i int, _ Twice) Diff { return Diff(2 * i) }
+func RuleTwice(x Diff, _ Twice) Diff { return x + x + 1 }
+
+func main() {
+	var tokens []interface{}
+	for _, a := range os.Args[1:] {
+		switch a {
+		case "-":
+			tokens = append(tokens, Minus{})
+		case "*":
+			tokens = append(tokens, Twice{})
+		default:
+			i, e := strconv.Atoi(a)
+			if e != nil {
+				panic(e)
+			}
+			tokens = append(tokens, i)
+		}
+	}
+
+	diff, resolved, e := _ParseResolved(tokens)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(diff, resolved)
+}
+`
+
+// Test the record of ambiguities resolved by a policy
+func TestResolutions(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(resolutionsMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.Resolutions = true
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleInt", "Diff", "int")
+	addrule("RuleSubtract", "Diff", "Diff", "Minus", "Diff")
+	addrule("RuleDouble", "Diff", "int", "Twice")
+	addrule("RuleTwice", "Diff", "Diff", "Twice")
+	if _, e := g.WriteParser("Diff", "main", "_"); e == nil {
+		t.Error("no error for Resolutions without an ambiguity policy")
+	}
+
+	g.Options.Ambiguity = earley.AmbiguityLeftmost
+	parserText, e := g.WriteParser("Diff", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	out, e := exec.Command("go", "run", mainGo, parserGo).CombinedOutput()
+	if e != nil {
+		t.Fatal(e, string(out))
+	}
+	expect := `7 []
+5 [{0 4 RuleSubtract RuleSubtract}]
+-5 [{0 3 RuleSubtract RuleTwice} {2 3 RuleDouble RuleTwice}]
+`
+	if string(out) != expect {
+		t.Errorf("wrong output:\nexpected:\n%s\ngot:\n%s", expect, out)
+	}
+}
+
+var resolutionsMainText = `
+package main
+
+import "fmt"
+
+type Diff int
+type Minus struct{}
+type Twice struct{}
+
+func RuleInt(i int) Diff { return Diff(i) }
+func RuleSubtract(x Diff, _ Minus, y Diff) Diff { return x - y }
+func RuleDouble(i int, _ Twice) Diff { return Diff(2 * i) }
+func RuleTwice(x Diff, _ Twice) Diff { return x + x + 1 }
+
+type summary struct {
+	first, last       int
+	chosen, discarded string
+}
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{7},
+		{10, Minus{}, 3, Minus{}, 2},
+		{1, Minus{}, 3, Twice{}},
+	} {
+		diff, resolutions, e := _ParseResolutions(tokens)
+		if e != nil {
+			fmt.Println(e)
+			continue
+		}
+		var summaries []summary
+		for _, r := range resolutions {
+			summaries = append(summaries, summary{r.Range.First.Index, r.Range.Last.Index, r.Chosen.Name, r.Discarded.Name})
+		}
+		fmt.Println(diff, summaries)
+	}
+}
+`
+
+// Test the warnings of ambiguities resolved by a policy
+func TestWarnings(t *testing.T) {
+	var options earley.Options
+	options.Warnings = true
+	if _, e := gleantest.Build(t, warningsMainText, "Diff", options); e == nil {
+		t.Error("no error for Warnings without an ambiguity policy")
+	}
+
+	options.Ambiguity = earley.AmbiguityLeftmost
+	options.Resolutions = true
+	parse, e := gleantest.Build(t, warningsMainText, "Diff", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	out, e := parse()
+	if e != nil {
+		t.Fatal(e, out)
+	}
+	expect := `7 []
+5 [{0 4 RuleSubtract RuleSubtract [int Minus int Minus int]}]
+-5 [{0 3 RuleSubtract RuleTwice [int Minus int Twice]} {2 3 RuleDouble RuleTwice [int Twice]}]
+`
+	if out != expect {
+		t.Errorf("wrong output:\nexpected:\n%s\ngot:\n%s", expect, out)
+	}
+}
+
+var warningsMainText = `
+package main
+
+import (
+	"fmt"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Diff int
+type Minus struct{}
+type Twice struct{}
+
+func RuleInt(i int) Diff { return Diff(i) }
+func RuleSubtract(x Diff, _ Minus, y Diff) Diff { return x - y }
+func RuleDouble(i int, _ Twice) Diff { return Diff(2 * i) }
+func RuleTwice(x Diff, _ Twice) Diff { return x + x + 1 }
+
+type summary struct {
+	first, last  int
+	rule1, rule2 string
+	example      []string
+}
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{7},
+		{10, Minus{}, 3, Minus{}, 2},
+		{1, Minus{}, 3, Twice{}},
+	} {
+		diff, warnings, e := _glean_ParseWarnings(tokens)
+		if e != nil {
+			fmt.Println(e)
+			continue
+		}
+		var summaries []summary
+		for _, w := range warnings {
+			a := w.(gleanerrors.Ambiguous)
+			summaries = append(summaries, summary{a.First.Index, a.Last.Index, a.Rule1.Name, a.Rule2.Name, a.Example})
+		}
+		_, resolutions, _ := _glean_ParseResolutions(tokens)
+		if len(resolutions) != len(warnings) {
+			fmt.Println("resolutions and warnings differ")
+		}
+		fmt.Println(diff, summaries)
+	}
+}
+`
@@ -0,0 +1,181 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/pat42smith/glean"
+)
+
+// AmbiguousExample searches for a shortest sequence of at most maxTokens
+// terminal symbols, matched by the goal symbol, that has two or more
+// derivations, and so would give a gleanerrors.Ambiguous error from the
+// parser. It returns the sequence and true if one is found, and otherwise
+// nil and false. Of the ambiguous sequences of the shortest length, the
+// first in order of the names of their symbols is returned, so the result
+// is deterministic. Inputs longer than maxTokens are not examined, so no
+// result is not proof that the grammar is unambiguous. The search may be
+// made before WriteParser; the goal is not recorded.
+//
+// The search visits each sequence the goal derives, up to maxTokens
+// terminals long, counting its derivations, so its cost may grow
+// exponentially with maxTokens. Small bounds catch most mistakes, such as
+// two rules with the same items, or nested rules that allow the same
+// tokens to be grouped in two ways.
+func (g *Grammar) AmbiguousExample(goal glean.Symbol, maxTokens int) ([]glean.Symbol, bool) {
+	s := g.name2symbol[goal]
+	if s == nil || s.isTerminal() {
+		return nil, false
+	}
+
+	// Number the terminals in order of name, and spell each sequence of
+	// terminals as a string holding a rune for each.
+	var terminals []*symbol
+	for _, t := range g.name2symbol {
+		if t.isTerminal() {
+			terminals = append(terminals, t)
+		}
+	}
+	sort.Slice(terminals, func(i, j int) bool {
+		return terminals[i].name < terminals[j].name
+	})
+	ids := make(map[*symbol]rune)
+	for n, t := range terminals {
+		ids[t] = rune(n)
+	}
+
+	allRules := append(g.rules[:len(g.rules):len(g.rules)], g.listRules...)
+	sentences := g.sentences(allRules, ids, maxTokens)[s]
+	sorted := make([]string, 0, len(sentences))
+	for w := range sentences {
+		sorted = append(sorted, w)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if la, lb := utf8.RuneCountInString(a), utf8.RuneCountInString(b); la != lb {
+			return la < lb
+		}
+		return a < b
+	})
+
+	for _, w := range sorted {
+		tokens := []rune(w)
+		if countDerivations(allRules, ids, tokens)[span{s, 0, len(tokens)}] > 1 {
+			example := make([]glean.Symbol, len(tokens))
+			for n, id := range tokens {
+				example[n] = terminals[id].name
+			}
+			return example, true
+		}
+	}
+	return nil, false
+}
+
+// Find the sequences of at most max terminals derived by each symbol
+func (g *Grammar) sentences(rules []*rule, ids map[*symbol]rune, max int) map[*symbol]map[string]bool {
+	sentences := make(map[*symbol]map[string]bool)
+	for _, s := range g.name2symbol {
+		sentences[s] = make(map[string]bool)
+	}
+	if max > 0 {
+		for t, id := range ids {
+			sentences[t][string(id)] = true
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, r := range rules {
+			partial := map[string]bool{"": true}
+			for _, item := range r.items {
+				next := make(map[string]bool)
+				for a := range partial {
+					for b := range sentences[item] {
+						if utf8.RuneCountInString(a)+utf8.RuneCountInString(b) <= max {
+							next[a+b] = true
+						}
+					}
+				}
+				partial = next
+			}
+			for w := range partial {
+				if !sentences[r.target][w] {
+					sentences[r.target][w] = true
+					changed = true
+				}
+			}
+		}
+	}
+	return sentences
+}
+
+// A symbol matching the tokens from start up to end
+type span struct {
+	s          *symbol
+	start, end int
+}
+
+// Count the derivations of each symbol matching each part of the tokens,
+// counting two for any number more than one. The parts are taken in order
+// of length; the counts for parts of one length depend on each other
+// through rules whose other items match nothing, so are found by iterating
+// until they settle, which they must, as they only grow.
+func countDerivations(rules []*rule, ids map[*symbol]rune, tokens []rune) map[span]int {
+	counts := make(map[span]int)
+	for t, id := range ids {
+		for n, token := range tokens {
+			if token == id {
+				counts[span{t, n, n + 1}] = 1
+			}
+		}
+	}
+
+	for length := 0; length <= len(tokens); length++ {
+		for changed := true; changed; {
+			changed = false
+			for start := 0; start+length <= len(tokens); start++ {
+				end := start + length
+				sums := make(map[*symbol]int)
+				for _, r := range rules {
+					// ways[k] counts the matches of the items so far
+					// from start to start+k
+					ways := make([]int, length+1)
+					ways[0] = 1
+					for _, item := range r.items {
+						next := make([]int, length+1)
+						for b, w := range ways {
+							if w == 0 {
+								continue
+							}
+							for e := b; e <= length; e++ {
+								if c := counts[span{item, start + b, start + e}]; c > 0 {
+									next[e] = min2(next[e] + w*c)
+								}
+							}
+						}
+						ways = next
+					}
+					sums[r.target] = min2(sums[r.target] + ways[length])
+				}
+				for s, n := range sums {
+					if sp := (span{s, start, end}); n > counts[sp] {
+						counts[sp] = n
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	return counts
+}
+
+// Limit a count to 2, which stands for any larger number
+func min2(n int) int {
+	if n > 2 {
+		return 2
+	}
+	return n
+}
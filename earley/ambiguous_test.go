@@ -0,0 +1,65 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test the search for ambiguous inputs
+func TestAmbiguousExample(t *testing.T) {
+	var g earley.Grammar
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	expect := func(goal glean.Symbol, max int, example string) {
+		t.Helper()
+		tokens, found := g.AmbiguousExample(goal, max)
+		if got := fmt.Sprint(tokens, found); got != example {
+			t.Errorf("goal %s, max %d: expected %s, got %s", goal, max, example, got)
+		}
+	}
+
+	addrule("RuleExpr", "Goal", "Expr")
+	addrule("RuleInt", "Expr", "int")
+	addrule("RuleParens", "Goal", "Open", "Goal", "Close")
+	expect("Goal", 6, "[] false")
+
+	addrule("RuleAdd", "Expr", "Expr", "Plus", "Expr")
+	expect("Goal", 4, "[] false")
+	expect("Goal", 5, "[int Plus int Plus int] true")
+	expect("Expr", 5, "[int Plus int Plus int] true")
+
+	addrule("RuleOpenClose", "Goal", "Open", "Close")
+	addrule("RulePair", "Goal", "Pair")
+	addrule("RuleMakePair", "Pair", "Open", "Close")
+	expect("Goal", 5, "[Open Close] true")
+	expect("Goal", 1, "[] false")
+	expect("Pair", 5, "[] false")
+
+	// Nullable symbols deriving themselves have infinitely many derivations.
+	addrule("RuleBlank", "Blank")
+	addrule("RuleBlank2", "Blank", "Blank", "Blank")
+	addrule("RuleInfinite", "Start", "Blank", "int")
+	expect("Start", 3, "[int] true")
+	expect("Blank", 0, "[] true")
+	expect("Blank", 3, "[] true")
+
+	// Lists are not ambiguous themselves, but their elements may be.
+	addrule("RuleInts", "Ints", glean.ListOf("int"))
+	expect("Ints", 6, "[] false")
+	addrule("RuleExprs", "Exprs", glean.ListOf("Expr"))
+	expect("Exprs", 6, "[int Plus int Plus int] true")
+	addrule("RuleBlanks", "Blanks", "Open", glean.ListOf("Blank"))
+	expect("Blanks", 3, "[Open] true")
+
+	expect("int", 5, "[] false")
+	expect("Missing", 5, "[] false")
+}
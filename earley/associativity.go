@@ -0,0 +1,71 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+)
+
+// Check Options.Associativity, and find the associativity of each rule:
+// 1 for left, -1 for right, or 0 for none, indexed by rule id
+func (g *Grammar) checkAssociativity() ([]int, error) {
+	if len(g.Options.Associativity) == 0 {
+		return nil, nil
+	}
+	if len(g.Options.Weights) > 0 {
+		return nil, fmt.Errorf("options Associativity and Weights cannot be combined")
+	}
+	for name, a := range g.Options.Associativity {
+		if s := g.name2symbol[name]; s == nil || !s.isTerminal() {
+			return nil, fmt.Errorf("associativity given for '%s', which is not a terminal symbol", name)
+		}
+		if a != AmbiguityLeftmost && a != AmbiguityRightmost {
+			return nil, fmt.Errorf("associativity of '%s' is not AmbiguityLeftmost or AmbiguityRightmost", name)
+		}
+	}
+
+	assoc := make([]int, len(g.rules)+len(g.listRules))
+	for _, r := range g.rules {
+		var from *symbol
+		for _, i := range r.items {
+			a, have := g.Options.Associativity[i.name]
+			if !have {
+				continue
+			}
+			dir := 1
+			if a == AmbiguityRightmost {
+				dir = -1
+			}
+			if from != nil && dir != assoc[r.id] {
+				return nil, fmt.Errorf("rule %s has symbols '%s' and '%s' of opposite associativity", r.name, from.name, i.name)
+			}
+			from = i
+			assoc[r.id] = dir
+		}
+	}
+	return assoc, nil
+}
+
+// Add the associativity of each prefix, if any symbols are associative:
+// that of its rules if they agree, and 0 otherwise, or if the prefix has
+// fewer than two items
+func (g *Grammar) addAssociativity() {
+	if g.assoc == nil {
+		return
+	}
+	g.addText("\nvar @_associativity = []int8{\n")
+	for _, p := range g.prefixes {
+		a := 0
+		if p.length >= 2 {
+			a = g.assoc[p.rules[0].id]
+			for _, r := range p.rules[1:] {
+				if g.assoc[r.id] != a {
+					a = 0
+				}
+			}
+		}
+		g.addf("\t%d,\n", a)
+	}
+	g.addString("}\n")
+}
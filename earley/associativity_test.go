@@ -0,0 +1,87 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test resolving the ambiguity of Ambiguous1 in TestParseErrors by
+// associativity
+func TestAssociativity(t *testing.T) {
+	for _, c := range []struct {
+		assoc  map[glean.Symbol]earley.Ambiguity
+		expect string
+	}{
+		{nil, "ambiguous: [RuleAdd RuleAdd]\nambiguous: [RuleAdd RuleMul]\n"},
+		{map[glean.Symbol]earley.Ambiguity{"Plus": earley.AmbiguityLeftmost}, "((2+3)+5)\nambiguous: [RuleAdd RuleMul]\n"},
+		{map[glean.Symbol]earley.Ambiguity{"Plus": earley.AmbiguityRightmost}, "(2+(3+5))\nambiguous: [RuleAdd RuleMul]\n"},
+	} {
+		var options earley.Options
+		options.Associativity = c.assoc
+		parse, e := gleantest.Build(t, associativityMainText, "Expr", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if out, e := parse(); e != nil || out != c.expect {
+			t.Errorf("associativity %v:\nexpected:\n%s\ngot:\n%s %v", c.assoc, c.expect, out, e)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleAdd", "Expr", []glean.Symbol{"Expr", "Plus", "Minus", "Expr"})
+	g.AddRule("RuleInt", "Expr", []glean.Symbol{"int"})
+	for _, options := range []earley.Options{
+		{Associativity: map[glean.Symbol]earley.Ambiguity{"Expr": earley.AmbiguityLeftmost}},
+		{Associativity: map[glean.Symbol]earley.Ambiguity{"Times": earley.AmbiguityLeftmost}},
+		{Associativity: map[glean.Symbol]earley.Ambiguity{"Plus": earley.AmbiguityError}},
+		{Associativity: map[glean.Symbol]earley.Ambiguity{"Plus": earley.AmbiguityLeftmost, "Minus": earley.AmbiguityRightmost}},
+		{Associativity: map[glean.Symbol]earley.Ambiguity{"Plus": earley.AmbiguityLeftmost}, Weights: map[string]int{"RuleAdd": 1}},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Expr", "main", "_"); e == nil {
+			t.Errorf("no error for options %v", options)
+		}
+	}
+}
+
+var associativityMainText = `
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Expr string
+type Plus struct{}
+type Times struct{}
+
+func RuleInt(i int) Expr                   { return Expr(fmt.Sprint(i)) }
+func RuleAdd(x Expr, _ Plus, y Expr) Expr  { return "(" + x + "+" + y + ")" }
+func RuleMul(x Expr, _ Times, y Expr) Expr { return "(" + x + "*" + y + ")" }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{2, Plus{}, 3, Plus{}, 5},
+		{2, Plus{}, 3, Times{}, 5},
+	} {
+		// The order of the rules of a mixed ambiguity depends on the parse
+		if expr, e := _glean_Parse(tokens); e != nil {
+			a := e.(gleanerrors.Ambiguous)
+			names := []string{a.Rule1.Name, a.Rule2.Name}
+			sort.Strings(names)
+			fmt.Println("ambiguous:", names)
+		} else {
+			fmt.Println(expr)
+		}
+	}
+}
+`
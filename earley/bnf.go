@@ -0,0 +1,122 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/pat42smith/glean"
+)
+
+// WriteBNF writes the rules of the grammar to w in BNF, one line for each
+// nonterminal symbol, sorted by name, as in
+//
+//	Sum ::= Sum Plus Term | Term
+//
+// The alternatives of a line are the rules of its symbol, in the order they
+// were added; a rule with no items is written as ε. List symbols are
+// included, with the rules the Grammar makes for them. The output depends
+// only on the rules, so it may be compared between versions of a grammar.
+func (g *Grammar) WriteBNF(w io.Writer) error {
+	_, rules := groupRules(append(append([]*rule(nil), g.rules...), g.listRules...))
+	for _, s := range g.Nonterminals() {
+		var alternatives []string
+		for _, r := range rules[s] {
+			items := "ε"
+			if len(r.items) > 0 {
+				items = strings.Join(symbolNames(r.items), " ")
+			}
+			alternatives = append(alternatives, items)
+		}
+		if _, e := fmt.Fprintf(w, "%s ::= %s\n", s, strings.Join(alternatives, " | ")); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// WriteEBNF writes the rules of the grammar to w in the EBNF of ISO/IEC 14977,
+// which other tools can read, after a comment line naming the format.
+//
+// Each nonterminal has one EBNF rule, whose alternatives are its rules in the
+// order they were added. The rule for goal comes first, and the others follow
+// in the order their first rules were added. Terminals are written as quoted
+// strings of their names, and nonterminals as meta identifiers, in which each
+// character other than a letter or digit, such as the dot of a qualified name,
+// becomes a space. An empty alternative is written as the comment (* empty *),
+// a list item []X as X, {X}, and an optional item *X as [X].
+func (g *Grammar) WriteEBNF(w io.Writer, goal glean.Symbol) error {
+	targets, rules := groupRules(g.rules)
+	var term func(s glean.Symbol) string
+	term = func(s glean.Symbol) string {
+		if e := glean.ListElement(s); e != "" {
+			t := term(e)
+			return t + ", {" + t + "}"
+		}
+		if e := glean.OptionalElement(s); e != "" {
+			return "[" + term(e) + "]"
+		}
+		if _, have := rules[s]; have {
+			return metaIdentifier(s)
+		}
+		return `"` + string(s) + `"`
+	}
+
+	if _, have := rules[goal]; have {
+		targets = append([]glean.Symbol{goal}, targets...)
+	}
+	if _, e := fmt.Fprintln(w, "(* Grammar written by glean, in ISO/IEC 14977 EBNF *)"); e != nil {
+		return e
+	}
+	written := make(map[glean.Symbol]bool)
+	for _, s := range targets {
+		if written[s] {
+			continue
+		}
+		written[s] = true
+		var alternatives []string
+		for _, r := range rules[s] {
+			if len(r.items) == 0 {
+				alternatives = append(alternatives, "(* empty *)")
+				continue
+			}
+			terms := make([]string, len(r.items))
+			for n, i := range r.items {
+				terms[n] = term(i.name)
+			}
+			alternatives = append(alternatives, strings.Join(terms, ", "))
+		}
+		if _, e := fmt.Fprintf(w, "%s = %s ;\n", metaIdentifier(s), strings.Join(alternatives, " | ")); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// Group rules by target, also returning the targets in the order their first rules appear
+func groupRules(rules []*rule) ([]glean.Symbol, map[glean.Symbol][]*rule) {
+	var targets []glean.Symbol
+	grouped := make(map[glean.Symbol][]*rule)
+	for _, r := range rules {
+		if _, have := grouped[r.target.name]; !have {
+			targets = append(targets, r.target.name)
+		}
+		grouped[r.target.name] = append(grouped[r.target.name], r)
+	}
+	return targets, grouped
+}
+
+// The name of a symbol as an EBNF meta identifier, which may contain only
+// letters, digits and spaces
+func metaIdentifier(s glean.Symbol) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, string(s)))
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+)
+
+// Test writing the grammar in BNF
+func TestWriteBNF(t *testing.T) {
+	var g Grammar
+	var b strings.Builder
+	if e := g.WriteBNF(&b); e != nil || b.Len() != 0 {
+		t.Errorf("empty grammar: %q %v", b.String(), e)
+	}
+	CheckZero(t, g)
+
+	g.AddRule("RuleSum", "Sum", []glean.Symbol{"Sum", "Plus", "Term"})
+	g.AddRule("RuleTerm", "Sum", []glean.Symbol{"Term"})
+	g.AddRule("RuleCall", "Term", []glean.Symbol{"Ident", "Open", glean.ListOf("Sum"), "Close"})
+	g.AddRule("RuleNone", "Term", nil)
+	expect := `Sum ::= Sum Plus Term | Term
+Term ::= Ident Open []Sum Close | ε
+[]Sum ::= Sum | []Sum Sum
+`
+	if e := g.WriteBNF(&b); e != nil || b.String() != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, b.String(), e)
+	}
+
+	// Writing a parser does not change the order of the rules.
+	if _, e := g.WriteParser("Sum", "main", "_"); e != nil {
+		t.Fatal(e)
+	}
+	b.Reset()
+	if e := g.WriteBNF(&b); e != nil || b.String() != expect {
+		t.Errorf("after WriteParser, expected:\n%s\ngot:\n%s %v", expect, b.String(), e)
+	}
+}
+
+// Test writing the grammar in ISO/IEC 14977 EBNF
+func TestWriteEBNF(t *testing.T) {
+	var g Grammar
+	g.AddRule("RuleBlock", "Block", []glean.Symbol{"Open", glean.ListOf("stmt.Stmt"), "Close"})
+	g.AddRule("RuleExpr", "stmt.Stmt", []glean.Symbol{"Expr", glean.OptionalOf("Semi")})
+	g.AddRule("RuleEmpty", "stmt.Stmt", nil)
+	g.AddRule("RuleName", "Expr", []glean.Symbol{"my_name"})
+	g.AddRule("RuleProgram", "Program", []glean.Symbol{"Block"})
+	expect := `(* Grammar written by glean, in ISO/IEC 14977 EBNF *)
+Program = Block ;
+Block = "Open", stmt Stmt, {stmt Stmt}, "Close" ;
+stmt Stmt = Expr, ["Semi"] | (* empty *) ;
+Expr = "my_name" ;
+`
+	var b strings.Builder
+	if e := g.WriteEBNF(&b, "Program"); e != nil || b.String() != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, b.String(), e)
+	}
+}
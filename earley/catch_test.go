@@ -0,0 +1,85 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test a parser that converts panics into errors
+func TestCatchPanics(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(catchMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.CatchPanics = true
+	g.Options.Recover = true
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleInt", "Quotient", "int")
+	addrule("RuleDivide", "Quotient", "Quotient", "Divide", "int")
+	parserText, e := g.WriteParser("Quotient", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	out, e := exec.Command("go", "run", mainGo, parserGo).CombinedOutput()
+	if e != nil {
+		t.Fatal(e, string(out))
+	}
+	expect := `6 <nil>
+0 input token (type string) is not a terminal symbol [12 {} x]
+0 runtime error: integer divide by zero [12 {} 0]
+0 [unexpected token: main.Divide internal error in parser: input token (type string) is not a terminal symbol]
+`
+	if string(out) != expect {
+		t.Errorf("wrong output:\nexpected:\n%s\ngot:\n%s", expect, out)
+	}
+}
+
+var catchMainText = `
+package main
+
+import (
+	"fmt"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Quotient int
+type Divide struct{}
+
+func RuleInt(i int) Quotient { return Quotient(i) }
+func RuleDivide(q Quotient, _ Divide, i int) Quotient { return q / Quotient(i) }
+
+func main() {
+	fmt.Println(_Parse([]interface{}{12, Divide{}, 2}))
+
+	for _, tokens := range [][]interface{}{{12, Divide{}, "x"}, {12, Divide{}, 0}} {
+		q, e := _Parse(tokens)
+		internal := e.(gleanerrors.Internal)
+		fmt.Println(q, internal.Recovered, internal.Tokens)
+	}
+
+	fmt.Println(_ParseRecover([]interface{}{12, Divide{}, Divide{}, "x"}, 0))
+}
+`
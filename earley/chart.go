@@ -0,0 +1,80 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the methods preparing the chart of matches and giving the matches
+// ending at a position
+func (g *Grammar) addPrepare() {
+	if g.Options.Reusable {
+		g.addReusablePrepare()
+		return
+	}
+
+	if g.Options.ChartStore {
+		g.addText(`
+func (parser *@_Parser) prepare() {
+	parser.chart.Reset(len(parser.tokens))
+	parser.todo = make([][]*@_Match, len(parser.tokens)+1)
+}
+
+func (parser *@_Parser) at(end int) map[@_Prefix][]*@_Match {
+	return parser.chart.At(end)
+}
+`)
+		return
+	}
+
+	g.addText(`
+func (parser *@_Parser) prepare() {
+	// fmt.Fprintln(os.Stderr, parser.tokens)
+	parser.matches = make([]map[@_Prefix][]*@_Match, len(parser.tokens)+1)
+	parser.todo = make([][]*@_Match, len(parser.tokens)+1)
+	for end := range parser.matches {
+		parser.matches[end] = make(map[@_Prefix][]*@_Match)
+	}
+}
+
+func (parser *@_Parser) at(end int) map[@_Prefix][]*@_Match {
+	return parser.matches[end]
+}
+`)
+}
+
+// Append the interface through which the parser stores its chart, and the
+// default implementation, if requested
+func (g *Grammar) addChart() {
+	if !g.Options.ChartStore {
+		return
+	}
+
+	g.addText(`
+// @Chart stores the matches found by a parse, by the position at which they
+// end. Before each parse, the parser calls Reset with the length of the
+// input; it then calls At for positions from 0 to that length, and adds
+// matches to the maps returned. At must return the same map for a
+// position throughout a parse, and the map must be empty at first. A
+// Chart is used by one parse at a time.
+type @Chart interface {
+	Reset(length int)
+	At(end int) map[@_Prefix][]*@_Match
+}
+
+// @MemoryChart is the Chart used when none is given, making new maps for
+// each parse, as the parser does without a Chart.
+type @MemoryChart struct {
+	positions []map[@_Prefix][]*@_Match
+}
+
+func (c *@MemoryChart) Reset(length int) {
+	c.positions = make([]map[@_Prefix][]*@_Match, length+1)
+	for end := range c.positions {
+		c.positions[end] = make(map[@_Prefix][]*@_Match)
+	}
+}
+
+func (c *@MemoryChart) At(end int) map[@_Prefix][]*@_Match {
+	return c.positions[end]
+}
+`)
+}
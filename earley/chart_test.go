@@ -0,0 +1,80 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test keeping the chart in a store given by the caller
+func TestChartStore(t *testing.T) {
+	var options earley.Options
+	options.ChartStore = true
+	parse, e := gleantest.Build(t, chartMainText, "Sum", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `6 <nil>
+6 <nil> 6 positions
+10 <nil> 8 positions
+0 unexpected token: main.Plus
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+	g.Options = earley.Options{ChartStore: true, Recover: true}
+	if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+		t.Error("no error for options ChartStore and Recover")
+	}
+}
+
+var chartMainText = `
+package main
+
+import "fmt"
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+// A chart reusing its maps from one parse to the next
+type reusingChart struct {
+	positions []map[_glean__Prefix][]*_glean__Match
+}
+
+func (c *reusingChart) Reset(length int) {
+	for len(c.positions) <= length {
+		c.positions = append(c.positions, make(map[_glean__Prefix][]*_glean__Match))
+	}
+	for _, m := range c.positions[:length+1] {
+		for p := range m {
+			delete(m, p)
+		}
+	}
+}
+
+func (c *reusingChart) At(end int) map[_glean__Prefix][]*_glean__Match {
+	return c.positions[end]
+}
+
+func main() {
+	fmt.Println(_glean_Parse([]interface{}{1, Plus{}, 2, Plus{}, 3}, nil))
+
+	var c reusingChart
+	sum, e := _glean_Parse([]interface{}{1, Plus{}, 2, Plus{}, 3}, &c)
+	fmt.Println(sum, e, len(c.positions), "positions")
+	sum, e = _glean_Parse([]interface{}{1, Plus{}, 2, Plus{}, 3, Plus{}, 4}, &c)
+	fmt.Println(sum, e, len(c.positions), "positions")
+	fmt.Println(_glean_Parse([]interface{}{1, Plus{}, Plus{}}, &c))
+}
+`
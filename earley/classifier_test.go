@@ -0,0 +1,103 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test a parser given a function to classify its tokens
+func TestClassifier(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(classifierMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.Classifier = true
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleCommand", "Command", "Verb", glean.ListOf("Name"))
+	parserText, e := g.WriteParser("Command", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	for _, test := range []struct{ input, output string }{
+		{"copy a b", "copy: [a b]"},
+		{"copy copy", "copy: [copy]"},
+		{"copy", "unexpected end of input"},
+		{"copy a ! b", "unexpected token: \"!\""},
+	} {
+		args := append([]string{"run", mainGo, parserGo}, strings.Fields(test.input)...)
+		out, e := exec.Command("go", args...).CombinedOutput()
+		if e != nil {
+			t.Fatal(e, string(out))
+		}
+		if string(out) != test.output+"\n" {
+			t.Errorf("wrong output for %q:\nexpected: %s\ngot: %s", test.input, test.output, out)
+		}
+	}
+}
+
+var classifierMainText = `
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type Verb = string
+type Name = string
+type Command string
+
+func RuleCommand(v Verb, names []Name) Command {
+	return Command(fmt.Sprint(v, ": ", names))
+}
+
+func main() {
+	var tokens []interface{}
+	for _, a := range os.Args[1:] {
+		tokens = append(tokens, a)
+	}
+
+	// The first word is the verb; the rest are names.
+	first := true
+	classify := func(t interface{}) int {
+		if t.(string) == "!" {
+			return -1
+		}
+		if first {
+			first = false
+			return _TerminalVerb
+		}
+		return _TerminalName
+	}
+
+	c, e := _Parse(tokens, classify)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(c)
+}
+`
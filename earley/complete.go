@@ -0,0 +1,167 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the function suggesting completions of the input, if requested
+func (g *Grammar) addComplete() {
+	if !g.Options.Complete {
+		return
+	}
+
+	g.addText("\nfunc @Complete(")
+	g.addInputParams(false)
+	g.addText(", max int) [][]string {\n")
+	g.addParserInit(false)
+	if g.Options.EndSymbol != "" {
+		g.addText("\tparser.tokens = parser.tokens[:len(parser.tokens)-1]\n")
+	}
+	g.addText(`	parser.prepare()
+	if parser.findMatches() != nil {
+		return nil
+	}
+	n := len(parser.tokens)
+
+	// toGoal[s][x] is the shortest sequence of terminals which, following
+	// a match of symbol x starting at position s, completes the goal.
+	toGoal := make([]map[@_Symbol][]@_Symbol, n+1)
+	for s := range toGoal {
+		toGoal[s] = make(map[@_Symbol][]@_Symbol)
+		if s == 0 {
+			toGoal[0][`)
+	g.addf("%d", g.goal.id)
+	g.addText(`] = []@_Symbol{}
+		}
+		for changed := true; changed; {
+			changed = false
+			for x, exts := range @_extendedBy {
+				for _, e := range exts {
+					rest := @_completion[e.to]
+					if rest == nil {
+						continue
+					}
+					for _, m := range parser.at(s)[e.from] {
+						after, have := toGoal[m.start][@_prefixTarget[e.to]]
+						if !have {
+							continue
+						}
+						if old, have := toGoal[s][@_Symbol(x)]; !have || len(rest)+len(after) < len(old) {
+							toGoal[s][@_Symbol(x)] = append(rest[:len(rest):len(rest)], after...)
+							changed = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var found [][]@_Symbol
+	seen := make(map[string]bool)
+	for p, rest := range @_completion {
+		if rest == nil {
+			continue
+		}
+		for _, m := range parser.at(n)[@_Prefix(p)] {
+			after, have := toGoal[m.start][@_prefixTarget[p]]
+			if !have {
+				continue
+			}
+			seq := append(rest[:len(rest):len(rest)], after...)
+			key := fmt.Sprint(seq)
+			if !seen[key] {
+				seen[key] = true
+				found = append(found, seq)
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return len(found[i]) < len(found[j])
+	})
+	if len(found) > max {
+		found = found[:max]
+	}
+
+	suggestions := make([][]string, len(found))
+	for i, seq := range found {
+		suggestions[i] = make([]string, len(seq))
+		for j, t := range seq {
+			suggestions[i][j] = @_symbolNames[t]
+		}
+	}
+	return suggestions
+}
+`)
+}
+
+// Add the tables used to suggest completions, if requested; ParseAll also
+// uses the targets of the prefixes
+func (g *Grammar) addCompletionTables() {
+	if g.Options.Complete || g.Options.ParseAll {
+		g.addText("\nvar @_prefixTarget = []@_Symbol{\n")
+		for _, p := range g.prefixes {
+			g.addf("\t%d,\n", p.target.id)
+		}
+		g.addString("}\n")
+	}
+	if !g.Options.Complete {
+		return
+	}
+
+	yields := g.shortestYields()
+	g.addText(`
+// The shortest sequence of terminals completing each prefix, or nil if none
+var @_completion = [][]@_Symbol{
+`)
+	for _, p := range g.prefixes {
+		var best []int
+		for _, r := range p.rules {
+			seq := joinYields(yields, r.items[p.length:])
+			if seq != nil && (best == nil || len(seq) < len(best)) {
+				best = seq
+			}
+		}
+		if best == nil {
+			g.addString("\tnil,\n")
+		} else {
+			g.addString("\t")
+			g.addSlice(best)
+			g.addString(",\n")
+		}
+	}
+	g.addString("}\n")
+}
+
+// Find the shortest sequence of terminals derived from each symbol, indexed
+// by symbol id. The sequence is nil if the symbol derives no sequence.
+func (g *Grammar) shortestYields() [][]int {
+	yields := make([][]int, len(g.symbols))
+	for _, t := range g.terminals {
+		yields[t.id] = []int{t.id}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, s := range g.nonterminals {
+			for _, r := range s.rules {
+				seq := joinYields(yields, r.items)
+				if seq != nil && (yields[s.id] == nil || len(seq) < len(yields[s.id])) {
+					yields[s.id] = seq
+					changed = true
+				}
+			}
+		}
+	}
+	return yields
+}
+
+// Join the shortest sequences of terminals derived from some items,
+// returning nil if any item derives no sequence.
+func joinYields(yields [][]int, items []*symbol) []int {
+	seq := []int{}
+	for _, item := range items {
+		if yields[item.id] == nil {
+			return nil
+		}
+		seq = append(seq, yields[item.id]...)
+	}
+	return seq
+}
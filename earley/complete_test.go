@@ -0,0 +1,78 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test the suggestions for completing an input
+func TestComplete(t *testing.T) {
+	var options earley.Options
+	options.Complete = true
+	parse, e := gleantest.Build(t, completeMainText, "Stmt", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	out, e := parse()
+	if e != nil {
+		t.Fatal(e, out)
+	}
+	expect := `[[Name Assign int Semi]]
+[[Assign int Semi]]
+[[Close Semi] [Plus int Close Semi]]
+[[Close Semi]]
+[[int Semi]]
+[[]]
+[]
+`
+	if out != expect {
+		t.Errorf("wrong output:\nexpected:\n%s\ngot:\n%s", expect, out)
+	}
+
+	options.Scannerless = true
+	if _, e := gleantest.Build(t, completeMainText, "Stmt", options); e == nil {
+		t.Error("no error for Complete with Scannerless")
+	}
+}
+
+var completeMainText = `
+package main
+
+import "fmt"
+
+type Stmt struct{}
+type Expr struct{}
+type Name struct{}
+type Assign struct{}
+type Semi struct{}
+type Plus struct{}
+type Open struct{}
+type Close struct{}
+
+func RuleAssign(Name, Assign, Expr, Semi) Stmt { return Stmt{} }
+func RuleInt(int) Expr                         { return Expr{} }
+func RuleAdd(Expr, Plus, int) Expr             { return Expr{} }
+func RuleParens(Open, Expr, Close) Expr        { return Expr{} }
+
+func main() {
+	for _, c := range []struct {
+		tokens []interface{}
+		max    int
+	}{
+		{nil, 5},
+		{[]interface{}{Name{}}, 5},
+		{[]interface{}{Name{}, Assign{}, Open{}, 1}, 5},
+		{[]interface{}{Name{}, Assign{}, Open{}, 1}, 1},
+		{[]interface{}{Name{}, Assign{}, Open{}, 1, Close{}, Plus{}}, 5},
+		{[]interface{}{Name{}, Assign{}, 1, Semi{}}, 5},
+		{[]interface{}{Semi{}}, 5},
+	} {
+		fmt.Println(_glean_Complete(c.tokens, c.max))
+	}
+}
+`
@@ -0,0 +1,200 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Append the parse function applying the rules concurrently, if requested.
+//
+// The trace, run backwards, applies the rules in postorder: each rule
+// takes the values of its items from the tops of the stacks, where the
+// rules and tokens just before it left them. applyConcurrent runs the
+// trace backwards in the same way, but on a single stack of tasks, so
+// that each rule's task learns which tasks give the values of its items.
+// A task is then ready once all of those are done; the tasks with only
+// tokens as items are ready at once, and the worker finishing the last
+// item of a task goes on to apply the task itself.
+func (g *Grammar) addParseConcurrent() {
+	if !g.Options.Concurrent {
+		return
+	}
+
+	g.addText(`
+// @ParseConcurrent parses the input as @Parse does, but applies the rules
+// on up to workers goroutines at once, or runtime.GOMAXPROCS(0) if workers
+// is less than 1. The rule functions must be safe to call concurrently.
+func @ParseConcurrent(`)
+	g.addInputParams(true)
+	g.addText(", workers int")
+	g.addResults("#G", "error")
+	g.addParserInit(true)
+	g.addCatch("catch", 1)
+	g.addText("\tvar zero #G\n")
+	g.addReducerCheck("zero")
+	g.addText(`	if e := parser.match(); e != nil {
+		return zero, e
+	}
+	return parser.applyConcurrent(workers), nil
+}
+
+// A rule to be applied by applyConcurrent
+type @_Task struct {
+	prefix  @_Prefix
+	args    []interface{} // the values of the items, as they become known
+	value   interface{}
+	parent  *@_Task
+	index   int   // the item of parent matched by this task
+	pending int32 // the items whose tasks are not yet done
+}
+
+func (parser *@_Parser) applyConcurrent(workers int) #G {
+	parser.tokensUsed = 0
+`)
+	g.addPoolResets()
+	g.addText(`
+	type entry struct {
+		task  *@_Task
+		token interface{}
+	}
+	var stack []entry
+	var ready []*@_Task
+	for n := len(parser.steps) - 1; n >= 0; n-- {
+		step := parser.steps[n]
+		if step < 0 {
+			token := parser.tokens[parser.tokensUsed]
+			parser.tokensUsed++
+`)
+	for _, t := range g.terminals {
+		if g.interned(t) {
+			g.addf("\t\t\tif step == %d {\n", -1-t.id)
+			g.addf("\t\t\t\tt := token.(%s)\n", t.name)
+			g.addf("\t\t\t\tif u, have := parser.%s[t]; have {\n", t.poolName())
+			g.addString("\t\t\t\t\tt = u\n\t\t\t\t} else {\n")
+			g.addf("\t\t\t\t\tparser.%s[t] = t\n\t\t\t\t}\n", t.poolName())
+			g.addString("\t\t\t\ttoken = t\n\t\t\t}\n")
+		}
+	}
+	g.addText(`			stack = append(stack, entry{token: token})
+			continue
+		}
+
+		p := @_Prefix(step)
+		items := stack[len(stack)-@_prefixLength[p]:]
+		stack = stack[:len(stack)-len(items)]
+		task := &@_Task{prefix: p, args: make([]interface{}, len(items))}
+		for i, item := range items {
+			if item.task == nil {
+				task.args[i] = item.token
+			} else {
+				item.task.parent = task
+				item.task.index = i
+				task.pending++
+			}
+		}
+		if task.pending == 0 {
+			ready = append(ready, task)
+		}
+		stack = append(stack, entry{task: task})
+	}
+	root := stack[0].task
+
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	queue := make(chan *@_Task, len(ready))
+	for _, task := range ready {
+		queue <- task
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	var failed sync.Once
+	var failure interface{}
+	panicked := false
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					failed.Do(func() {
+						failure, panicked = r, true
+					})
+				}
+			}()
+			for task := range queue {
+				for task != nil {
+					task.value = @_reduceConcurrent[task.prefix](parser, task.args)
+					task.args = nil
+					next := task.parent
+					if next != nil {
+						next.args[task.index] = task.value
+						if atomic.AddInt32(&next.pending, -1) != 0 {
+							next = nil
+						}
+					}
+					task = next
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if panicked {
+		panic(failure)
+	}
+	result, _ := root.value.(#G)
+	return result
+}
+`)
+}
+
+// Append the functions applying the rules for applyConcurrent, if wanted
+func (g *Grammar) addConcurrentReducers() {
+	if !g.Options.Concurrent {
+		return
+	}
+
+	g.addText("\nvar @_reduceConcurrent = []func(*@_Parser, []interface{}) interface{}{\n")
+	for _, p := range g.prefixes {
+		r := p.completedRule()
+		if r == nil {
+			g.addString("\tnil,\n")
+			continue
+		}
+		g.addText("\tfunc(parser *@_Parser, x []interface{}) interface{} {\n")
+		// The comma-ok assertions let a nil value pass as the zero value of its type.
+		args := make([]string, len(r.items))
+		for n := range r.items {
+			args[n] = fmt.Sprintf("x%d", n)
+		}
+		if !g.Options.Registry || r.target.optional || r.target.element != nil {
+			for n, item := range r.items {
+				g.addf("\t\t%s, _ := x[%d].(%s)\n", args[n], n, g.valueType(item))
+			}
+		}
+		switch {
+		case r.target.optionalList() && len(r.items) == 0:
+			g.addf("\t\treturn new(%s)\n", g.valueType(r.target.element))
+		case r.target.optional && len(r.items) == 0:
+			g.addf("\t\treturn (%s)(nil)\n", g.valueType(r.target))
+		case r.target.optional:
+			g.addf("\t\treturn &%s\n", args[0])
+		case r.target.element != nil && len(r.items) == 1:
+			g.addf("\t\treturn %s{%s}\n", g.valueType(r.target), args[0])
+		case r.target.element != nil:
+			g.addf("\t\treturn append(%s, %s)\n", args[0], args[1])
+		case g.Options.Registry:
+			g.addf("\t\treturn parser.reducers[%d](x)\n", r.id)
+		case g.Options.Interface:
+			g.addf("\t\treturn parser.reducer.%s(%s)\n", r.methodName(), strings.Join(args, ", "))
+		default:
+			g.addf("\t\treturn %s(%s)\n", r.reducer, strings.Join(args, ", "))
+		}
+		g.addString("\t},\n")
+	}
+	g.addString("}\n")
+}
@@ -0,0 +1,107 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test that parses may run concurrently, under the race detector
+func TestConcurrentParses(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(concurrentMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.Tree = true
+	g.Options.Recover = true
+	g.Options.ValidPrefix = true
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleInt", "Sum", "int")
+	addrule("RuleAdd", "Sum", "Sum", "Plus", "int")
+	parserText, e := g.WriteParser("Sum", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	out, e := exec.Command("go", "run", "-race", mainGo, parserGo).CombinedOutput()
+	if e != nil {
+		t.Fatal(e, string(out))
+	}
+	if string(out) != "ok\n" {
+		t.Errorf("wrong output:\n%s", out)
+	}
+}
+
+var concurrentMainText = `
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	var wg sync.WaitGroup
+	failures := make(chan string, 20*20*4)
+	for k := 1; k <= 20; k++ {
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			tokens := []interface{}{1}
+			for n := 2; n <= k; n++ {
+				tokens = append(tokens, Plus{}, n)
+			}
+			for rep := 0; rep < 20; rep++ {
+				if s, e := _Parse(tokens); e != nil || s != Sum(k*(k+1)/2) {
+					failures <- fmt.Sprint("Parse", k, s, e)
+				}
+				if tree, e := _ParseTree(tokens); e != nil || tree.End != len(tokens) {
+					failures <- fmt.Sprint("ParseTree", k, e)
+				}
+				if n := _ValidPrefix(append(tokens, 0)); n != len(tokens) {
+					failures <- fmt.Sprint("ValidPrefix", k, n)
+				}
+				if s, errs := _ParseRecover(append(tokens, Plus{}, Plus{}, 0), 0); len(errs) != 1 || s != Sum(k*(k+1)/2) {
+					failures <- fmt.Sprint("ParseRecover", k, s, errs)
+				}
+			}
+		}(k)
+	}
+	wg.Wait()
+	close(failures)
+	ok := true
+	for f := range failures {
+		fmt.Println(f)
+		ok = false
+	}
+	if ok {
+		fmt.Println("ok")
+	}
+}
+`
@@ -0,0 +1,90 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test the hook observing the growth of the chart
+func TestChartHook(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(chartHookMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.Debug = true
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleInt", "Sum", "int")
+	addrule("RuleAdd", "Sum", "Sum", "Plus", "Sum")
+	addrule("RuleFirst", "Top", "Sum")
+	parserText, e := g.WriteParser("Top", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	out, e := exec.Command("go", "run", "-race", mainGo, parserGo).CombinedOutput()
+	if e != nil {
+		t.Fatal(e, string(out))
+	}
+	if expect := "0 2\n1 3\n2 2\n3 5\n4 3\n5 7\n[6 4]\n"; string(out) != expect {
+		t.Errorf("wrong output:\nexpected:\n%s\ngot:\n%s", expect, out)
+	}
+}
+
+var chartHookMainText = `
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Sum int
+type Top int
+type Plus struct{}
+
+func RuleInt(i int) Sum { return Sum(i) }
+func RuleAdd(x Sum, _ Plus, y Sum) Sum { return x + y }
+func RuleFirst(s Sum) Top { return Top(s) }
+
+func main() {
+	tokens := []interface{}{1, Plus{}, 2, Plus{}, 3}
+	_Parse(tokens)
+	_ParseHook(tokens, func(position, matchCount int) {
+		fmt.Println(position, matchCount)
+	})
+
+	// Each parse calls only its own hook.
+	var wg sync.WaitGroup
+	var positions [2]int
+	for n, tokens := range [][]interface{}{tokens, {1, Plus{}, 2}} {
+		wg.Add(1)
+		go func(n int, tokens []interface{}) {
+			defer wg.Done()
+			_ParseHook(tokens, func(int, int) { positions[n]++ })
+		}(n, tokens)
+	}
+	wg.Wait()
+	fmt.Println(positions)
+}
+`
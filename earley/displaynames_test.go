@@ -0,0 +1,83 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test the display names of terminal symbols in errors
+func TestDisplayNames(t *testing.T) {
+	var options earley.Options
+	options.Recover = true
+	options.DisplayNames = map[glean.Symbol]string{"Open": "'('", "Close": "')'"}
+	parse, e := gleantest.Build(t, displayMainText, "Group", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	for _, c := range []struct {
+		input, expect string
+	}{
+		{"( 1 )", "1 <nil>\n[]\n"},
+		{"( ( 1 )", "0 unexpected token: '('\n[unexpected token: '(']\n"},
+		{"( 1 2 )", "0 unexpected token: 2\n[unexpected token: 2]\n"},
+		{"( 1 ) )", "0 unexpected token: ')'\n[unexpected token: ')']\n"},
+	} {
+		if out, e := parse(strings.Split(c.input, " ")...); e != nil {
+			t.Error(e, out)
+		} else if out != c.expect {
+			t.Errorf("input %q: expected %q, got %q", c.input, c.expect, out)
+		}
+	}
+
+	var g earley.Grammar
+	g.Options.DisplayNames = map[glean.Symbol]string{"Group": "group"}
+	g.AddRule("RuleGroup", "Group", []glean.Symbol{"Open", "int", "Close"})
+	if _, e := g.WriteParser("Group", "main", "_"); e == nil {
+		t.Error("no error for the display name of a nonterminal symbol")
+	}
+}
+
+var displayMainText = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type Group int
+type Open struct{}
+type Close struct{}
+
+func RuleGroup(_ Open, i int, _ Close) Group { return Group(i) }
+
+func main() {
+	var tokens []interface{}
+	for _, a := range os.Args[1:] {
+		switch a {
+		case "(":
+			tokens = append(tokens, Open{})
+		case ")":
+			tokens = append(tokens, Close{})
+		default:
+			i, e := strconv.Atoi(a)
+			if e != nil {
+				panic(e)
+			}
+			tokens = append(tokens, i)
+		}
+	}
+	fmt.Println(_glean_Parse(tokens))
+	_, errs := _glean_ParseRecover(tokens, 0)
+	fmt.Println(errs)
+}
+`
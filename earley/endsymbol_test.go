@@ -0,0 +1,111 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test a parser with an explicit end of input symbol
+func TestEndSymbol(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(endSymbolMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.EndSymbol = "EOF"
+	g.Options.ValidPrefix = true
+	g.Options.Recover = true
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleProgram", "Program", "Sum", "EOF")
+	addrule("RuleEmpty", "Program", "EOF")
+	addrule("RuleInt", "Sum", "int")
+	addrule("RuleAdd", "Sum", "Sum", "Plus", "int")
+	parserText, e := g.WriteParser("Program", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	for _, test := range []struct{ input, output string }{
+		{"1 + 2", "3 <nil> 4 [] 3"},
+		{"", "-1 <nil> 1 [] -1"},
+		{"1 +", "0 unexpected token: main.EOF 2 [unexpected token: main.EOF] 0"},
+		{"1 + + 2", "0 unexpected token: main.Plus 2 [unexpected token: main.Plus] 3"},
+		{"1 + + 2 +", "0 unexpected token: main.Plus 2 [unexpected token: main.Plus unexpected token: main.EOF] 0"},
+	} {
+		args := append([]string{"run", mainGo, parserGo}, strings.Fields(test.input)...)
+		out, e := exec.Command("go", args...).CombinedOutput()
+		if e != nil {
+			t.Fatal(e, string(out))
+		}
+		if string(out) != test.output+"\n" {
+			t.Errorf("wrong output for %q:\nexpected: %s\ngot: %s", test.input, test.output, out)
+		}
+	}
+
+	for _, end := range []glean.Symbol{"Sum", "Semicolon"} {
+		g.Options.EndSymbol = end
+		if _, e := g.WriteParser("Program", "main", "_"); e == nil {
+			t.Error("no error for end symbol", end)
+		}
+	}
+}
+
+var endSymbolMainText = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type Program int
+type Sum int
+type Plus struct{}
+type EOF struct{}
+
+func RuleProgram(s Sum, _ EOF) Program { return Program(s) }
+func RuleEmpty(_ EOF) Program { return -1 }
+func RuleInt(i int) Sum { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	var tokens []interface{}
+	for _, a := range os.Args[1:] {
+		if a == "+" {
+			tokens = append(tokens, Plus{})
+		} else {
+			i, e := strconv.Atoi(a)
+			if e != nil {
+				panic(e)
+			}
+			tokens = append(tokens, i)
+		}
+	}
+
+	program, e := _Parse(tokens)
+	recovered, errors := _ParseRecover(tokens, 0)
+	fmt.Println(program, e, _ValidPrefix(tokens), errors, recovered)
+}
+`
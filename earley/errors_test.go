@@ -107,6 +107,17 @@ func TestWriteParserErrors(t *testing.T) {
 	text, e = g.WriteParser("Goal", "main", "[:]")
 	WPMustError(t, "prefix '[:]' is not a valid Go identifier", text, e)
 
+	g.Options.ErrorsImport = "example.com/glean errors"
+	text, e = g.WriteParser("Goal", "main", "_")
+	WPMustError(t, "errors import path 'example.com/glean errors' is not valid", text, e)
+	g.Options.ErrorsImport = ""
+
+	g.Options.Recover = true
+	g.Options.Scannerless = true
+	text, e = g.WriteParser("Goal", "main", "_")
+	WPMustError(t, "options Recover and Scannerless cannot be combined", text, e)
+	g.Options = Options{}
+
 	text, e = g.WriteParser("Goal", "main", "_")
 	if e != nil {
 		t.Fatal("WriteParser failed:", e)
@@ -0,0 +1,89 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test a recovering parser that streams its errors to a sink
+func TestErrorSink(t *testing.T) {
+	var options earley.Options
+	options.Recover = true
+	options.ErrorSink = true
+	options.CatchPanics = true
+	parse, e := gleantest.Build(t, errorSinkMainText, "Sum", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `sink: unexpected token: main.Plus
+sink: unexpected token: 7
+6 2 true
+sink: unexpected token: main.Plus
+sink: too many errors
+3 2 true
+sink: unexpected token: 2
+sink: unexpected end of input
+1 2 true
+0 [unexpected token: 2 internal error in parser: sink panicked]
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+	g.Options = earley.Options{ErrorSink: true}
+	if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+		t.Errorf("no error for options %v", g.Options)
+	}
+}
+
+var errorSinkMainText = `
+package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	var seen []error
+	panicky := false
+	sink := func(e error) {
+		if panicky {
+			panic("sink panicked")
+		}
+		fmt.Println("sink:", e)
+		seen = append(seen, e)
+	}
+	for _, test := range []struct {
+		tokens    []interface{}
+		maxErrors int
+	}{
+		{[]interface{}{1, Plus{}, Plus{}, 2, 7, 8, Plus{}, 3}, 0},
+		{[]interface{}{Plus{}, 1, Plus{}, 2, 3, Plus{}, Plus{}}, 1},
+		{[]interface{}{1, 2, Plus{}}, 0},
+	} {
+		seen = nil
+		sum, errors := _glean_ParseRecoverSink(test.tokens, test.maxErrors, sink)
+		fmt.Println(sum, len(errors), reflect.DeepEqual(errors, seen))
+	}
+
+	// A panic in the sink ends the parse, and is not passed to the sink.
+	panicky = true
+	sum, errors := _glean_ParseRecoverSink([]interface{}{1, 2}, 0, sink)
+	fmt.Println(sum, errors)
+}
+`
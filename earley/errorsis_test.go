@@ -0,0 +1,61 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test matching parse errors with errors.Is and errors.As
+func TestErrorsIs(t *testing.T) {
+	parse, e := gleantest.Build(t, errorsIsMainText, "Sum", earley.Options{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `true false false false
+false true false true
+false true false true
+false false true false
+false false true false
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+}
+
+var errorsIsMainText = `
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Plus struct{}
+type Sum int
+
+func RuleInt(x int) Sum                { return Sum(x) }
+func RuleAdd(s Sum, _ Plus, y Sum) Sum { return s + y }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{},
+		{1, 2},
+		{1, Plus{}},
+		{1, Plus{}, 2, Plus{}, 3},
+		{1, Plus{}, 2, Plus{}, 3, Plus{}, 4, Plus{}, 5, Plus{}, 6},
+	} {
+		_, e := _glean_Parse(tokens)
+		e = fmt.Errorf("wrapped: %w", e)
+		var u gleanerrors.Unexpected
+		fmt.Println(errors.Is(e, gleanerrors.ErrNoInput), errors.Is(e, gleanerrors.ErrUnexpected),
+			errors.Is(e, gleanerrors.ErrAmbiguous), errors.As(e, &u))
+	}
+}
+`
@@ -0,0 +1,71 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the function returning the reductions of the parse as events,
+// if requested
+func (g *Grammar) addParseEvents() {
+	if !g.Options.Events {
+		return
+	}
+
+	g.addText(`
+// @Event reports that a rule was applied to reduce the input from Start
+// up to, but not including, End.
+type @Event struct {
+	// The id of the rule applied.
+	Rule int
+
+	Start, End int
+}
+
+// @ParseEvents parses its input, and returns a channel on which the rules
+// applied are sent as events, in the order in which @Parse would apply
+// them. The input is wholly parsed before the first event is sent, so an
+// error in it is returned at once, with no channel. The events are sent
+// by another goroutine, which waits for each to be received, and closes
+// the channel after the last. Once ctx is done, it sends at most the one
+// event it was waiting to send, and closes the channel early.
+func @ParseEvents(ctx context.Context, `)
+	g.addInputParams(false)
+	g.addResults("<-chan @Event", "error")
+	g.addParserInit(false)
+	g.addCatch("catch", 1)
+	g.addText(`	if e := parser.match(); e != nil {
+		return nil, e
+	}
+	events := make(chan @Event)
+	go func() {
+		defer close(events)
+		parser.sendEvents(ctx, events, parser.goalmatch)
+	}()
+	return events, nil
+}
+
+// Send the events of the rules applied within a complete match, and then
+// of its own rule; return false if ctx is done first
+func (parser *@_Parser) sendEvents(ctx context.Context, events chan<- @Event, m *@_Match) bool {
+	var children []*@_Match
+	for p := m; p.shorter != nil; p = p.shorter {
+		if p.last != nil {
+			children = append(children, p.last)
+		}
+	}
+	for n := len(children) - 1; n >= 0; n-- {
+		if !parser.sendEvents(ctx, events, children[n]) {
+			return false
+		}
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case events <- @Event{int(@_prefix2rule[m.prefix]), m.start, m.end}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+`)
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test the stream of rules applied by a parse
+func TestEvents(t *testing.T) {
+	var options earley.Options
+	options.Events = true
+	parse, e := gleantest.Build(t, eventsMainText, "Sum", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `RuleInt 0 1
+RuleSum 0 1
+RuleInt 2 3
+RuleProduct 2 5
+RuleAdd 0 5
+stopped early: true
+<nil> unexpected token: main.Plus
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+}
+
+var eventsMainText = `
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+type Sum int
+type Product int
+type Plus struct{}
+type Times struct{}
+
+func RuleInt(i int) Product                         { return Product(i) }
+func RuleSum(p Product) Sum                         { return Sum(p) }
+func RuleAdd(s Sum, _ Plus, p Product) Sum          { return s + Sum(p) }
+func RuleProduct(p Product, _ Times, i int) Product { return p * Product(i) }
+
+var names = []string{"RuleInt", "RuleSum", "RuleAdd", "RuleProduct"}
+
+func main() {
+	tokens := []interface{}{1, Plus{}, 2, Times{}, 3}
+	events, e := _glean_ParseEvents(context.Background(), tokens)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	for ev := range events {
+		fmt.Println(names[ev.Rule], ev.Start, ev.End)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, _ = _glean_ParseEvents(ctx, tokens)
+	<-events
+	cancel()
+	count := 1
+	for range events {
+		count++
+	}
+	fmt.Println("stopped early:", count < 5)
+
+	events, e = _glean_ParseEvents(context.Background(), []interface{}{1, Plus{}, Plus{}})
+	fmt.Println(events, e)
+}
+`
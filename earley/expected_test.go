@@ -0,0 +1,107 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test the expected symbols reported with unexpected tokens
+func TestExpected(t *testing.T) {
+	for _, options := range []earley.Options{
+		{},
+		{DisplayNames: map[glean.Symbol]string{"Plus": "+"}},
+		{Ambiguity: earley.AmbiguityLeftmost},
+		{Unchecked: true, GenericStacks: true},
+	} {
+		parse, e := gleantest.Build(t, expectedMainText, "Sum", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		expect := `1 [Minus Plus]
+2 [int]
+2 [Close Minus Plus]
+0 [Open int]
+`
+		if out, e := parse(); e != nil || out != expect {
+			t.Errorf("options %+v:\nexpected:\n%s\ngot:\n%s %v", options, expect, out, e)
+		}
+	}
+
+	parse, e := gleantest.Build(t, expectedRecoverMainText, "Sum", earley.Options{Recover: true})
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := "2 [Close Minus Plus]\n4 [int]\n"
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("recovering:\nexpected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+}
+
+var expectedMainText = `
+package main
+
+import (
+	"fmt"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Plus struct{}
+type Minus struct{}
+type Open struct{}
+type Close struct{}
+type Sum int
+
+func RuleInt(x int) Sum                     { return Sum(x) }
+func RuleAdd(s Sum, _ Plus, x int) Sum      { return s + Sum(x) }
+func RuleSub(s Sum, _ Minus, x int) Sum     { return s - Sum(x) }
+func RuleParens(_ Open, s Sum, _ Close) Sum { return s }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{1, 2},
+		{1, Plus{}},
+		{Open{}, 1, Open{}},
+		{Close{}},
+	} {
+		_, e := _glean_Parse(tokens)
+		u := e.(gleanerrors.Unexpected)
+		fmt.Println(u.Index, u.Expected)
+	}
+}
+`
+
+var expectedRecoverMainText = `
+package main
+
+import (
+	"fmt"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Plus struct{}
+type Minus struct{}
+type Open struct{}
+type Close struct{}
+type Sum int
+
+func RuleInt(x int) Sum                     { return Sum(x) }
+func RuleAdd(s Sum, _ Plus, x int) Sum      { return s + Sum(x) }
+func RuleSub(s Sum, _ Minus, x int) Sum     { return s - Sum(x) }
+func RuleParens(_ Open, s Sum, _ Close) Sum { return s }
+
+func main() {
+	_, errors := _glean_ParseRecover([]interface{}{Open{}, 1, 2, Plus{}}, 0)
+	for _, e := range errors {
+		u := e.(gleanerrors.Unexpected)
+		fmt.Println(u.Index, u.Expected)
+	}
+}
+`
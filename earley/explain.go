@@ -0,0 +1,104 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the function explaining why input is rejected, if requested
+func (g *Grammar) addExplain() {
+	if !g.Options.Explain {
+		return
+	}
+
+	g.addText(`
+// @Explain parses its input without applying any rules, and if it is
+// rejected, returns a report of why; if the input is accepted, the report
+// is empty. See Explain in the glean earley.Options for the form of the
+// report.
+func @Explain(`)
+	g.addInputParams(false)
+	g.addText(") string {\n")
+	g.addParserInit(false)
+	g.addText(`	e := parser.match()
+	if e == nil {
+		return ""
+	}
+	u, ok := e.(gleanerrors.Unexpected)
+	if !ok {
+		return "rejected: " + e.Error() + "\n"
+	}
+	return parser.explain(u)
+}
+
+func (parser *@_Parser) explain(u gleanerrors.Unexpected) string {
+	var report strings.Builder
+	n := u.Index
+	fmt.Fprintf(&report, "rejected at token %d of %d: %s\n", n, len(parser.tokens), u.Error())
+
+	report.WriteString("consumed:")
+	for _, t := range parser.tokens[:n] {
+		report.WriteString(" " + @_symbolNames[#T(t)])
+	}
+	report.WriteString("\n")
+
+	expected := u.Expected
+	for _, p := range @_goalPrefixes {
+		for _, m := range parser.at(n)[p] {
+			if m.start == 0 {
+				expected = append(expected, "end of input")
+				break
+			}
+		}
+	}
+	report.WriteString("expected:")
+	for _, name := range expected {
+		report.WriteString(" " + name)
+	}
+	report.WriteString("\n")
+
+	report.WriteString("partial rules:\n")
+	for p, rules := range @_partialRules {
+		starts := make(map[int]bool)
+		for _, m := range parser.at(n)[@_Prefix(p)] {
+			if starts[m.start] {
+				continue
+			}
+			starts[m.start] = true
+			for _, r := range rules {
+				desc := @_ruledesc[r]
+				length := @_prefixLength[p]
+				fmt.Fprintf(&report, "\t%s from %d: %s = %s . %s\n", desc.Name, m.start, desc.Target,
+					strings.Join(desc.Items[:length], " "), strings.Join(desc.Items[length:], " "))
+			}
+		}
+	}
+	return report.String()
+}
+`)
+}
+
+// Add the tables used to explain rejected input, if requested
+func (g *Grammar) addExplainTables() {
+	if !g.Options.Explain {
+		return
+	}
+
+	g.addText(`
+// The rules partly matched by each prefix, which has matched some of their
+// items but not all
+var @_partialRules = [][]@_Rule{
+`)
+	for _, p := range g.prefixes {
+		var rules []int
+		if p.length > 0 {
+			for _, r := range p.rules {
+				if len(r.items) > p.length {
+					rules = append(rules, r.id)
+				}
+			}
+		}
+		g.addString("\t")
+		g.addSlice(rules)
+		g.addString(",\n")
+	}
+	g.addString("}\n")
+}
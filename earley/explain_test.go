@@ -0,0 +1,82 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test explaining why input is rejected
+func TestExplain(t *testing.T) {
+	var options earley.Options
+	options.Explain = true
+	parse, e := gleantest.Build(t, explainMainText, "Sum", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `accepted
+rejected at token 2 of 3: unexpected token: main.Plus
+consumed: int Plus
+expected: int
+partial rules:
+	RuleAdd from 0: Sum = Sum Plus . int
+---
+rejected at token 2 of 2: unexpected end of input
+consumed: int Plus
+expected: int
+partial rules:
+	RuleAdd from 0: Sum = Sum Plus . int
+---
+rejected at token 1 of 2: unexpected token: 2
+consumed: int
+expected: Plus end of input
+partial rules:
+	RuleAdd from 0: Sum = Sum . Plus int
+---
+rejected: no tokens in parser input
+---
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+	g.Options = earley.Options{Explain: true, Scannerless: true}
+	if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+		t.Error("no error for options Explain and Scannerless")
+	}
+}
+
+var explainMainText = `
+package main
+
+import "fmt"
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{1, Plus{}, 2},
+		{1, Plus{}, Plus{}},
+		{1, Plus{}},
+		{1, 2},
+		{},
+	} {
+		if report := _glean_Explain(tokens); report == "" {
+			fmt.Println("accepted")
+		} else {
+			fmt.Print(report, "---\n")
+		}
+	}
+}
+`
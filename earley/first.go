@@ -0,0 +1,62 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import "github.com/pat42smith/glean"
+
+// FirstSets finds, for each nonterminal symbol, the terminal symbols that
+// can begin a sequence of tokens it derives, sorted by name. A nonterminal
+// deriving only the empty sequence has an empty set, and is still in the
+// map; terminals are not. Since a nullable item can match nothing, the
+// set takes in the items after it too, as with Nullable.
+//
+// Two rules for one target whose items begin with overlapping sets may
+// well match the same input, though not necessarily; the sets suit such
+// diagnostics, and companion lexers, rather than deciding ambiguity.
+func (g *Grammar) FirstSets() map[glean.Symbol][]glean.Symbol {
+	nullable := g.Nullable()
+	first := make(map[*symbol]map[*symbol]bool)
+	for _, s := range g.name2symbol {
+		if !s.isTerminal() {
+			first[s] = make(map[*symbol]bool)
+		}
+	}
+
+	add := func(set map[*symbol]bool, t *symbol) bool {
+		if set[t] {
+			return false
+		}
+		set[t] = true
+		return true
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range append(g.rules[:len(g.rules):len(g.rules)], g.listRules...) {
+			set := first[r.target]
+			for _, item := range r.items {
+				if item.isTerminal() {
+					changed = add(set, item) || changed
+					break
+				}
+				for t := range first[item] {
+					changed = add(set, t) || changed
+				}
+				if !nullable[item.name] {
+					break
+				}
+			}
+		}
+	}
+
+	sets := make(map[glean.Symbol][]glean.Symbol)
+	for s, set := range first {
+		names := make([]glean.Symbol, 0, len(set))
+		for t := range set {
+			names = append(names, t.name)
+		}
+		sortSymbols(names)
+		sets[s.name] = names
+	}
+	return sets
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test finding the terminals that can begin each nonterminal
+func TestFirstSets(t *testing.T) {
+	var g earley.Grammar
+	for _, r := range []struct {
+		name   string
+		target glean.Symbol
+		items  []glean.Symbol
+	}{
+		{"RuleSum", "Sum", []glean.Symbol{"Sum", "Plus", "Term"}},
+		{"RuleTerm", "Sum", []glean.Symbol{"Term"}},
+		{"RuleInt", "Term", []glean.Symbol{"Sign", "int"}},
+		{"RuleParen", "Term", []glean.Symbol{"Open", "Sum", "Close"}},
+		{"RuleMinus", "Sign", []glean.Symbol{"Minus"}},
+		{"RuleNoSign", "Sign", nil},
+		{"RuleBlock", "Block", []glean.Symbol{glean.ListOf("Stmt")}},
+		{"RuleStmt", "Stmt", []glean.Symbol{"Sum", "Semi"}},
+		{"RuleEmpty", "Stmt", []glean.Symbol{"Empty"}},
+		{"RuleEmptyItems", "Empty", []glean.Symbol{"Sign", "Sign"}},
+	} {
+		if e := g.AddRule(r.name, r.target, r.items); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	expect := map[glean.Symbol]string{
+		"Block":  "[Minus Open int]",
+		"Empty":  "[Minus]",
+		"Sign":   "[Minus]",
+		"Stmt":   "[Minus Open int]",
+		"Sum":    "[Minus Open int]",
+		"Term":   "[Minus Open int]",
+		"[]Stmt": "[Minus Open int]",
+	}
+	sets := g.FirstSets()
+	if len(sets) != len(expect) {
+		t.Errorf("expected %d sets, got %v", len(expect), sets)
+	}
+	for s, e := range expect {
+		if got := fmt.Sprint(sets[s]); got != e {
+			t.Errorf("first set of %s: expected %s, got %s", s, e, got)
+		}
+	}
+
+	g.AddRule("RuleNothing", "Nothing", nil)
+	if set, have := g.FirstSets()["Nothing"]; !have || len(set) != 0 {
+		t.Errorf("wrong first set for empty symbol: %v %v", set, have)
+	}
+}
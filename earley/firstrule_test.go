@@ -0,0 +1,115 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test resolving ambiguities between rules by the order they were added
+func TestFirstRule(t *testing.T) {
+	ifRule := "func RuleIf(_ If, c Cond, s Stmt) Stmt { return Stmt(fmt.Sprintf(\"if(%s,%s)\", c, s)) }"
+	ifElseRule := "func RuleIfElse(_ If, c Cond, s Stmt, _ Else, e Stmt) Stmt {\n" +
+		"\treturn Stmt(fmt.Sprintf(\"if(%s,%s,%s)\", c, s, e))\n}"
+	for _, c := range []struct {
+		rules   string
+		options earley.Options
+		expect  string
+	}{
+		{ifElseRule + "\n" + ifRule, earley.Options{FirstRule: true}, `if(a,if(b,x),y)
+[if(a,if(b,x),y) z]
+ambiguous
+`},
+		{ifRule + "\n" + ifElseRule, earley.Options{FirstRule: true}, `if(a,if(b,x,y))
+[if(a,if(b,x,y)) z]
+ambiguous
+`},
+		{ifRule + "\n" + ifElseRule, earley.Options{FirstRule: true, Ambiguity: earley.AmbiguityLeftmost}, `if(a,if(b,x,y))
+[if(a,if(b,x,y)) z]
+((p+q)+r)
+`},
+		{ifRule + "\n" + ifElseRule, earley.Options{}, `ambiguous
+ambiguous
+ambiguous
+`},
+	} {
+		parse, e := gleantest.Build(t, fmt.Sprintf(firstRuleMainText, c.rules), "Block", c.options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if out, e := parse(); e != nil || out != c.expect {
+			t.Errorf("options %+v, rules:\n%s\nexpected:\n%s\ngot:\n%s %v", c.options, c.rules, c.expect, out, e)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Expr", []glean.Symbol{"int"})
+	for _, options := range []earley.Options{
+		{FirstRule: true, ParseAll: true},
+		{FirstRule: true, Weights: map[string]int{"RuleInt": 1}},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Expr", "main", "_"); e == nil {
+			t.Errorf("no error for options %+v", options)
+		}
+	}
+}
+
+var firstRuleMainText = `
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type If struct{}
+type Else struct{}
+type Plus struct{}
+type Cond string
+type Stmt string
+type Block string
+
+%s
+
+func RuleCond(s string) Cond              { return Cond(s) }
+func RuleStmt(s string) Stmt              { return Stmt(s) }
+func RuleSum(x Stmt, _ Plus, y Stmt) Stmt { return "(" + x + "+" + y + ")" }
+
+func RuleBlock(stmts []Stmt) Block {
+	var s []string
+	for _, stmt := range stmts {
+		s = append(s, string(stmt))
+	}
+	if len(s) == 1 {
+		return Block(s[0])
+	}
+	return Block("[" + strings.Join(s, " ") + "]")
+}
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{If{}, "a", If{}, "b", "x", Else{}, "y"},
+		{If{}, "a", If{}, "b", "x", Else{}, "y", "z"},
+		{"p", Plus{}, "q", Plus{}, "r"},
+	} {
+		b, e := _glean_Parse(tokens)
+		if errors.Is(e, gleanerrors.ErrAmbiguous) {
+			fmt.Println("ambiguous")
+		} else if e != nil {
+			fmt.Println(e)
+		} else {
+			fmt.Println(b)
+		}
+	}
+}
+`
@@ -0,0 +1,80 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import "github.com/pat42smith/glean"
+
+// EndOfInput stands for the end of the input in the sets of FollowSets.
+// It is not a valid Go identifier, so is not the name of any symbol.
+const EndOfInput glean.Symbol = "$"
+
+// FollowSets finds, for each symbol of the grammar, the terminal symbols
+// that can come just after it in a sequence of tokens derived from the
+// goal, sorted by name. EndOfInput is among them for the goal, and for
+// each symbol that can end a derivation of the goal, since the end of the
+// input may follow it there. Nullable items are passed over, so in
+//
+//	Call = Ident Args Close
+//
+// with Args nullable, Close follows Ident. Since the goal is given,
+// FollowSets may be called before WriteParser. The rules unreachable from
+// the goal are included, so their items have sets too, though a symbol
+// that can end only such rules may have an empty one.
+func (g *Grammar) FollowSets(goal glean.Symbol) map[glean.Symbol][]glean.Symbol {
+	nullable := g.Nullable()
+	first := g.FirstSets()
+	follow := make(map[glean.Symbol]map[glean.Symbol]bool)
+	for name := range g.name2symbol {
+		follow[name] = make(map[glean.Symbol]bool)
+	}
+	if follow[goal] != nil {
+		follow[goal][EndOfInput] = true
+	}
+
+	add := func(set map[glean.Symbol]bool, t glean.Symbol) bool {
+		if set[t] {
+			return false
+		}
+		set[t] = true
+		return true
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, r := range append(g.rules[:len(g.rules):len(g.rules)], g.listRules...) {
+			for n, item := range r.items {
+				set := follow[item.name]
+				end := true
+				for _, next := range r.items[n+1:] {
+					if next.isTerminal() {
+						changed = add(set, next.name) || changed
+					} else {
+						for _, t := range first[next.name] {
+							changed = add(set, t) || changed
+						}
+					}
+					if !nullable[next.name] {
+						end = false
+						break
+					}
+				}
+				if end {
+					for t := range follow[r.target.name] {
+						changed = add(set, t) || changed
+					}
+				}
+			}
+		}
+	}
+
+	sets := make(map[glean.Symbol][]glean.Symbol)
+	for name, set := range follow {
+		names := make([]glean.Symbol, 0, len(set))
+		for t := range set {
+			names = append(names, t)
+		}
+		sortSymbols(names)
+		sets[name] = names
+	}
+	return sets
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test finding the terminals that can follow each symbol
+func TestFollowSets(t *testing.T) {
+	var g earley.Grammar
+	for _, r := range []struct {
+		name   string
+		target glean.Symbol
+		items  []glean.Symbol
+	}{
+		{"RuleSum", "Sum", []glean.Symbol{"Sum", "Plus", "Term"}},
+		{"RuleTerm", "Sum", []glean.Symbol{"Term", "Suffix"}},
+		{"RuleInt", "Term", []glean.Symbol{"int"}},
+		{"RuleCall", "Term", []glean.Symbol{"Ident", "Args", "Close"}},
+		{"RuleArgs", "Args", []glean.Symbol{glean.ListOf("Sum")}},
+		{"RuleNoArgs", "Args", nil},
+		{"RuleBang", "Suffix", []glean.Symbol{"Bang"}},
+		{"RuleNoSuffix", "Suffix", nil},
+		{"RuleUnused", "Unused", []glean.Symbol{"Dot"}},
+	} {
+		if e := g.AddRule(r.name, r.target, r.items); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	expect := map[glean.Symbol]string{
+		"Args":   "[Close]",
+		"Bang":   "[$ Close Ident Plus int]",
+		"Close":  "[$ Bang Close Ident Plus int]",
+		"Dot":    "[]",
+		"Ident":  "[Close Ident int]",
+		"Plus":   "[Ident int]",
+		"Suffix": "[$ Close Ident Plus int]",
+		"Sum":    "[$ Close Ident Plus int]",
+		"Term":   "[$ Bang Close Ident Plus int]",
+		"Unused": "[]",
+		"[]Sum":  "[Close Ident int]",
+		"int":    "[$ Bang Close Ident Plus int]",
+	}
+	sets := g.FollowSets("Sum")
+	if len(sets) != len(expect) {
+		t.Errorf("expected %d sets, got %v", len(expect), sets)
+	}
+	for s, e := range expect {
+		if got := fmt.Sprint(sets[s]); got != e {
+			t.Errorf("follow set of %s: expected %s, got %s", s, e, got)
+		}
+	}
+
+	sets = g.FollowSets("Unused")
+	if got := fmt.Sprint(sets["Unused"], sets["Dot"], sets["Sum"]); got != "[$] [$] [Close Ident Plus int]" {
+		t.Error("wrong follow sets for goal Unused:", got)
+	}
+	if got := fmt.Sprint(g.FollowSets("Missing")["Sum"]); got != "[Close Ident Plus int]" {
+		t.Error("wrong follow set for unknown goal:", got)
+	}
+}
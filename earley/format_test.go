@@ -0,0 +1,48 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"go/format"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test that the parser text is formatted as gofmt would, whatever the
+// lengths of the names in it
+func TestFormat(t *testing.T) {
+	var g earley.Grammar
+	g.AddRule("RuleSum", "Sum", []glean.Symbol{"Sum", "Plus", "VeryLongProductSymbolName"})
+	g.AddRule("RuleProduct", "Sum", []glean.Symbol{"VeryLongProductSymbolName"})
+	g.AddRule("RuleInts", "VeryLongProductSymbolName", []glean.Symbol{glean.ListOf("int")})
+
+	for _, prefix := range []string{"", "_", "_a_rather_long_parser_prefix_"} {
+		for n, options := range []earley.Options{
+			{},
+			{Tree: true, Events: true, Explain: true},
+			{Registry: true, GenericStacks: true},
+			{Interface: true, Concurrent: true, ValidPrefix: true},
+			{Recover: true, ErrorSink: true, DisplayNames: map[glean.Symbol]string{"Plus": "'+'"}},
+			{Ambiguity: earley.AmbiguityLeftmost, Resolutions: true, Warnings: true, Stepping: true},
+			{Scannerless: true, Debug: true},
+			{Classifier: true, Alternatives: true},
+			{Kinds: map[glean.Symbol]string{"Plus": "operator", "int": "number"}, KindFunc: "kindOf", Intern: []glean.Symbol{"int"}},
+		} {
+			g.Options = options
+			text, e := g.WriteParser("Sum", "main", prefix)
+			if e != nil {
+				t.Fatalf("prefix %q, options %d: %v", prefix, n, e)
+			}
+			formatted, e := format.Source([]byte(text))
+			if e != nil {
+				t.Fatal(e)
+			}
+			if string(formatted) != text {
+				t.Errorf("prefix %q, options %d: parser is not formatted", prefix, n)
+			}
+		}
+	}
+}
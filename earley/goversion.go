@@ -0,0 +1,64 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An option needing a Go release later than 1.0
+type versionedOption struct {
+	name  string
+	used  bool // Whether the option is chosen
+	minor int  // The minor version of the earliest release supporting it
+}
+
+// The options needing a Go release later than 1.0
+func (g *Grammar) versionedOptions() []versionedOption {
+	return []versionedOption{
+		{"GenericStacks", g.Options.GenericStacks, 18}, // type parameters
+		{"ParseAs", g.Options.ParseAs, 18},             // type parameters
+		{"Explain", g.Options.Explain, 10},             // strings.Builder
+		{"Complete", g.Options.Complete, 8},            // sort.SliceStable
+		{"Events", g.Options.Events, 7},                // context
+	}
+}
+
+// Check that the options chosen suit Options.GoVersion
+func (g *Grammar) checkGoVersion() error {
+	if g.Options.GoVersion == "" {
+		return nil
+	}
+	minor, ok := goMinorVersion(g.Options.GoVersion)
+	if !ok {
+		return fmt.Errorf("invalid Go version '%s'", g.Options.GoVersion)
+	}
+	for _, o := range g.versionedOptions() {
+		if o.used && minor < o.minor {
+			return fmt.Errorf("option %s requires Go 1.%d or later, not Go %s", o.name, o.minor, g.Options.GoVersion)
+		}
+	}
+	return nil
+}
+
+// Find the minor version of a Go 1 release, such as 18 from 1.18, 1.18.2
+// or go1.18rc1
+func goMinorVersion(version string) (int, bool) {
+	v := strings.TrimPrefix(version, "go")
+	if !strings.HasPrefix(v, "1.") {
+		return 0, false
+	}
+	v = v[2:]
+	end := 0
+	for end < len(v) && v[end] >= '0' && v[end] <= '9' {
+		end++
+	}
+	minor, e := strconv.Atoi(v[:end])
+	if e != nil {
+		return 0, false
+	}
+	return minor, true
+}
@@ -0,0 +1,42 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test that options are checked against Options.GoVersion
+func TestGoVersion(t *testing.T) {
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+
+	for _, version := range []string{"", "1.18", "go1.18", "1.21.3", "go1.22rc1"} {
+		g.Options = earley.Options{GenericStacks: true, GoVersion: version}
+		if _, e := g.WriteParser("Sum", "main", "_"); e != nil {
+			t.Errorf("version %q: %v", version, e)
+		}
+	}
+
+	g.Options = earley.Options{GenericStacks: true, GoVersion: "1.17"}
+	if _, e := g.WriteParser("Sum", "main", "_"); e == nil || !strings.Contains(e.Error(), "Go 1.18") {
+		t.Errorf("expected error requiring Go 1.18, got %v", e)
+	}
+
+	g.Options = earley.Options{GoVersion: "1.5"}
+	if _, e := g.WriteParser("Sum", "main", "_"); e != nil {
+		t.Error(e)
+	}
+
+	for _, version := range []string{"2.0", "x", "1.", "go"} {
+		g.Options = earley.Options{GoVersion: version}
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil || !strings.Contains(e.Error(), "invalid Go version") {
+			t.Errorf("version %q: expected invalid version error, got %v", version, e)
+		}
+	}
+}
@@ -6,37 +6,82 @@
 package earley
 
 import (
+	"bufio"
 	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
 	"go/token"
+	"io"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/pat42smith/glean"
 )
 
 // *Grammar is an Earley implentation of glean.Grammar.
 type Grammar struct {
+	// Options affecting the parsers written by WriteParser.
+	Options Options
+
 	rulenames                        map[string]struct{}
 	name2symbol                      map[glean.Symbol]*symbol
 	rules                            []*rule
+	listRules                        []*rule // Rules for list symbols, made by the Grammar
 	symbols, terminals, nonterminals []*symbol
 	prefixes                         []*prefix
 	goalname                         glean.Symbol // WriteParser argument
 	packname, prepend                string       // more WriteParser arguments
 	goal                             *symbol
-	builder                          *strings.Builder // accumulates parser text
+	assoc                            []int      // associativity of each rule, from checkAssociativity
+	prec                             []int      // precedence of each rule, from checkPrecedence
+	builder                          textWriter // receives parser text
 }
 
 // Implements glean.RuleAdder.AddRule.
 func (g *Grammar) AddRule(name string, target glean.Symbol, items []glean.Symbol) error {
-	if !token.IsIdentifier(name) {
+	return g.AddSharedRule(name, name, target, items)
+}
+
+// Implements glean.ImportAdder.AddImport, recording the path in
+// g.Options.Imports.
+func (g *Grammar) AddImport(name, path string) error {
+	if other, have := g.Options.Imports[name]; have && other != path {
+		return fmt.Errorf("package %s has import paths %s and %s", name, other, path)
+	}
+	if g.Options.Imports == nil {
+		g.Options.Imports = make(map[string]string)
+	}
+	g.Options.Imports[name] = path
+	return nil
+}
+
+// Implements glean.SharedRuleAdder.AddSharedRule.
+func (g *Grammar) AddSharedRule(name, reducer string, target glean.Symbol, items []glean.Symbol) error {
+	if !validName(name) {
 		return fmt.Errorf("rule name '%s' is not a valid Go identifier", name)
 	}
-	if !token.IsIdentifier(string(target)) {
+	if !validName(reducer) {
+		return fmt.Errorf("reducer name '%s' is not a valid Go identifier", reducer)
+	}
+	if !validName(string(target)) {
 		return fmt.Errorf("target symbol '%s' is not a valid Go identifier", target)
 	}
+	if g.Options.MaxItems > 0 && len(items) > g.Options.MaxItems {
+		return fmt.Errorf("rule %s has %d items, more than the limit of %d", name, len(items), g.Options.MaxItems)
+	}
 	for _, item := range items {
-		if !token.IsIdentifier(string(item)) {
+		e := item
+		if glean.OptionalElement(e) != "" {
+			e = glean.OptionalElement(e)
+		}
+		for glean.ListElement(e) != "" {
+			e = glean.ListElement(e)
+		}
+		if !validName(string(e)) {
 			return fmt.Errorf("rule item '%s' is not a valid Go identifier", item)
 		}
 	}
@@ -55,6 +100,7 @@ func (g *Grammar) AddRule(name string, target glean.Symbol, items []glean.Symbol
 
 	var r rule
 	r.name = name
+	r.reducer = reducer
 	r.target = g.findSymbol(target)
 	r.items = make([]*symbol, len(items))
 	for n, i := range items {
@@ -67,6 +113,15 @@ func (g *Grammar) AddRule(name string, target glean.Symbol, items []glean.Symbol
 	return nil
 }
 
+// Check that a rule or symbol name is a Go identifier, or one qualified by
+// a package name, as found by glean.ScanFilesWithOptions
+func validName(name string) bool {
+	if pkg, ident, found := strings.Cut(name, "."); found {
+		return token.IsIdentifier(pkg) && token.IsIdentifier(ident)
+	}
+	return token.IsIdentifier(name)
+}
+
 // Finds or creates a symbol from its name
 func (g *Grammar) findSymbol(name glean.Symbol) *symbol {
 	if s, have := g.name2symbol[name]; have {
@@ -74,33 +129,194 @@ func (g *Grammar) findSymbol(name glean.Symbol) *symbol {
 	}
 	s := &symbol{name: name}
 	g.name2symbol[name] = s
+	if e := glean.ListElement(name); e != "" {
+		s.element = g.findSymbol(e)
+		g.addListRule(s, s.element)
+		g.addListRule(s, s, s.element)
+	} else if e := glean.OptionalElement(name); e != "" {
+		s.element = g.findSymbol(e)
+		s.optional = true
+		g.addListRule(s)
+		g.addListRule(s, s.element)
+	}
 	return s
 }
 
-// Implements glean.ParserWriter.WriteParser.
+// Adds a rule for a list or optional symbol
+func (g *Grammar) addListRule(list *symbol, items ...*symbol) {
+	r := &rule{name: string(list.name), target: list, items: items}
+	g.listRules = append(g.listRules, r)
+	list.rules = append(list.rules, r)
+}
+
+// Implements glean.ParserWriter.WriteParser. The parser is formatted as
+// by gofmt; should that fail, which is a bug, the unformatted text is
+// returned with the error, to help find it.
 func (g *Grammar) WriteParser(goal glean.Symbol, packname, prepend string) (string, error) {
+	text, e := g.writeParser(nil, goal, packname, prepend)
+	return string(text), e
+}
+
+// WriteParserTo writes the parser to w as it is generated, rather than
+// building the whole text in memory as WriteParser does, which matters for
+// a large grammar. The text is not formatted; passing it through gofmt or
+// go/format gives the text WriteParser returns. Nothing is written if the
+// grammar or its options are invalid. With option PrefixType, RuleType or
+// SymbolType, the text must be checked as a whole for names declared twice,
+// so it is built in memory after all, and written once checked.
+func (g *Grammar) WriteParserTo(w io.Writer, goal glean.Symbol, packname, prepend string) error {
+	_, e := g.writeParser(w, goal, packname, prepend)
+	return e
+}
+
+// Write the parser, for WriteParser and WriteParserTo. If w is nil, the
+// formatted text is returned; otherwise the unformatted text is written to w.
+func (g *Grammar) writeParser(w io.Writer, goal glean.Symbol, packname, prepend string) ([]byte, error) {
 	if len(g.rulenames) == 0 {
-		return "", fmt.Errorf("grammar has no rules")
+		return nil, fmt.Errorf("grammar has no rules")
 	}
-	if !token.IsIdentifier(string(goal)) {
-		return "", fmt.Errorf("goal '%s' is not a valid Go identifier", goal)
+	if !validName(string(goal)) {
+		return nil, fmt.Errorf("goal '%s' is not a valid Go identifier", goal)
 	}
 	if !token.IsIdentifier(packname) {
-		return "", fmt.Errorf("package name '%s' is not a valid Go identifier", packname)
+		return nil, fmt.Errorf("package name '%s' is not a valid Go identifier", packname)
 	}
 	if prepend != "" && !token.IsIdentifier(prepend) {
-		return "", fmt.Errorf("prefix '%s' is not a valid Go identifier", prepend)
+		return nil, fmt.Errorf("prefix '%s' is not a valid Go identifier", prepend)
+	}
+	if g.Options.ErrorsImport != "" && !validImportPath(g.Options.ErrorsImport) {
+		return nil, fmt.Errorf("errors import path '%s' is not valid", g.Options.ErrorsImport)
+	}
+	for name, importPath := range g.Options.Imports {
+		if !token.IsIdentifier(name) || name == "_" {
+			return nil, fmt.Errorf("import name '%s' is not a valid Go identifier", name)
+		}
+		if !validImportPath(importPath) {
+			return nil, fmt.Errorf("import path '%s' is not valid", importPath)
+		}
+		if name == packname {
+			return nil, fmt.Errorf("import name '%s' is the name of the parser's package", name)
+		}
+		for _, std := range g.stdImports() {
+			if name == path.Base(std) || name == "gleanerrors" {
+				return nil, fmt.Errorf("import name '%s' is also used by the parser", name)
+			}
+		}
+	}
+	for _, r := range g.rules {
+		for _, name := range append([]string{r.reducer, string(r.target.name)}, symbolNames(r.items)...) {
+			if pkg := glean.SymbolPackage(glean.Symbol(name)); pkg != "" && g.Options.Imports[pkg] == "" {
+				return nil, fmt.Errorf("no import path given for package %s, used by rule %s", pkg, r.name)
+			}
+		}
+	}
+	if e := g.checkTypeStems(prepend); e != nil {
+		return nil, e
+	}
+	if e := g.checkGoVersion(); e != nil {
+		return nil, e
+	}
+	if g.Options.ErrorSink && !g.Options.Recover {
+		return nil, fmt.Errorf("option ErrorSink requires option Recover")
+	}
+	if g.Options.Recover && g.Options.Scannerless {
+		return nil, fmt.Errorf("options Recover and Scannerless cannot be combined")
+	}
+	if g.Options.Alternatives && !g.Options.Classifier {
+		return nil, fmt.Errorf("option Alternatives requires option Classifier")
+	}
+	if g.Options.Alternatives && g.Options.Scannerless {
+		return nil, fmt.Errorf("options Alternatives and Scannerless cannot be combined")
+	}
+	if g.Options.Stepping && (g.Options.Scannerless || g.Options.Recover) {
+		return nil, fmt.Errorf("option Stepping cannot be combined with Scannerless or Recover")
+	}
+	if g.Options.LongestPrefix && (g.Options.Scannerless || g.Options.EndSymbol != "") {
+		return nil, fmt.Errorf("option LongestPrefix cannot be combined with Scannerless or EndSymbol")
+	}
+	if g.Options.PartialInput && (g.Options.Scannerless || g.Options.EndSymbol != "") {
+		return nil, fmt.Errorf("option PartialInput cannot be combined with Scannerless or EndSymbol")
+	}
+	if g.Options.Sequence && (g.Options.Scannerless || g.Options.EndSymbol != "") {
+		return nil, fmt.Errorf("option Sequence cannot be combined with Scannerless or EndSymbol")
+	}
+	if g.Options.Interface && g.Options.Registry {
+		return nil, fmt.Errorf("options Interface and Registry cannot be combined")
+	}
+	if g.Options.ChartStore && g.Options.Recover {
+		return nil, fmt.Errorf("options ChartStore and Recover cannot be combined")
+	}
+	if g.Options.Explain && g.Options.Scannerless {
+		return nil, fmt.Errorf("options Explain and Scannerless cannot be combined")
+	}
+	if g.Options.Complete && g.Options.Scannerless {
+		return nil, fmt.Errorf("options Complete and Scannerless cannot be combined")
+	}
+	if g.Options.Concurrent && g.Options.Scannerless {
+		return nil, fmt.Errorf("options Concurrent and Scannerless cannot be combined")
+	}
+	if g.Options.ParseAs && g.Options.PartialInput {
+		return nil, fmt.Errorf("options ParseAs and PartialInput cannot be combined")
+	}
+	if g.Options.ParseAll && (g.Options.Scannerless || len(g.Options.Associativity) > 0 || len(g.Options.Precedence) > 0) {
+		return nil, fmt.Errorf("option ParseAll cannot be combined with Scannerless, Associativity or Precedence")
+	}
+	if g.Options.FirstRule && (g.Options.ParseAll || len(g.Options.Weights) > 0) {
+		return nil, fmt.Errorf("option FirstRule cannot be combined with ParseAll or Weights")
+	}
+	if g.Options.Lexer && (g.Options.Scannerless || g.Options.PartialInput) {
+		return nil, fmt.Errorf("option Lexer cannot be combined with Scannerless or PartialInput")
+	}
+	if g.Options.TypeIds && (g.Options.Classifier || g.Options.Scannerless || len(g.Options.Kinds) > 0) {
+		return nil, fmt.Errorf("option TypeIds cannot be combined with Classifier, Scannerless or Kinds")
+	}
+	if g.Options.UnknownTokens && (g.Options.Unchecked || g.Options.Classifier) {
+		return nil, fmt.Errorf("option UnknownTokens cannot be combined with Unchecked or Classifier")
+	}
+	if t := g.Options.TokenInterface; t != "" {
+		if !validName(t) {
+			return nil, fmt.Errorf("token interface '%s' is not a valid Go identifier", t)
+		}
+		if pkg := glean.SymbolPackage(glean.Symbol(t)); pkg != "" && g.Options.Imports[pkg] == "" {
+			return nil, fmt.Errorf("no import path given for package %s, used by token interface %s", pkg, t)
+		}
+		if g.Options.Classifier || g.Options.Scannerless || len(g.Options.Kinds) > 0 {
+			return nil, fmt.Errorf("option TokenInterface cannot be combined with Classifier, Scannerless or Kinds")
+		}
+	}
+	if g.Options.Ambiguity < AmbiguityError || g.Options.Ambiguity > AmbiguityLongest {
+		return nil, fmt.Errorf("unknown ambiguity policy %d", g.Options.Ambiguity)
+	}
+	if len(g.Options.Weights) > 0 && g.Options.Ambiguity != AmbiguityError {
+		return nil, fmt.Errorf("option Weights cannot be combined with an Ambiguity policy")
+	}
+	for name, w := range g.Options.Weights {
+		if _, have := g.rulenames[name]; !have {
+			return nil, fmt.Errorf("weight given for unknown rule %s", name)
+		}
+		if w < 0 {
+			return nil, fmt.Errorf("rule %s has negative weight %d", name, w)
+		}
+	}
+	if g.Options.Resolutions && g.Options.Ambiguity == AmbiguityError {
+		return nil, fmt.Errorf("option Resolutions requires an Ambiguity policy other than AmbiguityError")
+	}
+	if g.Options.Warnings && g.Options.Ambiguity == AmbiguityError {
+		return nil, fmt.Errorf("option Warnings requires an Ambiguity policy other than AmbiguityError")
 	}
 	g.goalname = goal
 	g.packname = packname
 	g.prepend = prepend
+	for n, r := range g.listRules {
+		r.id = len(g.rules) + n
+	}
 
 	g.sortSymbols()
 	for _, s := range g.symbols {
 		s.sortRules()
 	}
 	if len(g.terminals) == 0 {
-		return "", fmt.Errorf("grammar has no terminal symbols")
+		return nil, fmt.Errorf("grammar has no terminal symbols")
 	}
 	if len(g.nonterminals) == 0 {
 		panic("bug: how can we have rules but no nonterminals?")
@@ -108,16 +324,129 @@ func (g *Grammar) WriteParser(goal glean.Symbol, packname, prepend string) (stri
 
 	g.goal = g.name2symbol[g.goalname]
 	if g.goal == nil {
-		return "", fmt.Errorf("unknown goal symbol '%s'", g.goalname)
+		return nil, fmt.Errorf("unknown goal symbol '%s'", g.goalname)
 	}
 	if g.goal.isTerminal() {
-		return "", fmt.Errorf("goal '%s' is a terminal symbol", g.goalname)
+		return nil, fmt.Errorf("goal '%s' is a terminal symbol", g.goalname)
+	}
+	if e := g.checkProductive(); e != nil {
+		return nil, e
+	}
+	if e := g.checkHidden(); e != nil {
+		return nil, e
+	}
+	if e := g.checkIntern(); e != nil {
+		return nil, e
+	}
+	for s := range g.Options.DisplayNames {
+		if t := g.name2symbol[s]; t == nil || !t.isTerminal() {
+			return nil, fmt.Errorf("display name given for '%s', which is not a terminal symbol", s)
+		}
+	}
+	if e := g.checkLookahead(); e != nil {
+		return nil, e
+	}
+	if e := g.checkKinds(); e != nil {
+		return nil, e
+	}
+	assoc, e := g.checkAssociativity()
+	if e != nil {
+		return nil, e
+	}
+	g.assoc = assoc
+	prec, e := g.checkPrecedence()
+	if e != nil {
+		return nil, e
+	}
+	g.prec = prec
+	if g.Options.EndSymbol != "" {
+		if g.Options.Scannerless {
+			return nil, fmt.Errorf("options EndSymbol and Scannerless cannot be combined")
+		}
+		if s := g.name2symbol[g.Options.EndSymbol]; s == nil {
+			return nil, fmt.Errorf("end symbol '%s' does not appear in the grammar", g.Options.EndSymbol)
+		} else if !s.isTerminal() {
+			return nil, fmt.Errorf("end symbol '%s' is not a terminal symbol", g.Options.EndSymbol)
+		}
 	}
 
 	g.makePrefixes()
 
-	g.builder = new(strings.Builder)
+	renamed := g.Options.PrefixType != "" || g.Options.RuleType != "" || g.Options.SymbolType != ""
+	if w != nil && !renamed {
+		out := &stickyWriter{w: w}
+		b := bufio.NewWriter(out)
+		g.builder = b
+		g.addParserText()
+		g.builder = nil
+		b.Flush()
+		return nil, out.err
+	}
+
+	b := new(strings.Builder)
+	g.builder = b
+	g.addParserText()
+	g.builder = nil
+	text := []byte(b.String())
+	if w != nil {
+		if e := checkDeclarations(string(text)); e != nil {
+			return nil, e
+		}
+		_, e := w.Write(text)
+		return nil, e
+	}
+
+	// The text is formatted by hand, but the lengths of the names
+	// substituted into it can upset the alignment gofmt would give.
+	formatted, e := format.Source(text)
+	if e != nil {
+		return text, fmt.Errorf("bug: cannot format parser: %w", e)
+	}
+	if renamed {
+		if e := checkDeclarations(string(formatted)); e != nil {
+			return nil, e
+		}
+	}
+	return formatted, nil
+}
+
+// Write the text of the parser to g.builder
+func (g *Grammar) addParserText() {
+	g.addHeader()
 	g.addText(boilerplate)
+	if g.keepAlternatives() {
+		g.addText("\talternatives    [][2]*@_Match // Each shorter and last from which the match was made\n")
+	}
+	if len(g.Options.Weights) > 0 {
+		g.addText(`	weight          int           // The least weight of a derivation, once weighed
+	weighed         int           // 0 before weighing, 1 during, 2 after
+`)
+	}
+	g.addText("}\n")
+	g.addMatchFuncs()
+	g.addParseMethod()
+	g.addChart()
+	g.addReducerInterface()
+	g.addParse()
+	g.addParseRecover()
+	g.addParseTree()
+	g.addParseEvents()
+	g.addParseConcurrent()
+	g.addParseAs()
+	g.addParseLexer()
+	g.addReusable()
+	g.addParseAll()
+	g.addParseTrace()
+	g.addCatchMethods()
+	g.addValidPrefix()
+	g.addExplain()
+	g.addParseLongest()
+	g.addParseSequence()
+	g.addComplete()
+	g.addStepping()
+	g.addFindMatches()
+	g.addFollows()
+	g.addFindTrace()
 	g.addParserType()
 	g.addApplyTrace()
 
@@ -132,26 +461,38 @@ func (g *Grammar) WriteParser(goal glean.Symbol, packname, prepend string) (stri
 	g.addAppliers()
 	g.addPrefix2Rule()
 	g.addRuleDescriptions()
-
-	return g.builder.String(), nil
+	g.addSymbolNames()
+	g.addDisplayNames()
+	g.addWeights()
+	g.addAssociativity()
+	g.addPrecedence()
+	g.addLookahead()
+	g.addSteppingTables()
+	g.addPrefixLengths()
+	g.addConcurrentReducers()
+	g.addCompletionTables()
+	g.addExplainTables()
 }
 
 // Sort the symbols so terminals precede non-terminals, and assign each symbol a unique id.
+// Within each group, symbols are sorted by name, so the generated parser does not depend
+// on the order of iteration over g.name2symbol.
 func (g *Grammar) sortSymbols() {
-	t, u := 0, len(g.name2symbol)
-	g.symbols = make([]*symbol, u)
+	g.symbols = make([]*symbol, 0, len(g.name2symbol))
+	t := 0
 	for _, s := range g.name2symbol {
+		g.symbols = append(g.symbols, s)
 		if s.isTerminal() {
-			g.symbols[t] = s
 			t++
-		} else {
-			u--
-			g.symbols[u] = s
 		}
 	}
-	if t != u {
-		panic("bug")
-	}
+	sort.Slice(g.symbols, func(i, j int) bool {
+		si, sj := g.symbols[i], g.symbols[j]
+		if si.isTerminal() != sj.isTerminal() {
+			return si.isTerminal()
+		}
+		return si.name < sj.name
+	})
 	g.terminals = g.symbols[:t]
 	g.nonterminals = g.symbols[t:]
 
@@ -215,17 +556,33 @@ func (g *Grammar) addText(s string) {
 		switch c {
 		case '@':
 			g.addString(g.prepend)
+			if stem, custom, ok := g.typeStem(s[i+1:]); ok {
+				g.addString(custom)
+				i += len(stem)
+			}
 		case '#':
 			i++
 			d := s[i]
 			var t string
 			switch d {
 			case 'G':
-				t = string(g.goal.name)
+				t = g.valueType(g.goal)
+			case 'S':
+				t = g.goal.identifier()
 			case 'g':
 				t = strconv.Itoa(g.goal.prefix0.id)
 			case 'P':
 				t = g.packname
+			case 'E':
+				t = g.errorsImport()
+			case 'R':
+				t = strconv.Itoa(len(g.rules))
+			case 'T':
+				if g.Options.Classifier {
+					t = "parser.tokenType"
+				} else {
+					t = g.prepend + "_tokenType"
+				}
 			default:
 				t = fmt.Sprintf("#%c", d)
 			}
@@ -239,6 +596,136 @@ func (g *Grammar) addText(s string) {
 	}
 }
 
+// If text begins with the stem of one of the integer types of the parser,
+// and a replacement is given in the options, return the stem and its
+// replacement
+func (g *Grammar) typeStem(text string) (stem, custom string, ok bool) {
+	for _, t := range g.typeStems() {
+		if t.custom == "" || !strings.HasPrefix(text, t.stem) {
+			continue
+		}
+		if rest := text[len(t.stem):]; rest != "" {
+			if c := rest[0]; c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+				continue
+			}
+		}
+		return t.stem, t.custom, true
+	}
+	return "", "", false
+}
+
+// The stems of the names of the integer types of the parser, with their
+// replacements from the options
+func (g *Grammar) typeStems() []struct{ stem, custom string } {
+	return []struct{ stem, custom string }{
+		{"_Prefix", g.Options.PrefixType},
+		{"_Rule", g.Options.RuleType},
+		{"_Symbol", g.Options.SymbolType},
+	}
+}
+
+// Check the replacements for the stems of the integer type names. Each,
+// after the prefix, must be an identifier, and must not be declared
+// elsewhere in the parser text.
+func (g *Grammar) checkTypeStems(prepend string) error {
+	names := make(map[string]bool)
+	for _, t := range g.typeStems() {
+		if t.custom == "" {
+			continue
+		}
+		name := prepend + t.custom
+		if !token.IsIdentifier(name) {
+			return fmt.Errorf("type name '%s' for %s is not a valid Go identifier", name, t.stem[1:])
+		}
+		if names[name] {
+			return fmt.Errorf("type name '%s' is given for two types", name)
+		}
+		names[name] = true
+	}
+	return nil
+}
+
+// Check that no name is declared twice at file scope in the parser text,
+// as could happen if a replacement type name is that of another declaration
+func checkDeclarations(text string) error {
+	f, e := parser.ParseFile(token.NewFileSet(), "", text, parser.SkipObjectResolution)
+	if e != nil {
+		panic(e)
+	}
+	var names []*ast.Ident
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names = append(names, d.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					names = append(names, spec.Name)
+				case *ast.ValueSpec:
+					names = append(names, spec.Names...)
+				}
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name.Name] && name.Name != "_" {
+			return fmt.Errorf("name '%s' is declared twice in the parser", name.Name)
+		}
+		seen[name.Name] = true
+	}
+	return nil
+}
+
+// The import spec for the gleanerrors package
+func (g *Grammar) errorsImport() string {
+	path := g.Options.ErrorsImport
+	if path == "" || path == DefaultErrorsImport {
+		return strconv.Quote(DefaultErrorsImport)
+	}
+	return "gleanerrors " + strconv.Quote(path)
+}
+
+// Check that an import path uses only characters permitted by the Go specification
+func validImportPath(path string) bool {
+	if path == "" {
+		return false
+	}
+	for _, c := range path {
+		if !unicode.IsGraphic(c) || unicode.IsSpace(c) || c == unicode.ReplacementChar ||
+			strings.ContainsRune("!\"#$%&'()*,:;<=>?[\\]^`{|}", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// The destination of the parser text: a strings.Builder, or a bufio.Writer
+// when the text is written straight to an io.Writer
+type textWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
+// A stickyWriter passes writes on to w until one fails, then discards the
+// rest, keeping the error; so the parser text can be written without
+// checking each write.
+type stickyWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (s *stickyWriter) Write(p []byte) (int, error) {
+	if s.err == nil {
+		_, s.err = s.w.Write(p)
+	}
+	return len(p), nil
+}
+
 // Append a string to the parser text, unchanged
 func (g *Grammar) addString(s string) {
 	n, e := g.builder.WriteString(s)
@@ -267,15 +754,73 @@ func (g *Grammar) addSlice(s []int) {
 	g.addString("}")
 }
 
-// Standard text needing only simple modifications
-var boilerplate = `package #P
+// The standard packages imported by the parser
+func (g *Grammar) stdImports() []string {
+	var std []string
+	if g.Options.Events {
+		std = append(std, "context")
+	}
+	if g.Options.Tree {
+		std = append(std, "encoding/json")
+	}
+	// fmt is needed by:
+	//   - the token type switch, unless Classifier, UnknownTokens or Unchecked;
+	//   - the check of token options, with Scannerless, unless Unchecked;
+	//   - Reducers.Register, with Registry;
+	//   - Complete, Explain and ParseAs.
+	checked := !g.Options.Unchecked
+	if checked && (!g.Options.Classifier && !g.Options.UnknownTokens || g.Options.Scannerless) || g.Options.Registry || g.Options.Complete || g.Options.Explain || g.Options.ParseAs {
+		std = append(std, "fmt")
+	}
+	if g.Options.ParseAs {
+		std = append(std, "reflect")
+	}
+	if g.Options.Concurrent {
+		std = append(std, "runtime")
+	}
+	// sort orders the expected symbols of gleanerrors.Unexpected
+	std = append(std, "sort")
+	if g.Options.Explain {
+		std = append(std, "strings")
+	}
+	if g.Options.Concurrent {
+		std = append(std, "sync", "sync/atomic")
+	}
+	return std
+}
 
-import (
-	"fmt"
+// Append the package clause and imports
+func (g *Grammar) addHeader() {
+	std := g.stdImports()
+	g.addText("package #P\n\nimport (\n")
+	for _, path := range std {
+		g.addf("\t%q\n", path)
+	}
+	if len(std) > 0 {
+		g.addString("\n")
+	}
+	g.addText("\t#E\n")
 
-	"github.com/pat42smith/glean/gleanerrors"
-)
+	// The packages of qualified symbols, sorted by path as gofmt would
+	var names []string
+	for name := range g.Options.Imports {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := g.Options.Imports[names[i]], g.Options.Imports[names[j]]
+		return pi < pj || pi == pj && names[i] < names[j]
+	})
+	if len(names) > 0 {
+		g.addString("\n")
+	}
+	for _, name := range names {
+		g.addf("\t%s %q\n", name, g.Options.Imports[name])
+	}
+	g.addString(")\n")
+}
 
+// Standard text needing only simple modifications
+var boilerplate = `
 type @_Prefix int
 type @_Rule int
 type @_Symbol int
@@ -286,74 +831,720 @@ type @_Match struct {
 	start, end      int
 	shorter, last   *@_Match
 	shorter2, last2 *@_Match
+`
+
+// Append the functions recording matches and reporting ambiguities
+func (g *Grammar) addMatchFuncs() {
+	g.addText(`
+func (parser *@_Parser) addMatch(prefix @_Prefix, start, end int, shorter, last *@_Match) {
+	list := parser.at(end)[prefix]
+	for _, m := range list {
+		if m.start == start {
+			if m.shorter != shorter || m.last != last {
+`)
+	if g.assoc != nil {
+		// Of alternatives of an associative rule differing in where the
+		// last item begins, keep only the one the associativity prefers.
+		g.addText(`				if a := @_associativity[prefix]; a != 0 && shorter.end != m.shorter.end {
+					if shorter.end > m.shorter.end == (a > 0) {
+						m.shorter, m.last = shorter, last
+					}
+					return
+				}
+`)
+	}
+	if g.prec != nil {
+		// Of alternatives differing only in the rule matching the last
+		// item, keep only the one the precedence of the rules prefers.
+		g.addText(`				if shorter == m.shorter {
+					if o := @_outranks(last, m.last); o != 0 {
+						if o > 0 {
+							m.last = last
+						}
+						return
+					}
+				}
+`)
+	}
+	if g.Options.FirstRule {
+		// Of alternatives differing only in the rule matching the last
+		// item, keep only the one whose rule was added first.
+		g.addText(`				if shorter == m.shorter {
+					if @_prefix2rule[last.prefix] < @_prefix2rule[m.last.prefix] {
+						m.last = last
+					}
+					return
+				}
+`)
+	}
+	if g.keepAlternatives() {
+		g.addText(`				known := false
+				for _, a := range m.alternatives {
+					known = known || a == [2]*@_Match{shorter, last}
+				}
+				if !known {
+					m.alternatives = append(m.alternatives, [2]*@_Match{shorter, last})
+				}
+`)
+	}
+	if g.Options.Ambiguity != AmbiguityError {
+		// Keep the preferred alternative in shorter and last
+		g.addText(`				if @_preferred(shorter, last, m.shorter, m.last) {
+					m.shorter2, m.last2 = m.shorter, m.last
+					m.shorter, m.last = shorter, last
+				} else if m.shorter2 == nil {
+`)
+	} else {
+		g.addText("\t\t\t\tif m.shorter2 == nil {\n")
+	}
+	g.addText(`					m.shorter2 = shorter
+					m.last2 = last
+				}
+			}
+			return
+		}
+	}
+	m := @_Match{prefix, -1, start, end, shorter, last, nil, nil`)
+	if g.keepAlternatives() {
+		g.addText(", [][2]*@_Match{{shorter, last}}")
+	}
+	if len(g.Options.Weights) > 0 {
+		g.addText(", 0, 0")
+	}
+	g.addText(`}
+	parser.at(end)[prefix] = append(list, &m)
+	parser.todo[end] = append(parser.todo[end], &m)
+}
+`)
+	g.addWeigh()
+	g.addText(`
+func (parser *@_Parser) unexpected(tokens []interface{}, n, end int) error {
+`)
+	if g.Options.UnknownTokens {
+		g.addText(`	if l := gleanerrors.MakeLocation(tokens, n); l.Token != nil && #T(l.Token) == ` +
+			strconv.Itoa(len(g.symbols)) + ` {
+		return gleanerrors.UnknownToken{Location: l}
+	}
+`)
+	}
+	if len(g.Options.DisplayNames) > 0 {
+		g.addText(`	e := gleanerrors.Unexpected{Location: gleanerrors.MakeLocation(tokens, n), Expected: parser.expected(end)}
+	if e.Token != nil {
+		if t := int(#T(e.Token)); t < len(@_displayNames) {
+			e.Name = @_displayNames[t]
+		}
+	}
+	return e
+}
+`)
+	} else {
+		g.addText(`	return gleanerrors.Unexpected{Location: gleanerrors.MakeLocation(tokens, n), Expected: parser.expected(end)}
+}
+`)
+	}
+	g.addText(`
+// The names of the terminal symbols extending some match ending at end,
+// in sorted order
+func (parser *@_Parser) expected(end int) []string {
+	var expected []string
+	for t, exts := range @_extendedBy[:@_terminalCount] {
+		for _, e := range exts {
+			if len(parser.at(end)[e.from]) > 0 {
+				expected = append(expected, @_symbolNames[t])
+				break
+			}
+		}
+	}
+	sort.Strings(expected)
+	return expected
+}
+`)
+	g.addText(`
+func (parser *@_Parser) ambiguous(m1, m2 *@_Match, end int) gleanerrors.Ambiguous {
+`)
+	if g.Options.Scannerless {
+		g.addText("\tvar example []string\n")
+	} else {
+		g.addText(`	var example []string
+	for _, t := range parser.tokens[m1.start:end] {
+		example = append(example, @_symbolNames[#T(t)])
+	}
+`)
+	}
+	g.addText(`	return gleanerrors.Ambiguous{
+		Range:   gleanerrors.MakeRange(parser.tokens, m1.start, m1.end-1),
+		Rule1:   @_ruledesc[@_prefix2rule[m1.completePrefix]],
+		Rule2:   @_ruledesc[@_prefix2rule[m2.completePrefix]],
+		Example: example,
+	}
 }
+`)
+	if g.Options.Ambiguity == AmbiguityError {
+		return
+	}
 
-func @Parse(tokens []interface{}) (#G, error) {
-	var parser @_Parser
-	parser.tokens = tokens
-	return parser.parse()
+	if g.Options.Ambiguity == AmbiguityLongest {
+		g.addLongestMatch()
+		return
+	}
+	compare := ">"
+	if g.Options.Ambiguity == AmbiguityRightmost {
+		compare = "<"
+	}
+	g.addText(`
+func @_preferred(shorter1, last1, shorter2, last2 *@_Match) bool {
+	if shorter1.end != shorter2.end {
+		return shorter1.end ` + compare + ` shorter2.end
+	}
+	return @_prefix2rule[last1.prefix] < @_prefix2rule[last2.prefix]
+}
+`)
 }
 
-func (parser *@_Parser) parse() (#G, error) {
-	// fmt.Fprintln(os.Stderr, parser.tokens)
-	parser.matches = make([]map[@_Prefix][]*@_Match, len(parser.tokens)+1)
-	parser.todo = make([][]*@_Match, len(parser.tokens)+1)
-	for end := range parser.matches {
-		parser.matches[end] = make(map[@_Prefix][]*@_Match)
+// The condition under which findTrace prefers the goal match m to
+// goalmatch, with an Ambiguity policy other than AmbiguityError
+func (g *Grammar) goalPreferred() string {
+	if g.Options.Ambiguity == AmbiguityLongest {
+		return "@_longer(m, goalmatch)"
+	}
+	return "@_prefix2rule[m.prefix] < @_prefix2rule[goalmatch.prefix]"
+}
+
+// Append the main parse function
+func (g *Grammar) addParse() {
+	if g.Options.Registry {
+		g.addText(`
+type @Reducers map[int]func([]interface{}) interface{}
+
+func (r @Reducers) Register(name string, reducer func([]interface{}) interface{}) error {
+	for id, desc := range @_ruledesc[:#R] {
+		if desc.Name == name {
+			r[id] = reducer
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown rule: %s", name)
+}
+`)
+	}
+	if g.Options.Scannerless {
+		g.addText(`
+type @TokenOption struct {
+	Token  interface{}
+	Length int
+}
+`)
+	}
+
+	g.addText(`
+// @Parse keeps the state of each parse in its own @_Parser, and never
+// modifies the tables, so it and the other parse functions may be called
+// from several goroutines at once.
+func @Parse(`)
+	g.addInputParams(true)
+	g.addParseBody(func() { g.addParserInit(true) })
+
+	if g.Options.Debug {
+		g.addText(`
+// @ParseHook parses as @Parse does, but also calls hook as each position of
+// the input is finished, with the number of matches ending at that position.
+func @ParseHook(`)
+		g.addInputParams(true)
+		g.addText(", hook func(position, matchCount int)")
+		g.addParseBody(func() {
+			g.addParserInit(true)
+			g.addText("\tparser.chartHook = hook\n")
+		})
+	}
+
+	if g.Options.Ambiguity != AmbiguityError {
+		g.addText("\nfunc @ParseResolved(")
+		g.addInputParams(true)
+		g.addResults("#G", "int", "error")
+		g.addParserInit(true)
+		g.addCatch("catch", 2)
+		g.addText(`	result, e := parser.parse()
+	return result, parser.resolved, e
+}
+`)
+	}
+
+	if g.Options.Resolutions {
+		g.addText(`
+// @Resolution records an ambiguity resolved by the parser's policy.
+type @Resolution struct {
+	// The tokens matched by both alternatives.
+	Range gleanerrors.Range
+
+	// The rule applied, and the rule discarded, at the point where the
+	// alternatives differ. They are the same rule if it could be applied
+	// to the tokens in more than one way.
+	Chosen, Discarded gleanerrors.Rule
+}
+
+func @ParseResolutions(`)
+		g.addInputParams(true)
+		g.addResults("#G", "[]@Resolution", "error")
+		g.addParserInit(true)
+		g.addCatch("catch", 2)
+		g.addText(`	result, e := parser.parse()
+	return result, parser.resolutions, e
+}
+`)
+	}
+
+	if g.Options.Warnings {
+		g.addText(`
+// @ParseWarnings returns, as well as the result of the parse, a
+// gleanerrors.Ambiguous warning for each ambiguity resolved by the policy.
+func @ParseWarnings(`)
+		g.addInputParams(true)
+		g.addResults("#G", "[]error", "error")
+		g.addParserInit(true)
+		g.addCatch("catch", 2)
+		g.addText(`	result, e := parser.parse()
+	return result, parser.warnings, e
+}
+`)
+	}
+
+	if g.recordResolutions() {
+		g.addText(`
+func (parser *@_Parser) resolve(chosen, discarded *@_Match, end int) {
+	parser.resolved++
+`)
+		if g.Options.Resolutions {
+			g.addText(`	parser.resolutions = append(parser.resolutions, @Resolution{
+		Range:     gleanerrors.MakeRange(parser.tokens, chosen.start, end-1),
+		Chosen:    @_ruledesc[@_prefix2rule[chosen.completePrefix]],
+		Discarded: @_ruledesc[@_prefix2rule[discarded.completePrefix]],
+	})
+`)
+		}
+		if g.Options.Warnings {
+			g.addText("\tparser.warnings = append(parser.warnings, parser.ambiguous(chosen, discarded, end))\n")
+		}
+		g.addText("}\n")
 	}
+}
+
+// Whether the parser records each ambiguity it resolves, rather than just counting them
+func (g *Grammar) recordResolutions() bool {
+	return g.Options.Resolutions || g.Options.Warnings
+}
+
+// Append the results of a parse function, and the opening brace of its body.
+// With CatchPanics, the results are named r0, r1, ... so that a deferred
+// call may set them.
+func (g *Grammar) addResults(results ...string) {
+	g.addText(") (")
+	for n, r := range results {
+		if n > 0 {
+			g.addString(", ")
+		}
+		if g.Options.CatchPanics {
+			g.addf("r%d ", n)
+		}
+		g.addText(r)
+	}
+	g.addText(") {\n")
+}
+
+// Append the deferred call converting a panic into an error, if wanted;
+// method is catch or catchAll, and result is the index of the error result
+func (g *Grammar) addCatch(method string, result int) {
+	if !g.Options.CatchPanics {
+		return
+	}
+	tokens := "tokens"
+	if g.Options.Scannerless || g.Options.TokenInterface != "" {
+		tokens = "parser.tokens"
+	}
+	g.addf("\tdefer parser.%s(%s, &r%d)\n", method, tokens, result)
+}
+
+// Append the methods converting a panic into an error, if wanted
+func (g *Grammar) addCatchMethods() {
+	if !g.Options.CatchPanics {
+		return
+	}
+	g.addText(`
+func (parser *@_Parser) catch(tokens []interface{}, e *error) {
+	if r := recover(); r != nil {
+		*e = gleanerrors.Internal{Recovered: r, Tokens: tokens}
+	}
+}
+`)
+	if g.Options.Recover {
+		g.addText(`
+func (parser *@_Parser) catchAll(tokens []interface{}, errors *[]error) {
+	if r := recover(); r != nil {
+		*errors = append(parser.errors, gleanerrors.Internal{Recovered: r, Tokens: tokens})
+	}
+}
+`)
+	}
+}
+
+// Append the parameters through which a parse function receives its input;
+// reducers tells whether the parse function applies the rules
+func (g *Grammar) addInputParams(reducers bool) {
+	if g.Options.Scannerless {
+		g.addText("length int, next func(pos int) []@TokenOption")
+	} else {
+		g.addText("tokens " + g.tokenSliceType())
+	}
+	g.addOtherParams(reducers)
+}
+
+// Append the parameters of a parse function that follow its input
+func (g *Grammar) addOtherParams(reducers bool) {
+	if reducers && g.Options.Registry {
+		g.addText(", reducers @Reducers")
+	}
+	if reducers && g.Options.Interface {
+		g.addText(", reducer @Reducer")
+	}
+	if g.Options.Classifier {
+		g.addText(", classify func(interface{}) int")
+	}
+	if g.Options.Alternatives {
+		g.addText(", alternatives func(interface{}) []int")
+	}
+	if g.Options.ChartStore {
+		g.addText(", chart @Chart")
+	}
+}
+
+// The type of the tokens passed to the parse functions
+func (g *Grammar) tokenSliceType() string {
+	if g.Options.TokenInterface != "" {
+		return "[]" + g.Options.TokenInterface
+	}
+	return "[]interface{}"
+}
+
+// Append the arguments of a parse function passing its own parameters,
+// from addInputParams, to another
+func (g *Grammar) addInputArgs(reducers bool) {
+	if g.Options.Scannerless {
+		g.addText("length, next")
+	} else {
+		g.addText("tokens")
+	}
+	g.addOtherArgs(reducers)
+}
 
+// Append the arguments, from addOtherParams, that follow the input
+func (g *Grammar) addOtherArgs(reducers bool) {
+	if reducers && g.Options.Registry {
+		g.addText(", reducers")
+	}
+	if reducers && g.Options.Interface {
+		g.addText(", reducer")
+	}
+	if g.Options.Classifier {
+		g.addText(", classify")
+	}
+	if g.Options.Alternatives {
+		g.addText(", alternatives")
+	}
+	if g.Options.ChartStore {
+		g.addText(", chart")
+	}
+}
+
+// Append the results and body of the parse function, or of the Parse
+// method of a reusable parser; init appends the statements creating the
+// parser
+func (g *Grammar) addParseBody(init func()) {
+	if g.Options.PartialInput {
+		g.addResults("#G", g.tokenSliceType(), "error")
+		init()
+		g.addCatch("catch", 2)
+		g.addText(`	result, n, e := parser.parseLongest()
+	if e != nil {
+		return result, nil, e
+	}
+	return result, tokens[n:], nil
+}
+`)
+	} else {
+		g.addResults("#G", "error")
+		init()
+		g.addCatch("catch", 1)
+		g.addText("\treturn parser.parse()\n}\n")
+	}
+}
+
+// Append the statements by which a parse function creates its parser
+func (g *Grammar) addParserInit(reducers bool) {
+	g.addText("\tvar parser @_Parser\n")
+	g.addParserFields(reducers)
+}
+
+// Append the statements setting the fields of a new parser from the
+// parameters of a parse function
+func (g *Grammar) addParserFields(reducers bool) {
+	if g.Options.Scannerless {
+		g.addText(`	parser.tokens = make([]interface{}, length)
+	parser.next = next
+	parser.offered = make([][]@TokenOption, length)
+`)
+	} else if g.Options.TokenInterface != "" {
+		g.addText(`	parser.tokens = make([]interface{}, len(tokens), len(tokens)+1)
+	for n, t := range tokens {
+		parser.tokens[n] = t
+	}
+`)
+		if g.Options.EndSymbol != "" {
+			g.addf("\tvar endToken %s\n", g.Options.EndSymbol)
+			g.addText("\tparser.tokens = append(parser.tokens, endToken)\n")
+		}
+	} else if g.Options.EndSymbol != "" {
+		g.addf("\tvar endToken %s\n", g.Options.EndSymbol)
+		g.addText("\tparser.tokens = append(tokens[:len(tokens):len(tokens)], endToken)\n")
+	} else {
+		g.addText("\tparser.tokens = tokens\n")
+	}
+	if reducers && g.Options.Registry {
+		g.addText("\tparser.reducers = reducers\n")
+	}
+	if reducers && g.Options.Interface {
+		g.addText("\tparser.reducer = reducer\n")
+	}
+	if g.Options.Classifier {
+		g.addText("\tparser.classify = classify\n")
+	}
+	if g.Options.Alternatives {
+		g.addText("\tparser.alternates = alternatives\n")
+	}
+	if g.Options.ChartStore {
+		g.addText(`	parser.chart = chart
+	if parser.chart == nil {
+		parser.chart = new(@MemoryChart)
+	}
+`)
+	}
+}
+
+// Append the method that runs the parser
+func (g *Grammar) addParseMethod() {
+	g.addText(`
+func (parser *@_Parser) parse() (#G, error) {
 	var zero #G
+`)
+	g.addReducerCheck("zero")
+	g.addText(`	if e := parser.match(); e != nil {
+		return zero, e
+	}
+
+	return parser.applyTrace(), nil
+}
+
+func (parser *@_Parser) match() error {
+	parser.prepare()
 	if len(parser.tokens) == 0 {
-		return zero, gleanerrors.NoInput{}
+		return gleanerrors.NoInput{}
 	}
 	if e := parser.findMatches(); e != nil {
-		return zero, e
+		return e
 	}
-	if e := parser.findTrace(); e != nil {
-		return zero, e
+	return parser.findTrace()
+}
+`)
+	g.addPrepare()
+}
+
+// Append the check that a reducer is registered for every rule, if wanted;
+// zeros gives the results returned before the error
+func (g *Grammar) addReducerCheck(zeros string) {
+	if g.Options.Registry && !g.Options.Unchecked {
+		g.addText(`	for id, desc := range @_ruledesc[:#R] {
+		if parser.reducers[id] == nil {
+			return ` + zeros + `, gleanerrors.MissingReducer{Rule: desc}
+		}
+	}
+`)
+	}
+}
+
+// Append the function measuring the valid prefix of the input, if requested
+func (g *Grammar) addValidPrefix() {
+	if !g.Options.ValidPrefix {
+		return
+	}
+	g.addText("\nfunc @ValidPrefix(")
+	g.addInputParams(false)
+	g.addText(") int {\n")
+	g.addParserInit(false)
+	g.addText(`	parser.prepare()
+	if e, ok := parser.findMatches().(gleanerrors.Unexpected); ok {
+		return e.Index
+	}
+	return len(parser.tokens)
+}
+`)
+}
+
+// Append the function to find all matches of rule prefixes to the input
+func (g *Grammar) addFindMatches() {
+	if g.Options.Stepping {
+		g.addText(`
+func (parser *@_Parser) findMatches() error {
+	parser.addMatch(#g, 0, 0, nil, nil)
+	for end := range parser.todo {
+		if e := parser.step(end); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// Find the matches ending at end, given those ending before it
+func (parser *@_Parser) step(end int) error {
+	savePrefixes := parser.endPrefixes[:0]
+`)
+		for _, line := range strings.SplitAfter(g.capture(g.addPositionMatches), "\n") {
+			g.addString(strings.TrimPrefix(line, "\t"))
+		}
+		g.addText(`	parser.endPrefixes = savePrefixes
+	return nil
+}
+`)
+		return
+	}
+
+	if !g.Options.Scannerless {
+		g.addText(`
+func (parser *@_Parser) findMatches() error {
+	parser.addMatch(#g, 0, 0, nil, nil)
+	var savePrefixes []@_Prefix
+`)
+		if g.Options.Recover {
+			// Skipping tokens shortens parser.todo
+			g.addText("\tfor end := 0; end < len(parser.todo); end++ {\n")
+		} else {
+			g.addText("\tfor end := range parser.todo {\n")
+		}
+		g.addText("\t\tsavePrefixes = savePrefixes[:0]\n")
+		g.addPositionMatches()
+		g.addText(`	}
+	parser.endPrefixes = savePrefixes
+	return nil
+}
+`)
+		return
+	}
+
+	g.addText(`
+func (parser *@_Parser) findMatches() error {
+	parser.addMatch(#g, 0, 0, nil, nil)
+	var savePrefixes []@_Prefix
+	furthest := 0
+	for end := range parser.todo {
+		if end > furthest {
+			return parser.unexpected(parser.tokens, furthest, furthest)
+		}
+		if len(parser.todo[end]) == 0 {
+			continue
+		}
+		savePrefixes = savePrefixes[:0]
+		for p := range parser.at(end) {
+			savePrefixes = append(savePrefixes, p)
+		}
+
+		for k := 0; k < len(parser.todo[end]); k++ {
+			t := parser.todo[end][k]
+			for _, p := range @_followers[t.prefix] {
+				parser.addMatch(p, end, end, nil, nil)
+			}
+			for _, e := range @_extensions[t.prefix] {
+				if list, have := parser.at(end)[e.by]; have {
+					for _, m := range list {
+						if m.start == end {
+							parser.addMatch(e.to, t.start, end, t, m)
+							break
+						}
+					}
+				}
+			}
+			if s := @_symbolFinished[t.prefix]; s >= 0 {
+				for _, e := range @_extendedBy[s] {
+					if list, have := parser.at(t.start)[e.from]; have {
+						for _, m := range list {
+							parser.addMatch(e.to, m.start, end, m, t)
+						}
+					}
+				}
+			}
+		}
+`)
+	g.addChartHook()
+	g.addText(`
+		if end == len(parser.tokens) {
+			break
+		}
+		offered := parser.next(end)
+		parser.offered[end] = offered
+		if len(offered) > 0 {
+			parser.tokens[end] = offered[0].Token
+		}
+		for _, o := range offered {
+`)
+	if !g.Options.Unchecked {
+		g.addText(`			if o.Length < 1 || o.Length > len(parser.tokens)-end {
+				panic(fmt.Sprintf("token option at position %d has invalid length %d", end, o.Length))
+			}
+`)
 	}
-
-	return parser.applyTrace(), nil
-}
-
-func (parser *@_Parser) addMatch(prefix @_Prefix, start, end int, shorter, last *@_Match) {
-	list := parser.matches[end][prefix]
-	for _, m := range list {
-		if m.start == start {
-			if m.shorter != shorter || m.last != last {
-				if m.shorter2 == nil {
-					m.shorter2 = shorter
-					m.last2 = last
+	g.addText(`			token := #T(o.Token)
+			for _, e := range @_extendedBy[token] {
+				if list, have := parser.at(end)[e.from]; have {
+					for _, m := range list {
+						parser.addMatch(e.to, m.start, end+o.Length, m, nil)
+						if end+o.Length > furthest {
+							furthest = end + o.Length
+						}
+					}
 				}
 			}
-			return
 		}
 	}
-	m := @_Match{prefix, -1, start, end, shorter, last, nil, nil}
-	parser.matches[end][prefix] = append(list, &m)
-	parser.todo[end] = append(parser.todo[end], &m)
+	parser.endPrefixes = savePrefixes
+	return nil
+}
+`)
 }
 
-func (parser *@_Parser) findMatches() error {
-	parser.addMatch(#g, 0, 0, nil, nil)
-	var savePrefixes []@_Prefix
-	for end := range parser.todo {
-		savePrefixes = savePrefixes[:0]
-		for p := range parser.matches[end] {
+// Append the statements finding the matches ending at position end, in the
+// loop of findMatches, to the check that they leave a token unexpected
+func (g *Grammar) addPositionMatches() {
+	g.addText(`		for p := range parser.at(end) {
 			savePrefixes = append(savePrefixes, p)
 		}
 
-		var token @_Symbol = -1
+`)
+	if g.Options.Alternatives {
+		g.addText(`		var tokens []@_Symbol
 		if end < len(parser.tokens) {
-			token = @_tokenType(parser.tokens[end])
+			tokens = parser.tokenTypes(parser.tokens[end])
 		}
-		for k := 0; k < len(parser.todo[end]); k++ {
+`)
+	} else {
+		g.addText(`		var token @_Symbol = -1
+		if end < len(parser.tokens) {
+			token = #T(parser.tokens[end])
+		}
+`)
+	}
+	g.addText(`		for k := 0; k < len(parser.todo[end]); k++ {
 			t := parser.todo[end][k]
 			for _, p := range @_followers[t.prefix] {
 				parser.addMatch(p, end, end, nil, nil)
 			}
 			for _, e := range @_extensions[t.prefix] {
-				if list, have := parser.matches[end][e.by]; have {
+				if list, have := parser.at(end)[e.by]; have` + g.followsCondition("e.by") + ` {
 					for _, m := range list {
 						if m.start == end {
 							parser.addMatch(e.to, t.start, end, t, m)
@@ -362,18 +1553,25 @@ func (parser *@_Parser) findMatches() error {
 					}
 				}
 			}
-			if s := @_symbolFinished[t.prefix]; s >= 0 {
+			if s := @_symbolFinished[t.prefix]; s >= 0` + g.followsCondition("t.prefix") + ` {
 				for _, e := range @_extendedBy[s] {
-					if list, have := parser.matches[t.start][e.from]; have {
+					if list, have := parser.at(t.start)[e.from]; have {
 						for _, m := range list {
 							parser.addMatch(e.to, m.start, end, m, t)
 						}
 					}
 				}
 			}
-			if token >= 0 {
-				for _, e := range @_extendedBy[token] {
-					if list, have := parser.matches[end][e.from]; have {
+`)
+	if g.Options.Alternatives {
+		g.addText(`			for _, token := range tokens {
+`)
+	} else {
+		g.addText(`			if token >= 0 {
+`)
+	}
+	g.addText(`				for _, e := range @_extendedBy[token] {
+					if list, have := parser.at(end)[e.from]; have {
 						for _, m := range list {
 							parser.addMatch(e.to, m.start, end+1, m, nil)
 						}
@@ -381,52 +1579,160 @@ func (parser *@_Parser) findMatches() error {
 				}
 			}
 		}
-		if token >= 0 && len(parser.todo[end+1]) == 0 {
-			return gleanerrors.Unexpected{gleanerrors.MakeLocation(parser.tokens, end)}
+`)
+	g.addChartHook()
+	if g.Options.Alternatives {
+		g.addText(`		if len(tokens) > 0 && len(parser.todo[end+1]) == 0 {
+`)
+	} else {
+		g.addText(`		if token >= 0 && len(parser.todo[end+1]) == 0 {
+`)
+	}
+	if g.Options.Recover {
+		if g.Options.EndSymbol != "" {
+			// Never skip the end token
+			g.addText("\t\t\tif !parser.recovering || end == len(parser.tokens)-1 {\n")
+		} else {
+			g.addText("\t\t\tif !parser.recovering {\n")
 		}
+		g.addText(`				return parser.unexpected(parser.tokens, end, end)
+			}
+			if e := parser.skipToken(end); e != nil {
+				return e
+			}
+			end-- // try again with the next token
+`)
+	} else {
+		g.addText(`			return parser.unexpected(parser.tokens, end, end)
+`)
 	}
-	parser.endPrefixes = savePrefixes
-	return nil
+	g.addText("\t\t}\n")
 }
 
-func (parser *@_Parser) ambiguous(m1, m2 *@_Match) error {
-	return gleanerrors.Ambiguous{
-		gleanerrors.MakeRange(parser.tokens, m1.start, m1.end-1),
-		@_ruledesc[@_prefix2rule[m1.completePrefix]],
-		@_ruledesc[@_prefix2rule[m2.completePrefix]],
+// Run f, returning the parser text it writes rather than appending it
+func (g *Grammar) capture(f func()) string {
+	saved := g.builder
+	b := new(strings.Builder)
+	g.builder = b
+	f()
+	g.builder = saved
+	return b.String()
+}
+
+// Append the call of the chart hook, if wanted, at the end of a position
+func (g *Grammar) addChartHook() {
+	if g.Options.Debug {
+		g.addText(`		if parser.chartHook != nil {
+			parser.chartHook(end, len(parser.todo[end]))
+		}
+`)
 	}
 }
 
+// Whether findTrace also records the trace as prefix and terminal ids,
+// for ParseConcurrent and ParseTrace
+func (g *Grammar) recordSteps() bool {
+	return g.Options.Concurrent || g.Options.ParseTrace
+}
+
+// Append the function to find the trace of rules to apply
+func (g *Grammar) addFindTrace() {
+	g.addText(`
 func (parser *@_Parser) findTrace() error {
 	n := len(parser.tokens)
 	var goalmatch *@_Match
-	for _, p := range @_goalPrefixes {
-		if list, have := parser.matches[n][p]; have {
+`)
+	if len(g.Options.Weights) > 0 {
+		g.addText("\tvar tied *@_Match\n")
+	}
+	if g.Options.Ambiguity == AmbiguityError {
+		g.addText("\tvar ambiguities []gleanerrors.Ambiguous\n")
+	}
+	g.addText(`	for _, p := range @_goalPrefixes {
+		if list, have := parser.at(n)[p]; have {
 			for _, m := range list {
 				if m.start == 0 {
 					m.completePrefix = m.prefix
 					if goalmatch == nil {
 						goalmatch = m
+`)
+	if g.prec != nil {
+		g.addText(`					} else if o := @_outranks(m, goalmatch); o != 0 {
+						if o > 0 {
+							goalmatch = m
+						}
+`)
+	}
+	if g.Options.FirstRule {
+		g.addText(`					} else if @_prefix2rule[m.prefix] < @_prefix2rule[goalmatch.prefix] {
+						goalmatch = m
+`)
+	} else if len(g.Options.Weights) > 0 {
+		g.addText(`					} else if w := parser.goalWeight(m); w < parser.goalWeight(goalmatch) {
+						goalmatch, tied = m, nil
+					} else if w == parser.goalWeight(goalmatch) {
+						tied = m
+`)
+	} else if g.Options.Ambiguity != AmbiguityError {
+		if g.recordResolutions() {
+			g.addText(`					} else if ` + g.goalPreferred() + ` {
+						parser.resolve(m, goalmatch, n)
+						goalmatch = m
 					} else {
-						return parser.ambiguous(goalmatch, m)
-					}
+						parser.resolve(goalmatch, m, n)
+`)
+		} else {
+			g.addText(`					} else {
+						parser.resolved++
+						if ` + g.goalPreferred() + ` {
+							goalmatch = m
+						}
+`)
+		}
+	} else {
+		g.addText(`					} else {
+						ambiguities = append(ambiguities, parser.ambiguous(goalmatch, m, n))
+`)
+	}
+	g.addText(`					}
 					break
 				}
 			}
 		}
 	}
 	if goalmatch == nil {
-		return gleanerrors.Unexpected{gleanerrors.Location{len(parser.tokens), nil}}
+		return parser.unexpected(parser.tokens, len(parser.tokens), len(parser.tokens))
 	}
-
+`)
+	if len(g.Options.Weights) > 0 {
+		g.addText(`	parser.weigh(goalmatch)
+	if tied != nil {
+		ambiguities = append(ambiguities, parser.ambiguous(goalmatch, tied, n))
+	}
+`)
+	}
+	g.addText(`
 	parser.trace = parser.trace[:0]
 	parser.trace = append(parser.trace, @_appliers[goalmatch.prefix])
-
+`)
+	if g.recordSteps() {
+		g.addText(`	parser.steps = parser.steps[:0]
+	parser.steps = append(parser.steps, int(goalmatch.prefix))
+`)
+	}
+	if g.Options.Tree || g.Options.Events {
+		g.addText("\tparser.goalmatch = goalmatch\n")
+	}
+	g.addText(`
 	var stack []*@_Match
+	var ends []int // the end of the complete match containing each match in stack
 	stack = append(stack, goalmatch)
+	ends = append(ends, n)
 	for len(stack) > 0 {
 		m := stack[len(stack)-1]
 		stack = stack[:len(stack)-1]
+		end := ends[len(ends)-1]
+		ends = ends[:len(ends)-1]
 
 		if m.shorter != nil {
 			m.shorter.completePrefix = m.completePrefix
@@ -440,58 +1746,216 @@ func (parser *@_Parser) findTrace() error {
 		if m.last2 != nil {
 			m.last2.completePrefix = m.last2.prefix
 		}
-
+`)
+	if g.Options.Ambiguity == AmbiguityLongest {
+		// The matches of the last item may have gained alternatives after
+		// addMatch compared them, so compare them again.
+		g.addText(`		if m.last2 != nil && m.shorter2 == m.shorter && !@_longer(m.last, m.last2) {
+			m.last, m.last2 = m.last2, m.last
+		}
+`)
+	}
+	g.addText(`
 		if m.shorter2 != nil || m.last2 != nil {
-			if m.shorter2 != nil && m.shorter2 != m.shorter {
-				return parser.ambiguous(m, m)
+`)
+	if g.recordResolutions() {
+		g.addText(`			if m.shorter2 != nil && m.shorter2 != m.shorter {
+				parser.resolve(m, m, end)
+			} else {
+				parser.resolve(m.last, m.last2, m.last.end)
 			}
-			if m.last2 == nil || m.last2 == m.last {
+`)
+	} else if g.Options.Ambiguity != AmbiguityError {
+		g.addText("\t\t\tparser.resolved++\n")
+	} else {
+		g.addText(`			if m.shorter2 != nil && m.shorter2 != m.shorter {
+				ambiguities = append(ambiguities, parser.ambiguous(m, m, end))
+			} else if m.last2 == nil || m.last2 == m.last {
 				panic("bug")
+			} else {
+				ambiguities = append(ambiguities, parser.ambiguous(m.last, m.last2, m.last.end))
 			}
-			return parser.ambiguous(m.last, m.last2)
-		}
+`)
+	}
+	g.addText(`		}
 
 		if m.shorter != nil {
 			m.shorter.completePrefix = m.completePrefix
 			stack = append(stack, m.shorter)
+			ends = append(ends, end)
 		}
 		if m.last != nil {
 			parser.trace = append(parser.trace, @_appliers[m.last.prefix])
-			stack = append(stack, m.last)
+`)
+	if g.recordSteps() {
+		g.addText("\t\t\tparser.steps = append(parser.steps, int(m.last.prefix))\n")
+	}
+	g.addText(`			stack = append(stack, m.last)
+			ends = append(ends, m.last.end)
 		} else {
 			t := @_lastTerminal[m.prefix]
 			if t >= 0 {
 				parser.trace = append(parser.trace, @_applyTerminal[t])
-			}
+`)
+	if g.recordSteps() {
+		g.addText("\t\t\t\tparser.steps = append(parser.steps, -1-int(t))\n")
+	}
+	if g.Options.Scannerless {
+		g.addText(`				parser.chosen = append(parser.chosen, parser.chooseToken(t, m.shorter.end, m.end))
+`)
+	}
+	g.addText(`			}
 		}
 	}
-
+`)
+	if g.Options.Ambiguity == AmbiguityError {
+		g.addText(`
+	if len(ambiguities) == 1 {
+		return ambiguities[0]
+	} else if len(ambiguities) > 1 {
+		return gleanerrors.MultiAmbiguous{Ambiguities: ambiguities}
+	}
+`)
+	}
+	if g.Options.Scannerless {
+		g.addText(`
+	parser.tokens = parser.tokens[:0]
+	for n := len(parser.chosen) - 1; n >= 0; n-- {
+		parser.tokens = append(parser.tokens, parser.chosen[n])
+	}
+`)
+	}
+	g.addText(`
 	return nil
 }
-`
+`)
+	if g.Options.Scannerless {
+		g.addText(`
+func (parser *@_Parser) chooseToken(t @_Symbol, start, end int) interface{} {
+	for _, o := range parser.offered[start] {
+		if o.Length == end-start && #T(o.Token) == t {
+			return o.Token
+		}
+	}
+	panic("bug")
+}
+`)
+	}
+}
+
+// The Go type used for the values of a symbol in the parser
+func (g *Grammar) valueType(s *symbol) string {
+	if s.optional {
+		return "*" + g.valueType(s.element)
+	}
+	if s.element != nil {
+		return "[]" + g.valueType(s.element)
+	}
+	if g.Options.Registry && !s.isTerminal() {
+		return "interface{}"
+	}
+	return string(s.name)
+}
 
 // Append the parser type
 func (g *Grammar) addParserType() {
 	g.addText(`
 type @_Parser struct {
 	tokens      []interface{}
-	matches     []map[@_Prefix][]*@_Match
-	todo        [][]*@_Match
+`)
+	if g.Options.ChartStore {
+		g.addText("\tchart       @Chart\n")
+	} else {
+		g.addText("\tmatches     []map[@_Prefix][]*@_Match\n")
+	}
+	g.addText(`	todo        [][]*@_Match
 	trace       []func(*@_Parser)
 	tokensUsed  int
 	endPrefixes []@_Prefix
-
 `)
+	if g.Options.Registry {
+		g.addText("\treducers    @Reducers\n")
+	}
+	if g.Options.Interface {
+		g.addText("\treducer     @Reducer\n")
+	}
+	if g.Options.Ambiguity != AmbiguityError {
+		g.addText("\tresolved    int\n")
+	}
+	if g.Options.Resolutions {
+		g.addText("\tresolutions []@Resolution\n")
+	}
+	if g.Options.Warnings {
+		g.addText("\twarnings    []error\n")
+	}
+	if g.Options.Classifier {
+		g.addText("\tclassify    func(interface{}) int\n")
+	}
+	if g.Options.Alternatives {
+		g.addText("\talternates  func(interface{}) []int\n")
+	}
+	if g.Options.Tree || g.Options.Events {
+		g.addText("\tgoalmatch   *@_Match\n")
+	}
+	if g.recordSteps() {
+		g.addText("\tsteps       []int\n")
+	}
+	if g.Options.ParseAll {
+		g.addText("\tderiving    map[@_Span]bool\n")
+	}
+	if g.Options.Recover {
+		g.addText(`	input       []interface{}
+	original    []int
+	recovering  bool
+	maxErrors   int
+	errors      []error
+	lastSkipped int
+`)
+		if g.Options.ErrorSink {
+			g.addText("\tsink        func(error)\n")
+		}
+	}
+	if g.Options.Debug {
+		g.addText("\tchartHook   func(position, matchCount int)\n")
+	}
+	if g.Options.Scannerless {
+		g.addText(`	next        func(int) []@TokenOption
+	offered     [][]@TokenOption
+	chosen      []interface{}
+`)
+	}
+	g.addString("\n")
 	maxLen := 0
 	for _, s := range g.symbols {
-		if l := len(s.name); l > maxLen {
+		if l := len(s.stackName()); l > maxLen {
 			maxLen = l
 		}
 	}
 	for _, s := range g.symbols {
-		g.addf("\tstack%-*s []%s\n", maxLen, s.name, s.name)
+		if g.Options.GenericStacks {
+			g.addf("\t%-*s %s_Stack[%s]\n", maxLen, s.stackName(), g.prepend, g.valueType(s))
+		} else {
+			g.addf("\t%-*s []%s\n", maxLen, s.stackName(), g.valueType(s))
+		}
 	}
+	g.addPoolFields()
 	g.addString("}\n")
+
+	if g.Options.GenericStacks {
+		g.addText(`
+type @_Stack[T any] []T
+
+func (s *@_Stack[T]) push(x T) {
+	*s = append(*s, x)
+}
+
+func (s *@_Stack[T]) pop() T {
+	x := (*s)[len(*s)-1]
+	*s = (*s)[:len(*s)-1]
+	return x
+}
+`)
+	}
 }
 
 // Append the function to apply the trace
@@ -501,13 +1965,14 @@ func (parser *@_Parser) applyTrace() #G {
 	parser.tokensUsed = 0
 `)
 	for _, s := range g.nonterminals {
-		g.addf("\tparser.stack%s = parser.stack%s[:0]\n", s.name, s.name)
+		g.addf("\tparser.%s = parser.%s[:0]\n", s.stackName(), s.stackName())
 	}
+	g.addPoolResets()
 	g.addText(`
 	for n := len(parser.trace) - 1; n >= 0; n-- {
 		parser.trace[n](parser)
 	}
-	return parser.stack#G[0]
+	return parser.stack#S[0]
 }
 `)
 }
@@ -574,7 +2039,14 @@ var @_extendedBy = [][]@_ExtBy{
 		}
 		g.addString("},\n")
 	}
+	if g.Options.Classifier {
+		g.addString("\t{}, // tokens not classified as terminals\n")
+	}
+	if g.Options.UnknownTokens {
+		g.addString("\t{}, // tokens not of terminal types\n")
+	}
 	g.addString("}\n")
+	g.addText(fmt.Sprintf("\nconst @_terminalCount = %d\n", len(g.terminals)))
 }
 
 // For each prefix, write its extensions by nonterminal symbols
@@ -626,15 +2098,121 @@ func (g *Grammar) addSymbolFinished() {
 	g.addString("}\n")
 }
 
+// Add the length of each prefix, if needed by Stepping or Explain
+func (g *Grammar) addPrefixLengths() {
+	if !g.Options.Stepping && !g.Options.Explain && !g.Options.Concurrent {
+		return
+	}
+
+	g.addText("\nvar @_prefixLength = []int{\n")
+	for _, p := range g.prefixes {
+		g.addf("\t%d,\n", p.length)
+	}
+	g.addString("}\n")
+}
+
+// Add the constants giving the ids of the terminal symbols
+func (g *Grammar) addTerminalConstants() {
+	if g.Options.TypeIds && g.Options.TokenInterface == "" {
+		g.addText(`
+// The type of each terminal symbol must have a method TypeId() int,
+// returning the constant below for the symbol.`)
+	}
+	g.addText("\nconst (\n")
+	maxLen := 0
+	for _, s := range g.terminals {
+		if l := len(s.identifier()); l > maxLen {
+			maxLen = l
+		}
+	}
+	for _, s := range g.terminals {
+		g.addf("\t%sTerminal%-*s = %d\n", g.prepend, maxLen, s.identifier(), s.id)
+	}
+	g.addText(")\n")
+}
+
 // Add the function to determine a terminal's symbol id
 func (g *Grammar) addTokenType() {
+	if g.Options.TokenInterface != "" || g.Options.TypeIds {
+		iface := g.Options.TokenInterface
+		if iface == "" {
+			iface = "interface{ TypeId() int }"
+		}
+		g.addTerminalConstants()
+		g.addText("\nfunc @_tokenType(t interface{}) @_Symbol {\n")
+		if g.Options.Unchecked {
+			g.addText("\treturn @_Symbol(t.(" + iface + ").TypeId())\n}\n")
+			return
+		}
+		g.addf("\tif token, ok := t.(%s); ok {\n", iface)
+		g.addf("\t\tif id := token.TypeId(); id >= 0 && id < %d {\n", len(g.terminals))
+		g.addText(`			return @_Symbol(id)
+		}
+	}
+`)
+		if g.Options.UnknownTokens {
+			g.addf("\treturn %d\n}\n", len(g.symbols))
+		} else {
+			g.addText(`	panic(fmt.Sprintf("input token (type %T) is not a terminal symbol", t))
+}
+`)
+		}
+		return
+	}
+
+	if g.Options.Classifier {
+		g.addTerminalConstants()
+		g.addText(`
+func (parser *@_Parser) tokenType(t interface{}) @_Symbol {
+`)
+		if g.Options.Unchecked {
+			g.addText("\treturn @_Symbol(parser.classify(t))\n}\n")
+		} else {
+			g.addf("\tif id := parser.classify(t); id >= 0 && id < %d {\n", len(g.terminals))
+			g.addText("\t\treturn @_Symbol(id)\n\t}\n")
+			g.addf("\treturn %d\n}\n", len(g.symbols))
+		}
+		if g.Options.Alternatives {
+			g.addText(`
+func (parser *@_Parser) tokenTypes(t interface{}) []@_Symbol {
+	types := []@_Symbol{parser.tokenType(t)}
+	for _, id := range parser.alternates(t) {
+`)
+			if g.Options.Unchecked {
+				g.addText("\t\ttypes = append(types, @_Symbol(id))\n")
+			} else {
+				g.addf("\t\tif id >= 0 && id < %d {\n", len(g.terminals))
+				g.addText("\t\t\ttypes = append(types, @_Symbol(id))\n\t\t}\n")
+			}
+			g.addText(`	}
+	return types
+}
+`)
+		}
+		return
+	}
+
+	if len(g.Options.Kinds) > 0 {
+		g.addKindTokenType()
+		return
+	}
+
 	g.addText(`
 func @_tokenType(t interface{}) @_Symbol {
 	switch t.(type) {
 `)
-	for _, s := range g.terminals {
+	for n, s := range g.terminals {
+		if g.Options.Unchecked && n == len(g.terminals)-1 {
+			// Any token not of the other types is taken to be of this one.
+			g.addf("\tdefault: // %s\n\t\treturn %d\n\t}\n}\n", s.name, s.id)
+			return
+		}
 		g.addf("\tcase %s:\n\t\treturn %d\n", s.name, s.id)
 	}
+	if g.Options.UnknownTokens {
+		g.addf("\tdefault:\n\t\treturn %d\n\t}\n}\n", len(g.symbols))
+		return
+	}
 	g.addString(
 		`	default:
 		panic(fmt.Sprintf("input token (type %T) is not a terminal symbol", t))
@@ -647,7 +2225,9 @@ func @_tokenType(t interface{}) @_Symbol {
 func (g *Grammar) addGoalPrefixes() {
 	g.addText("\nvar @_goalPrefixes = []@_Prefix{\n")
 	for _, r := range g.goal.rules {
-		g.addf("\t%d,\n", r.fullPrefix.id)
+		if g.lookahead(r) < 0 { // no token follows the goal
+			g.addf("\t%d,\n", r.fullPrefix.id)
+		}
 	}
 	g.addString("}\n")
 }
@@ -657,14 +2237,37 @@ func (g *Grammar) addApplyTerminal() {
 	g.addText("\nvar @_applyTerminal = []func(*@_Parser){\n")
 	for _, t := range g.terminals {
 		g.addText("\tfunc(parser *@_Parser) {\n")
-		stack := "parser.stack" + t.name
-		g.addf("\t\t%s = append(%s, parser.tokens[parser.tokensUsed].(%s))\n", stack, stack, t.name)
+		if g.interned(t) {
+			g.addPushInterned(t, "parser.tokens[parser.tokensUsed]")
+		} else {
+			g.addPush(t, fmt.Sprintf("parser.tokens[parser.tokensUsed].(%s)", t.name))
+		}
 		g.addf("\t\tparser.tokensUsed++\n")
 		g.addString("\t},\n")
 	}
 	g.addString("}\n")
 }
 
+// Add a statement pushing a value onto a symbol's stack, inside an applier
+func (g *Grammar) addPush(s *symbol, value string) {
+	if g.Options.GenericStacks {
+		g.addf("\t\tparser.%s.push(%s)\n", s.stackName(), value)
+	} else {
+		g.addf("\t\tparser.%s = append(parser.%s, %s)\n", s.stackName(), s.stackName(), value)
+	}
+}
+
+// Add statements popping a value from a symbol's stack into a new variable, inside an applier
+func (g *Grammar) addPop(variable string, s *symbol) {
+	if g.Options.GenericStacks {
+		g.addf("\t\t%s := parser.%s.pop()\n", variable, s.stackName())
+	} else {
+		n := s.stackName()
+		g.addf("\t\t%s := parser.%s[len(parser.%s)-1]\n", variable, n, n)
+		g.addf("\t\tparser.%s = parser.%s[:len(parser.%s)-1]\n", n, n, n)
+	}
+}
+
 // Add the functions to apply rules
 func (g *Grammar) addAppliers() {
 	g.addText("\nvar @_appliers = []func(*@_Parser){\n")
@@ -677,19 +2280,47 @@ func (g *Grammar) addAppliers() {
 		g.addText("\tfunc(parser *@_Parser) {\n")
 
 		for n := len(r.items) - 1; n >= 0; n-- {
-			s := r.items[n]
-			g.addf("\t\tx%d := parser.stack%s[len(parser.stack%s)-1]\n", n, s.name, s.name)
-			g.addf("\t\tparser.stack%s = parser.stack%s[:len(parser.stack%s)-1]\n", s.name, s.name, s.name)
+			g.addPop(fmt.Sprintf("x%d", n), r.items[n])
+		}
+		if r.target.optional {
+			if len(r.items) == 0 && r.target.optionalList() {
+				g.addPush(r.target, fmt.Sprintf("new(%s)", g.valueType(r.target.element)))
+			} else if len(r.items) == 0 {
+				g.addPush(r.target, "nil")
+			} else {
+				g.addPush(r.target, "&x0")
+			}
+			g.addString("\t},\n")
+			continue
+		}
+		if r.target.element != nil {
+			if len(r.items) == 1 {
+				g.addPush(r.target, fmt.Sprintf("%s{x0}", g.valueType(r.target)))
+			} else {
+				g.addPush(r.target, "append(x0, x1)")
+			}
+			g.addString("\t},\n")
+			continue
+		}
+		if g.Options.Registry {
+			g.addf("\t\ty := parser.reducers[%d]([]interface{}{", r.id)
+		} else if g.Options.Interface {
+			g.addf("\t\ty := parser.reducer.%s(", r.methodName())
+		} else {
+			g.addf("\t\ty := %s(", r.reducer)
 		}
-		g.addf("\t\ty := %s(", r.name)
 		if len(r.items) > 0 {
 			g.addString("x0")
 			for n := 1; n < len(r.items); n++ {
 				g.addf(", x%d", n)
 			}
 		}
-		g.addString(")\n")
-		g.addf("\t\tparser.stack%s = append(parser.stack%s, y)\n", r.target.name, r.target.name)
+		if g.Options.Registry {
+			g.addString("})\n")
+		} else {
+			g.addString(")\n")
+		}
+		g.addPush(r.target, "y")
 
 		g.addString("\t},\n")
 	}
@@ -714,9 +2345,9 @@ func (g *Grammar) addRuleDescriptions() {
 	g.addText(`
 var @_ruledesc = []gleanerrors.Rule{
 `)
-	for _, r := range g.rules {
+	for _, r := range append(g.rules[:len(g.rules):len(g.rules)], g.listRules...) {
 		g.addText("\tgleanerrors.Rule{")
-		g.addf("\"%s\", \"%s\", []string{", r.name, r.target.name)
+		g.addf("Name: \"%s\", Target: \"%s\", Items: []string{", r.name, r.target.name)
 		for n, i := range r.items {
 			if n > 0 {
 				g.addString(", ")
@@ -727,3 +2358,93 @@ var @_ruledesc = []gleanerrors.Rule{
 	}
 	g.addString("}\n")
 }
+
+// Add the display names of the terminal symbols, if any were given
+func (g *Grammar) addDisplayNames() {
+	if len(g.Options.DisplayNames) == 0 {
+		return
+	}
+	g.addText("\nvar @_displayNames = []string{\n")
+	for _, s := range g.terminals {
+		g.addf("\t%q,\n", g.Options.DisplayNames[s.name])
+	}
+	g.addString("}\n")
+}
+
+// Whether each match keeps all the alternatives from which it was made
+func (g *Grammar) keepAlternatives() bool {
+	return len(g.Options.Weights) > 0 || g.Options.ParseAll
+}
+
+// Append the functions choosing the derivations of least weight, if rules have weights
+func (g *Grammar) addWeigh() {
+	if len(g.Options.Weights) == 0 {
+		return
+	}
+	g.addText(`
+// weigh finds the least total weight of the rules applied in a derivation
+// of a match, and makes the alternative giving it the match's shorter and
+// last. If another alternative gives the same weight, or might through a
+// cycle of matches, it is kept in shorter2 and last2, so the match is
+// reported as ambiguous if it is used. It returns -1 if every alternative
+// is in a cycle.
+func (parser *@_Parser) weigh(m *@_Match) int {
+	if m.weighed == 2 {
+		return m.weight
+	}
+	m.weighed = 1
+	best := -1
+	var cycle *[2]*@_Match
+	m.shorter2, m.last2 = nil, nil
+	for n, a := range m.alternatives {
+		w := 0
+		if a[0] != nil {
+			if a[0].weighed == 1 || parser.weigh(a[0]) < 0 {
+				cycle = &m.alternatives[n]
+				continue
+			}
+			w += a[0].weight
+		}
+		if a[1] != nil {
+			if a[1].weighed == 1 || parser.weigh(a[1]) < 0 {
+				cycle = &m.alternatives[n]
+				continue
+			}
+			w += a[1].weight + @_weights[@_prefix2rule[a[1].prefix]]
+		}
+		if best < 0 || w < best {
+			best = w
+			m.shorter, m.last = a[0], a[1]
+			m.shorter2, m.last2 = nil, nil
+		} else if w == best {
+			m.shorter2, m.last2 = a[0], a[1]
+		}
+	}
+	if cycle != nil && m.shorter2 == nil {
+		m.shorter2, m.last2 = cycle[0], cycle[1]
+	}
+	m.weight = best
+	m.weighed = 2
+	return best
+}
+
+func (parser *@_Parser) goalWeight(m *@_Match) int {
+	return parser.weigh(m) + @_weights[@_prefix2rule[m.prefix]]
+}
+`)
+}
+
+// Add the weights of the rules, if any were given
+func (g *Grammar) addWeights() {
+	if len(g.Options.Weights) == 0 {
+		return
+	}
+	g.addText("\nvar @_weights = []int{\n")
+	for _, r := range g.rules {
+		g.addf("\t%d, // %s\n", g.Options.Weights[r.name], r.name)
+	}
+	for _, r := range g.listRules {
+		g.addf("\t0, // %s\n", r.name)
+	}
+	g.addString("}\n")
+}
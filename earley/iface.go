@@ -0,0 +1,38 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"strings"
+)
+
+// The name of the method of the Reducer interface applying a rule
+func (r *rule) methodName() string {
+	return strings.Replace(r.name, ".", "_", 1)
+}
+
+// Append the interface through which the parser applies the rules, if requested
+func (g *Grammar) addReducerInterface() {
+	if !g.Options.Interface {
+		return
+	}
+
+	g.addText(`
+// @Reducer computes the values of the rules of the grammar, with one
+// method for each rule, named by the rule. The parse functions call its
+// methods in place of the rule functions.
+type @Reducer interface {
+`)
+	for _, r := range g.rules {
+		g.addf("\t%s(", r.methodName())
+		for n, i := range r.items {
+			if n > 0 {
+				g.addString(", ")
+			}
+			g.addString(g.valueType(i))
+		}
+		g.addf(") %s\n", g.valueType(r.target))
+	}
+	g.addString("}\n")
+}
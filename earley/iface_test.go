@@ -0,0 +1,73 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test applying the rules through a generated interface
+func TestInterface(t *testing.T) {
+	var options earley.Options
+	options.Interface = true
+	options.LongestPrefix = true
+	parse, e := gleantest.Build(t, interfaceMainText, "Sum", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := "10 <nil>\n[1 2 3 4] <nil>\n3 3 <nil>\n"
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+	g.Options = earley.Options{Interface: true, Registry: true}
+	if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+		t.Error("no error for options Interface and Registry")
+	}
+}
+
+var interfaceMainText = `
+package main
+
+import "fmt"
+
+type Sum int
+type Ints []int
+type Plus struct{}
+
+// The rule functions declare the grammar, and also evaluate it.
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+func RuleInts(list []int) Ints         { return list }
+
+// Two implementations of the grammar: evaluating, and listing the numbers
+type eval struct{}
+
+func (eval) RuleInt(i int) Sum                { return RuleInt(i) }
+func (eval) RuleAdd(s Sum, _ Plus, i int) Sum { return RuleAdd(s, Plus{}, i) }
+func (eval) RuleInts(list []int) Ints         { return RuleInts(list) }
+
+type listing struct{ numbers []int }
+
+func (l *listing) RuleInt(i int) Sum                { l.numbers = append(l.numbers, i); return 0 }
+func (l *listing) RuleAdd(s Sum, _ Plus, i int) Sum { l.numbers = append(l.numbers, i); return 0 }
+func (l *listing) RuleInts(list []int) Ints         { return list }
+
+var _ _glean_Reducer = eval{}
+
+func main() {
+	tokens := []interface{}{1, Plus{}, 2, Plus{}, 3, Plus{}, 4}
+	fmt.Println(_glean_Parse(tokens, eval{}))
+	var l listing
+	_, e := _glean_Parse(tokens, &l)
+	fmt.Println(l.numbers, e)
+	fmt.Println(_glean_ParseLongest([]interface{}{1, Plus{}, 2, Plus{}}, eval{}))
+}
+`
@@ -0,0 +1,109 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+)
+
+// Check the symbols named in Options.Intern
+func (g *Grammar) checkIntern() error {
+	for _, name := range g.Options.Intern {
+		s := g.name2symbol[name]
+		if s == nil {
+			return fmt.Errorf("unknown interned symbol '%s'", name)
+		}
+		if !s.isTerminal() {
+			return fmt.Errorf("interned symbol '%s' is not a terminal symbol", name)
+		}
+	}
+	return nil
+}
+
+// Whether the values of a symbol are interned
+func (g *Grammar) interned(s *symbol) bool {
+	for _, name := range g.Options.Intern {
+		if name == s.name {
+			return true
+		}
+	}
+	return false
+}
+
+// The name of the parser field holding the pool of values of an interned symbol
+func (s *symbol) poolName() string {
+	return "pool" + s.identifier()
+}
+
+// Append the parser fields holding the pools of interned values
+func (g *Grammar) addPoolFields() {
+	if len(g.Options.Intern) == 0 {
+		return
+	}
+	g.addString("\n")
+	maxLen := 0
+	if g.Options.Tree {
+		maxLen = len("tokenPool")
+	}
+	for _, s := range g.terminals {
+		if l := len(s.poolName()); g.interned(s) && l > maxLen {
+			maxLen = l
+		}
+	}
+	for _, s := range g.terminals {
+		if g.interned(s) {
+			g.addf("\t%-*s map[%s]%s\n", maxLen, s.poolName(), s.name, s.name)
+		}
+	}
+	if g.Options.Tree {
+		g.addf("\t%-*s map[interface{}]interface{}\n", maxLen, "tokenPool")
+	}
+}
+
+// Append the statements emptying the pools of interned values before
+// the rules are applied
+func (g *Grammar) addPoolResets() {
+	for _, s := range g.terminals {
+		if g.interned(s) {
+			g.addf("\tparser.%s = make(map[%s]%s)\n", s.poolName(), s.name, s.name)
+		}
+	}
+}
+
+// Append the statements of an applier pushing the interned value of the
+// next token, of an interned symbol, on the symbol's stack
+func (g *Grammar) addPushInterned(t *symbol, token string) {
+	g.addf("\t\tt := %s.(%s)\n", token, t.name)
+	g.addf("\t\tif u, have := parser.%s[t]; have {\n", t.poolName())
+	g.addString("\t\t\tt = u\n\t\t} else {\n")
+	g.addf("\t\t\tparser.%s[t] = t\n\t\t}\n", t.poolName())
+	g.addPush(t, "t")
+}
+
+// Append the method interning the tokens of parse tree leaves, and the
+// table of symbols interned, if wanted
+func (g *Grammar) addInternToken() {
+	if !g.Options.Tree || len(g.Options.Intern) == 0 {
+		return
+	}
+	g.addText(`
+// Return the first token equal to t of those interned in the parse tree
+func (parser *@_Parser) internToken(t interface{}) interface{} {
+	if parser.tokenPool == nil {
+		parser.tokenPool = make(map[interface{}]interface{})
+	}
+	if u, have := parser.tokenPool[t]; have {
+		return u
+	}
+	parser.tokenPool[t] = t
+	return t
+}
+
+var @_interned = []bool{
+`)
+	for _, s := range g.terminals {
+		g.addf("\t%v,\n", g.interned(s))
+	}
+	g.addString("}\n")
+}
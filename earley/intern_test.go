@@ -0,0 +1,98 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test interning the values of terminal symbols
+func TestIntern(t *testing.T) {
+	for _, c := range []struct {
+		intern []glean.Symbol
+		expect string
+	}{
+		{nil, "[alpha beta alpha beta] 4 distinct\ntree: 4 distinct\n"},
+		{[]glean.Symbol{"Ident"}, "[alpha beta alpha beta] 2 distinct\ntree: 2 distinct\n"},
+	} {
+		var options earley.Options
+		options.Intern = c.intern
+		options.Tree = true
+		parse, e := gleantest.Build(t, internMainText, "Idents", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if out, e := parse(); e != nil || out != c.expect {
+			t.Errorf("intern %v:\nexpected:\n%s\ngot:\n%s %v", c.intern, c.expect, out, e)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleIdents", "Idents", []glean.Symbol{glean.ListOf("Ident")})
+	for _, intern := range [][]glean.Symbol{{"Ident2"}, {"Idents"}} {
+		g.Options.Intern = intern
+		if _, e := g.WriteParser("Idents", "main", "_"); e == nil {
+			t.Errorf("no error interning %v", intern)
+		}
+	}
+}
+
+var internMainText = `
+package main
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+type Ident string
+type Idents []Ident
+
+func RuleIdents(list []Ident) Idents { return list }
+
+// The number of distinct strings, by their memory
+func distinct(list []Ident) int {
+	data := make(map[*byte]bool)
+	for _, id := range list {
+		data[unsafe.StringData(string(id))] = true
+	}
+	return len(data)
+}
+
+func main() {
+	var tokens []interface{}
+	for _, s := range []string{"alpha", "beta", "alpha", "beta"} {
+		tokens = append(tokens, Ident([]byte(s)))
+	}
+
+	list, e := _glean_Parse(tokens)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(list, distinct(list), "distinct")
+
+	tree, e := _glean_ParseTree(tokens)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	var leaves []Ident
+	var walk func(node *_glean_Node)
+	walk = func(node *_glean_Node) {
+		if node.Token != nil {
+			leaves = append(leaves, node.Token.(Ident))
+		}
+		for _, child := range node.Children {
+			walk(child)
+		}
+	}
+	walk(tree)
+	fmt.Println("tree:", distinct(leaves), "distinct")
+}
+`
@@ -0,0 +1,74 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+
+	"github.com/pat42smith/glean"
+)
+
+// Check Options.Kinds and Options.KindFunc
+func (g *Grammar) checkKinds() error {
+	if len(g.Options.Kinds) == 0 {
+		if g.Options.KindFunc != "" {
+			return fmt.Errorf("option KindFunc requires option Kinds")
+		}
+		return nil
+	}
+	if g.Options.Classifier || g.Options.Scannerless {
+		return fmt.Errorf("option Kinds cannot be combined with Classifier or Scannerless")
+	}
+	if !validName(g.Options.KindFunc) {
+		return fmt.Errorf("kind function '%s' is not a valid Go identifier", g.Options.KindFunc)
+	}
+	if pkg := glean.SymbolPackage(glean.Symbol(g.Options.KindFunc)); pkg != "" && g.Options.Imports[pkg] == "" {
+		return fmt.Errorf("no import path given for package %s, used by kind function %s", pkg, g.Options.KindFunc)
+	}
+	for name := range g.Options.Kinds {
+		if s := g.name2symbol[name]; s == nil || !s.isTerminal() {
+			return fmt.Errorf("kind given for '%s', which is not a terminal symbol", name)
+		}
+	}
+	seen := make(map[string]*symbol)
+	for _, s := range g.terminals {
+		kind := g.Options.Kinds[s.name]
+		if kind == "" {
+			return fmt.Errorf("no kind given for terminal symbol '%s'", s.name)
+		}
+		if t := seen[kind]; t != nil {
+			return fmt.Errorf("terminal symbols '%s' and '%s' have the same kind %s", t.name, s.name, kind)
+		}
+		seen[kind] = s
+	}
+	return nil
+}
+
+// Add the function to determine a terminal's symbol id from its kind
+func (g *Grammar) addKindTokenType() {
+	g.addText("\nfunc @_tokenType(t interface{}) @_Symbol {\n")
+	if g.Options.Unchecked {
+		g.addf("\tswitch %s(t) {\n", g.Options.KindFunc)
+	} else {
+		g.addf("\tswitch kind := %s(t); kind {\n", g.Options.KindFunc)
+	}
+	for n, s := range g.terminals {
+		if g.Options.Unchecked && n == len(g.terminals)-1 {
+			// Any token not of the other kinds is taken to be of this one.
+			g.addf("\tdefault: // %s\n\t\treturn %d\n\t}\n}\n", s.name, s.id)
+			return
+		}
+		g.addf("\tcase %s:\n\t\treturn %d\n", g.Options.Kinds[s.name], s.id)
+	}
+	if g.Options.UnknownTokens {
+		g.addf("\tdefault:\n\t\treturn %d\n\t}\n}\n", len(g.symbols))
+		return
+	}
+	g.addString(
+		`	default:
+		panic(fmt.Sprintf("input token (kind %v) is not a terminal symbol", kind))
+	}
+}
+`)
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test classifying tokens by their kinds
+func TestKinds(t *testing.T) {
+	kinds := map[glean.Symbol]string{"Num": "KindNum", "Plus": "KindPlus"}
+	for _, unchecked := range []bool{false, true} {
+		var options earley.Options
+		options.Kinds = kinds
+		options.KindFunc = "kindOf"
+		options.Unchecked = unchecked
+		parse, e := gleantest.Build(t, kindsMainText, "Sum", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		expect := "1+2\n"
+		if !unchecked {
+			expect += "panic: input token (kind 7) is not a terminal symbol\n"
+		}
+		if out, e := parse(); e != nil || out != expect {
+			t.Errorf("unchecked %v:\nexpected:\n%s\ngot:\n%s %v", unchecked, expect, out, e)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleSum", "Sum", []glean.Symbol{"Num", "Plus", "Num"})
+	for _, c := range []struct {
+		kinds    map[glean.Symbol]string
+		kindFunc string
+		options  func(*earley.Options)
+	}{
+		{nil, "kindOf", nil},
+		{map[glean.Symbol]string{"Num": "1"}, "kindOf", nil},
+		{map[glean.Symbol]string{"Num": "1", "Plus": "1"}, "kindOf", nil},
+		{map[glean.Symbol]string{"Num": "1", "Plus": "2", "Sum": "3"}, "kindOf", nil},
+		{map[glean.Symbol]string{"Num": "1", "Plus": "2", "Minus": "3"}, "kindOf", nil},
+		{kinds, "", nil},
+		{kinds, "kind of", nil},
+		{kinds, "lex.KindOf", nil},
+		{kinds, "kindOf", func(o *earley.Options) { o.Classifier = true }},
+		{kinds, "kindOf", func(o *earley.Options) { o.Scannerless = true }},
+	} {
+		g.Options = earley.Options{Kinds: c.kinds, KindFunc: c.kindFunc}
+		if c.options != nil {
+			c.options(&g.Options)
+		}
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+			t.Errorf("no error for kinds %v, function %q", c.kinds, c.kindFunc)
+		}
+	}
+}
+
+var kindsMainText = `
+package main
+
+import "fmt"
+
+const (
+	KindNum = iota
+	KindPlus
+)
+
+type Token struct {
+	Kind int
+	Text string
+}
+
+type Num = Token
+type Plus = Token
+type Sum string
+
+func kindOf(t interface{}) int { return t.(Token).Kind }
+
+func RuleSum(x Num, op Plus, y Num) Sum { return Sum(x.Text + op.Text + y.Text) }
+
+func main() {
+	tokens := []interface{}{Token{KindNum, "1"}, Token{KindPlus, "+"}, Token{KindNum, "2"}}
+	sum, e := _glean_Parse(tokens)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(sum)
+
+	defer func() {
+		if p := recover(); p != nil {
+			fmt.Println("panic:", p)
+		}
+	}()
+	tokens[1] = Token{7, "?"}
+	_glean_Parse(tokens)
+}
+`
@@ -0,0 +1,37 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the Lexer interface and the parse function taking one,
+// if requested
+func (g *Grammar) addParseLexer() {
+	if !g.Options.Lexer {
+		return
+	}
+
+	tokenType := g.tokenSliceType()[2:]
+	g.addText(`
+// @Lexer supplies the input of @ParseLexer. Next returns the next token
+// and true, or false at the end of the input.
+type @Lexer interface {
+	Next() (` + tokenType + `, bool)
+}
+
+// @ParseLexer reads the tokens from lex until its end, then parses them
+// as @Parse does.
+func @ParseLexer(lex @Lexer`)
+	g.addOtherParams(true)
+	g.addResults("#G", "error")
+	g.addText("\tvar tokens []" + tokenType + `
+	for {
+		token, ok := lex.Next()
+		if !ok {
+			break
+		}
+		tokens = append(tokens, token)
+	}
+	return @Parse(tokens`)
+	g.addOtherArgs(true)
+	g.addText(")\n}\n")
+}
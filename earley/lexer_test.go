@@ -0,0 +1,109 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test parsing tokens read from a lexer
+func TestLexer(t *testing.T) {
+	for _, options := range []earley.Options{
+		{Lexer: true},
+		{Lexer: true, Registry: true, CatchPanics: true},
+	} {
+		text := lexerMainText
+		if options.Registry {
+			text = lexerRegistryMainText
+		}
+		parse, e := gleantest.Build(t, text, "Sum", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		expect := "6\nunexpected end of input\n"
+		if out, e := parse(); e != nil || out != expect {
+			t.Errorf("options %+v:\nexpected:\n%s\ngot:\n%s %v", options, expect, out, e)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleSum", "Sum", []glean.Symbol{"int", "Plus", "int"})
+	for _, options := range []earley.Options{
+		{Lexer: true, Scannerless: true},
+		{Lexer: true, PartialInput: true},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+			t.Errorf("no error for options %+v", options)
+		}
+	}
+}
+
+var lexerCommonText = `
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Plus struct{}
+type Sum int
+
+// Lexes numbers and plus signs separated by spaces
+type lexer struct {
+	words []string
+}
+
+func (lex *lexer) Next() (interface{}, bool) {
+	if len(lex.words) == 0 {
+		return nil, false
+	}
+	w := lex.words[0]
+	lex.words = lex.words[1:]
+	if w == "+" {
+		return Plus{}, true
+	}
+	n, _ := strconv.Atoi(w)
+	return n, true
+}
+
+func try(input string) {
+	sum, e := parse(&lexer{strings.Fields(input)})
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(sum)
+}
+
+func main() {
+	try("1 + 2 + 3")
+	try("1 +")
+}
+`
+
+var lexerMainText = lexerCommonText + `
+func RuleInt(x int) Sum                { return Sum(x) }
+func RuleAdd(s Sum, _ Plus, x int) Sum { return s + Sum(x) }
+
+func parse(lex _glean_Lexer) (Sum, error) { return _glean_ParseLexer(lex) }
+`
+
+var lexerRegistryMainText = lexerCommonText + `
+func RuleInt(x int) Sum                { panic("not called") }
+func RuleAdd(s Sum, _ Plus, x int) Sum { panic("not called") }
+
+func parse(lex _glean_Lexer) (interface{}, error) {
+	reducers := _glean_Reducers{}
+	reducers.Register("RuleInt", func(v []interface{}) interface{} { return Sum(v[0].(int)) })
+	reducers.Register("RuleAdd", func(v []interface{}) interface{} { return v[0].(Sum) + Sum(v[2].(int)) })
+	return _glean_ParseLexer(lex, reducers)
+}
+`
@@ -0,0 +1,190 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test rules with list items
+func TestLists(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(listMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.Tree = true
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleBlock", "Block", "Open", glean.ListOf("Statement"), "Close")
+	addrule("RuleInt", "Statement", "int")
+	addrule("RuleNested", "Statement", "Block")
+	addrule("RuleSum", "Sum", glean.ListOf("int"))
+	addrule("RuleProgram", "Program", "Sum", "Block")
+	parserText, e := g.WriteParser("Program", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	for _, test := range []struct{ input, output string }{
+		{"1 2 3 { 4 }", "6 [4] 1"},
+		{"5 { 1 { 2 3 } 4 }", "5 [1 [2 3] 4] 3"},
+		{"5 { }", "unexpected token: main.Close"},
+		{"{ 1 }", "unexpected token: main.Open"},
+	} {
+		args := append([]string{"run", mainGo, parserGo}, strings.Split(test.input, " ")...)
+		out, e := exec.Command("go", args...).CombinedOutput()
+		if e != nil {
+			t.Fatal(e, string(out))
+		}
+		if string(out) != test.output+"\n" {
+			t.Errorf("wrong output for %q:\nexpected: %s\ngot: %s", test.input, test.output, out)
+		}
+	}
+
+	if e := g.AddRule("RuleBad", "Block", []glean.Symbol{"[]*Statement"}); e == nil {
+		t.Error("no error for invalid list item")
+	}
+}
+
+var listMainText = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type Open struct{}
+type Close struct{}
+type Statement string
+type Block []Statement
+type Sum int
+type Program string
+
+func RuleBlock(_ Open, stmts []Statement, _ Close) Block { return stmts }
+func RuleInt(i int) Statement { return Statement(strconv.Itoa(i)) }
+func RuleNested(b Block) Statement { return Statement(fmt.Sprint(b)) }
+func RuleProgram(s Sum, b Block) Program { return Program(fmt.Sprint(s, " ", b)) }
+
+func RuleSum(ints []int) Sum {
+	sum := 0
+	for _, i := range ints {
+		sum += i
+	}
+	return Sum(sum)
+}
+
+func main() {
+	var tokens []interface{}
+	for _, a := range os.Args[1:] {
+		switch a {
+		case "{":
+			tokens = append(tokens, Open{})
+		case "}":
+			tokens = append(tokens, Close{})
+		default:
+			i, e := strconv.Atoi(a)
+			if e != nil {
+				panic(e)
+			}
+			tokens = append(tokens, i)
+		}
+	}
+
+	program, e := _Parse(tokens)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	tree, e := _ParseTree(tokens)
+	if e != nil {
+		panic(e)
+	}
+	fmt.Println(program, len(tree.Children[1].Children[1].Children))
+}
+`
+
+// Test rules with items that are lists of lists
+func TestNestedLists(t *testing.T) {
+	parse, e := gleantest.Build(t, nestedMainText, "Table", earley.Options{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if out, e := parse(); e == nil || !strings.Contains(out, "ambiguous match for [][]int") {
+		t.Errorf("expected ambiguity, got: %s %v", out, e)
+	}
+
+	for _, options := range []earley.Options{
+		{Ambiguity: earley.AmbiguityLeftmost},
+		{Ambiguity: earley.AmbiguityRightmost},
+		{Ambiguity: earley.AmbiguityLeftmost, Tree: true},
+		{Ambiguity: earley.AmbiguityLeftmost, GenericStacks: true},
+		{Ambiguity: earley.AmbiguityLeftmost, Concurrent: true},
+	} {
+		parse, e := gleantest.Build(t, nestedMainText, "Table", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if out, e := parse(); e != nil || out != "[[1 2 3]] <nil>\n" {
+			t.Errorf("options %+v: wrong output: %s %v", options, out, e)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleTable", "Table", []glean.Symbol{"[][]Cell"})
+	if e := g.AddRule("RuleBad", "Table", []glean.Symbol{"[][]*Cell"}); e == nil {
+		t.Error("no error for invalid nested list item")
+	}
+	if e := g.RenameSymbol("Cell", "Entry"); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := g.WriteParser("Table", "main", "_"); e != nil {
+		t.Fatal(e)
+	}
+	if e := g.RenameSymbol("[][]Entry", "Rows"); e == nil {
+		t.Error("no error renaming a nested list symbol")
+	}
+}
+
+var nestedMainText = `
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+type Table [][]int
+
+func RuleTable(rows [][]int) Table { return rows }
+
+func main() {
+	table, e := _glean_Parse([]interface{}{1, 2, 3})
+	fmt.Println(table, e)
+	if e != nil {
+		os.Exit(1)
+	}
+}
+`
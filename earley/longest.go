@@ -0,0 +1,61 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the function parsing the longest complete prefix of the input,
+// if requested, and the method doing so, which Sequence and PartialInput
+// also use
+func (g *Grammar) addParseLongest() {
+	if !g.Options.LongestPrefix && !g.Options.Sequence && !g.Options.PartialInput {
+		return
+	}
+
+	if g.Options.LongestPrefix {
+		g.addText(`
+// @ParseLongest parses the longest prefix of its input that is a complete
+// #G, returning its value and the number of tokens in it. The tokens after
+// the prefix are left for the caller.
+func @ParseLongest(`)
+		g.addInputParams(true)
+		g.addResults("#G", "int", "error")
+		g.addParserInit(true)
+		g.addCatch("catch", 2)
+		g.addText(`	return parser.parseLongest()
+}
+`)
+	}
+	g.addText(`
+func (parser *@_Parser) parseLongest() (#G, int, error) {
+	var zero #G
+`)
+	g.addReducerCheck("zero, 0")
+	g.addText(`	parser.prepare()
+	if len(parser.tokens) == 0 {
+		return zero, 0, gleanerrors.NoInput{}
+	}
+
+	// findMatches finishes each position before moving on, so the matches
+	// ending at the positions it reached are complete even if it fails.
+	err := parser.findMatches()
+	for n := len(parser.tokens); n >= 0; n-- {
+		for _, p := range @_goalPrefixes {
+			for _, m := range parser.at(n)[p] {
+				if m.start != 0 {
+					continue
+				}
+				parser.tokens = parser.tokens[:n]
+				if e := parser.findTrace(); e != nil {
+					return zero, 0, e
+				}
+				return parser.applyTrace(), n, nil
+			}
+		}
+	}
+	if err == nil {
+		err = parser.unexpected(parser.tokens, len(parser.tokens), len(parser.tokens))
+	}
+	return zero, 0, err
+}
+`)
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test parsing the longest complete prefix of the input
+func TestLongestPrefix(t *testing.T) {
+	var options earley.Options
+	options.LongestPrefix = true
+	parse, e := gleantest.Build(t, longestMainText, "Sum", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `6 5 <nil>
+3 3 <nil>
+3 3 <nil>
+7 1 <nil>
+0 0 unexpected token: main.Plus
+0 0 no tokens in parser input
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+	for _, options := range []earley.Options{
+		{LongestPrefix: true, Scannerless: true},
+		{LongestPrefix: true, EndSymbol: "int"},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+			t.Errorf("no error for options %v", options)
+		}
+	}
+}
+
+var longestMainText = `
+package main
+
+import "fmt"
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{1, Plus{}, 2, Plus{}, 3},
+		{1, Plus{}, 2, Plus{}},
+		{1, Plus{}, 2, 5, 6},
+		{7, 8},
+		{Plus{}, 1},
+		{},
+	} {
+		fmt.Println(_glean_ParseLongest(tokens))
+	}
+}
+`
@@ -0,0 +1,72 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the functions choosing between alternatives for AmbiguityLongest
+func (g *Grammar) addLongestMatch() {
+	g.addText("\n// Whether each prefix completes a rule of a list symbol\nvar @_listRule = []bool{\n")
+	for _, p := range g.prefixes {
+		r := p.completedRule()
+		g.addf("\t%v,\n", r != nil && r.target.element != nil && !r.target.optional)
+	}
+	g.addString("}\n")
+
+	g.addText(`
+func @_preferred(shorter1, last1, shorter2, last2 *@_Match) bool {
+	if shorter1 != shorter2 {
+		return @_compareEnds(@_itemEnds(shorter1), @_itemEnds(shorter2)) > 0
+	}
+	return @_longer(last1, last2)
+}
+
+// Whether m1 is preferred to m2, a match of another rule for the same
+// tokens: the first of their items to end at a different place ends
+// later in m1, or, if there is none, m1's rule was added first.
+func @_longer(m1, m2 *@_Match) bool {
+	if c := @_compareEnds(@_itemEnds(m1), @_itemEnds(m2)); c != 0 {
+		return c > 0
+	}
+	return @_prefix2rule[m1.prefix] < @_prefix2rule[m2.prefix]
+}
+
+// The positions where the items of a match end, last item first. Where
+// the first item is a list, its elements are counted as items instead.
+func @_itemEnds(m *@_Match) []int {
+	var ends []int
+	for m.shorter != nil {
+		if m.shorter.shorter == nil && m.last != nil && @_listRule[m.last.prefix] {
+			m = m.last
+			continue
+		}
+		ends = append(ends, m.end)
+		m = m.shorter
+	}
+	return ends
+}
+
+// Compare the ends of the items of two derivations beginning at the same
+// place and ending at the same place, or at least after all the items
+// given: 1 if the first item to end at a different place ends later in
+// ends1, -1 if in ends2, and 0 if there is no such item. An item missing
+// from one list ends with the derivation, so after every item present.
+func @_compareEnds(ends1, ends2 []int) int {
+	i, j := len(ends1)-1, len(ends2)-1
+	for ; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if ends1[i] != ends2[j] {
+			if ends1[i] > ends2[j] {
+				return 1
+			}
+			return -1
+		}
+	}
+	switch {
+	case i < 0 && j >= 0:
+		return 1
+	case j < 0 && i >= 0:
+		return -1
+	}
+	return 0
+}
+`)
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test choosing the longest match with AmbiguityLongest
+func TestLongestMatch(t *testing.T) {
+	for _, options := range []earley.Options{
+		{Ambiguity: earley.AmbiguityLongest},
+		{Ambiguity: earley.AmbiguityLongest, Resolutions: true},
+		{Ambiguity: earley.AmbiguityLongest, Stepping: true},
+	} {
+		parse, e := gleantest.Build(t, longestMatchMainText, "Phrase", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		expect := `[a]
+[a b]
+[a b] [c]
+[a b] [c d] [e]
+[a b c] [d]
+`
+		if out, e := parse(); e != nil || out != expect {
+			t.Errorf("options %+v:\nexpected:\n%s\ngot:\n%s %v", options, expect, out, e)
+		}
+	}
+}
+
+var longestMatchMainText = `
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Word string
+type Dot struct{}
+type Group string
+type Phrase string
+
+func RuleOne(w Word) Group            { return Group(w) }
+func RuleTwo(w1, w2 Word) Group       { return Group(w1 + " " + w2) }
+func RuleThree(g Group, _ Dot, w Word) Group { return Group(string(g) + " " + string(w)) }
+
+func RulePhrase(groups []Group) Phrase {
+	var s []string
+	for _, g := range groups {
+		s = append(s, "["+string(g)+"]")
+	}
+	return Phrase(strings.Join(s, " "))
+}
+
+func main() {
+	for _, words := range []string{"a", "a b", "a b c", "a b c d e", "a b . c d"} {
+		var tokens []interface{}
+		for _, w := range strings.Fields(words) {
+			if w == "." {
+				tokens = append(tokens, Dot{})
+			} else {
+				tokens = append(tokens, Word(w))
+			}
+		}
+		p, e := _glean_Parse(tokens)
+		if e != nil {
+			fmt.Println(e)
+		} else {
+			fmt.Println(p)
+		}
+	}
+}
+`
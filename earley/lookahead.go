@@ -0,0 +1,95 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+)
+
+// Check the rules and symbols named in Options.Lookahead
+func (g *Grammar) checkLookahead() error {
+	if len(g.Options.Lookahead) == 0 {
+		return nil
+	}
+	if g.Options.Scannerless {
+		return fmt.Errorf("options Lookahead and Scannerless cannot be combined")
+	}
+	if g.Options.Complete {
+		return fmt.Errorf("options Lookahead and Complete cannot be combined")
+	}
+	for name, s := range g.Options.Lookahead {
+		if _, have := g.rulenames[name]; !have {
+			return fmt.Errorf("lookahead given for unknown rule %s", name)
+		}
+		if t := g.name2symbol[s]; t == nil || !t.isTerminal() {
+			return fmt.Errorf("lookahead '%s' of rule %s is not a terminal symbol", s, name)
+		}
+	}
+	return nil
+}
+
+// The id of the symbol that must follow a match of a rule, or -1 if none
+func (g *Grammar) lookahead(r *rule) int {
+	if s, have := g.Options.Lookahead[r.name]; have {
+		return g.name2symbol[s].id
+	}
+	return -1
+}
+
+// The condition, to be added to an if statement, that a complete rule
+// matched by the prefix given is followed by its lookahead at end
+func (g *Grammar) followsCondition(prefix string) string {
+	if len(g.Options.Lookahead) == 0 {
+		return ""
+	}
+	return " && parser.follows(" + prefix + ", end)"
+}
+
+// Append the method checking the lookahead of a rule, if any rules have one
+func (g *Grammar) addFollows() {
+	if len(g.Options.Lookahead) == 0 {
+		return
+	}
+	g.addText(`
+// Whether the token at end is the lookahead, if any, of the rule
+// completed by prefix p
+func (parser *@_Parser) follows(p @_Prefix, end int) bool {
+	want := @_lookahead[p]
+	if want < 0 {
+		return true
+	}
+	if end >= len(parser.tokens) {
+		return false
+	}
+`)
+	if g.Options.Alternatives {
+		g.addText(`	for _, t := range parser.tokenTypes(parser.tokens[end]) {
+		if t == want {
+			return true
+		}
+	}
+	return false
+}
+`)
+	} else {
+		g.addText("\treturn #T(parser.tokens[end]) == want\n}\n")
+	}
+}
+
+// For each prefix that is a complete rule, write the symbol id of the
+// rule's lookahead, or -1 if none, if any rules have one.
+func (g *Grammar) addLookahead() {
+	if len(g.Options.Lookahead) == 0 {
+		return
+	}
+	g.addText("\nvar @_lookahead = []@_Symbol{\n")
+	for _, p := range g.prefixes {
+		if r := p.completedRule(); r != nil && g.lookahead(r) >= 0 {
+			g.addf("\t%d, // %s\n", g.lookahead(r), r.name)
+		} else {
+			g.addString("\t-1,\n")
+		}
+	}
+	g.addString("}\n")
+}
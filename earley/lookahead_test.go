@@ -0,0 +1,95 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test rules with trailing context
+func TestLookahead(t *testing.T) {
+	for _, c := range []struct {
+		lookahead map[string]glean.Symbol
+		expect    string
+	}{
+		{nil, "ambiguous match for []Piece\nambiguous match for []Piece\n2 ambiguities:\n"},
+		{map[string]glean.Symbol{"RuleWord": "Dot"}, "[ab]\n[ab c]\n[ab cd]\n"},
+		{map[string]glean.Symbol{"RuleWord": "string"}, "[ab]\n[a bc]\nambiguous match for []Piece\n"},
+		{map[string]glean.Symbol{"RuleSentence": "Dot"},
+			"unexpected end of input\nunexpected end of input\nunexpected end of input\n"},
+	} {
+		var options earley.Options
+		options.Lookahead = c.lookahead
+		parse, e := gleantest.Build(t, lookaheadMainText, "Sentence", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		out, e := parse()
+		if e != nil {
+			t.Fatal(e, out)
+		}
+		if out != c.expect {
+			t.Errorf("lookahead %v:\nexpected:\n%s\ngot:\n%s", c.lookahead, c.expect, out)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleWord", "Piece", []glean.Symbol{"string"})
+	g.AddRule("RuleSentence", "Sentence", []glean.Symbol{"Piece", "Dot"})
+	for _, options := range []earley.Options{
+		{Lookahead: map[string]glean.Symbol{"RulePair": "Dot"}},
+		{Lookahead: map[string]glean.Symbol{"RuleWord": "Piece"}},
+		{Lookahead: map[string]glean.Symbol{"RuleWord": "Comma"}},
+		{Lookahead: map[string]glean.Symbol{"RuleWord": "Dot"}, Scannerless: true},
+		{Lookahead: map[string]glean.Symbol{"RuleWord": "Dot"}, Complete: true},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Sentence", "main", "_"); e == nil {
+			t.Errorf("no error for options %v", options)
+		}
+	}
+}
+
+var lookaheadMainText = `
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Sentence []string
+type Piece string
+type Dot struct{}
+
+func RuleSentence(p []Piece, _ Dot) Sentence {
+	var s Sentence
+	for _, x := range p {
+		s = append(s, string(x))
+	}
+	return s
+}
+func RuleWord(w string) Piece    { return Piece(w) }
+func RulePair(v, w string) Piece { return Piece(v + w) }
+
+func main() {
+	for _, words := range []string{"a b", "a b c", "a b c d"} {
+		var tokens []interface{}
+		for _, w := range strings.Fields(words) {
+			tokens = append(tokens, w)
+		}
+		tokens = append(tokens, Dot{})
+		if s, e := _glean_Parse(tokens); e != nil {
+			line, _, _ := strings.Cut(e.Error(), "\n")
+			fmt.Println(line)
+		} else {
+			fmt.Println(s)
+		}
+	}
+}
+`
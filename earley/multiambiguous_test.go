@@ -0,0 +1,75 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test reporting several ambiguities together
+func TestMultiAmbiguous(t *testing.T) {
+	var options earley.Options
+	options.Recover = true
+	parse, e := gleantest.Build(t, multiAmbiguousMainText, "Pair", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `1 1
+2 [1 0]
+2 [3 0]
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+}
+
+var multiAmbiguousMainText = `
+package main
+
+import (
+	"fmt"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Pair string
+type Value string
+type Small int
+type Stop struct{}
+
+func RulePair(x, y Value) Pair  { return Pair(x + y) }
+func RuleOne(x Value) Pair      { return Pair(x) }
+func RuleInt(i int) Value       { return "int" }
+func RuleSmall(i int) Small     { return Small(i) }
+func RuleCoerce(s Small) Value  { return "small" }
+func RuleFloat(f float64) Value { return "float" }
+func RuleStop(_ Stop) Pair      { return "stop" }
+
+func show(e error) {
+	switch e := e.(type) {
+	case gleanerrors.Ambiguous:
+		fmt.Println(1, e.First.Index)
+	case gleanerrors.MultiAmbiguous:
+		var starts []int
+		for _, a := range e.Ambiguities {
+			starts = append(starts, a.First.Index)
+		}
+		fmt.Println(len(e.Ambiguities), starts)
+	default:
+		fmt.Println(e)
+	}
+}
+
+func main() {
+	_, e := _glean_Parse([]interface{}{1.5, 2})
+	show(e)
+	_, e = _glean_Parse([]interface{}{1, 2})
+	show(e)
+	_, errors := _glean_ParseRecover([]interface{}{1, Stop{}, Stop{}, 2}, 0)
+	show(errors[len(errors)-1])
+}
+`
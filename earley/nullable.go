@@ -0,0 +1,48 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"sort"
+
+	"github.com/pat42smith/glean"
+)
+
+// Nullable finds the nonterminal symbols that can derive the empty
+// sequence of tokens. The result holds true for each of them, and has no
+// entry for any other symbol, so two results are equal exactly when they
+// describe the same symbols. NullableSymbols gives the same symbols in
+// sorted order.
+func (g *Grammar) Nullable() map[glean.Symbol]bool {
+	nullable := make(map[glean.Symbol]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, r := range append(g.rules[:len(g.rules):len(g.rules)], g.listRules...) {
+			if nullable[r.target.name] {
+				continue
+			}
+			empty := true
+			for _, item := range r.items {
+				empty = empty && nullable[item.name]
+			}
+			if empty {
+				nullable[r.target.name] = true
+				changed = true
+			}
+		}
+	}
+	return nullable
+}
+
+// NullableSymbols returns the symbols found by Nullable, sorted by name.
+func (g *Grammar) NullableSymbols() []glean.Symbol {
+	var symbols []glean.Symbol
+	for s := range g.Nullable() {
+		symbols = append(symbols, s)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i] < symbols[j]
+	})
+	return symbols
+}
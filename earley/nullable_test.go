@@ -0,0 +1,72 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test finding the symbols that derive the empty sequence
+func TestNullable(t *testing.T) {
+	var g earley.Grammar
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleExpr", "Goal", "Expr")
+	addrule("RuleInt", "Expr", "int")
+	addrule("RuleNil", "Nil")
+	addrule("RuleNil0", "Nothing", "Nil")
+	addrule("RuleNothing", "Goal", "Plus", "Nothing", "Open", "Close")
+	addrule("RuleBlank", "Blank")
+	addrule("RuleUnderscore", "Blank", "Underscore")
+	addrule("RuleBlank2", "Blank", "Blank", "Blank")
+	addrule("RuleNils", "Nils", glean.ListOf("Nil"))
+	addrule("RuleInts", "Ints", glean.ListOf("int"))
+	addrule("RuleBoth", "Both", "Nothing", "Expr")
+
+	expect := "[Blank Nil Nils Nothing []Nil]"
+	if got := fmt.Sprint(g.NullableSymbols()); got != expect {
+		t.Errorf("expected %s, got %s", expect, got)
+	}
+	nullable := g.Nullable()
+	if len(nullable) != 5 || !nullable["Blank"] || nullable["Both"] || nullable["int"] {
+		t.Errorf("wrong result from Nullable: %v", nullable)
+	}
+
+	// Blank and []Nil are nullable and recursive, so derive themselves.
+	// The first warning is of the blowup from RuleBlank2.
+	expect = "[symbol Blank derives itself alone; input it matches has infinitely many parses" +
+		" symbol []Nil derives itself alone; input it matches has infinitely many parses]"
+	if got := fmt.Sprint(g.Validate()[1:]); got != expect {
+		t.Errorf("wrong warnings:\nexpected %s\ngot %s", expect, got)
+	}
+	addrule("RuleB", "B", "C", "Nil")
+	addrule("RuleC", "C", "Nothing", "B")
+	if got := fmt.Sprint(g.Validate()[1]); got != "symbols B, C derive each other alone; input they match has infinitely many parses" {
+		t.Error("wrong warning:", got)
+	}
+
+	var empty earley.Grammar
+	if n := empty.Nullable(); len(n) != 0 {
+		t.Errorf("empty grammar has nullable symbols %v", n)
+	}
+}
+
+// Test the nullable symbols of the example interpreter
+func TestNullableInterpreter(t *testing.T) {
+	var g earley.Grammar
+	if _, _, e := glean.ScanFiles(&g, "../glean/testdata/interpret.go"); e != nil {
+		t.Fatal(e)
+	}
+	expect := "[EmptyExpressionList EmptyIdentifierList ExpressionList IdentifierList Statement StatementList]"
+	if got := fmt.Sprint(g.NullableSymbols()); got != expect {
+		t.Errorf("expected %s, got %s", expect, got)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test rules with optional items
+func TestOptional(t *testing.T) {
+	for _, options := range []earley.Options{
+		{},
+		{GenericStacks: true},
+		{Concurrent: true},
+		{Tree: true},
+	} {
+		parse, e := gleantest.Build(t, optionalMainText, "Statement", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		expect := `if 1 then 2
+if 1 then 2 else 3
+if 1 then if 2 then 3 else 4 else 5
+unexpected token: main.Else
+`
+		if out, e := parse(); e != nil || out != expect {
+			t.Errorf("options %+v:\nexpected:\n%s\ngot:\n%s %v", options, expect, out, e)
+		}
+	}
+
+	var g earley.Grammar
+	for _, item := range []glean.Symbol{"**Else", "[]*Else", "*"} {
+		if e := g.AddRule("RuleBad", "Statement", []glean.Symbol{item}); e == nil {
+			t.Errorf("no error for item %s", item)
+		}
+	}
+}
+
+var optionalMainText = `
+package main
+
+import "fmt"
+
+type If struct{}
+type Then struct{}
+type Else struct{}
+type Statement string
+type ElseClause Statement
+
+func RuleInt(i int) Statement { return Statement(fmt.Sprint(i)) }
+
+func RuleIf(_ If, cond int, _ Then, body Statement, els *ElseClause) Statement {
+	s := fmt.Sprint("if ", cond, " then ", body)
+	if els != nil {
+		s += " else " + string(*els)
+	}
+	return Statement(s)
+}
+
+func RuleElse(_ Else, body Statement) ElseClause { return ElseClause(body) }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{If{}, 1, Then{}, 2},
+		{If{}, 1, Then{}, 2, Else{}, 3},
+		{If{}, 1, Then{}, If{}, 2, Then{}, 3, Else{}, 4, Else{}, 5},
+		{Else{}, 3},
+	} {
+		s, e := _glean_Parse(tokens)
+		if e != nil {
+			fmt.Println(e)
+		} else {
+			fmt.Println(s)
+		}
+	}
+}
+`
+
+// Test an optional list item, which matches zero or more elements
+func TestOptionalList(t *testing.T) {
+	for _, options := range []earley.Options{
+		{},
+		{GenericStacks: true},
+		{Concurrent: true},
+		{Tree: true},
+	} {
+		parse, e := gleantest.Build(t, optionalListMainText, "Block", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		expect := `{}: nil slice
+{1}
+{1 2 3}
+{{}: nil slice {2}}
+unexpected token: main.Close
+`
+		if out, e := parse(); e != nil || out != expect {
+			t.Errorf("options %+v:\nexpected:\n%s\ngot:\n%s %v", options, expect, out, e)
+		}
+	}
+}
+
+var optionalListMainText = `
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Open struct{}
+type Close struct{}
+type Block string
+
+func RuleInt(i int) Block { return Block(fmt.Sprint(i)) }
+
+func RuleBlock(_ Open, body *[]Block, _ Close) Block {
+	if *body == nil {
+		return "{}: nil slice"
+	}
+	var s []string
+	for _, b := range *body {
+		s = append(s, string(b))
+	}
+	return Block("{" + strings.Join(s, " ") + "}")
+}
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{Open{}, Close{}},
+		{Open{}, 1, Close{}},
+		{Open{}, 1, 2, 3, Close{}},
+		{Open{}, Open{}, Close{}, Open{}, 2, Close{}, Close{}},
+		{Close{}},
+	} {
+		s, e := _glean_Parse(tokens)
+		if e != nil {
+			fmt.Println(e)
+		} else {
+			fmt.Println(s)
+		}
+	}
+}
+`
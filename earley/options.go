@@ -0,0 +1,844 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import "github.com/pat42smith/glean"
+
+// DefaultErrorsImport is the import path of the gleanerrors package used
+// by generated parsers, unless Options.ErrorsImport says otherwise.
+const DefaultErrorsImport = "github.com/pat42smith/glean/gleanerrors"
+
+// DefaultLongRuleFactor is the factor used by Grammar.Validate when
+// Options.LongRuleFactor is 0.
+const DefaultLongRuleFactor = 4
+
+// Ambiguity is a policy for handling inputs that can be parsed in more than one way.
+type Ambiguity int
+
+const (
+	// Report an ambiguity as a gleanerrors.Ambiguous error, or several
+	// as a gleanerrors.MultiAmbiguous error listing all of them.
+	AmbiguityError Ambiguity = iota
+
+	// Choose the derivation in which the earlier items of a rule match
+	// as much of the input as possible. For a rule such as
+	// Sum -> Sum Plus Sum, this makes Plus left associative.
+	AmbiguityLeftmost
+
+	// Choose the derivation in which the earlier items of a rule match
+	// as little of the input as possible, making Plus right associative
+	// in the example above.
+	AmbiguityRightmost
+
+	// Choose the longest match, item by item, as a hand-written parser
+	// taking as many tokens as it can for each item in turn would: of two
+	// derivations of a rule, the one whose first item to end at a
+	// different place ends later. Within a rule, this chooses as
+	// AmbiguityLeftmost does, except where the earlier items are split
+	// differently. Where different rules match the same tokens, their
+	// items are compared in the same way, and the rule added first is
+	// chosen only if their items end at the same places. The elements of
+	// a list count as items, so the first element matches as much as it
+	// can, then the second, and so on.
+	AmbiguityLongest
+)
+
+// Options select optional features of the parser written by WriteParser.
+//
+// The zero value of Options selects the default parser, as described
+// in the documentation for glean.ParserWriter.
+//
+// Whatever the options, the generated parse functions keep no state
+// between calls, so they may be called from several goroutines at once.
+// The reducers passed with Registry, and the function passed with
+// Classifier, must then also be safe for concurrent use.
+type Options struct {
+	// If Registry is true, the parser does not call the rule functions
+	// directly. Instead, the parse function takes a further argument,
+	// a map from rule ids to reducer functions, and calls those.
+	// This allows one parser to serve several interpretations of a grammar.
+	//
+	// With this option, the parse function has the signature
+	//
+	//	func(tokens []interface{}, reducers Reducers) (interface{}, error)
+	//
+	// where Reducers is the generated type prefix + "Reducers", a map from
+	// rule ids to functions of type func([]interface{}) interface{}.
+	// Rule ids are numbered from 0 in the order the rules were added.
+	// Reducers has a Register method to add a reducer by rule name.
+	//
+	// The values of nonterminal symbols are all of type interface{}, except
+	// that the value of a list symbol is a slice of its element's type,
+	// and that of an optional symbol a pointer to it.
+	// The types of terminal symbols are still used to classify tokens.
+	// If a reducer is missing for any rule, the parse function returns
+	// a gleanerrors.MissingReducer error.
+	Registry bool
+
+	// If Interface is true, the parser does not call the rule functions
+	// directly either. Instead, it declares an interface, Reducer (with the
+	// prefix prepended), with one method for each rule, and each function
+	// applying the rules takes a further argument of that type, after the
+	// tokens, whose methods it calls:
+	//
+	//	func Parse(tokens []interface{}, reducer Reducer) (Goal, error)
+	//
+	// Each method is named by its rule, with the dot of a qualified name
+	// replaced by an underscore, and has the types of the rule's items as
+	// parameters and that of its target as its result, as found by the
+	// scanner in the rule function's signature; a rule made by an alias
+	// directive has a method of its own. Unlike Registry, this keeps the
+	// types of the values, while still letting one grammar serve several
+	// implementations. The rule functions need not exist. Interface cannot
+	// be combined with Registry.
+	Interface bool
+
+	// If ChartStore is true, the parser keeps the matches it finds, its
+	// chart, in a store given by its caller. The store is of the generated
+	// interface type Chart (with the prefix prepended), which gives the
+	// matches ending at each position as a map, and each function taking
+	// input has a further parameter, after any classify and alternatives,
+	//
+	//	chart Chart
+	//
+	// If it is nil, a new MemoryChart (with the prefix prepended) is used,
+	// which behaves as the parser does without ChartStore. A caller may
+	// supply a chart of its own to reuse the maps of one parse in the next,
+	// to bound or measure their memory, or to lay them out in other ways.
+	// The matches refer to one another by pointer, as the parse is traced
+	// back from the last, so however the chart holds its maps, all the
+	// matches of a parse remain in memory until it ends; the chart cannot
+	// move positions out of memory. The calls through the interface make
+	// parsing somewhat slower. ChartStore cannot be combined with Recover,
+	// which removes positions from the chart as it skips tokens.
+	ChartStore bool
+
+	// If Scannerless is true, the parser does not take a slice of tokens.
+	// Instead, its input is a sequence of positions (for example, the bytes
+	// or runes of a text), and at each position a function supplies the
+	// tokens that may begin there, each with the number of positions it
+	// covers. The parser considers all of these possible tokenizations, and
+	// the parse function has the signature
+	//
+	//	func(length int, next func(pos int) []TokenOption) (Goal, error)
+	//
+	// where TokenOption is the generated type
+	//
+	//	type TokenOption struct {
+	//		Token  interface{}
+	//		Length int
+	//	}
+	//
+	// (with the prefix prepended to its name). The symbol of each option is
+	// found from the type of its Token, just as for the tokens passed to the
+	// default parse function. Each Length must be at least 1 and must not
+	// extend past the end of the input; otherwise the parser panics.
+	// If two options at one position have the same symbol and length, the
+	// first is used.
+	//
+	// next is called at most once for each position, and only for positions
+	// where some match of the grammar ends. The locations in errors are
+	// positions in the input; their Token is the first option offered at
+	// that position, if any. The reducers receive the tokens chosen for the
+	// parse, in order.
+	Scannerless bool
+
+	// If Recover is true, a further parse function is written, which
+	// continues past unexpected tokens and reports all the errors found:
+	//
+	//	func ParseRecover(tokens []interface{}, maxErrors int) (Goal, []error)
+	//
+	// (with the prefix prepended to its name; with Registry, the reducers
+	// argument precedes maxErrors). When a token cannot extend any match,
+	// it is reported with a gleanerrors.Unexpected error and skipped.
+	// A run of consecutive skipped tokens counts as a single error, at the
+	// first of them. If the remaining tokens form a valid parse, the goal
+	// built from them is returned along with the errors.
+	//
+	// If maxErrors is positive, at most maxErrors errors are reported.
+	// When a further error is found, including one found after all tokens
+	// have been read, the parser stops and ends the list with a
+	// gleanerrors.TooManyErrors error instead. If maxErrors is 0 or
+	// negative, there is no limit.
+	//
+	// Errors found after all tokens have been read, such as a premature end
+	// of input or an ambiguity, also end the list. Their locations refer
+	// to the original tokens. When the parser stops at an unexpected token,
+	// the end of the input, or the limit on errors, the goal returned is a
+	// partial one: that built from the longest prefix of the remaining
+	// tokens forming a goal, or the zero value if there is none. After an
+	// ambiguity, the zero value is returned. Recover cannot be combined
+	// with Scannerless.
+	Recover bool
+
+	// MaxErrors is the value of the generated constant prefix + "MaxErrors",
+	// offered as a default for the maxErrors argument of the function
+	// written when Recover is true.
+	MaxErrors int
+
+	// If ErrorSink is true, a further recovering parse function streams
+	// the errors it finds to a function given by its caller:
+	//
+	//	func ParseRecoverSink(tokens []interface{}, maxErrors int, sink func(error)) (Goal, []error)
+	//
+	// (with the prefix prepended, and the further parameters of
+	// ParseRecover). It parses as ParseRecover does, but calls sink with
+	// each error as soon as it is found, before parsing goes on, so that a
+	// tool such as a language server may publish them as they come. The
+	// errors passed to sink, in order, are those returned at the end; the
+	// last of them is the gleanerrors.TooManyErrors error, if maxErrors is
+	// exceeded, or the error found after all tokens have been read, if
+	// any. A run of skipped tokens is passed as one error, when its first
+	// token is skipped. The goal returned is the same as from ParseRecover.
+	// sink is called on the goroutine calling ParseRecoverSink. With
+	// CatchPanics, a panic, in the parser or in sink, ends the parse with
+	// a gleanerrors.Internal error, which is returned but not passed to
+	// sink. ErrorSink requires Recover.
+	ErrorSink bool
+
+	// If GenericStacks is true, the parser keeps the values of each symbol
+	// in a stack of the generic type prefix + "_Stack", rather than in a
+	// plain slice, and the code applying each rule calls its push and pop
+	// methods. The generated code is then considerably shorter, but it
+	// requires Go 1.18 or later. The benchmarks BenchmarkSliceStack and
+	// BenchmarkGenericStack in github.com/pat42smith/glean/timing compare
+	// the two; any difference in speed is small compared to the noise.
+	GenericStacks bool
+
+	// If ParseAs is true, a further, generic, parse function is written:
+	//
+	//	func ParseAs[T any](tokens []interface{}) (T, error)
+	//
+	// (with the prefix prepended to its name, and the same parameters as
+	// the parse function), which parses as the parse function does, then
+	// returns the goal's value as a T, typically an interface the goal
+	// type implements. So several parsers whose goals share an interface
+	// can be called alike. A value that is not a T gives an error, not a
+	// panic. ParseAs requires Go 1.18 or later, and cannot be combined
+	// with PartialInput.
+	ParseAs bool
+
+	// If Lexer is true, the parser declares the interface
+	//
+	//	type Lexer interface {
+	//		Next() (interface{}, bool)
+	//	}
+	//
+	// and a further parse function taking the tokens from one:
+	//
+	//	func ParseLexer(lex Lexer) (Goal, error)
+	//
+	// (with the prefix prepended to the names, and the same further
+	// parameters as the parse function). Next returns each token in turn
+	// and true, then false at the end of the input. With TokenInterface,
+	// Next returns a token of that type. The lexer need not build a slice
+	// of the tokens for the parser; but ParseLexer still reads all of them
+	// before it parses, and keeps them until the parse ends, as the parse
+	// function does, so error locations are indexes of the tokens as
+	// before. Lexer cannot be combined with Scannerless or PartialInput.
+	Lexer bool
+
+	// If Reusable is true, the parser declares the type Parser (with the
+	// prefix prepended), whose method
+	//
+	//	func (p *Parser) Parse(tokens []interface{}) (Goal, error)
+	//
+	// (with the same parameters and results as the parse function) parses
+	// as the parse function does, but keeps the slices and maps it makes
+	// for one parse to reuse in the next, rather than leaving them to the
+	// garbage collector. This suits a program parsing many small inputs.
+	// The zero value of Parser is ready for use; one Parser must not be
+	// used by several goroutines at once, but each may have its own. The
+	// matches themselves are still allocated anew by each parse.
+	Reusable bool
+
+	// GoVersion, if not empty, is the Go release under which the parser
+	// must compile, such as "1.17" or "go1.17". WriteParser then fails if an
+	// option asks for code the release does not support, such as the type
+	// parameters of GenericStacks, which need Go 1.18. If GoVersion is
+	// empty, no release is assumed and every option is allowed. The glean
+	// command takes the release from the go directive of the go.mod file
+	// governing the parser, unless told otherwise.
+	GoVersion string
+
+	// Ambiguity selects how the parser handles an ambiguous input.
+	// With AmbiguityError, the default, the parse fails. Each of the three
+	// other policies picks one derivation deterministically:
+	// AmbiguityLeftmost lets the earlier items of a rule match as much of
+	// the input as they can, AmbiguityRightmost as little, and
+	// AmbiguityLongest lets each item in turn match as much as it can,
+	// comparing the items of different rules too. Where two derivations
+	// apply different rules to the same tokens, AmbiguityLeftmost and
+	// AmbiguityRightmost choose the rule added first; AmbiguityLongest does
+	// so only if the items of both rules end at the same places.
+	//
+	// A policy other than AmbiguityError masks genuine ambiguities in the
+	// grammar, so should be used only where any valid parse will do.
+	// To show how often it applies, a further parse function is written:
+	//
+	//	func ParseResolved(tokens []interface{}) (Goal, int, error)
+	//
+	// (with the prefix prepended to its name, and the same parameters as
+	// the parse function), which also returns the number of places in the
+	// chosen derivation where an alternative was discarded.
+	Ambiguity Ambiguity
+
+	// Weights gives weights, which must not be negative, to rules named
+	// by their names; other rules have weight 0. If any are given, an
+	// ambiguity is resolved in favor of the derivation in which the total
+	// weight of the rules applied is least. If two derivations have the
+	// least weight, the input is still reported as ambiguous. An input
+	// in which a symbol derives itself is also reported as ambiguous,
+	// wherever the parser chooses such a derivation, or might have.
+	// Weights cannot be combined with an Ambiguity policy.
+	Weights map[string]int
+
+	// Lookahead gives trailing context to rules named by their names: a
+	// rule matches only where the next token is of the terminal symbol
+	// given, which is not part of the match and remains to be matched by
+	// what follows. At the end of the input no token follows, so a rule
+	// with a lookahead never matches there; in particular, such a rule
+	// of the goal symbol cannot complete the parse. With EndSymbol, the
+	// end token may serve as a lookahead. Lookahead cannot be combined
+	// with Scannerless or Complete.
+	Lookahead map[string]glean.Symbol
+
+	// Associativity declares terminal symbols, such as operators, to be
+	// left associative, by AmbiguityLeftmost, or right associative, by
+	// AmbiguityRightmost. A rule containing such a symbol takes its
+	// associativity. Where two derivations of such a rule match the same
+	// tokens, differing only in where its last item begins, the parser
+	// chooses between them as the Ambiguity policy of that name would, and
+	// does not count or report an ambiguity. So with Plus left associative,
+	// the rule Expr = Expr Plus Expr parses 2 + 3 + 5 as (2 + 3) + 5. Other
+	// ambiguities, including those between different rules, as between
+	// Expr Plus Expr and Expr Times Expr, are handled as usual. A rule must
+	// not contain symbols of opposite associativity. Associativity cannot
+	// be combined with Weights.
+	Associativity map[glean.Symbol]Ambiguity
+
+	// Precedence gives precedence levels, which must be positive, to
+	// terminal symbols such as operators. A rule containing such a symbol
+	// takes its level; the symbols of a rule must not have different
+	// levels. Where derivations apply different rules with levels to the
+	// same tokens, as with Expr = Expr Plus Expr and Expr = Expr Times
+	// Expr on 1 + 2 * 3, the parser keeps the one applying the rule of
+	// lower level, so that higher levels bind more tightly, and does not
+	// count or report an ambiguity. With Plus at level 1 and Times at
+	// level 2, 1 + 2 * 3 parses as 1 + (2 * 3). Rules of the same level
+	// are chosen between by their associativity, if they agree in it, so
+	// that with Plus and Minus both at level 1 and left associative,
+	// 1 - 2 + 3 parses as (1 - 2) + 3. Precedence cannot be combined with
+	// Weights.
+	Precedence map[glean.Symbol]int
+
+	// If FirstRule is true, where different rules match a symbol to the
+	// same tokens, the parser keeps the match of the rule added first,
+	// and does not count or report an ambiguity. This settles ambiguities
+	// such as that of the dangling else: with RuleIfElse added before
+	// RuleIf, the else of if a then if b then x else y belongs to the
+	// first if, and with RuleIf first, to the second. Precedence, if
+	// given, is considered first. Other ambiguities, such as those of one
+	// rule matching the same tokens in different ways, are handled as
+	// usual. FirstRule cannot be combined with ParseAll or Weights.
+	FirstRule bool
+
+	// If Resolutions is true, the ambiguities resolved by the Ambiguity
+	// policy are recorded, and a further parse function returns them:
+	//
+	//	func ParseResolutions(tokens []interface{}) (Goal, []Resolution, error)
+	//
+	// (with the prefix prepended to its name and to Resolution, and the same
+	// parameters as the parse function). Resolution is a generated type
+	// holding the range of tokens and the rules chosen and discarded at
+	// each place counted by ParseResolved, in the order the parser found
+	// them. This allows checking that the policy chose as intended.
+	// Resolutions requires a policy other than AmbiguityError.
+	Resolutions bool
+
+	// If Warnings is true, a further parse function reports the ambiguities
+	// resolved by the Ambiguity policy as warnings, rather than failing:
+	//
+	//	func ParseWarnings(tokens []interface{}) (Goal, []error, error)
+	//
+	// (with the prefix prepended to its name, and the same parameters as
+	// the parse function). Each warning is a gleanerrors.Ambiguous, as the
+	// parse function would return with AmbiguityError, whose Rule1 is the
+	// rule chosen and Rule2 the rule discarded; they are in the order the
+	// parser found them. The result is that chosen by the policy. This
+	// suits a grammar still in development: with AmbiguityLeftmost, input
+	// is parsed in a deterministic way, while its ambiguities are still
+	// made known. Warnings requires a policy other than AmbiguityError.
+	Warnings bool
+
+	// If ParseAll is true, a further parse function returns every parse of
+	// ambiguous input, rather than failing or choosing one:
+	//
+	//	func ParseAll(tokens []interface{}, max int) ([]Goal, error)
+	//
+	// (with the prefix prepended to its name, and the same further
+	// parameters as the parse function before max). It applies the rules of
+	// each derivation of the input in turn, and returns the goal values of
+	// up to max of them, or of all of them if max <= 0. Their number may
+	// grow exponentially with the length of the input, so max should
+	// usually be given. Derivations in which a symbol derives itself,
+	// matching the same input, are omitted, as there could be infinitely
+	// many of them. The Ambiguity policy and Weights do not affect ParseAll;
+	// each match keeps all the ways it was made, so the parser uses more
+	// memory. ParseAll cannot be combined with Scannerless, Associativity
+	// or Precedence.
+	ParseAll bool
+
+	// If ParseTrace is true, a further parse function also returns the
+	// rules applied:
+	//
+	//	func ParseTrace(tokens []interface{}) (Goal, []gleanerrors.Rule, error)
+	//
+	// (with the prefix prepended to its name, and the same parameters as
+	// the parse function). The rules are in the order the parser applied
+	// them, bottom up, so each follows the rules deriving its items and the
+	// goal's rule comes last. The rules of list symbols are included. This
+	// shows how input was parsed, for debugging a grammar or relating the
+	// values built to the input.
+	ParseTrace bool
+
+	// If Events is true, a further parse function returns the rules
+	// applied as a stream of events, for tools building their own
+	// structures from the parse:
+	//
+	//	func ParseEvents(ctx context.Context, tokens []interface{}) (<-chan Event, error)
+	//
+	// (with the prefix prepended to the names of the function and type,
+	// and the same further parameters as ParseTree). Each Event gives the
+	// id of a rule and the range of input it reduced, and the events come
+	// in the order in which the parse function would apply the rules:
+	// those within a rule's match before the rule itself, from left to
+	// right. The input is wholly parsed first, so an error is returned at
+	// once, with no channel. The events are sent from another goroutine,
+	// each once the one before has been received, and the channel is
+	// closed after the last, or early once ctx is done.
+	Events bool
+
+	// If Concurrent is true, a further parse function applies the rules
+	// on several goroutines at once:
+	//
+	//	func ParseConcurrent(tokens []interface{}, workers int) (Target, error)
+	//
+	// (with the prefix prepended, and the same further parameters as the
+	// parse function before workers). The input is matched as by the
+	// parse function; then each rule is applied as soon as the rules
+	// matching its items have been, on one of up to workers goroutines,
+	// or runtime.GOMAXPROCS(0) if workers is less than 1. The rules of
+	// sibling subtrees may so be applied at the same time, while a rule's
+	// own items are always ready before it. This pays only when the rule
+	// functions are slow, as with I/O or heavy computation, and the parse
+	// tree is bushy rather than a long chain; otherwise the scheduling
+	// costs more than it saves.
+	//
+	// The rule functions (or the Reducers or Reducer methods) must then be
+	// safe to call concurrently: they must not modify shared state without
+	// synchronization, nor rely on the order in which the parse function
+	// applies rules. Each value is passed to exactly one rule, so a rule
+	// may modify the values it receives. A panic in a rule function is
+	// raised again in the goroutine calling ParseConcurrent, after the
+	// other goroutines have stopped, so CatchPanics still applies.
+	// Concurrent cannot be combined with Scannerless.
+	Concurrent bool
+
+	// If Tree is true, two further parse functions are written, which
+	// return the concrete syntax tree of the input instead of applying
+	// the rules:
+	//
+	//	func ParseTree(tokens []interface{}) (*Node, error)
+	//	func ParseJSON(tokens []interface{}) ([]byte, error)
+	//
+	// (with the prefix prepended to their names, and the same input
+	// parameters as the parse function, less any reducers). Node is a
+	// generated type holding the rule id, symbol, span and children of a
+	// node, or the token at a leaf. ParseJSON encodes the tree as JSON:
+	// each rule node is an object with keys rule (the rule id), name,
+	// symbol, start, end and children, and each token is an object with
+	// keys symbol, start, end and value. A node for a list symbol has the
+	// matches of its elements as children, and a rule id beyond those of
+	// the rules added to the Grammar. The value is the token as encoded
+	// by encoding/json; if a token cannot be encoded, ParseJSON returns
+	// the error from encoding/json. If the tokens have a method
+	// Span() (start, end int) giving their offsets in the source text,
+	// the Span method of a Node gives the source range of its tokens.
+	Tree bool
+
+	// Hidden lists nonterminal symbols to be left out of the trees
+	// returned by the functions written when Tree is true. Such symbols
+	// typically exist only to express precedence, as in the layering
+	// Sum, Product, Item. Wherever a node for a hidden symbol would appear,
+	// its children take its place, in order, among its parent's children;
+	// when a hidden node has several children, the parent thus gains
+	// several children. The goal symbol may not be hidden.
+	Hidden []glean.Symbol
+
+	// EndSymbol, if not empty, is a terminal symbol marking the end of the
+	// input, so that rules may refer to it, as in
+	//
+	//	func RuleProgram(s Statements, _ EOF) Program
+	//
+	// The parse functions append a token of this type, its zero value,
+	// to the tokens they are given. (It should therefore not be an interface
+	// type.) An empty input is thus parsed as the end token alone, and
+	// gleanerrors.NoInput is not returned. An error at the end token has
+	// the index of the end of the input. ValidPrefix also appends the end
+	// token, so it returns one more than the length of the input exactly
+	// when the input is complete. EndSymbol cannot be used with Scannerless.
+	EndSymbol glean.Symbol
+
+	// If Classifier is true, the parser does not find the symbols of tokens
+	// from their types. Instead, each function taking input has a further
+	// parameter
+	//
+	//	classify func(interface{}) int
+	//
+	// which is called with each token and returns the id of its terminal
+	// symbol. This allows the symbol to depend on context known to the
+	// lexer, or one Go type to serve several terminal symbols through type
+	// aliases. The ids are those of the generated constants prefix +
+	// "Terminal" + symbol, such as _glean_TerminalPlus. A token for which
+	// classify returns any other value is unexpected. Each token must still
+	// be of the Go type of its symbol, as the rule functions receive it.
+	Classifier bool
+
+	// If Alternatives is true, a token may match more than one terminal
+	// symbol. This suits reserved words that are lexed as identifiers but
+	// act as keywords in some positions of the grammar. Alternatives
+	// requires Classifier, and each function taking input has, after
+	// classify, a further parameter
+	//
+	//	alternatives func(interface{}) []int
+	//
+	// which is called with each token and returns the ids of the terminal
+	// symbols the token may match besides the one returned by classify.
+	// Ids that are not those of terminal symbols are ignored. The parser
+	// considers every symbol of each token, keeping those that lead to a
+	// valid parse. The token must suit the rule functions of each of its
+	// symbols; typically the symbols are aliases of one Go type.
+	//
+	// Two parses that differ only in the symbol matched by some token
+	// must differ in the rules applied, so if both are valid the input is
+	// ambiguous, and is treated as any other ambiguous input: it yields a
+	// gleanerrors.Ambiguous error naming two of the rules, or is resolved
+	// by the Ambiguity policy or the Weights. The Example of such an error
+	// and the Name of an Unexpected error use the symbols returned by
+	// classify. Alternatives cannot be combined with Scannerless, whose
+	// token options may already overlap.
+	Alternatives bool
+
+	// Kinds, if not empty, gives for each terminal symbol a Go constant
+	// expression, its kind, and the parser finds the symbols of tokens from
+	// their kinds rather than their Go types. This suits a lexer producing
+	// one struct type for all tokens, such as Token{Kind int; Text string}.
+	// KindFunc then names a function of the parser's package, or of a
+	// package in Imports,
+	//
+	//	func KindFunc(t interface{}) K
+	//
+	// which returns the kind of each token, and the parser switches on its
+	// result. Every terminal symbol must have a kind, and no two the same
+	// expression. The terminal symbols are still Go types, as the rule
+	// functions receive each token as a value of its symbol's type, so they
+	// are typically aliases of the one token type, as in
+	//
+	//	type Plus = Token
+	//
+	// A token whose kind is not that of a terminal symbol causes a panic.
+	// Kinds cannot be combined with Classifier, which classifies tokens by
+	// other means, nor with Scannerless.
+	Kinds    map[glean.Symbol]string
+	KindFunc string
+
+	// If TypeIds is true, the parser finds the symbols of tokens from a
+	// method of their types, rather than with a type switch whose cases it
+	// tries in turn. The type of each terminal symbol must have the method
+	//
+	//	TypeId() int
+	//
+	// returning the id of the symbol, the generated constant prefix +
+	// "Terminal" + symbol, such as _glean_TerminalPlus. The id then
+	// indexes the parser's tables directly, so finding it takes the same
+	// time however many terminal symbols the grammar has; with many, this
+	// is much faster than the type switch. A token whose type lacks the
+	// method, or returns any other id, causes a panic. TypeIds cannot be
+	// combined with Classifier, Kinds or Scannerless. It is implied by
+	// TokenInterface.
+	TypeIds bool
+
+	// TokenInterface, if not empty, names an interface type of the parser's
+	// package, or of a package in Imports, having the method
+	//
+	//	TypeId() int
+	//
+	// and the functions taking input accept a slice of it in place of
+	// []interface{}:
+	//
+	//	func Parse(tokens []Token) (Goal, error)
+	//
+	// This saves a lexer producing such tokens from copying them into a
+	// slice of interface{}, and lets the compiler check their type. TypeId
+	// returns the id of the token's terminal symbol, one of the generated
+	// constants prefix + "Terminal" + symbol, as with Classifier; a token
+	// returning any other id causes a panic. Each token must still be of
+	// the Go type of its symbol, as the rule functions receive it. With
+	// EndSymbol, the end symbol's type must also implement the interface.
+	// The parser still copies the tokens into a slice of its own.
+	// TokenInterface cannot be combined with Classifier, Kinds or
+	// Scannerless.
+	TokenInterface string
+
+	// DisplayNames gives names for terminal symbols, to be shown in
+	// gleanerrors.Unexpected errors in place of the tokens, as in
+	//
+	//	map[glean.Symbol]string{"Open": "(", "Close": ")"}
+	//
+	// The name of the unexpected token's symbol is put in the Name field
+	// of the error, and is then used by its Error method. The names are
+	// kept in a table in the generated parser. Tokens of symbols without
+	// a display name are described by their values or types, as before.
+	DisplayNames map[glean.Symbol]string
+
+	// If ValidPrefix is true, a further function is written:
+	//
+	//	func ValidPrefix(tokens []interface{}) int
+	//
+	// (with the prefix prepended to its name, and the same input parameters
+	// as the parse function, less any reducers). It returns the length of
+	// the longest prefix of the input that can begin a valid input: the
+	// index of the first token that cannot be accepted, or the length of
+	// the input if every token can be. (This assumes that every symbol of
+	// the grammar can match some input.) It shows where input stops being
+	// grammatical, even if it is merely incomplete. Like the parse function,
+	// it panics if a token does not belong to a terminal symbol.
+	ValidPrefix bool
+
+	// If Explain is true, a further function reports why input is rejected:
+	//
+	//	func Explain(tokens []interface{}) string
+	//
+	// (with the prefix prepended to its name, and the same parameters as
+	// ValidPrefix). It parses the input without applying any rules, and
+	// returns the empty string if the input is accepted. If the input is
+	// rejected for a reason other than an unexpected token, such as an
+	// ambiguity, the report is the single line "rejected: " followed by the
+	// error message. Otherwise it has these lines, in this order:
+	//
+	//	rejected at token N of L: <the gleanerrors.Unexpected message>
+	//	consumed: <the symbols of the tokens before token N>
+	//	expected: <the terminal symbols that could follow, sorted by name>
+	//	partial rules:
+	//		<rule> from S: <target> = <items matched> . <items remaining>
+	//
+	// with the symbol names separated by spaces, and "end of input" last
+	// among the expected symbols if the tokens before token N are already
+	// complete. Each partial rule line, indented by a tab, gives a rule of
+	// which some items, but not all, match the tokens from token S to just
+	// before token N. The lines are meant for people, and their wording may
+	// change, but the order of the sections will not. Explain cannot be
+	// combined with Scannerless.
+	Explain bool
+
+	// If LongestPrefix is true, a further parse function parses the longest
+	// prefix of its input that is a complete goal, rather than the whole:
+	//
+	//	func ParseLongest(tokens []interface{}) (Goal, int, error)
+	//
+	// (with the prefix prepended to its name, and the same parameters as the
+	// parse function). It also returns the number of tokens in the prefix,
+	// leaving those after it to the caller, as when input arrives as a
+	// stream. Only complete goals starting at the first token are
+	// considered, and of these the one ending furthest on is chosen, even
+	// if the tokens following it cannot continue the input. If two rules
+	// complete the goal at that point, or the prefix is otherwise
+	// ambiguous, this is reported or resolved as by the parse function;
+	// shorter prefixes are not then tried. If no prefix is a complete
+	// goal, the error is that of the parse function. LongestPrefix cannot
+	// be combined with Scannerless or EndSymbol.
+	LongestPrefix bool
+
+	// If PartialInput is true, the parse function need not consume all of
+	// its input. It parses the longest prefix of the input that is a
+	// complete goal, as ParseLongest does, and also returns the tokens
+	// after it, which may be empty:
+	//
+	//	func Parse(tokens []interface{}) (Goal, []interface{}, error)
+	//
+	// The remaining tokens share the memory of the tokens given. As with
+	// ParseLongest, shorter prefixes are not tried if the longest is
+	// ambiguous; the ambiguity is reported or resolved as usual. The other
+	// parse functions, such as ParseTree, still require the whole input to
+	// be a goal. PartialInput cannot be combined with Scannerless or
+	// EndSymbol.
+	PartialInput bool
+
+	// If Sequence is true, a further parse function parses its input as a
+	// sequence of goals, one after another, as for a file holding a series
+	// of independent declarations, without a recursive rule to collect them:
+	//
+	//	func ParseSequence(tokens []interface{}) ([]Goal, error)
+	//
+	// (with the prefix prepended to its name, and the same parameters as the
+	// parse function). Each goal is found as by ParseLongest, from the
+	// tokens after the one before, until the input is used up. The choice
+	// is greedy: each goal is the longest possible, even if a shorter one
+	// would let the rest of the input be parsed. A goal matching no tokens
+	// is never returned; if the longest goal at some point is empty, that
+	// point's token is reported as unexpected. An empty input is an empty
+	// sequence. The first error ends the parse, and is returned with the
+	// goals before it; in a gleanerrors.Unexpected, gleanerrors.Ambiguous
+	// or gleanerrors.MultiAmbiguous error, the indexes are those within the
+	// whole input. Sequence cannot be combined with Scannerless or EndSymbol.
+	Sequence bool
+
+	// If Complete is true, a further function is written, suggesting ways
+	// to complete an input:
+	//
+	//	func Complete(tokens []interface{}, max int) [][]string
+	//
+	// (with the prefix prepended to its name, and the same input parameters
+	// as the parse function, less any reducers). Each suggestion is a
+	// sequence of terminal symbol names which, appended to the input, would
+	// make a valid input. For each incomplete match of a rule prefix at the
+	// end of the input, from which the goal can still be reached, the
+	// shortest such sequence is found; these are returned shortest first,
+	// without duplicates, at most max of them. A suggestion is empty if the
+	// input is already valid. If the input cannot begin a valid input,
+	// Complete returns nil. With EndSymbol, the end token is not appended,
+	// so the suggestions end with the end symbol. Complete cannot be used
+	// with Scannerless.
+	Complete bool
+
+	// If CatchPanics is true, each parse function recovers from any panic
+	// during the parse, whether in the parser itself or in a rule function,
+	// and returns a gleanerrors.Internal error holding the value passed to
+	// panic and the input tokens. (In the Scannerless parser, the tokens
+	// are the first offered at each position reached.) This is a safety net
+	// for parsers embedded in long running programs; without it, panics
+	// propagate with their stack traces, which is better during development.
+	CatchPanics bool
+
+	// If Debug is true, a further parse function lets the growth of its
+	// chart be observed:
+	//
+	//	func ParseHook(tokens []interface{}, hook func(position, matchCount int)) (Goal, error)
+	//
+	// (with the prefix prepended to its name, and the same parameters as
+	// the parse function before hook). It calls hook as it finishes each
+	// position of the input, passing the number of matches of rule
+	// prefixes that end there. A position with an unusually large count
+	// points at an ambiguous construct in the grammar. The hook belongs to
+	// one parse, so parses with different hooks may run at once. In the
+	// Scannerless parser, positions where no match ends are passed over
+	// silently.
+	Debug bool
+
+	// If Stepping is true, the parser declares a type DebugParser, which
+	// finds the matches of rule prefixes one position of the input at a
+	// time, so that the working of the Earley algorithm may be followed:
+	//
+	//	func NewDebugParser(tokens []interface{}) *DebugParser
+	//	func (d *DebugParser) Step() (done bool)
+	//	func (d *DebugParser) Matches(position int) []DebugMatch
+	//	func (d *DebugParser) Result() (Goal, error)
+	//
+	// (with the prefix prepended to the names of the function and types,
+	// and the same parameters as the parse function). Between steps,
+	// Matches lists the matches ending at a position, each with a rule,
+	// the number of its items matched, and the position where the match
+	// starts. DebugParser also has methods Done, Position and Err, and
+	// Result finishes the parse. Stepping cannot be combined with
+	// Scannerless or Recover.
+	Stepping bool
+
+	// MaxItems, if positive, is the largest number of items AddRule accepts
+	// in a rule. A longer rule is rejected with an error, guarding against
+	// a production pasted by mistake.
+	MaxItems int
+
+	// LongRuleFactor sets when Grammar.Validate warns of a long rule: when
+	// its item count is more than LongRuleFactor times the median item count
+	// of the grammar's rules (taken as at least 1). Such a rule often ought
+	// to be factored into smaller ones. If LongRuleFactor is 0, the factor
+	// DefaultLongRuleFactor is used; if it is negative, there is no warning.
+	LongRuleFactor int
+
+	// ErrorsImport is the import path from which the parser imports
+	// the gleanerrors package. If empty, DefaultErrorsImport is used.
+	// This is useful when gleanerrors has been vendored or forked.
+	ErrorsImport string
+
+	// Intern lists terminal symbols whose values are interned: of the
+	// tokens of such a symbol that are equal, as by ==, the parser passes
+	// the first to the rule functions in place of each of the others, and
+	// with Tree, puts the first in the parse tree. Where tokens hold
+	// pointers, such as identifiers held as strings, which each token holds
+	// separately, the values kept by the caller then share the memory of
+	// one token, and the others may be freed. Each parse has its own pool,
+	// emptied when it applies the rules, so values are not shared between
+	// parses. The Go types of these symbols must be comparable; the
+	// parser does not compile otherwise.
+	Intern []glean.Symbol
+
+	// If Unchecked is true, the parser does not check the values supplied
+	// by its caller, for somewhat faster parsing of trusted input. This is
+	// not safe for untrusted input. The checks removed are these:
+	//
+	//   - the type switch on tokens has no case for the last terminal
+	//     symbol, taking any token not of another terminal's type to be of
+	//     that symbol's type, rather than panicking;
+	//   - with Classifier, the ids returned by classify, and with
+	//     Alternatives, by alternatives, are not checked to be those of
+	//     terminal symbols;
+	//   - with Scannerless, the lengths of token options are not checked to
+	//     be positive and to lie within the input;
+	//   - with Registry, the parse functions do not check that a reducer
+	//     is registered for every rule.
+	//
+	// The caller must ensure that every token is of a terminal symbol's
+	// type, that every id is that of a terminal symbol, that every length
+	// is valid, and that every reducer is registered. The parser still
+	// reports unexpected tokens and ambiguities as usual, and Go's own
+	// bounds checks remain, so input breaking this contract leads to a
+	// panic or a wrong result, not to corrupted memory.
+	Unchecked bool
+
+	// If UnknownTokens is true, a token that is not of any terminal
+	// symbol's type does not make the parser panic. The parse functions
+	// instead return a gleanerrors.UnknownToken error, with the token and
+	// its index; ParseRecover reports the error and skips the token, as it
+	// does an unexpected one. This applies also to the TypeId method of
+	// TypeIds and TokenInterface returning an id that is not a terminal's,
+	// and with Kinds to a kind that is not a terminal's. UnknownTokens
+	// cannot be combined with Unchecked, which drops the check, nor with
+	// Classifier, whose unknown ids are already reported as unexpected.
+	UnknownTokens bool
+
+	// PrefixType, RuleType and SymbolType, if not empty, replace the stems
+	// _Prefix, _Rule and _Symbol in the names of the integer types the
+	// parser uses internally for rule prefixes, rules and symbols. The
+	// prefix is still prepended, so with the prefix _glean_ and SymbolType
+	// "_internalSymbol", the type is named _glean__internalSymbol. This
+	// helps to tell these types from those of the package when reading
+	// stack traces. Each name must be a valid identifier, and must not be
+	// that of anything else the parser declares.
+	PrefixType, RuleType, SymbolType string
+
+	// Imports gives the import path of each package named in the qualified
+	// rule and symbol names, such as "ast" in "ast.Statement", keyed by the
+	// package name. The scanner gives such names to types of imported
+	// packages, and with AllowMultiplePackages to all names; it adds the
+	// paths of the imported packages through AddImport, but those of the
+	// scanned packages themselves must be given here. The parser imports
+	// each package under its name, so the name must not be that of the
+	// parser's own package, nor one of the packages the parser imports
+	// itself. WriteParser fails if a package named in the grammar has no
+	// import path. Within the parser, the dot of a qualified name becomes
+	// an underscore in the names derived from it, such as the constants of
+	// Classifier.
+	Imports map[string]string
+}
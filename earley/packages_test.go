@@ -0,0 +1,153 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test a parser for rules gathered from several packages
+func TestMultiplePackages(t *testing.T) {
+	tmp := t.TempDir()
+	write := func(name, text string) string {
+		file := filepath.Join(tmp, name)
+		if e := os.MkdirAll(filepath.Dir(file), 0700); e != nil {
+			t.Fatal(e)
+		}
+		if e := os.WriteFile(file, []byte(text), 0444); e != nil {
+			t.Fatal(e)
+		}
+		return file
+	}
+
+	// The parser imports gleanerrors from this copy of glean.
+	geText, e := os.ReadFile("../gleanerrors/gleanerrors.go")
+	if e != nil {
+		t.Fatal(e)
+	}
+	write("gleanerrors/gleanerrors.go", string(geText))
+	write("go.mod", "module github.com/pat42smith/glean\n")
+	exprGo := write("expr/expr.go", packagesExprText)
+	stmtGo := write("stmt/stmt.go", packagesStmtText)
+	mainGo := write("main/main.go", packagesMainText)
+
+	var g earley.Grammar
+	options := glean.ScanOptions{AllowMultiplePackages: true}
+	if _, _, e := glean.ScanFilesWithOptions(&g, options, exprGo, stmtGo); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := g.WriteParser("stmt.Stmt", "main", "_"); e == nil {
+		t.Error("no error for missing import paths")
+	}
+	g.Options.Imports = map[string]string{
+		"expr": "github.com/pat42smith/glean/expr",
+		"stmt": "github.com/pat42smith/glean/stmt",
+	}
+	parserText, e := g.WriteParser("stmt.Stmt", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+	parserGo := write("main/parser.go", parserText)
+
+	cmd := exec.Command("go", "run", mainGo, parserGo)
+	cmd.Dir = filepath.Join(tmp, "main")
+	cmd.Env = append(os.Environ(), "GOWORK=off")
+	out, e := cmd.CombinedOutput()
+	if e != nil {
+		t.Fatal(e, string(out))
+	}
+	if string(out) != "print 6\n" {
+		t.Errorf("wrong output: %s", out)
+	}
+
+	g.Options.Imports["main"] = "github.com/pat42smith/glean/main"
+	if _, e := g.WriteParser("stmt.Stmt", "main", "_"); e == nil {
+		t.Error("no error for importing the parser's own package")
+	}
+}
+
+var packagesExprText = `
+package expr
+
+type Expr int
+type Plus struct{}
+
+func RuleInt(i int) Expr               { return Expr(i) }
+func RuleAdd(x Expr, _ Plus, i int) Expr { return x + Expr(i) }
+`
+
+var packagesStmtText = `
+package stmt
+
+import (
+	"fmt"
+
+	"github.com/pat42smith/glean/expr"
+)
+
+type Stmt string
+type Print struct{}
+
+func RulePrint(_ Print, x expr.Expr) Stmt { return Stmt(fmt.Sprint("print ", x)) }
+`
+
+var packagesMainText = `
+package main
+
+import (
+	"fmt"
+
+	"github.com/pat42smith/glean/expr"
+	"github.com/pat42smith/glean/stmt"
+)
+
+func main() {
+	s, e := _Parse([]interface{}{stmt.Print{}, 1, expr.Plus{}, 2, expr.Plus{}, 3})
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(s)
+}
+`
+
+// Test a parser using a type from an imported package as a symbol
+func TestImportedTypes(t *testing.T) {
+	for _, options := range []earley.Options{{}, {Tree: true}, {Intern: []glean.Symbol{"time.Duration"}}} {
+		parse, e := gleantest.Build(t, importedMainText, "Sum", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if out, e := parse(); e != nil || out != "3m5s <nil>\n" {
+			t.Errorf("options %+v: wrong output: %s %v", options, out, e)
+		}
+	}
+}
+
+var importedMainText = `
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type Sum time.Duration
+type Plus struct{}
+
+func RuleOne(d time.Duration) Sum            { return Sum(d) }
+func RuleSum(a Sum, _ Plus, b time.Duration) Sum { return a + Sum(b) }
+
+func main() {
+	sum, e := _glean_Parse([]interface{}{3 * time.Minute, Plus{}, 5 * time.Second})
+	fmt.Println(time.Duration(sum), e)
+}
+`
@@ -86,9 +86,9 @@ func TestParseErrors(t *testing.T) {
 		rule1, rule2 string,
 		target string, items1, items2 []string,
 		where1 int, token1 string, where2 int, token2 string,
-		args ...string) {
+		example []string, args ...string) {
 		f :=
-			`gleanerrors.Ambiguous{Range:gleanerrors.Range{First:gleanerrors.Location{Index:%d, Token:%s}, Last:gleanerrors.Location{Index:%d, Token:%s}}, Rule1:gleanerrors.Rule{Name:"%s", Target:"%s", Items:%#v}, Rule2:gleanerrors.Rule{Name:"%s", Target:"%s", Items:%#v}}
+			`gleanerrors.Ambiguous{Range:gleanerrors.Range{First:gleanerrors.Location{Index:%d, Token:%s, Line:0, Column:0}, Last:gleanerrors.Location{Index:%d, Token:%s, Line:0, Column:0}}, Rule1:gleanerrors.Rule{Name:"%s", Target:"%s", Items:%#v}, Rule2:gleanerrors.Rule{Name:"%s", Target:"%s", Items:%#v}, Example:%#v}
 ambiguous match for %s
    %s: %s
 or %s: %s
@@ -96,10 +96,10 @@ or %s: %s
 		i1 := strings.Join(items1, " ")
 		i2 := strings.Join(items2, " ")
 		expect1 := fmt.Sprintf(f, where1, token1, where2, token2,
-			rule1, target, items1, rule2, target, items2,
+			rule1, target, items1, rule2, target, items2, example,
 			target, rule1, i1, rule2, i2)
 		expect2 := fmt.Sprintf(f, where1, token1, where2, token2,
-			rule2, target, items2, rule1, target, items1,
+			rule2, target, items2, rule1, target, items1, example,
 			target, rule2, i2, rule1, i1)
 
 		out := runok(t2, args...)
@@ -113,11 +113,11 @@ or %s: %s
 	})
 
 	t.Run("Unexpected", func(t2 *testing.T) {
-		try(t2, "gleanerrors.Unexpected{Location:gleanerrors.Location{Index:1, Token:17}}\nunexpected token: 17", "3", "17")
+		try(t2, "gleanerrors.Unexpected{Location:gleanerrors.Location{Index:1, Token:17, Line:0, Column:0}, Name:\"\", Expected:[]string{\"Open\", \"Plus\"}}\nunexpected token: 17", "3", "17")
 	})
 
 	t.Run("Incomplete", func(t2 *testing.T) {
-		try(t2, "gleanerrors.Unexpected{Location:gleanerrors.Location{Index:2, Token:interface {}(nil)}}\nunexpected end of input", "100", "+")
+		try(t2, "gleanerrors.Unexpected{Location:gleanerrors.Location{Index:2, Token:interface {}(nil), Line:0, Column:0}, Name:\"\", Expected:[]string{\"int\"}}\nunexpected end of input", "100", "+")
 	})
 
 	t.Run("BadToken", func(t2 *testing.T) {
@@ -133,35 +133,35 @@ or %s: %s
 	t.Run("Ambiguous1", func(t2 *testing.T) {
 		ambiguity(t2, "RuleAdd", "RuleAdd",
 			"Expr", []string{"Expr", "Plus", "Expr"}, []string{"Expr", "Plus", "Expr"},
-			0, "2", 4, "5",
+			0, "2", 4, "5", []string{"int", "Plus", "int", "Plus", "int"},
 			"2", "+", "3", "+", "5")
 	})
 
 	t.Run("Ambiguous2", func(t2 *testing.T) {
 		ambiguity(t2, "RuleOpenClose", "RulePair",
 			"Goal", []string{"Open", "Close"}, []string{"Pair"},
-			0, "main.Open{}", 1, "main.Close{}",
+			0, "main.Open{}", 1, "main.Close{}", []string{"Open", "Close"},
 			"(", ")")
 	})
 
 	t.Run("Ambiguous3", func(t2 *testing.T) {
 		ambiguity(t2, "RuleOpenClose", "RulePair",
 			"Goal", []string{"Open", "Close"}, []string{"Pair"},
-			1, "main.Open{}", 2, "main.Close{}",
+			1, "main.Open{}", 2, "main.Close{}", []string{"Open", "Close"},
 			"(", "(", ")", ")")
 	})
 
 	t.Run("Ambiguous4", func(t2 *testing.T) {
 		ambiguity(t2, "RuleNull0", "RuleNil0",
 			"Nothing", []string{"Null"}, []string{"Nil"},
-			1, "main.Open{}", 0, "main.Plus{}",
+			1, "main.Open{}", 0, "main.Plus{}", []string(nil),
 			"+", "(", ")")
 	})
 
 	t.Run("Ambiguous5", func(t2 *testing.T) {
 		ambiguity(t2, "RuleBlank", "RuleBlank2",
 			"Blank", []string{}, []string{"Blank", "Blank"},
-			0, "99", -1, "interface {}(nil)",
+			0, "99", -1, "interface {}(nil)", []string(nil),
 			"99", "(", ")")
 	})
 }
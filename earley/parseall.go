@@ -0,0 +1,109 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the function returning every parse of the input, if requested
+func (g *Grammar) addParseAll() {
+	if !g.Options.ParseAll {
+		return
+	}
+
+	g.addText(`
+// @ParseAll parses the input as @Parse does, but rather than failing if the
+// input is ambiguous, it applies the rules of each of its derivations, and
+// returns the goal values of up to max of them; if max <= 0, of all of them.
+// Derivations in which a symbol derives itself, matching the same input,
+// are omitted.
+func @ParseAll(`)
+	g.addInputParams(true)
+	g.addText(", max int")
+	g.addResults("[]#G", "error")
+	g.addParserInit(true)
+	g.addCatch("catch", 1)
+	g.addReducerCheck("nil")
+	g.addText(`	parser.prepare()
+	if len(parser.tokens) == 0 {
+		return nil, gleanerrors.NoInput{}
+	}
+	if e := parser.findMatches(); e != nil {
+		return nil, e
+	}
+
+	n := len(parser.tokens)
+	parser.deriving = make(map[@_Span]bool)
+	var results []#G
+	yield := func(trace []func(*@_Parser)) bool {
+		parser.trace = trace
+		results = append(results, parser.applyTrace())
+		return max <= 0 || len(results) < max
+	}
+	for _, p := range @_goalPrefixes {
+		for _, m := range parser.at(n)[p] {
+			if m.start == 0 && !parser.derive(m, []func(*@_Parser){@_appliers[p]}, yield) {
+				return results, nil
+			}
+		}
+	}
+	if results == nil {
+		return nil, parser.unexpected(parser.tokens, n, n)
+	}
+	return results, nil
+}
+
+// A symbol and the range of input matched by it
+type @_Span struct {
+	symbol     @_Symbol
+	start, end int
+}
+
+// derive calls yield with each trace of the rules deriving the complete
+// match m, appended to trace, in the order findTrace would give it, while
+// yield returns true. It returns false once yield has. The traces may
+// share the start of trace, so yield must not keep them. A derivation
+// of m's symbol and range within that of m itself is skipped.
+func (parser *@_Parser) derive(m *@_Match, trace []func(*@_Parser), yield func([]func(*@_Parser)) bool) bool {
+	span := @_Span{@_prefixTarget[m.prefix], m.start, m.end}
+	if parser.deriving[span] {
+		return true
+	}
+	parser.deriving[span] = true
+	more := parser.deriveParts(m, trace, func(trace []func(*@_Parser)) bool {
+		// The rest of the trace is outside m, and may match the same
+		// symbol and range again if they are empty.
+		delete(parser.deriving, span)
+		more := yield(trace)
+		parser.deriving[span] = true
+		return more
+	})
+	delete(parser.deriving, span)
+	return more
+}
+
+// deriveParts calls yield as derive does, for the items of the match m,
+// which need not be complete
+func (parser *@_Parser) deriveParts(m *@_Match, trace []func(*@_Parser), yield func([]func(*@_Parser)) bool) bool {
+	for _, a := range m.alternatives {
+		shorter, last := a[0], a[1]
+		rest := func(trace []func(*@_Parser)) bool {
+			if shorter == nil {
+				return yield(trace)
+			}
+			return parser.deriveParts(shorter, trace, yield)
+		}
+		var more bool
+		if last != nil {
+			more = parser.derive(last, append(trace, @_appliers[last.prefix]), rest)
+		} else if t := @_lastTerminal[m.prefix]; t >= 0 {
+			more = rest(append(trace, @_applyTerminal[t]))
+		} else {
+			more = rest(trace)
+		}
+		if !more {
+			return false
+		}
+	}
+	return true
+}
+`)
+}
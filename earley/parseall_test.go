@@ -0,0 +1,99 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test returning every parse of ambiguous input
+func TestParseAll(t *testing.T) {
+	for _, options := range []earley.Options{
+		{ParseAll: true},
+		{ParseAll: true, Ambiguity: earley.AmbiguityLeftmost},
+		{ParseAll: true, Weights: map[string]int{"RuleAdd": 1}},
+		{ParseAll: true, Unchecked: true, GenericStacks: true},
+	} {
+		parse, e := gleantest.Build(t, parseAllMainText, "Expr", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		expect := `[1]
+[(1+2)]
+[((1+2)+3) (1+(2+3))]
+[(((1+2)+3)+4) ((1+(2+3))+4) ((1+2)+(3+4)) (1+((2+3)+4)) (1+(2+(3+4)))]
+2
+[(1)]
+[((1)+2)]
+no tokens in parser input
+unexpected token: main.Plus
+unexpected end of input
+`
+		if out, e := parse(); e != nil || out != expect {
+			t.Errorf("options %+v:\nexpected:\n%s\ngot:\n%s %v", options, expect, out, e)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleAdd", "Expr", []glean.Symbol{"Expr", "Plus", "Expr"})
+	g.AddRule("RuleInt", "Expr", []glean.Symbol{"int"})
+	for _, options := range []earley.Options{
+		{ParseAll: true, Scannerless: true},
+		{ParseAll: true, Associativity: map[glean.Symbol]earley.Ambiguity{"Plus": earley.AmbiguityLeftmost}},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Expr", "main", "_"); e == nil {
+			t.Errorf("no error for options %+v", options)
+		}
+	}
+}
+
+var parseAllMainText = `
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+type Plus struct{}
+type Open struct{}
+type Close struct{}
+type Expr string
+
+func RuleAdd(x Expr, _ Plus, y Expr) Expr { return "(" + x + "+" + y + ")" }
+func RuleInt(x int) Expr                  { return Expr(fmt.Sprint(x)) }
+
+// Parenthesized expressions derive themselves through RuleParen and
+// RuleBare, in a cycle
+func RuleParen(_ Open, x Expr, _ Close) Expr { return "(" + x + ")" }
+func RuleBare(x Expr) Expr                   { return x }
+
+func show(exprs []Expr, e error) {
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	sort.Slice(exprs, func(i, j int) bool { return exprs[i] < exprs[j] })
+	fmt.Println(exprs)
+}
+
+func main() {
+	show(_glean_ParseAll([]interface{}{1}, 0))
+	show(_glean_ParseAll([]interface{}{1, Plus{}, 2}, 0))
+	show(_glean_ParseAll([]interface{}{1, Plus{}, 2, Plus{}, 3}, 0))
+	show(_glean_ParseAll([]interface{}{1, Plus{}, 2, Plus{}, 3, Plus{}, 4}, 0))
+	exprs, _ := _glean_ParseAll([]interface{}{1, Plus{}, 2, Plus{}, 3, Plus{}, 4}, 2)
+	fmt.Println(len(exprs))
+	show(_glean_ParseAll([]interface{}{Open{}, 1, Close{}}, 0))
+	show(_glean_ParseAll([]interface{}{Open{}, 1, Close{}, Plus{}, 2}, 0))
+	show(_glean_ParseAll([]interface{}{}, 0))
+	show(_glean_ParseAll([]interface{}{1, Plus{}, Plus{}}, 0))
+	show(_glean_ParseAll([]interface{}{1, Plus{}}, 0))
+}
+`
@@ -0,0 +1,31 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the generic parse function returning the goal as a given type,
+// if requested
+func (g *Grammar) addParseAs() {
+	if !g.Options.ParseAs {
+		return
+	}
+
+	g.addText(`
+// @ParseAs parses the input as @Parse does, then returns the goal's value
+// as a T, or an error if it is not one.
+func @ParseAs[T any](`)
+	g.addInputParams(true)
+	g.addText(") (T, error) {\n\tvar zero T\n\tgoal, e := @Parse(")
+	g.addInputArgs(true)
+	g.addText(`)
+	if e != nil {
+		return zero, e
+	}
+	t, ok := interface{}(goal).(T)
+	if !ok {
+		return zero, fmt.Errorf("parse result of type %T is not a %v", goal, reflect.TypeOf(&zero).Elem())
+	}
+	return t, nil
+}
+`)
+}
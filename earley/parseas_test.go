@@ -0,0 +1,89 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test the generic parse function converting the goal
+func TestParseAs(t *testing.T) {
+	for _, options := range []earley.Options{
+		{ParseAs: true},
+		{ParseAs: true, Registry: true},
+		{ParseAs: true, CatchPanics: true, GoVersion: "1.18"},
+	} {
+		source := parseAsMainText
+		if options.Registry {
+			source += parseAsRegistryText
+		} else {
+			source += parseAsPlainText
+		}
+		parse, e := gleantest.Build(t, source, "Sum", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		expect := `sum 5 <nil>
+sum 5 <nil>
+0 parse result of type main.Sum is not a int
+<nil> unexpected token: main.Plus
+`
+		if out, e := parse(); e != nil || out != expect {
+			t.Errorf("options %+v:\nexpected:\n%s\ngot:\n%s %v", options, expect, out, e)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+	for _, options := range []earley.Options{
+		{ParseAs: true, GoVersion: "1.17"},
+		{ParseAs: true, PartialInput: true},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+			t.Errorf("no error for options %+v", options)
+		}
+	}
+}
+
+var parseAsMainText = `
+package main
+
+import "fmt"
+
+type Sum int
+type Plus struct{}
+
+func (s Sum) String() string { return fmt.Sprint("sum ", int(s)) }
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+`
+
+var parseAsPlainText = `
+func main() {
+	tokens := []interface{}{2, Plus{}, 3}
+	fmt.Println(_glean_ParseAs[fmt.Stringer](tokens))
+	fmt.Println(_glean_ParseAs[Sum](tokens))
+	fmt.Println(_glean_ParseAs[int](tokens))
+	fmt.Println(_glean_ParseAs[fmt.Stringer]([]interface{}{Plus{}}))
+}
+`
+
+var parseAsRegistryText = `
+func main() {
+	reducers := make(_glean_Reducers)
+	reducers.Register("RuleInt", func(x []interface{}) interface{} { return RuleInt(x[0].(int)) })
+	reducers.Register("RuleAdd", func(x []interface{}) interface{} { return RuleAdd(x[0].(Sum), Plus{}, x[2].(int)) })
+	tokens := []interface{}{2, Plus{}, 3}
+	fmt.Println(_glean_ParseAs[fmt.Stringer](tokens, reducers))
+	fmt.Println(_glean_ParseAs[Sum](tokens, reducers))
+	fmt.Println(_glean_ParseAs[int](tokens, reducers))
+	fmt.Println(_glean_ParseAs[fmt.Stringer]([]interface{}{Plus{}}, reducers))
+}
+`
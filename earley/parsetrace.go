@@ -0,0 +1,33 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the parse function returning the rules applied, if requested
+func (g *Grammar) addParseTrace() {
+	if !g.Options.ParseTrace {
+		return
+	}
+
+	g.addText(`
+// @ParseTrace parses the input as @Parse does, and also returns the rules
+// it applied, in the order it applied them, each after those of its items.
+func @ParseTrace(`)
+	g.addInputParams(true)
+	g.addResults("#G", "[]gleanerrors.Rule", "error")
+	g.addParserInit(true)
+	g.addCatch("catch", 2)
+	g.addText(`	result, e := parser.parse()
+	if e != nil {
+		return result, nil, e
+	}
+	var rules []gleanerrors.Rule
+	for n := len(parser.steps) - 1; n >= 0; n-- {
+		if step := parser.steps[n]; step >= 0 {
+			rules = append(rules, @_ruledesc[@_prefix2rule[step]])
+		}
+	}
+	return result, rules, nil
+}
+`)
+}
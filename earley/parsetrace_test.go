@@ -0,0 +1,67 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test returning the rules applied by a parse
+func TestParseTrace(t *testing.T) {
+	for _, options := range []earley.Options{
+		{ParseTrace: true},
+		{ParseTrace: true, Concurrent: true, CatchPanics: true},
+	} {
+		parse, e := gleantest.Build(t, parseTraceMainText, "Sum", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		expect := `6 [RuleInt RuleTerm RuleParen RuleTerm RuleInt RuleAdd RuleInt RuleAdd]
+3 [RuleInt RuleTerm]
+unexpected token: main.Plus
+`
+		if out, e := parse(); e != nil || out != expect {
+			t.Errorf("options %+v:\nexpected:\n%s\ngot:\n%s %v", options, expect, out, e)
+		}
+	}
+}
+
+var parseTraceMainText = `
+package main
+
+import "fmt"
+
+type Plus struct{}
+type Open struct{}
+type Close struct{}
+type Sum int
+type Term int
+
+func RuleAdd(s Sum, _ Plus, t Term) Sum      { return s + Sum(t) }
+func RuleTerm(t Term) Sum                    { return Sum(t) }
+func RuleInt(x int) Term                     { return Term(x) }
+func RuleParen(_ Open, s Sum, _ Close) Term { return Term(s) }
+
+func try(tokens ...interface{}) {
+	sum, rules, e := _glean_ParseTrace(tokens)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	var names []string
+	for _, r := range rules {
+		names = append(names, r.Name)
+	}
+	fmt.Println(sum, names)
+}
+
+func main() {
+	try(Open{}, 1, Close{}, Plus{}, 2, Plus{}, 3)
+	try(3)
+	try(1, Plus{}, Plus{})
+}
+`
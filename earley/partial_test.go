@@ -0,0 +1,69 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test a parse function that need not consume all its input
+func TestPartialInput(t *testing.T) {
+	var options earley.Options
+	options.PartialInput = true
+	options.CatchPanics = true
+	parse, e := gleantest.Build(t, partialMainText, "Sum", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `6 []interface {}{} <nil>
+3 []interface {}{main.Plus{}} <nil>
+3 []interface {}{3, main.Plus{}, 4} <nil>
+0 []interface {}(nil) unexpected token: main.Plus
+0 []interface {}(nil) no tokens in parser input
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+	for _, options := range []earley.Options{
+		{PartialInput: true, Scannerless: true},
+		{PartialInput: true, EndSymbol: "int"},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+			t.Errorf("no error for options %v", options)
+		}
+	}
+}
+
+var partialMainText = `
+package main
+
+import "fmt"
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{1, Plus{}, 2, Plus{}, 3},
+		{1, Plus{}, 2, Plus{}},
+		{1, Plus{}, 2, 3, Plus{}, 4},
+		{Plus{}},
+		{},
+	} {
+		sum, rest, e := _glean_Parse(tokens)
+		fmt.Printf("%d %#v %v\n", sum, rest, e)
+	}
+}
+`
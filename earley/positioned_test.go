@@ -0,0 +1,65 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test the positions reported for tokens implementing gleanerrors.Positioned,
+// in both the message and the Go syntax of an Unexpected error
+func TestPositioned(t *testing.T) {
+	parse, e := gleantest.Build(t, positionedMainText, "Sum", earley.Options{})
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `unexpected token: main.Plus at line 2, column 7
+gleanerrors.Unexpected{Location:gleanerrors.Location{Index:2, Token:main.Plus{Line:2, Col:7}, Line:2, Column:7}, Name:"", Expected:[]string{"int"}}
+2 7
+unexpected token: 5
+gleanerrors.Unexpected{Location:gleanerrors.Location{Index:1, Token:5, Line:0, Column:0}, Name:"", Expected:[]string{"Plus"}}
+0 0
+unexpected end of input
+gleanerrors.Unexpected{Location:gleanerrors.Location{Index:2, Token:interface {}(nil), Line:0, Column:0}, Name:"", Expected:[]string{"int"}}
+0 0
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+}
+
+var positionedMainText = `
+package main
+
+import (
+	"fmt"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Plus struct{ Line, Col int }
+type Sum int
+
+func (p Plus) Pos() (int, int) { return p.Line, p.Col }
+
+func RuleInt(x int) Sum                { return Sum(x) }
+func RuleAdd(s Sum, _ Plus, x int) Sum { return s + Sum(x) }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{1, Plus{1, 3}, Plus{2, 7}},
+		{1, 5},
+		{1, Plus{1, 3}},
+	} {
+		_, e := _glean_Parse(tokens)
+		fmt.Println(e)
+		fmt.Printf("%#v\n", e)
+		u := e.(gleanerrors.Unexpected)
+		fmt.Println(u.Line, u.Column)
+	}
+}
+`
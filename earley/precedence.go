@@ -0,0 +1,92 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+)
+
+// Check Options.Precedence, and find the precedence level of each rule,
+// or 0 for none, indexed by rule id
+func (g *Grammar) checkPrecedence() ([]int, error) {
+	if len(g.Options.Precedence) == 0 {
+		return nil, nil
+	}
+	if len(g.Options.Weights) > 0 {
+		return nil, fmt.Errorf("options Precedence and Weights cannot be combined")
+	}
+	for name, level := range g.Options.Precedence {
+		if s := g.name2symbol[name]; s == nil || !s.isTerminal() {
+			return nil, fmt.Errorf("precedence given for '%s', which is not a terminal symbol", name)
+		}
+		if level <= 0 {
+			return nil, fmt.Errorf("precedence of '%s' is not positive", name)
+		}
+	}
+
+	prec := make([]int, len(g.rules)+len(g.listRules))
+	for _, r := range g.rules {
+		var from *symbol
+		for _, i := range r.items {
+			level, have := g.Options.Precedence[i.name]
+			if !have {
+				continue
+			}
+			if from != nil && level != prec[r.id] {
+				return nil, fmt.Errorf("rule %s has symbols '%s' and '%s' of different precedence", r.name, from.name, i.name)
+			}
+			from = i
+			prec[r.id] = level
+		}
+	}
+	return prec, nil
+}
+
+// Add the precedence of each prefix that completes a rule, and the
+// function comparing matches by it, if any symbols have precedence
+func (g *Grammar) addPrecedence() {
+	if g.prec == nil {
+		return
+	}
+	g.addText("\nvar @_precedence = []int{\n")
+	for _, p := range g.prefixes {
+		level := 0
+		if r := p.completedRule(); r != nil {
+			level = g.prec[r.id]
+		}
+		g.addf("\t%d,\n", level)
+	}
+	g.addString("}\n")
+
+	g.addText(`
+// Compare complete matches of different rules for the same tokens: 1 if
+// the precedence of the rules prefers m1, -1 if it prefers m2, and 0 if
+// it does not decide
+func @_outranks(m1, m2 *@_Match) int {
+	if m1 == nil || m2 == nil {
+		return 0
+	}
+	p1, p2 := @_precedence[m1.prefix], @_precedence[m2.prefix]
+	switch {
+	case p1 == 0 || p2 == 0:
+		return 0
+	case p1 < p2:
+		return 1
+	case p1 > p2:
+		return -1
+	}
+`)
+	if g.assoc != nil {
+		// Rules of the same level and associativity are chosen between
+		// as alternatives of one associative rule would be.
+		g.addText(`	if a := @_associativity[m1.prefix]; a != 0 && a == @_associativity[m2.prefix] && m1.shorter.end != m2.shorter.end {
+		if m1.shorter.end > m2.shorter.end == (a > 0) {
+			return 1
+		}
+		return -1
+	}
+`)
+	}
+	g.addText("\treturn 0\n}\n")
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test resolving ambiguities of a flat expression grammar by precedence
+func TestPrecedence(t *testing.T) {
+	prec := map[glean.Symbol]int{"Plus": 1, "Minus": 1, "Times": 2}
+	left := map[glean.Symbol]earley.Ambiguity{
+		"Plus":  earley.AmbiguityLeftmost,
+		"Minus": earley.AmbiguityLeftmost,
+		"Times": earley.AmbiguityLeftmost,
+	}
+	for _, c := range []struct {
+		options earley.Options
+		expect  string
+	}{
+		{earley.Options{Precedence: prec}, "7\n10\nambiguous\n26\nambiguous\nambiguous\n"},
+		{earley.Options{Precedence: prec, Associativity: left}, "7\n10\n2\n26\n11\n-1\n"},
+		{earley.Options{Precedence: prec, Associativity: left, Stepping: true}, "7\n10\n2\n26\n11\n-1\n"},
+	} {
+		parse, e := gleantest.Build(t, precedenceMainText, "Expr", c.options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if out, e := parse(); e != nil || out != c.expect {
+			t.Errorf("options %+v:\nexpected:\n%s\ngot:\n%s %v", c.options, c.expect, out, e)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleAdd", "Expr", []glean.Symbol{"Expr", "Plus", "Minus", "Expr"})
+	g.AddRule("RuleInt", "Expr", []glean.Symbol{"int"})
+	for _, options := range []earley.Options{
+		{Precedence: map[glean.Symbol]int{"Expr": 1}},
+		{Precedence: map[glean.Symbol]int{"Times": 1}},
+		{Precedence: map[glean.Symbol]int{"Plus": 0}},
+		{Precedence: map[glean.Symbol]int{"Plus": 1, "Minus": 2}},
+		{Precedence: map[glean.Symbol]int{"Plus": 1}, Weights: map[string]int{"RuleAdd": 1}},
+		{Precedence: map[glean.Symbol]int{"Plus": 1}, ParseAll: true},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Expr", "main", "_"); e == nil {
+			t.Errorf("no error for options %+v", options)
+		}
+	}
+}
+
+var precedenceMainText = `
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Expr int
+type Plus struct{}
+type Minus struct{}
+type Times struct{}
+
+func RuleInt(i int) Expr                   { return Expr(i) }
+func RuleAdd(x Expr, _ Plus, y Expr) Expr  { return x + y }
+func RuleSub(x Expr, _ Minus, y Expr) Expr { return x - y }
+func RuleMul(x Expr, _ Times, y Expr) Expr { return x * y }
+func RuleNeg(_ Minus, x Expr) Expr         { return -x }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{1, Plus{}, 2, Times{}, 3},
+		{2, Times{}, 3, Plus{}, 4},
+		{1, Minus{}, 2, Plus{}, 3},
+		{2, Times{}, 3, Plus{}, 4, Times{}, 5},
+		{1, Plus{}, 2, Times{}, 3, Plus{}, 4},
+		{Minus{}, 2, Plus{}, 1},
+	} {
+		expr, e := _glean_Parse(tokens)
+		if errors.Is(e, gleanerrors.ErrAmbiguous) {
+			fmt.Println("ambiguous")
+		} else if e != nil {
+			fmt.Println(e)
+		} else {
+			fmt.Println(expr)
+		}
+	}
+}
+`
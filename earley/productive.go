@@ -0,0 +1,94 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pat42smith/glean"
+)
+
+// Unproductive returns the nonterminal symbols that derive no finite
+// sequence of tokens, sorted by name: those each of whose rules has an
+// item that is unproductive itself, as with Expr given only the rule
+// Expr = Open Expr Close. Terminals are productive, as is a nonterminal
+// with a rule of no items. Such a symbol can never be matched, so
+// WriteParser fails if one is reachable from the goal.
+func (g *Grammar) Unproductive() []glean.Symbol {
+	productive := g.productive()
+	var symbols []glean.Symbol
+	for name, s := range g.name2symbol {
+		if !s.isTerminal() && !productive[s] {
+			symbols = append(symbols, name)
+		}
+	}
+	sortSymbols(symbols)
+	return symbols
+}
+
+// Find the productive nonterminal symbols
+func (g *Grammar) productive() map[*symbol]bool {
+	productive := make(map[*symbol]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, r := range append(g.rules[:len(g.rules):len(g.rules)], g.listRules...) {
+			if productive[r.target] {
+				continue
+			}
+			ok := true
+			for _, item := range r.items {
+				ok = ok && (item.isTerminal() || productive[item])
+			}
+			if ok {
+				productive[r.target] = true
+				changed = true
+			}
+		}
+	}
+	return productive
+}
+
+// Find the symbols reachable from the goal, including the goal itself
+func (g *Grammar) reachable(goal *symbol) map[*symbol]bool {
+	reachable := make(map[*symbol]bool)
+	if goal == nil {
+		return reachable
+	}
+	reachable[goal] = true
+	todo := []*symbol{goal}
+	for len(todo) > 0 {
+		s := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		for _, r := range s.rules {
+			for _, item := range r.items {
+				if !reachable[item] {
+					reachable[item] = true
+					todo = append(todo, item)
+				}
+			}
+		}
+	}
+	return reachable
+}
+
+// Check that the symbols reachable from the goal are productive
+func (g *Grammar) checkProductive() error {
+	productive := g.productive()
+	var symbols []glean.Symbol
+	for s := range g.reachable(g.goal) {
+		if !s.isTerminal() && !productive[s] {
+			symbols = append(symbols, s.name)
+		}
+	}
+	if len(symbols) == 0 {
+		return nil
+	}
+	sortSymbols(symbols)
+	names := make([]string, len(symbols))
+	for n, s := range symbols {
+		names[n] = string(s)
+	}
+	return fmt.Errorf("symbols deriving no finite sequence of tokens: %s", strings.Join(names, ", "))
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test finding the symbols that derive no finite sequence of tokens
+func TestUnproductive(t *testing.T) {
+	var g earley.Grammar
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleSum", "Sum", "Sum", "Plus", "Expr")
+	addrule("RuleExpr", "Sum", "Expr")
+	addrule("RuleInt", "Expr", "int")
+	addrule("RuleNil", "Nil")
+	addrule("RuleNils", "Nils", glean.ListOf("Nil"))
+	if got := g.Unproductive(); len(got) != 0 {
+		t.Error("unexpected unproductive symbols:", got)
+	}
+
+	addrule("RuleLoop", "Loop", "Loop")
+	addrule("RuleParens", "Parens", "Open", "Parens", "Close")
+	addrule("RuleWrap", "Wrap", glean.ListOf("Parens"))
+	if got := fmt.Sprint(g.Unproductive()); got != "[Loop Parens Wrap []Parens]" {
+		t.Error("wrong unproductive symbols:", got)
+	}
+
+	// Unproductive symbols are harmless unless reachable from the goal.
+	if _, e := g.WriteParser("Sum", "main", "_"); e != nil {
+		t.Fatal(e)
+	}
+	addrule("RuleCall", "Expr", "Ident", "Wrap")
+	_, e := g.WriteParser("Sum", "main", "_")
+	if e == nil || !strings.Contains(e.Error(), "no finite sequence of tokens: Parens, Wrap, []Parens") {
+		t.Error("wrong error for unproductive symbols:", e)
+	}
+}
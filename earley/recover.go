@@ -0,0 +1,158 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the parse function that recovers from errors, if requested
+func (g *Grammar) addParseRecover() {
+	if !g.Options.Recover {
+		return
+	}
+
+	g.addf("\nconst %sMaxErrors = %d\n", g.prepend, g.Options.MaxErrors)
+	g.addText("\nfunc @ParseRecover(")
+	g.addInputParams(true)
+	g.addText(", maxErrors int")
+	g.addResults("#G", "[]error")
+	g.addParserInit(true)
+	g.addCatch("catchAll", 1)
+	g.addText(`	parser.input = parser.tokens
+	parser.recovering = true
+	parser.maxErrors = maxErrors
+	return parser.parseRecover()
+}
+`)
+
+	if g.Options.ErrorSink {
+		g.addText("\n// @ParseRecoverSink parses as @ParseRecover does, but also passes each\n")
+		g.addText("// error to sink as soon as it is found.\n")
+		g.addText("func @ParseRecoverSink(")
+		g.addInputParams(true)
+		g.addText(", maxErrors int, sink func(error)")
+		g.addResults("#G", "[]error")
+		g.addParserInit(true)
+		g.addCatch("catchAll", 1)
+		g.addText(`	parser.input = parser.tokens
+	parser.recovering = true
+	parser.maxErrors = maxErrors
+	parser.sink = sink
+	return parser.parseRecover()
+}
+
+func (parser *@_Parser) addError(e error) {
+	parser.errors = append(parser.errors, e)
+	if parser.sink != nil {
+		parser.sink(e)
+	}
+}
+`)
+	} else {
+		g.addText(`
+func (parser *@_Parser) addError(e error) {
+	parser.errors = append(parser.errors, e)
+}
+`)
+	}
+
+	g.addText(`
+func (parser *@_Parser) parseRecover() (#G, []error) {
+	result, e := parser.parse()
+	if e == nil {
+		return result, parser.errors
+	}
+	switch e.(type) {
+	case gleanerrors.Unexpected, gleanerrors.UnknownToken, gleanerrors.TooManyErrors:
+		result = parser.partialGoal()
+	}
+
+	// The limit may have been reached by the tokens skipped before the end
+	// of the input, and then the final error is not reported.
+	if _, ok := e.(gleanerrors.TooManyErrors); !ok && parser.maxErrors > 0 && len(parser.errors) >= parser.maxErrors {
+		e = gleanerrors.TooManyErrors{Limit: parser.maxErrors, Location: gleanerrors.MakeLocation(parser.input, len(parser.input))}
+	}
+	parser.addError(parser.restoreLocations(e))
+	return result, parser.errors
+}
+
+// partialGoal returns the goal built from the longest prefix of the remaining
+// tokens that forms one, after the search for matches has stopped at an error;
+// or the zero goal if there is no such prefix, or it is ambiguous.
+func (parser *@_Parser) partialGoal() #G {
+	var zero #G
+	for n := len(parser.tokens); n > 0; n-- {
+		for _, p := range @_goalPrefixes {
+			for _, m := range parser.at(n)[p] {
+				if m.start != 0 {
+					continue
+				}
+				parser.tokens = parser.tokens[:n]
+				if parser.findTrace() != nil {
+					return zero
+				}
+				return parser.applyTrace()
+			}
+		}
+	}
+	return zero
+}
+
+func (parser *@_Parser) skipToken(end int) error {
+	index := end
+	if parser.original != nil {
+		index = parser.original[end]
+	}
+	if len(parser.errors) == 0 || index != parser.lastSkipped+1 {
+		if parser.maxErrors > 0 && len(parser.errors) >= parser.maxErrors {
+			return gleanerrors.TooManyErrors{Limit: parser.maxErrors, Location: gleanerrors.MakeLocation(parser.input, index)}
+		}
+		parser.addError(parser.unexpected(parser.input, index, end))
+	}
+	parser.lastSkipped = index
+
+	if parser.original == nil {
+		parser.tokens = append([]interface{}(nil), parser.tokens...)
+		parser.original = make([]int, len(parser.tokens))
+		for n := range parser.original {
+			parser.original[n] = n
+		}
+	}
+	parser.tokens = append(parser.tokens[:end], parser.tokens[end+1:]...)
+	parser.original = append(parser.original[:end], parser.original[end+1:]...)
+	parser.matches = parser.matches[:len(parser.matches)-1]
+	parser.todo = parser.todo[:len(parser.todo)-1]
+	return nil
+}
+
+func (parser *@_Parser) restoreLocations(e error) error {
+	if parser.original == nil {
+		return e
+	}
+	restore := func(n int) int {
+		if n < 0 {
+			return n
+		} else if n >= len(parser.original) {
+			return len(parser.input)
+		}
+		return parser.original[n]
+	}
+	switch e := e.(type) {
+	case gleanerrors.Unexpected:
+		e.Location = gleanerrors.MakeLocation(parser.input, restore(e.Index))
+		return e
+	case gleanerrors.UnknownToken:
+		e.Location = gleanerrors.MakeLocation(parser.input, restore(e.Index))
+		return e
+	case gleanerrors.Ambiguous:
+		e.Range = gleanerrors.MakeRange(parser.input, restore(e.First.Index), restore(e.Last.Index))
+		return e
+	case gleanerrors.MultiAmbiguous:
+		restored := make([]gleanerrors.Ambiguous, len(e.Ambiguities))
+		for n, a := range e.Ambiguities {
+			restored[n] = parser.restoreLocations(a).(gleanerrors.Ambiguous)
+		}
+		return gleanerrors.MultiAmbiguous{Ambiguities: restored}
+	}
+	return e
+}
+`)
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test a parser that recovers from unexpected tokens
+func TestRecover(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(recoverMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.Recover = true
+	g.Options.MaxErrors = 2
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleInt", "Sum", "int")
+	addrule("RuleAdd", "Sum", "Sum", "Plus", "int")
+	parserText, e := g.WriteParser("Sum", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	for _, test := range []struct{ input, output string }{
+		{"1 + 2 + 3", "6"},
+		{"1 + + 2", "3\nUnexpected 2"},
+		{"1 2 3 + 4", "5\nUnexpected 1"},
+		{"+ 1 + 2 3", "3\nUnexpected 0\nUnexpected 4"},
+		{"1 + + 2 + + 3 + + 4", "6\nUnexpected 2\nUnexpected 5\nTooManyErrors 8"},
+		{"1 + + 2 + + 3 +", "6\nUnexpected 2\nUnexpected 5\nTooManyErrors 8"},
+		{"1 + 2 +", "3\nUnexpected 4"},
+		{"1 + + 2 +", "3\nUnexpected 2\nUnexpected 5"},
+		{"+ +", "0\nUnexpected 0\nUnexpected 2"},
+	} {
+		args := append([]string{"run", mainGo, parserGo}, strings.Split(test.input, " ")...)
+		out, e := exec.Command("go", args...).CombinedOutput()
+		if e != nil {
+			t.Fatal(e, string(out))
+		}
+		if string(out) != test.output+"\n" {
+			t.Errorf("wrong output for %q:\nexpected:\n%s\ngot:\n%s", test.input, test.output, out)
+		}
+	}
+}
+
+var recoverMainText = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	var tokens []interface{}
+	for _, a := range os.Args[1:] {
+		if a == "+" {
+			tokens = append(tokens, Plus{})
+		} else {
+			i, e := strconv.Atoi(a)
+			if e != nil {
+				panic(e)
+			}
+			tokens = append(tokens, i)
+		}
+	}
+
+	sum, errors := _ParseRecover(tokens, _MaxErrors)
+	fmt.Println(sum)
+	for _, e := range errors {
+		switch e := e.(type) {
+		case gleanerrors.Unexpected:
+			fmt.Println("Unexpected", e.Index)
+		case gleanerrors.TooManyErrors:
+			fmt.Println("TooManyErrors", e.Index)
+		default:
+			fmt.Println(e)
+		}
+	}
+}
+`
@@ -0,0 +1,156 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test applying the rules on several goroutines
+func TestParseConcurrent(t *testing.T) {
+	var options earley.Options
+	options.Concurrent = true
+	options.CatchPanics = true
+	options.Intern = []glean.Symbol{"int"}
+	parse, e := gleantest.Build(t, reduceConcurrentMainText, "Tree", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `3 <nil> 3 <nil>
+3 <nil> 3 <nil>
+3 <nil> 3 <nil>
+115 <nil> 115 <nil>
+115 <nil> 115 <nil>
+115 <nil> 115 <nil>
+0 internal error in parser: negative leaf -1 0 internal error in parser: negative leaf -1
+0 internal error in parser: negative leaf -1 0 internal error in parser: negative leaf -1
+0 internal error in parser: negative leaf -1 0 internal error in parser: negative leaf -1
+0 unexpected token: main.Close 0 unexpected token: main.Close
+0 unexpected token: main.Close 0 unexpected token: main.Close
+0 unexpected token: main.Close 0 unexpected token: main.Close
+0 <nil>
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleLeaf", "Tree", []glean.Symbol{"int"})
+	g.Options = earley.Options{Concurrent: true, Scannerless: true}
+	if _, e := g.WriteParser("Tree", "main", "_"); e == nil {
+		t.Errorf("no error for options %v", g.Options)
+	}
+}
+
+var reduceConcurrentMainText = `
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+type Tree int
+type Open struct{}
+type Close struct{}
+type LBrack struct{}
+type RBrack struct{}
+type Star struct{}
+type Unit struct{}
+type Meet struct{}
+
+func RuleLeaf(i int) Tree {
+	if i < 0 {
+		panic(fmt.Sprint("negative leaf ", i))
+	}
+	return Tree(i)
+}
+
+func RulePair(_ Open, a, b Tree, _ Close) Tree { return a + b }
+
+func RuleMany(_ LBrack, ts []Tree, _ RBrack) Tree {
+	var sum Tree
+	for _, t := range ts {
+		sum += t
+	}
+	return sum
+}
+
+func RuleUnit() Unit { return Unit{} }
+
+func RuleStar(_ Star, _ Unit) Tree { return 100 }
+
+var arrived int32
+var both = make(chan struct{})
+
+// Each of two sibling Meet leaves waits for the other, so they can only
+// be applied concurrently.
+func RuleMeet(_ Meet) Tree {
+	if atomic.AddInt32(&arrived, 1) == 2 {
+		close(both)
+	}
+	select {
+	case <-both:
+	case <-time.After(10 * time.Second):
+		panic("sibling rules were not applied concurrently")
+	}
+	return 0
+}
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{Open{}, 1, 2, Close{}},
+		{LBrack{}, Open{}, 1, 2, Close{}, 3, Star{}, LBrack{}, 4, 5, RBrack{}, RBrack{}},
+		{Open{}, 1, Open{}, 2, -1, Close{}, Close{}},
+		{Open{}, 1, Close{}},
+	} {
+		for _, workers := range []int{1, 4, 0} {
+			result, e := _glean_Parse(tokens)
+			cresult, ce := _glean_ParseConcurrent(tokens, workers)
+			fmt.Println(result, e, cresult, ce)
+		}
+	}
+	fmt.Println(_glean_ParseConcurrent([]interface{}{Open{}, Meet{}, Meet{}, Close{}}, 2))
+}
+`
+
+// Test applying the rules on several goroutines when their values are nil interfaces
+func TestParseConcurrentNil(t *testing.T) {
+	parse, e := gleantest.Build(t, reduceConcurrentNilMainText, "Node", earley.Options{Concurrent: true})
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := "<nil> <nil>\n<nil> <nil>\n"
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+}
+
+var reduceConcurrentNilMainText = `
+package main
+
+import "fmt"
+
+type Node interface{}
+type Word string
+
+func RuleWord(_ Word) Node { return nil }
+
+func RulePair(a, b Node) Node {
+	if a != nil || b != nil {
+		panic("not nil")
+	}
+	return nil
+}
+
+func main() {
+	fmt.Println(_glean_ParseConcurrent([]interface{}{Word("a")}, 2))
+	fmt.Println(_glean_ParseConcurrent([]interface{}{Word("a"), Word("b")}, 2))
+}
+`
@@ -0,0 +1,93 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test a parser that looks up its reducers at run time
+func TestRegistry(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(registryMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.Registry = true
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleInt", "Sum", "int")
+	addrule("RuleAdd", "Sum", "Sum", "Plus", "int")
+	parserText, e := g.WriteParser("Sum", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	out, e := exec.Command("go", "run", mainGo, parserGo).CombinedOutput()
+	if e != nil {
+		t.Fatal(e, string(out))
+	}
+	if expect := "20\n(((4+5)+5)+6)\nno reducer for rule RuleAdd\n"; string(out) != expect {
+		t.Errorf("wrong output:\nexpected:\n%s\ngot:\n%s", expect, out)
+	}
+}
+
+var registryMainText = `
+package main
+
+import (
+	"fmt"
+)
+
+type Plus struct{}
+
+func main() {
+	tokens := []interface{}{4, Plus{}, 5, Plus{}, 5, Plus{}, 6}
+
+	evaluate := make(_Reducers)
+	evaluate.Register("RuleInt", func(x []interface{}) interface{} { return x[0] })
+	evaluate.Register("RuleAdd", func(x []interface{}) interface{} { return x[0].(int) + x[2].(int) })
+	sum, e := _Parse(tokens, evaluate)
+	if e != nil {
+		panic(e)
+	}
+	fmt.Println(sum)
+
+	show := _Reducers{
+		0: func(x []interface{}) interface{} { return fmt.Sprint(x[0]) },
+		1: func(x []interface{}) interface{} { return fmt.Sprintf("(%s+%d)", x[0], x[2]) },
+	}
+	text, e := _Parse(tokens, show)
+	if e != nil {
+		panic(e)
+	}
+	fmt.Println(text)
+
+	if e := show.Register("RuleSubtract", nil); e == nil {
+		panic("registered unknown rule")
+	}
+
+	missing := make(_Reducers)
+	missing.Register("RuleInt", evaluate[0])
+	_, e = _Parse(tokens, missing)
+	fmt.Println(e)
+}
+`
@@ -0,0 +1,80 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+)
+
+// RemoveRule removes the rule of the given name from the grammar. The ids
+// of the later rules are renumbered, so the grammar is as if the rule had
+// never been added, and WriteParser may be called as usual.
+//
+// Symbols that no longer appear in any rule are removed too, along with
+// the rules of list symbols no longer used. A symbol that loses its last
+// rule but still appears as an item of other rules becomes a terminal.
+// Options naming the rule, such as Weights, must be updated separately.
+// An error is returned, and the grammar is unchanged, if there is no rule
+// of that name.
+func (g *Grammar) RemoveRule(name string) error {
+	index := -1
+	for n, r := range g.rules {
+		if r.name == name {
+			index = n
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("unknown rule '%s'", name)
+	}
+
+	r := g.rules[index]
+	g.rules = append(g.rules[:index], g.rules[index+1:]...)
+	for n := index; n < len(g.rules); n++ {
+		g.rules[n].id = n
+	}
+	delete(g.rulenames, name)
+
+	target := r.target
+	kept := target.rules[:0]
+	for _, t := range target.rules {
+		if t != r {
+			kept = append(kept, t)
+		}
+	}
+	target.rules = kept
+
+	g.removeUnused()
+	return nil
+}
+
+// Remove the symbols not appearing in any rule, and the rules of list
+// and optional symbols that are not used
+func (g *Grammar) removeUnused() {
+	used := make(map[*symbol]bool)
+	var use func(s *symbol)
+	use = func(s *symbol) {
+		if !used[s] {
+			used[s] = true
+			if s.element != nil {
+				use(s.element)
+			}
+		}
+	}
+	for _, r := range g.rules {
+		use(r.target)
+		for _, i := range r.items {
+			use(i)
+		}
+	}
+
+	for name, s := range g.name2symbol {
+		if !used[s] {
+			delete(g.name2symbol, name)
+			if s.element != nil {
+				g.removeListRules(s)
+			}
+		}
+	}
+}
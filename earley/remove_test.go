@@ -0,0 +1,74 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+)
+
+func TestRemoveRule(t *testing.T) {
+	var g Grammar
+	add := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	add("RuleBlock", "Block", "Open", "[]Stmt", "Close")
+	add("RuleExpr", "Stmt", "Expr", "Semi")
+	add("RuleInt", "Expr", "int")
+	add("RuleNumbers", "Stmt", "[]Number")
+	add("RuleNumber", "Number", "int")
+
+	before := describe(&g)
+	if e := g.RemoveRule("RuleMissing"); e == nil {
+		t.Error("no error removing unknown rule")
+	}
+	if e := g.RemoveRule("[]Stmt"); e == nil {
+		t.Error("no error removing list rule")
+	}
+	if after := describe(&g); after != before {
+		t.Errorf("grammar changed by failed removals:\n%s", after)
+	}
+
+	// Removing RuleNumbers leaves []Number unused, with its rules.
+	if e := g.RemoveRule("RuleNumbers"); e != nil {
+		t.Fatal(e)
+	}
+	expect := `RuleBlock: Block = Open []Stmt Close
+RuleExpr: Stmt = Expr Semi
+RuleInt: Expr = int
+RuleNumber: Number = int
+[]Stmt: []Stmt = Stmt
+[]Stmt: []Stmt = []Stmt Stmt
+Block Close Expr Number Open Semi Stmt []Stmt int`
+	if got := describe(&g); got != expect {
+		t.Errorf("wrong grammar after removing RuleNumbers:\n%s", got)
+	}
+	for n, r := range g.rules {
+		if r.id != n {
+			t.Errorf("rule %s has id %d at index %d", r.name, r.id, n)
+		}
+	}
+
+	// Removing RuleInt makes Expr a terminal.
+	if e := g.RemoveRule("RuleInt"); e != nil {
+		t.Fatal(e)
+	}
+	if e := g.RemoveRule("RuleInt"); e == nil {
+		t.Error("no error removing a rule twice")
+	}
+	if !g.name2symbol["Expr"].isTerminal() {
+		t.Error("Expr is not a terminal")
+	}
+	if _, e := g.WriteParser("Block", "main", "_"); e != nil {
+		t.Error(e)
+	}
+
+	add("RuleInt", "Expr", "int")
+	if _, e := g.WriteParser("Block", "main", "_"); e != nil {
+		t.Error(e)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+
+	"github.com/pat42smith/glean"
+)
+
+// RenameSymbol changes the name of a symbol in every rule in which it
+// appears, as target or item. Lists of the symbol, and lists of those
+// lists, are renamed with it, as are the optional forms of all these.
+//
+// If the new name is already used, the two symbols are merged, provided
+// both are terminals or both are nonterminals; otherwise an error is
+// returned. A list or optional symbol cannot be renamed directly, only
+// through its element. If an error is returned, the grammar is unchanged.
+//
+// Symbols named in g.Options are not renamed.
+func (g *Grammar) RenameSymbol(old, new glean.Symbol) error {
+	s := g.name2symbol[old]
+	if s == nil {
+		return fmt.Errorf("unknown symbol '%s'", old)
+	}
+	if s.element != nil {
+		kind := "list"
+		if s.optional {
+			kind = "optional"
+		}
+		return fmt.Errorf("%s symbol '%s' cannot be renamed; rename '%s' instead", kind, old, s.element.name)
+	}
+	if !validName(string(new)) {
+		return fmt.Errorf("new symbol name '%s' is not a valid Go identifier", new)
+	}
+	if new == old {
+		return nil
+	}
+	t := g.name2symbol[new]
+	if t != nil && t.isTerminal() != s.isTerminal() {
+		return fmt.Errorf("cannot merge terminal and nonterminal symbols '%s' and '%s'", old, new)
+	}
+
+	// No errors are possible beyond this point.
+	g.renameOrMerge(s, new)
+	g.renameOptional(old, new)
+	for old, new = glean.ListOf(old), glean.ListOf(new); g.name2symbol[old] != nil; old, new = glean.ListOf(old), glean.ListOf(new) {
+		g.renameOrMerge(g.name2symbol[old], new)
+		g.renameOptional(old, new)
+	}
+	return nil
+}
+
+// Rename the optional form of a renamed symbol, if it is used
+func (g *Grammar) renameOptional(old, new glean.Symbol) {
+	if s := g.name2symbol[glean.OptionalOf(old)]; s != nil {
+		g.renameOrMerge(s, glean.OptionalOf(new))
+	}
+}
+
+// Give a symbol a new name, merging it with any existing symbol of that name
+func (g *Grammar) renameOrMerge(s *symbol, name glean.Symbol) {
+	delete(g.name2symbol, s.name)
+	t := g.name2symbol[name]
+	if t == nil {
+		s.name = name
+		g.name2symbol[name] = s
+		if s.element != nil {
+			for _, r := range s.rules {
+				r.name = string(name)
+			}
+		}
+		return
+	}
+
+	if s.element != nil {
+		// t already has its own rules, the same as those of s.
+		g.removeListRules(s)
+	} else {
+		for _, r := range s.rules {
+			r.target = t
+		}
+		t.rules = append(t.rules, s.rules...)
+	}
+	for _, r := range append(g.rules[:len(g.rules):len(g.rules)], g.listRules...) {
+		for n, i := range r.items {
+			if i == s {
+				r.items[n] = t
+			}
+		}
+	}
+	for _, u := range g.name2symbol {
+		if u.element == s {
+			u.element = t
+		}
+	}
+}
+
+// Remove the rules of a list or optional symbol
+func (g *Grammar) removeListRules(list *symbol) {
+	kept := g.listRules[:0]
+	for _, r := range g.listRules {
+		if r.target != list {
+			kept = append(kept, r)
+		}
+	}
+	g.listRules = kept
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+)
+
+// describe lists the rules of a grammar, in a form independent of symbol order
+func describe(g *Grammar) string {
+	var lines []string
+	for _, r := range append(g.rules[:len(g.rules):len(g.rules)], g.listRules...) {
+		var items []string
+		for _, i := range r.items {
+			items = append(items, string(i.name))
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s = %s", r.name, r.target.name, strings.Join(items, " ")))
+	}
+	var names []string
+	for name, s := range g.name2symbol {
+		if name != s.name {
+			names = append(names, "bad "+string(name))
+		}
+		names = append(names, string(name))
+		for _, r := range s.rules {
+			if r.target != s {
+				names = append(names, "bad target "+r.name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(lines, "\n") + "\n" + strings.Join(names, " ")
+}
+
+func TestRenameSymbol(t *testing.T) {
+	var g Grammar
+	add := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	add("RuleBlock", "Block", "Open", "[]Stmt", "Close")
+	add("RuleExpr", "Stmt", "Expr", "Semi")
+	add("RuleInt", "Expr", "int")
+	add("RuleNumbers", "Stmt", "[]Number")
+	add("RuleNumber", "Number", "int")
+
+	before := describe(&g)
+	for _, bad := range []struct{ old, new glean.Symbol }{
+		{"Missing", "Expr"},
+		{"[]Stmt", "Stmts"},
+		{"Expr", "2x"},
+		{"Expr", "Semi"},
+		{"int", "Stmt"},
+	} {
+		if e := g.RenameSymbol(bad.old, bad.new); e == nil {
+			t.Errorf("no error renaming %s to %s", bad.old, bad.new)
+		}
+	}
+	if after := describe(&g); after != before {
+		t.Errorf("failed renames changed the grammar:\n%s\n%s", before, after)
+	}
+
+	if e := g.RenameSymbol("Expr", "Expression"); e != nil {
+		t.Fatal(e)
+	}
+	if e := g.RenameSymbol("Stmt", "Statement"); e != nil {
+		t.Fatal(e)
+	}
+	if e := g.RenameSymbol("Number", "Statement"); e != nil {
+		t.Fatal(e)
+	}
+	expect := `RuleBlock: Block = Open []Statement Close
+RuleExpr: Statement = Expression Semi
+RuleInt: Expression = int
+RuleNumbers: Statement = []Statement
+RuleNumber: Statement = int
+[]Statement: []Statement = Statement
+[]Statement: []Statement = []Statement Statement
+Block Close Expression Open Semi Statement []Statement int`
+	if got := describe(&g); got != expect {
+		t.Errorf("wrong grammar after renames:\nexpected:\n%s\ngot:\n%s", expect, got)
+	}
+
+	if _, e := g.WriteParser("Block", "main", "_"); e != nil {
+		t.Error(e)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pat42smith/glean"
+)
+
+// A Report summarizes the problems found by the static analyses of a
+// grammar, as returned by Grammar.Report. The count of each kind of
+// problem is the length of its slice; each slice is sorted, so two
+// reports of the same grammar are equal.
+type Report struct {
+	// Goal is the goal symbol the report was made for.
+	Goal glean.Symbol
+
+	// AmbiguousRules holds the pairs of rules with the same target and
+	// the same items, differing only by name. Any input matching one
+	// also matches the other, so it is always ambiguous. Each pair is
+	// given in order of rule name.
+	AmbiguousRules [][2]string
+
+	// Unreachable holds the symbols that appear in no derivation from
+	// the goal.
+	Unreachable []glean.Symbol
+
+	// Unproductive holds the nonterminal symbols that derive no sequence
+	// of tokens, since each of their rules has an item that is
+	// unproductive itself.
+	Unproductive []glean.Symbol
+
+	// UnitCycles holds the groups of nonterminal symbols each of which
+	// derives every other, and itself, alone, through rules whose other
+	// items are all nullable, as with A = B and B = A C where C is
+	// nullable. An input matching such a symbol has infinitely many
+	// derivations. Each group is sorted by name.
+	UnitCycles [][]glean.Symbol
+}
+
+// Report runs the static analyses of the grammar for the goal symbol and
+// collects their results. A goal that is not a symbol of the grammar
+// leaves every symbol unreachable.
+//
+// The duplicate rules and the unit derivations are found in one pass
+// over the rules; the productive and reachable symbols each need one
+// further pass for every level of derivation.
+func (g *Grammar) Report(goal glean.Symbol) Report {
+	report := Report{Goal: goal}
+	nullable := g.Nullable()
+	allRules := append(g.rules[:len(g.rules):len(g.rules)], g.listRules...)
+
+	// Duplicate rules and unit derivations
+	unit := make(map[*symbol][]*symbol)
+	for n, r := range allRules {
+		if n < len(g.rules) {
+			for _, r2 := range g.rules[:n] {
+				if r2.target == r.target && sameItems(r2.items, r.items) {
+					pair := [2]string{r2.name, r.name}
+					if pair[1] < pair[0] {
+						pair[0], pair[1] = pair[1], pair[0]
+					}
+					report.AmbiguousRules = append(report.AmbiguousRules, pair)
+				}
+			}
+		}
+		for k, item := range r.items {
+			if item.isTerminal() {
+				continue
+			}
+			rest := true
+			for k2, other := range r.items {
+				rest = rest && (k2 == k || nullable[other.name])
+			}
+			if rest {
+				unit[r.target] = append(unit[r.target], item)
+			}
+		}
+	}
+	sort.Slice(report.AmbiguousRules, func(i, j int) bool {
+		a, b := report.AmbiguousRules[i], report.AmbiguousRules[j]
+		return a[0] < b[0] || a[0] == b[0] && a[1] < b[1]
+	})
+
+	// Productive and reachable symbols
+	productive := g.productive()
+	reachable := g.reachable(g.name2symbol[goal])
+
+	for name, s := range g.name2symbol {
+		if !reachable[s] {
+			report.Unreachable = append(report.Unreachable, name)
+		}
+		if !s.isTerminal() && !productive[s] {
+			report.Unproductive = append(report.Unproductive, name)
+		}
+	}
+	sortSymbols(report.Unreachable)
+	sortSymbols(report.Unproductive)
+
+	// Unit cycles: the symbols reaching themselves by unit derivations,
+	// grouped with the symbols they reach and are reached from.
+	reaches := make(map[*symbol]map[*symbol]bool)
+	for s := range unit {
+		seen := make(map[*symbol]bool)
+		todo := []*symbol{s}
+		for len(todo) > 0 {
+			t := todo[len(todo)-1]
+			todo = todo[:len(todo)-1]
+			for _, u := range unit[t] {
+				if !seen[u] {
+					seen[u] = true
+					todo = append(todo, u)
+				}
+			}
+		}
+		reaches[s] = seen
+	}
+	grouped := make(map[*symbol]bool)
+	for s, seen := range reaches {
+		if !seen[s] || grouped[s] {
+			continue
+		}
+		var group []glean.Symbol
+		for t := range seen {
+			if reaches[t][s] {
+				grouped[t] = true
+				group = append(group, t.name)
+			}
+		}
+		sortSymbols(group)
+		report.UnitCycles = append(report.UnitCycles, group)
+	}
+	sort.Slice(report.UnitCycles, func(i, j int) bool {
+		return report.UnitCycles[i][0] < report.UnitCycles[j][0]
+	})
+
+	return report
+}
+
+// Problems returns the total number of problems in the report.
+func (r Report) Problems() int {
+	return len(r.AmbiguousRules) + len(r.Unreachable) + len(r.Unproductive) + len(r.UnitCycles)
+}
+
+// String formats the report for reading by programs as well as people.
+// The first lines give the goal and the count of each kind of problem:
+//
+//	goal Expr
+//	ambiguous 1
+//	unreachable 0
+//	unproductive 0
+//	cycles 0
+//
+// Each problem follows on its own line, beginning with the same word as
+// its count, and then the rules or symbols involved, separated by spaces,
+// as in "ambiguous RuleA RuleB".
+func (r Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "goal %s\n", r.Goal)
+	fmt.Fprintf(&b, "ambiguous %d\n", len(r.AmbiguousRules))
+	fmt.Fprintf(&b, "unreachable %d\n", len(r.Unreachable))
+	fmt.Fprintf(&b, "unproductive %d\n", len(r.Unproductive))
+	fmt.Fprintf(&b, "cycles %d\n", len(r.UnitCycles))
+	for _, pair := range r.AmbiguousRules {
+		fmt.Fprintf(&b, "ambiguous %s %s\n", pair[0], pair[1])
+	}
+	for _, s := range r.Unreachable {
+		fmt.Fprintf(&b, "unreachable %s\n", s)
+	}
+	for _, s := range r.Unproductive {
+		fmt.Fprintf(&b, "unproductive %s\n", s)
+	}
+	for _, group := range r.UnitCycles {
+		b.WriteString("cycles")
+		for _, s := range group {
+			b.WriteString(" ")
+			b.WriteString(string(s))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Sort symbols by name
+func sortSymbols(symbols []glean.Symbol) {
+	sort.Slice(symbols, func(i, j int) bool {
+		return symbols[i] < symbols[j]
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test the summary of the static analyses
+func TestReport(t *testing.T) {
+	var g earley.Grammar
+	for _, r := range []struct {
+		name, target string
+		items        []glean.Symbol
+	}{
+		{"RuleSum", "S", []glean.Symbol{"S", "plus", "T"}},
+		{"RuleSumAgain", "S", []glean.Symbol{"S", "plus", "T"}},
+		{"RuleT", "S", []glean.Symbol{"T"}},
+		{"RuleNum", "T", []glean.Symbol{"num"}},
+		{"RuleParen", "T", []glean.Symbol{"lp", "U", "rp"}},
+		{"RuleU", "U", []glean.Symbol{"V", "E"}},
+		{"RuleV", "V", []glean.Symbol{"U"}},
+		{"RuleE", "E", nil},
+		{"RuleLoop", "W", []glean.Symbol{"W", "num"}},
+		{"RuleList", "X", []glean.Symbol{"[]num"}},
+	} {
+		if e := g.AddRule(r.name, glean.Symbol(r.target), r.items); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	report := g.Report("S")
+	expect := `goal S
+ambiguous 1
+unreachable 3
+unproductive 3
+cycles 1
+ambiguous RuleSum RuleSumAgain
+unreachable W
+unreachable X
+unreachable []num
+unproductive U
+unproductive V
+unproductive W
+cycles U V
+`
+	if got := report.String(); got != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s", expect, got)
+	}
+	if n := report.Problems(); n != 8 {
+		t.Errorf("expected 8 problems, got %d", n)
+	}
+
+	report = g.Report("X")
+	if len(report.Unreachable) != 9 || len(report.UnitCycles) != 1 {
+		t.Errorf("wrong report for goal X:\n%s", report)
+	}
+	report = g.Report("Missing")
+	if len(report.Unreachable) != 12 {
+		t.Errorf("wrong report for unknown goal:\n%s", report)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the reusable parser type, if requested
+func (g *Grammar) addReusable() {
+	if !g.Options.Reusable {
+		return
+	}
+
+	g.addText(`
+// @Parser parses as @Parse does, but keeps the memory it allocates for one
+// parse to reuse in the next, for programs parsing many inputs. Its zero
+// value is ready for use. It keeps the input and results of the last parse
+// until the next. A @Parser must not be used by several goroutines at once.
+type @Parser struct {
+	parser @_Parser
+}
+
+// Parse parses the input as @Parse does.
+func (p *@Parser) Parse(`)
+	g.addInputParams(true)
+	g.addParseBody(func() {
+		g.addText("\tparser := &p.parser\n\tparser.reuse()\n")
+		g.addParserFields(true)
+	})
+
+	g.addText(`
+// Empty the parser for another parse, keeping the memory it has allocated
+func (parser *@_Parser) reuse() {
+	*parser = @_Parser{
+`)
+	if !g.Options.ChartStore {
+		g.addText("\t\tmatches: parser.matches,\n")
+	}
+	g.addText(`		todo: parser.todo,
+		trace: parser.trace[:0],
+		endPrefixes: parser.endPrefixes[:0],
+`)
+	for _, s := range g.symbols {
+		g.addf("\t\t%s: parser.%s[:0],\n", s.stackName(), s.stackName())
+	}
+	g.addText("\t}\n}\n")
+}
+
+// Append the methods preparing the chart of matches and giving the matches
+// ending at a position, for a parser that may be reused; the slices of
+// matches are emptied rather than made anew
+func (g *Grammar) addReusablePrepare() {
+	g.addText(`
+func (parser *@_Parser) prepare() {
+	size := len(parser.tokens) + 1
+`)
+	if g.Options.ChartStore {
+		g.addText("\tparser.chart.Reset(len(parser.tokens))\n")
+	} else {
+		g.addText(`	if cap(parser.matches) < size {
+		grown := make([]map[@_Prefix][]*@_Match, size)
+		copy(grown, parser.matches[:cap(parser.matches)])
+		parser.matches = grown
+	}
+	parser.matches = parser.matches[:size]
+	for end, m := range parser.matches {
+		if m == nil {
+			parser.matches[end] = make(map[@_Prefix][]*@_Match)
+		}
+		for p := range m {
+			delete(m, p)
+		}
+	}
+`)
+	}
+	g.addText(`	if cap(parser.todo) < size {
+		grown := make([][]*@_Match, size)
+		copy(grown, parser.todo[:cap(parser.todo)])
+		parser.todo = grown
+	}
+	parser.todo = parser.todo[:size]
+	for end := range parser.todo {
+		parser.todo[end] = parser.todo[end][:0]
+	}
+}
+
+func (parser *@_Parser) at(end int) map[@_Prefix][]*@_Match {
+`)
+	if g.Options.ChartStore {
+		g.addText("\treturn parser.chart.At(end)\n}\n")
+	} else {
+		g.addText("\treturn parser.matches[end]\n}\n")
+	}
+}
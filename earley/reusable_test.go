@@ -0,0 +1,83 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test a parser reused for several parses, and compare the allocations of
+// reused and new parsers
+func TestReusable(t *testing.T) {
+	for _, options := range []earley.Options{
+		{Reusable: true},
+		{Reusable: true, GenericStacks: true},
+		{Reusable: true, Recover: true},
+	} {
+		parse, e := gleantest.Build(t, reusableMainText, "Sum", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		out, e := parse()
+		if e != nil {
+			t.Fatal(out, e)
+		}
+		expect := "6\n1\nunexpected token: main.Plus\n10\nno tokens in parser input\n3\n"
+		if !strings.HasPrefix(out, expect) {
+			t.Errorf("options %+v:\nexpected:\n%s\ngot:\n%s", options, expect, out)
+			continue
+		}
+		var fresh, reused int64
+		if _, e = fmt.Sscanf(out[len(expect):], "allocs %d %d\n", &fresh, &reused); e != nil {
+			t.Fatal(out, e)
+		}
+		if reused*2 > fresh {
+			t.Errorf("options %+v: %d allocations per parse reusing the parser, %d without", options, reused, fresh)
+		}
+	}
+}
+
+var reusableMainText = `
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+type Plus struct{}
+type Sum int
+
+func RuleInt(x int) Sum                { return Sum(x) }
+func RuleAdd(s Sum, _ Plus, x int) Sum { return s + Sum(x) }
+
+func main() {
+	var parser _glean_Parser
+	for _, tokens := range [][]interface{}{
+		{1, Plus{}, 2, Plus{}, 3},
+		{1},
+		{1, Plus{}, Plus{}},
+		{1, Plus{}, 2, Plus{}, 3, Plus{}, 4},
+		{},
+		{1, Plus{}, 2},
+	} {
+		sum, e := parser.Parse(tokens)
+		if e != nil {
+			fmt.Println(e)
+		} else {
+			fmt.Println(sum)
+		}
+	}
+
+	tokens := []interface{}{1, Plus{}, 2, Plus{}, 3, Plus{}, 4}
+	fresh := testing.AllocsPerRun(100, func() { _glean_Parse(tokens) })
+	reused := testing.AllocsPerRun(100, func() { parser.Parse(tokens) })
+	fmt.Println("allocs", int(fresh), int(reused))
+}
+`
@@ -6,6 +6,7 @@ package earley
 // A grammar rule
 type rule struct {
 	name       string
+	reducer    string // The function computing the rule's value
 	target     *symbol
 	items      []*symbol
 	id         int
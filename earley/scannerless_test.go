@@ -0,0 +1,108 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test a parser that considers overlapping tokenizations of its input
+func TestScannerless(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(scannerlessMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.Scannerless = true
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleNumber", "Sum", "Number")
+	addrule("RuleAdd", "Sum", "Sum", "Plus", "Number")
+	addrule("RuleWord", "Sum", "Word")
+	parserText, e := g.WriteParser("Sum", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	for _, test := range []struct{ input, output string }{
+		{"12+3", "15"},
+		{"7", "7"},
+		{"100+20+3", "123"},
+		{"plus", "4"},
+		{"1+plus", "unexpected token: \"p\""},
+		{"12+", "unexpected end of input"},
+		{"", "no tokens in parser input"},
+	} {
+		out, e := exec.Command("go", "run", mainGo, parserGo, test.input).CombinedOutput()
+		if e != nil {
+			t.Fatal(e, string(out))
+		}
+		if string(out) != test.output+"\n" {
+			t.Errorf("wrong output for %q:\nexpected: %s\ngot: %s", test.input, test.output, out)
+		}
+	}
+}
+
+var scannerlessMainText = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type Sum int
+type Number int
+type Plus struct{}
+type Word string
+
+func RuleNumber(n Number) Sum { return Sum(n) }
+func RuleAdd(s Sum, _ Plus, n Number) Sum { return s + Sum(n) }
+func RuleWord(w Word) Sum { return Sum(len(w)) }
+
+func main() {
+	text := os.Args[1]
+
+	// Offer every run of digits and every run of letters starting at a position.
+	next := func(pos int) []_TokenOption {
+		var options []_TokenOption
+		for end := pos + 1; end <= len(text) && text[end-1] >= '0' && text[end-1] <= '9'; end++ {
+			n, _ := strconv.Atoi(text[pos:end])
+			options = append(options, _TokenOption{Number(n), end - pos})
+		}
+		for end := pos + 1; end <= len(text) && text[end-1] >= 'a' && text[end-1] <= 'z'; end++ {
+			options = append(options, _TokenOption{Word(text[pos:end]), end - pos})
+		}
+		if text[pos] == '+' {
+			options = append(options, _TokenOption{Plus{}, 1})
+		}
+		return options
+	}
+
+	sum, e := _Parse(len(text), next)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(sum)
+}
+`
@@ -0,0 +1,390 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pat42smith/glean"
+)
+
+// SelfTest checks the code generator against a reference recognizer.
+//
+// Each input is a sequence of terminal symbols. SelfTest writes a parser
+// for the goal, and runs it on a token of each symbol, in order. It also
+// runs a recognizer that interprets the rule prefixes of the grammar
+// directly, without generating code. If the two disagree on whether an
+// input is valid, whether it is ambiguous, or, when it is valid and not
+// ambiguous, on the parse tree, an error describing the first disagreement
+// is returned. As for the parser, an empty input is never valid.
+//
+// The parser is written as with Options.Registry set, and with the other
+// options at their zero values, apart from ErrorsImport. It is built with
+// the go command, run in the current directory, which must therefore lie in
+// a module in which the gleanerrors package may be imported. A terminal
+// symbol that is not a predeclared Go type is declared as an empty struct.
+func (g *Grammar) SelfTest(goal glean.Symbol, inputs [][]glean.Symbol) error {
+	saved := g.Options
+	defer func() { g.Options = saved }()
+	g.Options = Options{Registry: true, ErrorsImport: saved.ErrorsImport}
+
+	parserText, e := g.WriteParser(goal, "main", "_")
+	if e != nil {
+		return e
+	}
+
+	var lines []string
+	var expected []string
+	for _, input := range inputs {
+		symbols := make([]*symbol, len(input))
+		for n, name := range input {
+			s := g.name2symbol[name]
+			if s == nil || !s.isTerminal() {
+				return fmt.Errorf("input symbol '%s' is not a terminal symbol", name)
+			}
+			symbols[n] = s
+		}
+		lines = append(lines, strings.Join(symbolNames(symbols), " "))
+		expected = append(expected, g.reference(symbols))
+	}
+
+	dir, e := os.MkdirTemp("", "glean-selftest")
+	if e != nil {
+		return e
+	}
+	defer os.RemoveAll(dir)
+	mainGo := filepath.Join(dir, "main.go")
+	if e = os.WriteFile(mainGo, []byte(g.selfTestMain()), 0444); e != nil {
+		return e
+	}
+	parserGo := filepath.Join(dir, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		return e
+	}
+
+	cmd := exec.Command("go", "run", mainGo, parserGo)
+	cmd.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+	out, e := cmd.CombinedOutput()
+	if e != nil {
+		return fmt.Errorf("%v running parser: %s", e, out)
+	}
+	results := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(results) != len(inputs) {
+		return fmt.Errorf("parser gave %d results for %d inputs: %s", len(results), len(inputs), out)
+	}
+	for n := range inputs {
+		if results[n] != expected[n] {
+			return fmt.Errorf("input '%s': parser gives %s, recognizer gives %s", lines[n], results[n], expected[n])
+		}
+	}
+	return nil
+}
+
+// The names of some symbols
+func symbolNames(symbols []*symbol) []string {
+	names := make([]string, len(symbols))
+	for n, s := range symbols {
+		names[n] = string(s.name)
+	}
+	return names
+}
+
+// The program that runs the parser for SelfTest, reading inputs from stdin.
+// It prints the result for each input in the form given by reference.
+func (g *Grammar) selfTestMain() string {
+	var b strings.Builder
+	b.WriteString(`package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	` + g.errorsImport() + `
+)
+
+type _selfNode string
+
+`)
+	for _, s := range g.terminals {
+		if _, ok := types.Universe.Lookup(string(s.name)).(*types.TypeName); !ok {
+			fmt.Fprintf(&b, "type %s struct{}\n", s.name)
+		}
+	}
+	b.WriteString("\nvar _selfTokens = map[string]interface{}{\n")
+	for _, s := range g.terminals {
+		fmt.Fprintf(&b, "\t%q: *new(%s),\n", s.name, s.name)
+	}
+	b.WriteString(`}
+
+func _selfRender(items []string, args []interface{}) []string {
+	var kids []string
+	for n, a := range args {
+		if elem := strings.TrimPrefix(items[n], "[]"); elem != items[n] {
+			v := reflect.ValueOf(a)
+			var elems []string
+			for i := 0; i < v.Len(); i++ {
+				elems = append(elems, _selfRender([]string{elem}, []interface{}{v.Index(i).Interface()})...)
+			}
+			kids = append(kids, "["+strings.Join(elems, " ")+"]")
+		} else if elem := strings.TrimPrefix(items[n], "*"); elem != items[n] {
+			if v := reflect.ValueOf(a); v.IsNil() {
+				kids = append(kids, "nil")
+			} else {
+				kids = append(kids, "&"+_selfRender([]string{elem}, []interface{}{v.Elem().Interface()})[0])
+			}
+		} else if s, ok := a.(_selfNode); ok {
+			kids = append(kids, string(s))
+		} else {
+			kids = append(kids, items[n])
+		}
+	}
+	return kids
+}
+
+func main() {
+	reducers := make(_Reducers)
+	for id, desc := range __ruledesc[:`)
+	fmt.Fprintf(&b, "%d", len(g.rules))
+	b.WriteString(`] {
+		desc := desc
+		reducers[id] = func(args []interface{}) interface{} {
+			return _selfNode(desc.Name + "(" + strings.Join(_selfRender(desc.Items, args), " ") + ")")
+		}
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var input []interface{}
+		for _, name := range strings.Fields(scanner.Text()) {
+			input = append(input, _selfTokens[name])
+		}
+		result, e := _Parse(input, reducers)
+		switch e.(type) {
+		case nil:
+			fmt.Println("valid", result)
+		case gleanerrors.Ambiguous, gleanerrors.MultiAmbiguous:
+			fmt.Println("ambiguous")
+		case gleanerrors.Unexpected, gleanerrors.NoInput:
+			fmt.Println("invalid")
+		default:
+			fmt.Println("error", e)
+		}
+	}
+}
+`)
+	return b.String()
+}
+
+// A complete match of a rule to the input, found by the reference recognizer
+type refMatch struct {
+	r          *rule
+	start, end int
+}
+
+// An item of the reference recognizer: a rule prefix matched from start
+type refItem struct {
+	p     *prefix
+	start int
+}
+
+// Run the reference recognizer on the input, and describe the result:
+// "invalid", "ambiguous", or "valid" and the parse tree.
+func (g *Grammar) reference(input []*symbol) string {
+	if len(input) == 0 {
+		return "invalid"
+	}
+	matches := g.recognize(input)
+
+	d := deriver{input, matches, make(map[refSpan][]string), make(map[refSpan]bool), false}
+	trees := d.symbol(g.goal, 0, len(input))
+	if d.cyclic || len(trees) > 1 {
+		return "ambiguous"
+	} else if len(trees) == 0 {
+		return "invalid"
+	}
+	return "valid " + trees[0]
+}
+
+// Find all complete matches of rules to spans of the input, by
+// interpreting the prefixes of the rules as an Earley recognizer.
+func (g *Grammar) recognize(input []*symbol) map[refMatch]bool {
+	matches := make(map[refMatch]bool)
+	sets := make([][]refItem, len(input)+1)
+	have := make([]map[refItem]bool, len(input)+1)
+	for n := range have {
+		have[n] = make(map[refItem]bool)
+	}
+	add := func(end int, item refItem) bool {
+		if have[end][item] {
+			return false
+		}
+		have[end][item] = true
+		sets[end] = append(sets[end], item)
+		return true
+	}
+	add(0, refItem{g.goal.prefix0, 0})
+
+	for end := range sets {
+		// Repeat until nothing changes, so matches of the empty string are complete.
+		for changed := true; changed; {
+			changed = false
+			for n := 0; n < len(sets[end]); n++ {
+				item := sets[end][n]
+				for _, r := range item.p.rules {
+					if len(r.items) != item.p.length {
+						continue
+					}
+					matches[refMatch{r, item.start, end}] = true
+					for _, waiting := range sets[item.start] {
+						for _, ext := range waiting.p.extensions {
+							if ext.rules[0].items[waiting.p.length] == r.target {
+								changed = add(end, refItem{ext, waiting.start}) || changed
+							}
+						}
+					}
+				}
+				for _, ext := range item.p.extensions {
+					if next := ext.rules[0].items[item.p.length]; !next.isTerminal() {
+						changed = add(end, refItem{next.prefix0, end}) || changed
+					}
+				}
+			}
+		}
+
+		if end < len(input) {
+			for _, item := range sets[end] {
+				for _, ext := range item.p.extensions {
+					if ext.rules[0].items[item.p.length] == input[end] {
+						add(end+1, refItem{ext, item.start})
+					}
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// A symbol matching a span of the input
+type refSpan struct {
+	s          *symbol
+	start, end int
+}
+
+// A deriver finds the parse trees of spans of the input, from the
+// complete matches found by the reference recognizer. At most two trees
+// are found for any span, enough to show an ambiguity.
+type deriver struct {
+	input   []*symbol
+	matches map[refMatch]bool
+	memo    map[refSpan][]string
+	active  map[refSpan]bool
+	cyclic  bool // A symbol derives itself, so has infinitely many trees
+}
+
+// The trees of a symbol matching a span
+func (d *deriver) symbol(s *symbol, start, end int) []string {
+	if s.isTerminal() {
+		if end == start+1 && d.input[start] == s {
+			return []string{string(s.name)}
+		}
+		return nil
+	}
+
+	span := refSpan{s, start, end}
+	if trees, have := d.memo[span]; have {
+		return trees
+	}
+	if d.active[span] {
+		d.cyclic = true
+		return nil
+	}
+	d.active[span] = true
+	var trees []string
+	for _, r := range s.rules {
+		if !d.matches[refMatch{r, start, end}] {
+			continue
+		}
+		for _, kids := range d.items(r.items, start, end) {
+			if len(trees) < 2 {
+				trees = append(trees, render(r, kids))
+			}
+		}
+	}
+	delete(d.active, span)
+	d.memo[span] = trees
+	return trees
+}
+
+// The sequences of trees of some items matching a span
+func (d *deriver) items(items []*symbol, start, end int) [][]string {
+	if len(items) == 0 {
+		if start == end {
+			return [][]string{nil}
+		}
+		return nil
+	}
+	var found [][]string
+	for mid := start; mid <= end && len(found) < 2; mid++ {
+		if !d.matched(items[0], start, mid) {
+			continue
+		}
+		rest := d.items(items[1:], mid, end)
+		if len(rest) == 0 {
+			continue
+		}
+		for _, t := range d.symbol(items[0], start, mid) {
+			for _, r := range rest {
+				if len(found) < 2 {
+					found = append(found, append([]string{t}, r...))
+				}
+			}
+		}
+	}
+	return found
+}
+
+// Whether a symbol matches a span, as found by the recognizer. This is
+// checked before looking for trees, so that a symbol is found to derive
+// itself only when it really does.
+func (d *deriver) matched(s *symbol, start, end int) bool {
+	if s.isTerminal() {
+		return end == start+1 && d.input[start] == s
+	}
+	for _, r := range s.rules {
+		if d.matches[refMatch{r, start, end}] {
+			return true
+		}
+	}
+	return false
+}
+
+// Write the tree for a rule, given the trees of its items, as the parser
+// written by SelfTest does. The matches of a list symbol are flattened,
+// and an optional symbol is written as nil or & and its element; an absent
+// optional list is a pointer to an empty list.
+func render(r *rule, kids []string) string {
+	if r.target.optional {
+		if len(kids) == 0 && r.target.optionalList() {
+			return "&[]"
+		} else if len(kids) == 0 {
+			return "nil"
+		}
+		return "&" + kids[0]
+	}
+	if r.target.element != nil {
+		if len(kids) == 1 {
+			return "[" + kids[0] + "]"
+		}
+		return strings.TrimSuffix(kids[0], "]") + " " + kids[1] + "]"
+	}
+	return r.name + "(" + strings.Join(kids, " ") + ")"
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test the comparison of the generated parser with the reference recognizer
+func TestSelfTest(t *testing.T) {
+	var g earley.Grammar
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleExpr", "Goal", "Expr")
+	addrule("RuleInt", "Expr", "int")
+	addrule("RuleAdd", "Expr", "Expr", "Plus", "Expr")
+	addrule("RuleCall", "Expr", "Name", "Open", "Args", "Close")
+	addrule("RuleNoArgs", "Args")
+	addrule("RuleArgs", "Args", "[]Arg")
+	addrule("RuleArg", "Arg", "Expr", "Comma")
+	addrule("RuleBlank", "Blank")
+	addrule("RuleBlank2", "Blank", "Blank", "Blank")
+	addrule("RuleBlankGoal", "Goal", "Blank", "Name")
+	addrule("RuleReturn", "Goal", "Return", "*Expr")
+	addrule("RuleDone", "Goal", "Done", "*[]Arg")
+
+	var inputs [][]glean.Symbol
+	for _, input := range []string{
+		"",
+		"int",
+		"int Plus int",
+		"int Plus int Plus int",
+		"int Plus",
+		"Name Open Close",
+		"Name Open int Comma Close",
+		"Name Open int Comma Name Open Close Comma int Plus int Comma Close",
+		"Name Open int Close",
+		"Name",
+		"Plus",
+		"Return",
+		"Return int Plus int",
+		"Done",
+		"Done int Comma int Plus int Comma",
+	} {
+		var symbols []glean.Symbol
+		for _, s := range strings.Fields(input) {
+			symbols = append(symbols, glean.Symbol(s))
+		}
+		inputs = append(inputs, symbols)
+	}
+	if e := g.SelfTest("Goal", inputs); e != nil {
+		t.Error(e)
+	}
+
+	if e := g.SelfTest("Goal", [][]glean.Symbol{{"Expr"}}); e == nil {
+		t.Error("no error for a nonterminal in the input")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the function parsing the input as a sequence of goals, if requested
+func (g *Grammar) addParseSequence() {
+	if !g.Options.Sequence {
+		return
+	}
+
+	g.addText(`
+// @ParseSequence parses its input as a sequence of #G, each the longest
+// complete #G following the one before, and returns their values. The
+// first error ends the parse, and is returned with the values before it.
+func @ParseSequence(`)
+	g.addInputParams(true)
+	g.addResults("[]#G", "error")
+	g.addParserInit(true)
+	g.addCatch("catch", 1)
+	g.addText(`	return parser.parseSequence()
+}
+
+func (parser *@_Parser) parseSequence() ([]#G, error) {
+	input := parser.tokens
+	var results []#G
+	for start := 0; start < len(input); {
+		parser.tokens = input[start:]
+		result, n, e := parser.parseLongest()
+		if e != nil {
+			return results, @_shiftError(e, start)
+		}
+		if n == 0 {
+			return results, parser.unexpected(input, start, 0)
+		}
+		results = append(results, result)
+		start += n
+	}
+	return results, nil
+}
+
+// Move the locations in an error found in part of the input by offset, to
+// give them within the whole
+func @_shiftError(e error, offset int) error {
+	switch e := e.(type) {
+	case gleanerrors.Unexpected:
+		e.Index += offset
+		return e
+	case gleanerrors.UnknownToken:
+		e.Index += offset
+		return e
+	case gleanerrors.Ambiguous:
+		e.First.Index += offset
+		e.Last.Index += offset
+		return e
+	case gleanerrors.MultiAmbiguous:
+		shifted := make([]gleanerrors.Ambiguous, len(e.Ambiguities))
+		for n, a := range e.Ambiguities {
+			shifted[n] = @_shiftError(a, offset).(gleanerrors.Ambiguous)
+		}
+		return gleanerrors.MultiAmbiguous{Ambiguities: shifted}
+	default:
+		return e
+	}
+}
+`)
+}
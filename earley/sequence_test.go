@@ -0,0 +1,81 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test parsing the input as a sequence of goals
+func TestSequence(t *testing.T) {
+	var options earley.Options
+	options.Sequence = true
+	parse, e := gleantest.Build(t, sequenceMainText, "Sum", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `[3 7] <nil>
+[6] <nil>
+[] <nil>
+[3] unexpected token: main.Plus 3
+[3] unexpected token: "x" 3
+[1] unexpected token: main.Plus 1
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+	for _, options := range []earley.Options{
+		{Sequence: true, Scannerless: true},
+		{Sequence: true, EndSymbol: "int"},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+			t.Errorf("no error for options %v", options)
+		}
+	}
+}
+
+var sequenceMainText = `
+package main
+
+import (
+	"fmt"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Sum int
+type Plus struct{}
+type Name string
+
+func RuleNone() Sum                    { return 0 }
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+func RuleName(s string) Name           { return Name(s) }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{1, Plus{}, 2, 3, Plus{}, 4},
+		{1, Plus{}, 2, Plus{}, 3},
+		{},
+		{1, Plus{}, 2, Plus{}},
+		{1, Plus{}, 2, "x"},
+		{1, Plus{}, Plus{}},
+	} {
+		sums, e := _glean_ParseSequence(tokens)
+		fmt.Print(sums, " ", e)
+		if u, ok := e.(gleanerrors.Unexpected); ok {
+			fmt.Print(" ", u.Index)
+		}
+		fmt.Println()
+	}
+}
+`
@@ -0,0 +1,61 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test integer arithmetic with generic stacks
+func TestGenericStacks(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(arithmeticMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.GenericStacks = true
+	g.AddRule("RuleSum", "Sum", []glean.Symbol{"Product"})
+	g.AddRule("RuleAdd", "Sum", []glean.Symbol{"Sum", "Plus", "Product"})
+	g.AddRule("RuleSubtract", "Sum", []glean.Symbol{"Sum", "Minus", "Product"})
+	g.AddRule("RuleProduct", "Product", []glean.Symbol{"Item"})
+	g.AddRule("RuleMultiply", "Product", []glean.Symbol{"Product", "Times", "Item"})
+	g.AddRule("RuleDivide", "Product", []glean.Symbol{"Product", "Divide", "Item"})
+	g.AddRule("RuleParenthesis", "Item", []glean.Symbol{"Open", "Sum", "Close"})
+	g.AddRule("RuleItem", "Item", []glean.Symbol{"Int"})
+	parserText, e := g.WriteParser("Sum", "main", "_arith")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !strings.Contains(parserText, "_arith_Stack[Sum]") {
+		t.Error("parser does not use generic stacks")
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	for _, test := range testdata {
+		ans := strconv.Itoa(test.answer)
+		args := append([]string{"run", mainGo, parserGo}, strings.Split(test.expr, " ")...)
+		got, e := exec.Command("go", args...).CombinedOutput()
+		if e != nil {
+			t.Fatal(e, string(got))
+		}
+		if string(got) != ans+"\n" {
+			t.Errorf("wrong answer %s for %v", got, test)
+		}
+	}
+}
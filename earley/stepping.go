@@ -0,0 +1,114 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+// Append the parser that finds matches a position at a time, if requested
+func (g *Grammar) addStepping() {
+	if !g.Options.Stepping {
+		return
+	}
+
+	g.addText(`
+// @DebugParser finds the matches of rule prefixes to its input one position
+// at a time, so that they may be inspected between steps. It does not
+// recover from panics.
+type @DebugParser struct {
+	parser @_Parser
+	end    int   // The next position to process
+	err    error // The error found, if any
+}
+
+// @DebugMatch describes a match of the first Length items of Rule to the
+// tokens from Start to the position where the match ends. When several
+// rules begin with the same items, Rule is the first of them.
+type @DebugMatch struct {
+	Rule   gleanerrors.Rule
+	Length int
+	Start  int
+}
+
+// @NewDebugParser returns a parser for the input, with no positions
+// processed. It takes the same arguments as @Parse.
+func @NewDebugParser(`)
+	g.addInputParams(true)
+	g.addText(`) *@DebugParser {
+`)
+	g.addParserInit(true)
+	g.addText(`	parser.prepare()
+	parser.addMatch(#g, 0, 0, nil, nil)
+	return &@DebugParser{parser: parser}
+}
+
+// Step processes the next position of the input, finding the matches that
+// end there, and tells whether the parser is now done. Position n is that
+// before token n; there is one more position than there are tokens.
+func (d *@DebugParser) Step() (done bool) {
+	if !d.Done() {
+		d.err = d.parser.step(d.end)
+		d.end++
+	}
+	return d.Done()
+}
+
+// Done tells whether every position has been processed or an error found.
+func (d *@DebugParser) Done() bool {
+	return d.err != nil || d.end == len(d.parser.todo)
+}
+
+// Position returns the number of positions processed.
+func (d *@DebugParser) Position() int {
+	return d.end
+}
+
+// Err returns the error found by the last step, if any.
+func (d *@DebugParser) Err() error {
+	return d.err
+}
+
+// Matches returns the matches ending at a position, in the order found.
+// Before the position is processed, these are only those ending with the
+// token before it.
+func (d *@DebugParser) Matches(position int) []@DebugMatch {
+	var list []@DebugMatch
+	for _, m := range d.parser.todo[position] {
+		list = append(list, @DebugMatch{@_ruledesc[@_prefixRule[m.prefix]], @_prefixLength[m.prefix], m.start})
+	}
+	return list
+}
+
+// Result processes the remaining positions, and returns the result of the
+// parse, or the error found, as @Parse would.
+func (d *@DebugParser) Result() (#G, error) {
+	var zero #G
+	parser := &d.parser
+`)
+	g.addReducerCheck("zero")
+	g.addText(`	if len(parser.tokens) == 0 {
+		return zero, gleanerrors.NoInput{}
+	}
+	for !d.Step() {
+	}
+	if d.err != nil {
+		return zero, d.err
+	}
+	if e := parser.findTrace(); e != nil {
+		return zero, e
+	}
+	return parser.applyTrace(), nil
+}
+`)
+}
+
+// Append the table of the rule of each prefix, for a DebugParser, if requested
+func (g *Grammar) addSteppingTables() {
+	if !g.Options.Stepping {
+		return
+	}
+
+	g.addText("\nvar @_prefixRule = []@_Rule{\n")
+	for _, p := range g.prefixes {
+		g.addf("\t%d,\n", p.rules[0].id)
+	}
+	g.addString("}\n")
+}
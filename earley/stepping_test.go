@@ -0,0 +1,77 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test running a parser a position at a time
+func TestStepping(t *testing.T) {
+	var options earley.Options
+	options.Stepping = true
+	parse, e := gleantest.Build(t, steppingMainText, "Sum", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := `0 [RuleInt 0 0]
+1 [RuleInt 1 0] [RuleAdd 1 0]
+2 [RuleAdd 2 0]
+3 [RuleAdd 3 0] [RuleAdd 1 0]
+done 4 <nil>
+4 <nil>
+0 unexpected token: main.Plus
+0 no tokens in parser input
+`
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("expected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+	for _, options := range []earley.Options{
+		{Stepping: true, Scannerless: true},
+		{Stepping: true, Recover: true},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+			t.Errorf("no error for options %v", options)
+		}
+	}
+}
+
+var steppingMainText = `
+package main
+
+import "fmt"
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	d := _glean_NewDebugParser([]interface{}{1, Plus{}, 3})
+	for !d.Done() {
+		d.Step()
+		position := d.Position() - 1
+		fmt.Print(position)
+		for _, m := range d.Matches(position) {
+			fmt.Print(" [", m.Rule.Name, " ", m.Length, " ", m.Start, "]")
+		}
+		fmt.Println()
+	}
+	fmt.Println("done", d.Position(), d.Err())
+	fmt.Println(d.Result())
+
+	d = _glean_NewDebugParser([]interface{}{1, Plus{}, Plus{}})
+	fmt.Println(d.Result())
+	fmt.Println(_glean_NewDebugParser(nil).Result())
+}
+`
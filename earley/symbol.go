@@ -5,16 +5,19 @@ package earley
 
 import (
 	"sort"
+	"strings"
 
 	"github.com/pat42smith/glean"
 )
 
 // A grammar symbol
 type symbol struct {
-	name    glean.Symbol
-	rules   []*rule
-	id      int
-	prefix0 *prefix
+	name     glean.Symbol
+	rules    []*rule
+	id       int
+	prefix0  *prefix
+	element  *symbol // For a list or optional symbol, the symbol listed or made optional
+	optional bool    // Whether the symbol is optional, rather than a list, if it has an element
 }
 
 // Terminal symbols are not produced by any rules
@@ -22,6 +25,33 @@ func (s *symbol) isTerminal() bool {
 	return len(s.rules) == 0
 }
 
+// Whether the symbol is an optional list, such as *[]X, matching zero or more
+// of the list's elements
+func (s *symbol) optionalList() bool {
+	return s.optional && s.element.element != nil
+}
+
+// The name of the parser field holding the stack of values of the symbol
+func (s *symbol) stackName() string {
+	if s.element != nil {
+		return s.identifier()
+	}
+	return "stack" + s.identifier()
+}
+
+// The symbol's name, made usable in generated identifiers by replacing
+// the dot of a qualified name with an underscore, the brackets of a list
+// symbol with "list", and the star of an optional symbol with "opt"
+func (s *symbol) identifier() string {
+	if s.optional {
+		return "opt" + s.element.identifier()
+	}
+	if s.element != nil {
+		return "list" + s.element.identifier()
+	}
+	return strings.Replace(string(s.name), ".", "_", 1)
+}
+
 // Sort a symbol's rules lexicographically, so rules with common prefixes are together.
 func (s *symbol) sortRules() {
 	sort.Slice(s.rules, func(i, j int) bool {
@@ -0,0 +1,34 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import "github.com/pat42smith/glean"
+
+// Terminals returns the terminal symbols of the grammar, those without
+// rules, sorted by name. These are the types of the tokens passed to the
+// parser, which the program using it must define, unless they are
+// predeclared, such as int. A terminal of the grammar is a terminal of any
+// parser written for it, whatever the goal.
+func (g *Grammar) Terminals() []glean.Symbol {
+	return g.classify(true)
+}
+
+// Nonterminals returns the nonterminal symbols of the grammar, those with
+// rules, sorted by name. List symbols are among them, with the rules the
+// Grammar makes for them.
+func (g *Grammar) Nonterminals() []glean.Symbol {
+	return g.classify(false)
+}
+
+// Collect the terminal or nonterminal symbols, sorted by name
+func (g *Grammar) classify(terminal bool) []glean.Symbol {
+	var symbols []glean.Symbol
+	for name, s := range g.name2symbol {
+		if s.isTerminal() == terminal {
+			symbols = append(symbols, name)
+		}
+	}
+	sortSymbols(symbols)
+	return symbols
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test listing the terminal and nonterminal symbols
+func TestTerminals(t *testing.T) {
+	var g earley.Grammar
+	if s := g.Terminals(); s != nil {
+		t.Error("terminals of empty grammar:", s)
+	}
+	if s := g.Nonterminals(); s != nil {
+		t.Error("nonterminals of empty grammar:", s)
+	}
+
+	g.AddRule("RuleSum", "Sum", []glean.Symbol{"Sum", "Plus", "Term"})
+	g.AddRule("RuleTerm", "Sum", []glean.Symbol{"Term"})
+	g.AddRule("RuleInt", "Term", []glean.Symbol{"int"})
+	g.AddRule("RuleCall", "Term", []glean.Symbol{"Ident", "Open", glean.ListOf("Sum"), "Close"})
+	check := func(what string, got []glean.Symbol, expect string) {
+		t.Helper()
+		if fmt.Sprint(got) != expect {
+			t.Errorf("wrong %s: expected %s, got %v", what, expect, got)
+		}
+	}
+	check("terminals", g.Terminals(), "[Close Ident Open Plus int]")
+	check("nonterminals", g.Nonterminals(), "[Sum Term []Sum]")
+
+	// A symbol given rules is no longer a terminal.
+	g.AddRule("RuleIdent", "Ident", []glean.Symbol{"string"})
+	check("terminals", g.Terminals(), "[Close Open Plus int string]")
+	check("nonterminals", g.Nonterminals(), "[Ident Sum Term []Sum]")
+
+	// The symbols are the same after writing a parser.
+	if _, e := g.WriteParser("Sum", "main", "_"); e != nil {
+		t.Fatal(e)
+	}
+	check("terminals", g.Terminals(), "[Close Open Plus int string]")
+	check("nonterminals", g.Nonterminals(), "[Ident Sum Term []Sum]")
+}
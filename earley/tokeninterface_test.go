@@ -0,0 +1,145 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test a parser taking a slice of a token interface type
+func TestTokenInterface(t *testing.T) {
+	for _, unchecked := range []bool{false, true} {
+		var options earley.Options
+		options.TokenInterface = "Token"
+		options.Unchecked = unchecked
+		options.ValidPrefix = true
+		parse, e := gleantest.Build(t, tokenInterfaceMainText, "Sum", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		// Without checks, the bad token causes some other panic.
+		expect := "1+2\n2\npanic: "
+		if !unchecked {
+			expect += "input token (type main.Bad) is not a terminal symbol\n"
+		}
+		if out, e := parse(); e != nil || !strings.HasPrefix(out, expect) || !unchecked && out != expect {
+			t.Errorf("unchecked %v:\nexpected:\n%s\ngot:\n%s %v", unchecked, expect, out, e)
+		}
+	}
+
+	var options earley.Options
+	options.TokenInterface = "Token"
+	options.EndSymbol = "EOF"
+	options.Complete = true
+	parse, e := gleantest.Build(t, tokenInterfaceEndMainText, "Program", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := "1+2\n[[Num EOF]]\n"
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("with EndSymbol:\nexpected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleSum", "Sum", []glean.Symbol{"Num", "Plus", "Num"})
+	for _, c := range []struct {
+		name    string
+		options func(*earley.Options)
+	}{
+		{"Token", func(o *earley.Options) { o.Classifier = true }},
+		{"Token", func(o *earley.Options) { o.Scannerless = true }},
+		{"Token", func(o *earley.Options) {
+			o.Kinds = map[glean.Symbol]string{"Num": "1", "Plus": "2"}
+			o.KindFunc = "kindOf"
+		}},
+		{"a token", nil},
+		{"lex.Token", nil},
+	} {
+		g.Options = earley.Options{TokenInterface: c.name}
+		if c.options != nil {
+			c.options(&g.Options)
+		}
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+			t.Errorf("no error for token interface %q with options %+v", c.name, g.Options)
+		}
+	}
+}
+
+var tokenInterfaceMainText = `
+package main
+
+import "fmt"
+
+type Token interface {
+	TypeId() int
+}
+
+type Num string
+type Plus struct{}
+type Bad struct{}
+type Sum string
+
+func (Num) TypeId() int  { return _glean_TerminalNum }
+func (Plus) TypeId() int { return _glean_TerminalPlus }
+func (Bad) TypeId() int  { return 99 }
+
+func RuleSum(x Num, _ Plus, y Num) Sum { return Sum(x + "+" + y) }
+
+func main() {
+	tokens := []Token{Num("1"), Plus{}, Num("2")}
+	sum, e := _glean_Parse(tokens)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(sum)
+	fmt.Println(_glean_ValidPrefix([]Token{Num("1"), Plus{}, Plus{}}))
+
+	defer func() {
+		if p := recover(); p != nil {
+			fmt.Println("panic:", p)
+		}
+	}()
+	tokens[1] = Bad{}
+	_glean_Parse(tokens)
+}
+`
+
+var tokenInterfaceEndMainText = `
+package main
+
+import "fmt"
+
+type Token interface {
+	TypeId() int
+}
+
+type Num string
+type Plus struct{}
+type EOF struct{}
+type Sum string
+type Program string
+
+func (Num) TypeId() int  { return _glean_TerminalNum }
+func (Plus) TypeId() int { return _glean_TerminalPlus }
+func (EOF) TypeId() int  { return _glean_TerminalEOF }
+
+func RuleSum(x Num, _ Plus, y Num) Sum       { return Sum(x + "+" + y) }
+func RuleProgram(s Sum, _ EOF) Program       { return Program(s) }
+
+func main() {
+	program, e := _glean_Parse([]Token{Num("1"), Plus{}, Num("2")})
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(program)
+	fmt.Println(_glean_Complete([]Token{Num("1"), Plus{}}, 1))
+}
+`
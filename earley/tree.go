@@ -0,0 +1,218 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import "fmt"
+
+// Check the symbols to be hidden in the parse tree
+func (g *Grammar) checkHidden() error {
+	for _, h := range g.Options.Hidden {
+		s := g.name2symbol[h]
+		if s == nil {
+			return fmt.Errorf("unknown hidden symbol '%s'", h)
+		}
+		if s.isTerminal() {
+			return fmt.Errorf("hidden symbol '%s' is a terminal symbol", h)
+		}
+		if s == g.goal {
+			return fmt.Errorf("hidden symbol '%s' is the goal", h)
+		}
+	}
+	return nil
+}
+
+// Append the functions returning the parse tree, if requested
+func (g *Grammar) addParseTree() {
+	if !g.Options.Tree {
+		return
+	}
+
+	g.addText(`
+// @Node is a node of the concrete syntax tree returned by @ParseTree.
+type @Node struct {
+	// The id of the rule applied at this node, or -1 if the node is a token.
+	Rule int
+
+	// The symbol matched by this node.
+	Symbol string
+
+	// The input matched by this node is from Start up to, but not including, End.
+	Start, End int
+
+	// The nodes matching the items of the rule, if the node is not a token.
+	Children []*@Node
+
+	// The token, if the node is a token.
+	Token interface{}
+}
+
+// MarshalJSON encodes a node as a JSON object. A rule node is written as
+//
+//	{"rule": 2, "name": "RuleAdd", "symbol": "Sum", "start": 0, "end": 3, "children": [...]}
+//
+// and a token as
+//
+//	{"symbol": "int", "start": 2, "end": 3, "value": 7}
+//
+// where the value is the token encoded by encoding/json.
+func (node *@Node) MarshalJSON() ([]byte, error) {
+	if node.Rule < 0 {
+		return json.Marshal(struct {
+			Symbol string      ` + "`json:\"symbol\"`" + `
+			Start  int         ` + "`json:\"start\"`" + `
+			End    int         ` + "`json:\"end\"`" + `
+			Value  interface{} ` + "`json:\"value\"`" + `
+		}{node.Symbol, node.Start, node.End, node.Token})
+	}
+	children := node.Children
+	if children == nil {
+		children = []*@Node{}
+	}
+	return json.Marshal(struct {
+		Rule     int      ` + "`json:\"rule\"`" + `
+		Name     string   ` + "`json:\"name\"`" + `
+		Symbol   string   ` + "`json:\"symbol\"`" + `
+		Start    int      ` + "`json:\"start\"`" + `
+		End      int      ` + "`json:\"end\"`" + `
+		Children []*@Node ` + "`json:\"children\"`" + `
+	}{node.Rule, @_ruledesc[node.Rule].Name, node.Symbol, node.Start, node.End, children})
+}
+
+// Span returns the range of source offsets covered by the tokens of the
+// node, from the start of its first token to the end of its last. These
+// tokens must have a method
+//
+//	Span() (start, end int)
+//
+// giving their own offsets; ok is false if either does not, or if the
+// node covers no tokens, as a node of a rule with no items does.
+func (node *@Node) Span() (start, end int, ok bool) {
+	first, last := node.firstToken(), node.lastToken()
+	if first == nil {
+		return 0, 0, false
+	}
+	type spanner interface {
+		Span() (start, end int)
+	}
+	s1, ok1 := first.Token.(spanner)
+	s2, ok2 := last.Token.(spanner)
+	if !ok1 || !ok2 {
+		return 0, 0, false
+	}
+	start, _ = s1.Span()
+	_, end = s2.Span()
+	return start, end, true
+}
+
+func (node *@Node) firstToken() *@Node {
+	if node.Rule < 0 {
+		return node
+	}
+	for _, child := range node.Children {
+		if t := child.firstToken(); t != nil {
+			return t
+		}
+	}
+	return nil
+}
+
+func (node *@Node) lastToken() *@Node {
+	if node.Rule < 0 {
+		return node
+	}
+	for n := len(node.Children) - 1; n >= 0; n-- {
+		if t := node.Children[n].lastToken(); t != nil {
+			return t
+		}
+	}
+	return nil
+}
+`)
+
+	g.addText("\nfunc @ParseTree(")
+	g.addInputParams(false)
+	g.addResults("*@Node", "error")
+	g.addParserInit(false)
+	g.addCatch("catch", 1)
+	g.addText(`	if e := parser.match(); e != nil {
+		return nil, e
+	}
+	return parser.makeNode(parser.goalmatch), nil
+}
+`)
+
+	g.addText("\nfunc @ParseJSON(")
+	g.addInputParams(false)
+	g.addText(") ([]byte, error) {\n\ttree, e := @ParseTree(")
+	g.addInputArgs(false)
+	g.addText(`)
+	if e != nil {
+		return nil, e
+	}
+	return json.Marshal(tree)
+}
+
+func (parser *@_Parser) makeNode(m *@_Match) *@Node {
+	rule := @_prefix2rule[m.prefix]
+	node := &@Node{Rule: int(rule), Symbol: @_ruledesc[rule].Target, Start: m.start, End: m.end}
+	for ; m.shorter != nil; m = m.shorter {
+		if m.last != nil {
+			child := parser.makeNode(m.last)
+			if @_hidden[child.Symbol] {
+				for n := len(child.Children) - 1; n >= 0; n-- {
+					node.Children = append(node.Children, child.Children[n])
+				}
+			} else {
+				node.Children = append(node.Children, child)
+			}
+			continue
+		}
+		t := @_lastTerminal[m.prefix]
+		leaf := &@Node{Rule: -1, Symbol: @_symbolNames[t], Start: m.shorter.end, End: m.end}
+`)
+	if g.Options.Scannerless {
+		g.addText("\t\tleaf.Token = parser.chooseToken(t, m.shorter.end, m.end)\n")
+	} else {
+		g.addText("\t\tleaf.Token = parser.tokens[m.shorter.end]\n")
+	}
+	if len(g.Options.Intern) > 0 {
+		g.addText(`		if @_interned[t] {
+			leaf.Token = parser.internToken(leaf.Token)
+		}
+`)
+	}
+	g.addText(`		node.Children = append(node.Children, leaf)
+	}
+	for i, j := 0, len(node.Children)-1; i < j; i, j = i+1, j-1 {
+		node.Children[i], node.Children[j] = node.Children[j], node.Children[i]
+	}
+	if rule >= #R && len(node.Children) == 2 && node.Children[0].Symbol == node.Symbol {
+		// Flatten a list, so its children are its elements
+		node.Children = append(node.Children[0].Children, node.Children[1])
+	}
+	return node
+}
+`)
+	g.addInternToken()
+}
+
+// Add the names of the symbols, and the set of symbols hidden in the parse tree
+func (g *Grammar) addSymbolNames() {
+	if g.Options.Tree {
+		g.addText("\nvar @_hidden = map[string]bool{")
+		for n, h := range g.Options.Hidden {
+			if n > 0 {
+				g.addString(", ")
+			}
+			g.addf("%q: true", h)
+		}
+		g.addString("}\n")
+	}
+
+	g.addText("\nvar @_symbolNames = []string{\n")
+	for _, s := range g.symbols {
+		g.addf("\t%q,\n", s.name)
+	}
+	g.addString("}\n")
+}
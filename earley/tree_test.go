@@ -0,0 +1,256 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test the parse functions returning a syntax tree
+func TestParseTree(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(treeMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.Tree = true
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleInt", "Sum", "int")
+	addrule("RuleAdd", "Sum", "Sum", "Plus", "int")
+	parserText, e := g.WriteParser("Sum", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	out, e := exec.Command("go", "run", mainGo, parserGo).CombinedOutput()
+	if e != nil {
+		t.Fatal(e, string(out))
+	}
+	expect := `1 0 3 2
+{"rule":1,"name":"RuleAdd","symbol":"Sum","start":0,"end":3,"children":[` +
+		`{"rule":0,"name":"RuleInt","symbol":"Sum","start":0,"end":1,"children":[` +
+		`{"symbol":"int","start":0,"end":1,"value":4}]},` +
+		`{"symbol":"Plus","start":1,"end":2,"value":{}},` +
+		`{"symbol":"int","start":2,"end":3,"value":5}]}
+unexpected token: main.Plus
+`
+	if string(out) != expect {
+		t.Errorf("wrong output:\nexpected:\n%s\ngot:\n%s", expect, out)
+	}
+}
+
+var treeMainText = `
+package main
+
+import (
+	"fmt"
+)
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	tokens := []interface{}{4, Plus{}, 5}
+
+	tree, e := _ParseTree(tokens)
+	if e != nil {
+		panic(e)
+	}
+	fmt.Println(len(tree.Children[0].Children), tree.Start, tree.End, tree.Children[2].Token.(int)-3)
+
+	text, e := _ParseJSON(tokens)
+	if e != nil {
+		panic(e)
+	}
+	fmt.Println(string(text))
+
+	_, e = _ParseJSON([]interface{}{4, Plus{}, Plus{}})
+	fmt.Println(e)
+}
+`
+
+// Test hiding symbols in the syntax tree
+func TestHiddenSymbols(t *testing.T) {
+	tmp := t.TempDir()
+
+	mainGo := filepath.Join(tmp, "main.go")
+	if e := os.WriteFile(mainGo, []byte(hiddenMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	var g earley.Grammar
+	g.Options.Tree = true
+	g.Options.Hidden = []glean.Symbol{"Product", "Item"}
+	addrule := func(name string, target glean.Symbol, items ...glean.Symbol) {
+		if e := g.AddRule(name, target, items); e != nil {
+			t.Fatal(e)
+		}
+	}
+	addrule("RuleSum", "Sum", "Product")
+	addrule("RuleAdd", "Sum", "Sum", "Plus", "Product")
+	addrule("RuleProduct", "Product", "Item")
+	addrule("RuleMultiply", "Product", "Product", "Times", "Item")
+	addrule("RuleItem", "Item", "int")
+	parserText, e := g.WriteParser("Sum", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	parserGo := filepath.Join(tmp, "parser.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	out, e := exec.Command("go", "run", mainGo, parserGo).CombinedOutput()
+	if e != nil {
+		t.Fatal(e, string(out))
+	}
+	expect := "(RuleAdd (RuleAdd (RuleSum 1) + 2 * 3) + 4)\n"
+	if string(out) != expect {
+		t.Errorf("wrong output:\nexpected:\n%s\ngot:\n%s", expect, out)
+	}
+
+	for _, hidden := range []glean.Symbol{"Sum", "Plus", "Quotient"} {
+		g.Options.Hidden = []glean.Symbol{hidden}
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+			t.Error("no error when hiding", hidden)
+		}
+	}
+}
+
+var hiddenMainText = `
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Sum int
+type Product int
+type Item int
+type Plus struct{}
+type Times struct{}
+
+func RuleSum(p Product) Sum { return Sum(p) }
+func RuleAdd(s Sum, _ Plus, p Product) Sum { return s + Sum(p) }
+func RuleProduct(i Item) Product { return Product(i) }
+func RuleMultiply(p Product, _ Times, i Item) Product { return p * Product(i) }
+func RuleItem(i int) Item { return Item(i) }
+
+var ruleNames = []string{"RuleSum", "RuleAdd", "RuleProduct", "RuleMultiply", "RuleItem"}
+
+func show(node *_Node) string {
+	switch node.Token.(type) {
+	case Plus:
+		return "+"
+	case Times:
+		return "*"
+	case int:
+		return fmt.Sprint(node.Token)
+	}
+	var items []string
+	for _, c := range node.Children {
+		items = append(items, show(c))
+	}
+	return fmt.Sprintf("(%s %s)", ruleNames[node.Rule], strings.Join(items, " "))
+}
+
+func main() {
+	tree, e := _ParseTree([]interface{}{1, Plus{}, 2, Times{}, 3, Plus{}, 4})
+	if e != nil {
+		panic(e)
+	}
+	fmt.Println(show(tree))
+}
+`
+
+// Test the source spans of tree nodes
+func TestTreeSpan(t *testing.T) {
+	var options earley.Options
+	options.Tree = true
+	parse, e := gleantest.Build(t, spanMainText, "List", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	out, e := parse()
+	if e != nil {
+		t.Fatal(e, out)
+	}
+	expect := `List 0 0 false
+Items 2 6 true
+Close 7 8 true
+List 0 0 false
+Items 0 0 false
+Close 2 3 true
+`
+	if out != expect {
+		t.Errorf("wrong output:\nexpected:\n%s\ngot:\n%s", expect, out)
+	}
+}
+
+var spanMainText = `
+package main
+
+import "fmt"
+
+type List struct{}
+type Items struct{}
+type Open struct{}
+
+type Word struct {
+	text string
+	at   int
+}
+
+type Close struct {
+	at int
+}
+
+func (w Word) Span() (start, end int)  { return w.at, w.at + len(w.text) }
+func (c Close) Span() (start, end int) { return c.at, c.at + 1 }
+
+func RuleList(Open, Items, Close) List { return List{} }
+func RuleNone() Items                  { return Items{} }
+func RuleSome([]Word) Items            { return Items{} }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{Open{}, Word{"a", 2}, Word{"bc", 4}, Close{7}},
+		{Open{}, Close{2}},
+	} {
+		tree, e := _glean_ParseTree(tokens)
+		if e != nil {
+			fmt.Println(e)
+			continue
+		}
+		for _, node := range []*_glean_Node{tree, tree.Children[1], tree.Children[2]} {
+			start, end, ok := node.Span()
+			fmt.Println(node.Symbol, start, end, ok)
+		}
+	}
+}
+`
@@ -0,0 +1,89 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test finding the symbols of tokens from their TypeId methods
+func TestTypeIds(t *testing.T) {
+	for _, unchecked := range []bool{false, true} {
+		var options earley.Options
+		options.TypeIds = true
+		options.Unchecked = unchecked
+		parse, e := gleantest.Build(t, typeIdsMainText, "Sum", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		// Without checks, the bad tokens have undefined effects.
+		expect := "1+2\n"
+		if !unchecked {
+			expect += "panic: input token (type main.Bad) is not a terminal symbol\n" +
+				"panic: input token (type int) is not a terminal symbol\n"
+		}
+		if out, e := parse(); e != nil || !strings.HasPrefix(out, expect) || !unchecked && out != expect {
+			t.Errorf("unchecked %v:\nexpected:\n%s\ngot:\n%s %v", unchecked, expect, out, e)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleSum", "Sum", []glean.Symbol{"Num", "Plus", "Num"})
+	for _, options := range []func(*earley.Options){
+		func(o *earley.Options) { o.Classifier = true },
+		func(o *earley.Options) { o.Scannerless = true },
+		func(o *earley.Options) {
+			o.Kinds = map[glean.Symbol]string{"Num": "1", "Plus": "2"}
+			o.KindFunc = "kindOf"
+		},
+	} {
+		g.Options = earley.Options{TypeIds: true}
+		options(&g.Options)
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+			t.Errorf("no error for TypeIds with options %+v", g.Options)
+		}
+	}
+}
+
+var typeIdsMainText = `
+package main
+
+import "fmt"
+
+type Num string
+type Plus struct{}
+type Bad struct{}
+type Sum string
+
+func (Num) TypeId() int  { return _glean_TerminalNum }
+func (Plus) TypeId() int { return _glean_TerminalPlus }
+func (Bad) TypeId() int  { return -1 }
+
+func RuleSum(x Num, _ Plus, y Num) Sum { return Sum(x + "+" + y) }
+
+func try(tokens []interface{}) {
+	defer func() {
+		if p := recover(); p != nil {
+			fmt.Println("panic:", p)
+		}
+	}()
+	sum, e := _glean_Parse(tokens)
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(sum)
+}
+
+func main() {
+	try([]interface{}{Num("1"), Plus{}, Num("2")})
+	try([]interface{}{Num("1"), Bad{}, Num("2")})
+	try([]interface{}{Num("1"), 7, Num("2")})
+}
+`
@@ -0,0 +1,86 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test replacing the names of the parser's integer types
+func TestTypeNames(t *testing.T) {
+	var options earley.Options
+	options.PrefixType = "_internalPrefix"
+	options.RuleType = "_internalRule"
+	options.SymbolType = "_internalSymbol"
+	options.Tree = true
+	parse, e := gleantest.Build(t, typeNamesMainText, "Sum", options)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if out, e := parse(); e != nil || out != "6\n" {
+		t.Error(e, out)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+	g.Options.Tree = true
+	g.Options.SymbolType = "_internalSymbol"
+	text, e := g.WriteParser("Sum", "main", "_glean_")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !strings.Contains(text, "type _glean__internalSymbol int") || strings.Contains(text, "_glean__Symbol") {
+		t.Error("symbol type not renamed")
+	}
+	if !strings.Contains(text, "type _glean__Rule int") {
+		t.Error("rule type renamed")
+	}
+
+	for _, bad := range []struct {
+		prefix, rule, symbol string
+		message              string
+	}{
+		{"", "", "1x", "not a valid Go identifier"},
+		{"X", "", "X", "given for two types"},
+		{"_Match", "", "", "declared twice"},
+		{"", "Parse", "", "declared twice"},
+	} {
+		g.Options.PrefixType = bad.prefix
+		g.Options.RuleType = bad.rule
+		g.Options.SymbolType = bad.symbol
+		prepend := "_glean_"
+		if bad.symbol == "1x" {
+			prepend = ""
+		}
+		if _, e := g.WriteParser("Sum", "main", prepend); e == nil || !strings.Contains(e.Error(), bad.message) {
+			t.Errorf("expected error containing %q for %v; got %v", bad.message, bad, e)
+		}
+	}
+}
+
+var typeNamesMainText = `
+package main
+
+import "fmt"
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	s, e := _glean_Parse([]interface{}{1, Plus{}, 2, Plus{}, 3})
+	if e != nil {
+		fmt.Println(e)
+		return
+	}
+	fmt.Println(s)
+}
+`
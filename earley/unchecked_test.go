@@ -0,0 +1,75 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test parsers generated without checks of their input
+func TestUnchecked(t *testing.T) {
+	for _, c := range []struct {
+		classifier bool
+		mainText   string
+	}{
+		{false, uncheckedMainText},
+		{true, uncheckedClassifierMainText},
+	} {
+		var options earley.Options
+		options.Unchecked = true
+		options.Classifier = c.classifier
+		parse, e := gleantest.Build(t, c.mainText, "Sum", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		expect := "6 <nil>\n0 unexpected token: main.Plus\n"
+		if out, e := parse(); e != nil || out != expect {
+			t.Errorf("classifier %v:\nexpected:\n%s\ngot:\n%s %v", c.classifier, expect, out, e)
+		}
+	}
+}
+
+var uncheckedMainText = `
+package main
+
+import "fmt"
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	fmt.Println(_glean_Parse([]interface{}{1, Plus{}, 2, Plus{}, 3}))
+	fmt.Println(_glean_Parse([]interface{}{1, Plus{}, Plus{}}))
+}
+`
+
+var uncheckedClassifierMainText = `
+package main
+
+import "fmt"
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func classify(t interface{}) int {
+	if _, ok := t.(Plus); ok {
+		return _glean_TerminalPlus
+	}
+	return _glean_Terminalint
+}
+
+func main() {
+	fmt.Println(_glean_Parse([]interface{}{1, Plus{}, 2, Plus{}, 3}, classify))
+	fmt.Println(_glean_Parse([]interface{}{1, Plus{}, Plus{}}, classify))
+}
+`
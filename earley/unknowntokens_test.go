@@ -0,0 +1,123 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test reporting tokens not of terminal types as errors
+func TestUnknownTokens(t *testing.T) {
+	for _, options := range []earley.Options{
+		{UnknownTokens: true},
+		{UnknownTokens: true, DisplayNames: map[glean.Symbol]string{"Plus": "+"}},
+		{UnknownTokens: true, EndSymbol: "End"},
+		{UnknownTokens: true, Recover: true},
+		{UnknownTokens: true, Sequence: true},
+		{UnknownTokens: true, Kinds: map[glean.Symbol]string{"int": "0", "Plus": "1", "End": "2"}, KindFunc: "kind"},
+	} {
+		parse, e := gleantest.Build(t, unknownTokensMainText, "Sum", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		expect := `6
+input token (type string) is not a terminal symbol 2 true
+input token (type float64) is not a terminal symbol 0 true
+input token (type string) is not a terminal symbol 4 true
+`
+		if out, e := parse(); e != nil || out != expect {
+			t.Errorf("options %+v:\nexpected:\n%s\ngot:\n%s %v", options, expect, out, e)
+		}
+	}
+
+	parse, e := gleantest.Build(t, unknownTokensRecoverMainText, "Sum", earley.Options{UnknownTokens: true, Recover: true})
+	if e != nil {
+		t.Fatal(e)
+	}
+	expect := "3 [input token (type string) is not a terminal symbol]\n"
+	if out, e := parse(); e != nil || out != expect {
+		t.Errorf("recovering:\nexpected:\n%s\ngot:\n%s %v", expect, out, e)
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Sum", []glean.Symbol{"int"})
+	for _, options := range []earley.Options{
+		{UnknownTokens: true, Unchecked: true},
+		{UnknownTokens: true, Classifier: true},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Sum", "main", "_"); e == nil {
+			t.Errorf("no error for options %+v", options)
+		}
+	}
+}
+
+var unknownTokensMainText = `
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pat42smith/glean/gleanerrors"
+)
+
+type Plus struct{}
+type End struct{}
+type Sum int
+
+func RuleInt(x int) Sum                { return Sum(x) }
+func RuleAdd(s Sum, _ Plus, x int) Sum { return s + Sum(x) }
+func RuleEnd(s Sum, _ End) Sum         { return s }
+
+func kind(t interface{}) int {
+	switch t.(type) {
+	case int:
+		return 0
+	case Plus:
+		return 1
+	case End:
+		return 2
+	}
+	return -1
+}
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{1, Plus{}, 2, Plus{}, 3},
+		{1, Plus{}, "two"},
+		{1.5},
+		{1, Plus{}, 2, Plus{}, "three"},
+	} {
+		sum, e := _glean_Parse(tokens)
+		if e == nil {
+			fmt.Println(sum)
+			continue
+		}
+		var u gleanerrors.UnknownToken
+		ok := errors.As(e, &u) && errors.Is(e, gleanerrors.ErrUnknownToken)
+		fmt.Println(e, u.Index, ok)
+	}
+}
+`
+
+var unknownTokensRecoverMainText = `
+package main
+
+import "fmt"
+
+type Plus struct{}
+type Sum int
+
+func RuleInt(x int) Sum                { return Sum(x) }
+func RuleAdd(s Sum, _ Plus, x int) Sum { return s + Sum(x) }
+
+func main() {
+	fmt.Println(_glean_ParseRecover([]interface{}{1, "one", Plus{}, 2}, 0))
+}
+`
@@ -0,0 +1,252 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley
+
+import (
+	"fmt"
+	"go/types"
+	"sort"
+	"strings"
+
+	"github.com/pat42smith/glean"
+)
+
+// Validate checks the grammar for likely mistakes that do not prevent
+// writing a parser, and returns a warning for each one found.
+//
+// The checks are for rules much longer than is usual in the grammar, as
+// set by g.Options.LongRuleFactor, and for rules of shapes that make the
+// number of matches in the parse chart grow far faster than the input, as
+// in checkBlowup. Such a parser may be fast on the inputs of its tests but
+// very slow on longer or adversarial ones. There is also a check for
+// symbols deriving themselves alone, as in checkCycles.
+func (g *Grammar) Validate() []error {
+	var warnings []error
+	warnings = append(warnings, g.checkLongRules(g.rules)...)
+	warnings = append(warnings, g.checkBlowup()...)
+	warnings = append(warnings, g.checkCycles()...)
+	return warnings
+}
+
+// Warn of the symbols that derive themselves alone, typically through
+// recursive rules whose other items are nullable, as with Blank = Blank
+// Blank where Blank is nullable. The groups of such symbols are the
+// UnitCycles of Report. An input matching one of them has infinitely many
+// derivations, and so is reported as ambiguous.
+func (g *Grammar) checkCycles() []error {
+	var warnings []error
+	for _, group := range g.Report("").UnitCycles {
+		if len(group) == 1 {
+			warnings = append(warnings, fmt.Errorf("symbol %s derives itself alone; input it matches has infinitely many parses", group[0]))
+		} else {
+			names := make([]string, len(group))
+			for n, s := range group {
+				names[n] = string(s)
+			}
+			warnings = append(warnings, fmt.Errorf("symbols %s derive each other alone; input they match has infinitely many parses",
+				strings.Join(names, ", ")))
+		}
+	}
+	return warnings
+}
+
+// Check looks for symbols likely to be misspelt, as one might be in a
+// single rule, and returns a warning for each one found. It reports each
+// nonterminal unreachable from the goal, as one whose only uses are
+// misspelt would be, and each terminal whose name is close to that of a
+// nonterminal, as in Expr2 or Exrp for Expr, which probably should have
+// been that nonterminal. A terminal has no rules by design, so a terminal
+// is not reported merely for having none; nor is one named by a
+// predeclared Go type, such as int. Lists and optional symbols are not
+// reported, only their elements.
+func (g *Grammar) Check(goal glean.Symbol) []error {
+	var warnings []error
+	for _, name := range g.Report(goal).Unreachable {
+		if s := g.name2symbol[name]; !s.isTerminal() && s.element == nil {
+			warnings = append(warnings, fmt.Errorf("symbol '%s' is unreachable from goal '%s'", name, goal))
+		}
+	}
+
+	var terminals, nonterminals []glean.Symbol
+	for name, s := range g.name2symbol {
+		if s.element != nil {
+			continue
+		} else if !s.isTerminal() {
+			nonterminals = append(nonterminals, name)
+		} else if _, ok := types.Universe.Lookup(string(name)).(*types.TypeName); !ok {
+			terminals = append(terminals, name)
+		}
+	}
+	sortSymbols(terminals)
+	sortSymbols(nonterminals)
+	for _, t := range terminals {
+		for _, n := range nonterminals {
+			if closeNames(string(t), string(n)) {
+				warnings = append(warnings, fmt.Errorf("symbol '%s' has no rules, so is a terminal; is it a misspelling of '%s'?", t, n))
+				break
+			}
+		}
+	}
+	return warnings
+}
+
+// Tell whether the name of a terminal is close to that of a nonterminal:
+// the same but for case, or for digits appended, or within one edit,
+// adding, removing, changing or swapping a letter. Names of one or two
+// letters are too short to tell.
+func closeNames(t, n string) bool {
+	if len(n) < 3 {
+		return false
+	}
+	if strings.EqualFold(t, n) || strings.TrimRight(t, "0123456789") == n {
+		return true
+	}
+	if len(t) < len(n) {
+		t, n = n, t
+	}
+	switch len(t) - len(n) {
+	case 0:
+		diff := -1
+		for i := range t {
+			if t[i] != n[i] {
+				if diff >= 0 {
+					return i == diff+1 && t[diff] == n[i] && t[i] == n[diff] && t[i+1:] == n[i+1:]
+				}
+				diff = i
+			}
+		}
+		return true
+	case 1:
+		i := 0
+		for i < len(n) && t[i] == n[i] {
+			i++
+		}
+		return t[i+1:] == n[i:]
+	}
+	return false
+}
+
+// AddRuleChecked adds a rule as AddRule does, then checks it against the
+// rules already added, returning a warning for each likely mistake found.
+// This suits tools building a grammar a rule at a time, which can report
+// a problem as soon as the rule causing it is added; AddRule does not
+// check these, to stay cheap for building a grammar all at once.
+//
+// The checks are for a rule identical, but for its name, to an earlier
+// rule; for a rule whose only item is its target; and for a rule much
+// longer than is usual in the grammar so far, as in Validate. The rule is
+// added even if there are warnings; if it cannot be added, an error is
+// returned, with no warnings.
+func (g *Grammar) AddRuleChecked(name string, target glean.Symbol, items []glean.Symbol) ([]error, error) {
+	if e := g.AddRule(name, target, items); e != nil {
+		return nil, e
+	}
+	r := g.rules[len(g.rules)-1]
+
+	var warnings []error
+	for _, other := range r.target.rules[:len(r.target.rules)-1] {
+		if sameItems(other.items, r.items) {
+			warnings = append(warnings, fmt.Errorf("rule %s is the same as rule %s", r.name, other.name))
+		}
+	}
+	if len(r.items) == 1 && r.items[0] == r.target {
+		warnings = append(warnings, fmt.Errorf("rule %s derives %s from itself alone", r.name, r.target.name))
+	}
+	warnings = append(warnings, g.checkLongRules([]*rule{r})...)
+	return warnings, nil
+}
+
+// Tell whether two lists of items are the same
+func sameItems(a, b []*symbol) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for n := range a {
+		if a[n] != b[n] {
+			return false
+		}
+	}
+	return true
+}
+
+// Warn of those of the rules given whose length is far above the median
+// of the grammar's rules
+func (g *Grammar) checkLongRules(rules []*rule) []error {
+	factor := g.Options.LongRuleFactor
+	if factor == 0 {
+		factor = DefaultLongRuleFactor
+	}
+	if factor < 0 || len(g.rules) == 0 {
+		return nil
+	}
+
+	lengths := make([]int, len(g.rules))
+	for n, r := range g.rules {
+		lengths[n] = len(r.items)
+	}
+	sort.Ints(lengths)
+	median := lengths[len(lengths)/2]
+	if median < 1 {
+		median = 1
+	}
+
+	var warnings []error
+	for _, r := range rules {
+		if len(r.items) > factor*median {
+			warnings = append(warnings, fmt.Errorf("rule %s has %d items, more than %d times the median of %d",
+				r.name, len(r.items), factor, median))
+		}
+	}
+	return warnings
+}
+
+// Warn of rules whose shapes are known to make the parse chart grow
+// rapidly. This is only a heuristic, looking at rules directly rather
+// than at what their items derive. Two shapes are caught: a rule using
+// its target more than once, as in A = A A, whose input may have a number
+// of parses exponential in its length; and a target with one rule
+// beginning with the target and another ending with it, as in
+// E = E Plus T and E = T Times E, whose input may be split between the two in
+// many ways.
+func (g *Grammar) checkBlowup() []error {
+	var warnings []error
+	embedding := make(map[*rule]bool)
+	for _, r := range g.rules {
+		uses := 0
+		for _, i := range r.items {
+			if i == r.target {
+				uses++
+			}
+		}
+		if uses > 1 {
+			embedding[r] = true
+			warnings = append(warnings, fmt.Errorf("rule %s uses %s %d times; the number of parses may grow exponentially with the input",
+				r.name, r.target.name, uses))
+		}
+	}
+
+	left := make(map[*symbol]*rule)
+	right := make(map[*symbol]*rule)
+	for _, r := range g.rules {
+		if len(r.items) < 2 || embedding[r] {
+			continue
+		}
+		if r.items[0] == r.target && left[r.target] == nil {
+			left[r.target] = r
+		}
+		if r.items[len(r.items)-1] == r.target && right[r.target] == nil {
+			right[r.target] = r
+		}
+	}
+	warned := make(map[*symbol]bool)
+	for _, r := range g.rules {
+		t := r.target
+		if left[t] != nil && right[t] != nil && !warned[t] {
+			warned[t] = true
+			warnings = append(warnings, fmt.Errorf("rules %s and %s make %s both left and right recursive; the number of parses may grow exponentially with the input",
+				left[t].name, right[t].name, t.name))
+		}
+	}
+	return warnings
+}
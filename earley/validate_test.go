@@ -0,0 +1,175 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Test the limit on rule length and the warning for long rules
+func TestRuleLength(t *testing.T) {
+	var g earley.Grammar
+	g.Options.MaxItems = 10
+	long := make([]glean.Symbol, 11)
+	for n := range long {
+		long[n] = "Item"
+	}
+	if e := g.AddRule("RuleTooLong", "Goal", long); e == nil {
+		t.Error("rule with too many items was accepted")
+	} else if e.Error() != "rule RuleTooLong has 11 items, more than the limit of 10" {
+		t.Error("wrong error:", e)
+	}
+
+	for _, r := range []struct {
+		name  string
+		items []glean.Symbol
+	}{
+		{"RuleA", []glean.Symbol{"A"}},
+		{"RuleB", []glean.Symbol{"A", "B"}},
+		{"RuleC", []glean.Symbol{"C"}},
+		{"RuleLong", long[:9]},
+	} {
+		if e := g.AddRule(r.name, "Goal", r.items); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	warnings := g.Validate()
+	if len(warnings) != 1 || warnings[0].Error() != "rule RuleLong has 9 items, more than 4 times the median of 2" {
+		t.Error("wrong warnings:", warnings)
+	}
+
+	g.Options.LongRuleFactor = 5
+	if warnings = g.Validate(); len(warnings) != 0 {
+		t.Error("unexpected warnings:", warnings)
+	}
+
+	g.Options.LongRuleFactor = -1
+	if warnings = g.Validate(); len(warnings) != 0 {
+		t.Error("unexpected warnings:", warnings)
+	}
+}
+
+// Test the warnings of AddRuleChecked
+func TestAddRuleChecked(t *testing.T) {
+	var g earley.Grammar
+	for _, r := range []struct {
+		name   string
+		target glean.Symbol
+		items  []glean.Symbol
+		expect []string
+	}{
+		{"RuleA", "Goal", []glean.Symbol{"A"}, nil},
+		{"RuleB", "Goal", []glean.Symbol{"A", "B"}, nil},
+		{"RuleA2", "Goal", []glean.Symbol{"A"}, []string{"rule RuleA2 is the same as rule RuleA"}},
+		{"RuleOther", "Other", []glean.Symbol{"A"}, nil},
+		{"RuleLoop", "Goal", []glean.Symbol{"Goal"}, []string{"rule RuleLoop derives Goal from itself alone"}},
+		{"RuleLong", "Goal", []glean.Symbol{"A", "B", "A", "B", "A"},
+			[]string{"rule RuleLong has 5 items, more than 4 times the median of 1"}},
+		{"RuleLong2", "Goal", []glean.Symbol{"A", "B", "A", "B", "A"}, []string{
+			"rule RuleLong2 is the same as rule RuleLong",
+			"rule RuleLong2 has 5 items, more than 4 times the median of 1"}},
+	} {
+		warnings, e := g.AddRuleChecked(r.name, r.target, r.items)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if len(warnings) != len(r.expect) {
+			t.Errorf("rule %s: expected %v, got %v", r.name, r.expect, warnings)
+			continue
+		}
+		for n, w := range warnings {
+			if w.Error() != r.expect[n] {
+				t.Errorf("rule %s: expected %v, got %v", r.name, r.expect, warnings)
+				break
+			}
+		}
+	}
+
+	if warnings, e := g.AddRuleChecked("RuleA", "Goal", []glean.Symbol{"C"}); e == nil || warnings != nil {
+		t.Error("duplicate rule name accepted:", warnings, e)
+	}
+}
+
+// Test the warnings of rules that make the parse chart grow rapidly
+func TestBlowupWarnings(t *testing.T) {
+	var g earley.Grammar
+	for _, r := range []struct {
+		name   string
+		target glean.Symbol
+		items  []glean.Symbol
+	}{
+		{"RulePair", "A", []glean.Symbol{"A", "A"}},
+		{"RuleA", "A", []glean.Symbol{"a"}},
+		{"RuleSum", "E", []glean.Symbol{"E", "Plus", "T"}},
+		{"RuleT", "E", []glean.Symbol{"T"}},
+		{"RuleProduct", "E", []glean.Symbol{"T", "Times", "E"}},
+		{"RuleList", "List", []glean.Symbol{"Item", "List"}},
+		{"RuleList2", "List", []glean.Symbol{"Item", "Comma", "List"}},
+		{"RuleItem", "List", []glean.Symbol{"Item"}},
+	} {
+		if e := g.AddRule(r.name, r.target, r.items); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	expect := []string{
+		"rule RulePair uses A 2 times; the number of parses may grow exponentially with the input",
+		"rules RuleSum and RuleProduct make E both left and right recursive; the number of parses may grow exponentially with the input",
+	}
+	warnings := g.Validate()
+	if len(warnings) != len(expect) {
+		t.Fatalf("expected %v, got %v", expect, warnings)
+	}
+	for n, w := range warnings {
+		if w.Error() != expect[n] {
+			t.Errorf("expected %s, got %s", expect[n], w)
+		}
+	}
+}
+
+// Test the warnings for symbols that are likely misspelt
+func TestCheckSymbols(t *testing.T) {
+	var g earley.Grammar
+	for _, r := range []struct {
+		name   string
+		target glean.Symbol
+		items  []glean.Symbol
+	}{
+		{"RuleSum", "Sum", []glean.Symbol{"Sum", "Plus", "Expr"}},
+		{"RuleOne", "Sum", []glean.Symbol{"Expr2"}},
+		{"RuleParen", "Expr", []glean.Symbol{"Open", "Sum", "Close"}},
+		{"RuleInt", "Expr", []glean.Symbol{"int"}},
+		{"RuleNeg", "Expr", []glean.Symbol{"Minus", "Exrp"}},
+		{"RuleTerm", "Term", []glean.Symbol{glean.ListOf("Int")}},
+		{"RuleInt2", "Int", []glean.Symbol{"int"}},
+	} {
+		if e := g.AddRule(r.name, r.target, r.items); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	expect := []string{
+		"symbol 'Int' is unreachable from goal 'Sum'",
+		"symbol 'Term' is unreachable from goal 'Sum'",
+		"symbol 'Expr2' has no rules, so is a terminal; is it a misspelling of 'Expr'?",
+		"symbol 'Exrp' has no rules, so is a terminal; is it a misspelling of 'Expr'?",
+	}
+	warnings := g.Check("Sum")
+	if len(warnings) != len(expect) {
+		t.Fatalf("expected %v, got %v", expect, warnings)
+	}
+	for n, w := range warnings {
+		if w.Error() != expect[n] {
+			t.Errorf("expected %s, got %s", expect[n], w)
+		}
+	}
+
+	if warnings = g.Check("Term"); len(warnings) != 4 {
+		t.Errorf("expected 4 warnings for goal Term, got %v", warnings)
+	}
+}
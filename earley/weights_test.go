@@ -0,0 +1,92 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+// Test the resolution of ambiguities by rule weights
+func TestWeights(t *testing.T) {
+	for _, c := range []struct {
+		weights map[string]int
+		expect  string
+	}{
+		{map[string]int{"RuleDirect": 3, "RuleInt": 2, "RuleCoerce": 1},
+			"coerce(5) <nil>\nsum(coerce(5),coerce(6)) <nil>\n ambiguous match for Value\n"},
+		{map[string]int{"RuleDirect": 0, "RuleInt": 2, "RuleCoerce": 1},
+			"direct(5) <nil>\nsum(coerce(5),coerce(6)) <nil>\n ambiguous match for Value\n"},
+		{map[string]int{"RuleDirect": 2, "RuleInt": 1, "RuleSmall": 1, "RuleCoerce": 1},
+			"int(5) <nil>\nsum(int(5),int(6)) <nil>\n ambiguous match for Value\n"},
+		{map[string]int{"RuleDirect": 1, "RuleInt": 1, "RuleCoerce": 1},
+			" ambiguous match for Goal\n 2 ambiguities:\n 3 ambiguities:\n"},
+	} {
+		var options earley.Options
+		options.Weights = c.weights
+		parse, e := gleantest.Build(t, weightsMainText, "Goal", options)
+		if e != nil {
+			t.Fatal(e)
+		}
+		out, e := parse()
+		if e != nil {
+			t.Fatal(e, out)
+		}
+		if out != c.expect {
+			t.Errorf("weights %v:\nexpected:\n%s\ngot:\n%s", c.weights, c.expect, out)
+		}
+	}
+
+	var g earley.Grammar
+	g.AddRule("RuleInt", "Value", []glean.Symbol{"int"})
+	for _, options := range []earley.Options{
+		{Weights: map[string]int{"RuleFloat": 1}},
+		{Weights: map[string]int{"RuleInt": -1}},
+		{Weights: map[string]int{"RuleInt": 1}, Ambiguity: earley.AmbiguityLeftmost},
+	} {
+		g.Options = options
+		if _, e := g.WriteParser("Value", "main", "_"); e == nil {
+			t.Errorf("no error for options %v", options)
+		}
+	}
+}
+
+var weightsMainText = `
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Goal string
+type Value string
+type Small int
+type Plus struct{}
+
+func RuleGoal(v Value) Goal                 { return Goal(v) }
+func RuleDirect(i int) Goal                 { return Goal(fmt.Sprintf("direct(%d)", i)) }
+func RuleInt(i int) Value                   { return Value(fmt.Sprintf("int(%d)", i)) }
+func RuleSmall(i int) Small                 { return Small(i) }
+func RuleCoerce(s Small) Value              { return Value(fmt.Sprintf("coerce(%d)", s)) }
+func RuleSum(x Value, _ Plus, y Value) Value { return Value("sum(" + x + "," + y + ")") }
+
+func main() {
+	for _, tokens := range [][]interface{}{
+		{5},
+		{5, Plus{}, 6},
+		{5, Plus{}, 6, Plus{}, 7},
+	} {
+		g, e := _glean_Parse(tokens)
+		if e != nil {
+			fmt.Println(g, strings.Split(e.Error(), "\n")[0])
+		} else {
+			fmt.Println(g, e)
+		}
+	}
+}
+`
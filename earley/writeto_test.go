@@ -0,0 +1,95 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package earley_test
+
+import (
+	"errors"
+	"go/format"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// A writer that always fails
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+// A writer that counts the calls of Write
+type countWriter struct {
+	strings.Builder
+	writes int
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	c.writes++
+	return c.Builder.Write(p)
+}
+
+// Test writing the parser to an io.Writer
+func TestWriteParserTo(t *testing.T) {
+	var g earley.Grammar
+	g.AddRule("RuleSum", "Sum", []glean.Symbol{"Sum", "Plus", "Term"})
+	g.AddRule("RuleTerm", "Sum", []glean.Symbol{"Term"})
+	g.AddRule("RuleProduct", "Term", []glean.Symbol{"Term", "Times", "int"})
+	g.AddRule("RuleInt", "Term", []glean.Symbol{"int"})
+	g.Options.Tree = true
+
+	text, e := g.WriteParser("Sum", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+	var b countWriter
+	if e = g.WriteParserTo(&b, "Sum", "main", "_"); e != nil {
+		t.Fatal(e)
+	}
+	if formatted, e := format.Source([]byte(b.String())); e != nil || string(formatted) != text {
+		t.Error("WriteParserTo and WriteParser differ once formatted:", e)
+	}
+	if b.writes < 2 {
+		t.Errorf("the parser was written in %d calls, not streamed", b.writes)
+	}
+
+	// The text must not depend on the order of iteration over a map.
+	for n := 0; n < 10; n++ {
+		again, e := g.WriteParser("Sum", "main", "_")
+		if e != nil {
+			t.Fatal(e)
+		}
+		if again != text {
+			t.Fatal("WriteParser output varies between calls")
+		}
+	}
+
+	b.Reset()
+	if e = g.WriteParserTo(&b, "Missing", "main", "_"); e == nil || b.Len() != 0 {
+		t.Errorf("expected an error and no output, got %v and %d bytes", e, b.Len())
+	}
+	if e = g.WriteParserTo(failWriter{}, "Sum", "main", "_"); e == nil || e.Error() != "write failed" {
+		t.Error("wrong error from failing writer:", e)
+	}
+
+	// With renamed types, the text is checked before it is written.
+	g.Options.PrefixType = "prefix"
+	text, e = g.WriteParser("Sum", "main", "_")
+	if e != nil {
+		t.Fatal(e)
+	}
+	b.Reset()
+	if e = g.WriteParserTo(&b, "Sum", "main", "_"); e != nil {
+		t.Fatal(e)
+	}
+	if formatted, e := format.Source([]byte(b.String())); e != nil || string(formatted) != text {
+		t.Error("WriteParserTo and WriteParser differ once formatted, with PrefixType:", e)
+	}
+	g.Options.PrefixType = "_Match"
+	b.Reset()
+	if e = g.WriteParserTo(&b, "Sum", "main", "_"); e == nil || !strings.Contains(e.Error(), "declared twice") || b.Len() != 0 {
+		t.Errorf("expected an error and no output for a clashing type, got %v and %d bytes", e, b.Len())
+	}
+}
@@ -10,19 +10,316 @@ and the functions themselves provide the actions to implement the rules.
 
 With no files listed, glean scans the the .go files of the package in the
 current directory, excluding _test.go files. Given a list of one or more files,
-glean scans those files. The files must all belong to the same package.
+glean scans those files. The files must all belong to the same package. Given
+the single file -, glean scans Go source read from stdin, as for go:generate
+pipelines; the flags that select the files to scan then have no effect.
+
+Before writing the parser, glean checks that none of its file scope names
+is declared in another file of the package in the output file's directory.
 
 Usage:
  glean [flags] [file...]
 
 The flags are:
  -o file
-  Write the generated parser to this file. Default: parse.go
+  Write the generated parser to this file, or to stdout if file is -.
+  Writing to stdout skips the check for names declared elsewhere in the
+  package. Default: parse.go
  -t symbol
   Sets the target symbol that the parser will construct. Default: Target
+  This flag may be repeated, to write a parser for each target into the
+  one file. Each parser's prefix is then that of -p followed by its
+  target and an underscore, as in _glean_Program_Parse, and the parsers
+  share the imports of the file. The flags that inspect the grammar for a
+  target, -ebnf, -lint and -warn-symbols, use the first.
  -p prefix
   Apply the indicated prefix to all file scope names in the generated parser.
   Default: _glean_
+ -ambiguity policy
+  How the parser handles ambiguous input: error (the default) reports it,
+  leftmost, rightmost and longest choose a derivation. See Ambiguity in
+  github.com/pat42smith/glean/earley.Options.
+ -first-rule
+  Where different rules match a symbol to the same tokens, choose the rule
+  whose function was found first, rather than reporting an ambiguity, as
+  for a dangling else. See FirstRule in
+  github.com/pat42smith/glean/earley.Options.
+ -catch-panics
+  Make the parse functions recover from panics, returning them as
+  gleanerrors.Internal errors along with the input tokens.
+ -classifier
+  Give the parse functions a further argument, a function returning the
+  terminal symbol id of each token, rather than using the token types.
+  See Classifier in github.com/pat42smith/glean/earley.Options.
+ -alternatives
+  With -classifier, give the parse functions another argument, a function
+  returning the ids of further terminal symbols each token may match, such
+  as keywords that are also identifiers. See Alternatives in
+  github.com/pat42smith/glean/earley.Options.
+ -kind symbol=expr
+  Give the terminal symbol the kind expr, a Go constant expression. With
+  -kind-func, the parser finds each token's symbol from its kind rather
+  than its type, so one token type, aliased by each terminal symbol, can
+  serve them all. This flag may be repeated; every terminal symbol needs a
+  kind. See Kinds in github.com/pat42smith/glean/earley.Options.
+ -kind-func name
+  The function, of type func(interface{}) K, returning the kind of each
+  token, for -kind.
+ -type-ids
+  Find the symbol of each token by calling its method TypeId() int, which
+  returns a constant such as _glean_TerminalPlus, rather than by a type
+  switch. This is faster for grammars with many terminal symbols. See
+  TypeIds in github.com/pat42smith/glean/earley.Options.
+ -token-interface name
+  Let the parse functions take a slice of the named interface type, in
+  place of []interface{}. Its method TypeId() int returns the id of each
+  token's terminal symbol, a constant such as _glean_TerminalPlus. See
+  TokenInterface in github.com/pat42smith/glean/earley.Options.
+ -unknown-tokens
+  Make the parse functions return a gleanerrors.UnknownToken error for a
+  token that is not of a terminal symbol's type, rather than panicking.
+  See UnknownTokens in github.com/pat42smith/glean/earley.Options.
+ -debug
+  Also generate _glean_ParseHook, which takes a further function and calls
+  it with the number of matches ending at each input position.
+  See Debug in github.com/pat42smith/glean/earley.Options.
+ -stepping
+  Declare the type DebugParser and the function NewDebugParser (with the
+  prefix), which find the matches of the input one position at a time, so
+  the working of the parser may be followed. See Stepping in
+  github.com/pat42smith/glean/earley.Options.
+ -display symbol=name
+  Show tokens of the terminal symbol as name in unexpected token errors.
+  This flag may be repeated, once for each symbol.
+ -eof symbol
+  Append a token of this terminal symbol's type (its zero value) to the
+  input, so rules can match the end of input explicitly.
+ -errors path
+  Import the gleanerrors package from this path, for use when it has
+  been vendored or forked. Default: github.com/pat42smith/glean/gleanerrors
+ -registry
+  Generate a parser that calls reducers registered at run time,
+  rather than the rule functions. See Registry in
+  github.com/pat42smith/glean/earley.Options.
+ -interface
+  Generate a parser that declares an interface, _glean_Reducer, with a
+  method for each rule, and calls the methods of a value of that type
+  passed to the parse functions, rather than the rule functions. See
+  Interface in github.com/pat42smith/glean/earley.Options.
+ -chart-store
+  Give the parse functions a further argument, a _glean_Chart in which the
+  parser keeps the matches it finds; if it is nil, a new _glean_MemoryChart
+  is used. See ChartStore in github.com/pat42smith/glean/earley.Options.
+ -resolutions
+  With an -ambiguity policy other than error, also generate
+  _glean_ParseResolutions, which lists the ambiguities resolved, with the
+  rules chosen and discarded.
+ -warnings
+  With an -ambiguity policy other than error, also generate
+  _glean_ParseWarnings, which returns a parse together with a warning for
+  each ambiguity resolved, rather than failing on ambiguous input.
+ -parse-all
+  Also generate _glean_ParseAll, which returns the targets of up to a given
+  number of parses of ambiguous input, rather than failing. See ParseAll
+  in github.com/pat42smith/glean/earley.Options.
+ -parse-trace
+  Also generate _glean_ParseTrace, which returns the rules applied, in the
+  order they were applied, with the target. See ParseTrace in
+  github.com/pat42smith/glean/earley.Options.
+ -scannerless
+  Generate a parser whose input is a sequence of positions, at each of
+  which a function offers possibly overlapping tokens of varying lengths.
+  See Scannerless in github.com/pat42smith/glean/earley.Options.
+ -recover
+  Also generate _glean_ParseRecover, which skips unexpected tokens
+  and returns all the errors found. See Recover in
+  github.com/pat42smith/glean/earley.Options.
+ -max-errors n
+  Set the constant _glean_MaxErrors, the suggested error limit for
+  _glean_ParseRecover; a nonzero value implies -recover. Default: 0 (no limit)
+ -error-sink
+  Also generate _glean_ParseRecoverSink, which recovers from errors as
+  _glean_ParseRecover does, but also passes each error to a function as
+  soon as it is found, for tools reporting errors as they come. Implies
+  -recover. See ErrorSink in github.com/pat42smith/glean/earley.Options.
+ -generic
+  Keep the values of symbols in stacks of a generic type, which shortens
+  the generated code. Requires Go 1.18 or later.
+ -parse-as
+  Also generate _glean_ParseAs[T any], which parses as _glean_Parse does
+  but returns the target as a T, such as an interface the target type
+  implements, or an error if it is not one. Requires Go 1.18 or later.
+ -lexer
+  Also generate the interface _glean_Lexer, whose method Next returns the
+  tokens in turn, and _glean_ParseLexer, which parses the tokens read from
+  one. See Lexer in github.com/pat42smith/glean/earley.Options.
+ -reusable
+  Also generate the type _glean_Parser, whose Parse method parses as
+  _glean_Parse does, but reuses the memory of one parse in the next. See
+  Reusable in github.com/pat42smith/glean/earley.Options.
+ -go version
+  The Go release, such as 1.17, under which the parser must compile; an
+  option needing a later release, such as -generic, is then an error. By
+  default, the release is that of the go directive in the go.mod file of
+  the output directory or the nearest directory above it, if any. See
+  GoVersion in github.com/pat42smith/glean/earley.Options.
+ -tree
+  Also generate _glean_ParseTree and _glean_ParseJSON, which return the
+  syntax tree of the input. See Tree in github.com/pat42smith/glean/earley.Options.
+ -events
+  Also generate _glean_ParseEvents, which sends the rules applied, with the
+  ranges of input they reduce, as events on a channel. See Events in
+  github.com/pat42smith/glean/earley.Options.
+ -concurrent
+  Also generate _glean_ParseConcurrent, which takes a number of workers
+  and applies the rules on that many goroutines, each rule as soon as its
+  items are ready. The rule functions must be safe to call concurrently.
+  See Concurrent in github.com/pat42smith/glean/earley.Options.
+ -hidden symbols
+  With -tree, leave the nodes for these symbols (separated by commas) out
+  of the parse trees, putting their children in their place.
+ -intern symbols
+  Intern the tokens of these terminal symbols (separated by commas): of
+  equal tokens, the first is passed to the rule functions for all, so they
+  may share memory. The symbols' types must be comparable. See Intern in
+  github.com/pat42smith/glean/earley.Options.
+ -valid-prefix
+  Also generate _glean_ValidPrefix, which returns the length of the longest
+  prefix of its input that can begin a valid input.
+ -explain
+  Also generate _glean_Explain, which reports why its input is rejected:
+  where the parse stalled, the tokens consumed, the terminal symbols
+  expected and the rules partly matched. See Explain in
+  github.com/pat42smith/glean/earley.Options.
+ -longest-prefix
+  Also generate _glean_ParseLongest, which parses the longest prefix of its
+  input that is a complete target, and returns the number of tokens in it.
+  See LongestPrefix in github.com/pat42smith/glean/earley.Options.
+ -partial-input
+  Let _glean_Parse parse the longest prefix of its input that is a complete
+  target, returning the tokens after it as a further result. See
+  PartialInput in github.com/pat42smith/glean/earley.Options.
+ -sequence
+  Also generate _glean_ParseSequence, which parses its input as a sequence
+  of targets, each the longest following the one before, and returns them
+  as a slice. See Sequence in github.com/pat42smith/glean/earley.Options.
+ -complete
+  Also generate _glean_Complete, which suggests the shortest sequences of
+  terminal symbols completing a partial input. See Complete in
+  github.com/pat42smith/glean/earley.Options.
+ -unchecked
+  Generate a parser that does not check the tokens, ids, token options
+  and reducers supplied by its caller, for somewhat faster parsing of
+  trusted input. Not safe for untrusted input. See Unchecked in
+  github.com/pat42smith/glean/earley.Options for the checks dropped and
+  the contract the caller must uphold.
+ -prefix-type name
+ -rule-type name
+ -symbol-type name
+  Replace _Prefix, _Rule or _Symbol, after the prefix, in the names of the
+  parser's integer types, for example to avoid a clash with names declared
+  elsewhere in the package. See PrefixType in
+  github.com/pat42smith/glean/earley.Options.
+ -max-items n
+  Fail, naming the rule, if any rule has more than n items.
+  Default: 0 (no limit)
+ -long-rule-factor n
+  Warn of rules with more than n times the median number of items of the
+  grammar's rules. Default: 0 (a factor of 4); -1 disables the warning.
+ -weight rule=n
+  Give the rule the weight n. Ambiguous input is then parsed in the way
+  whose rules have the least total weight. This flag may be repeated.
+  See Weights in github.com/pat42smith/glean/earley.Options.
+ -assoc symbol=left
+ -assoc symbol=right
+  Make the rules containing the terminal symbol, such as an operator, left
+  or right associative, so that with -assoc Plus=left, 2 + 3 + 5 parses as
+  (2 + 3) + 5 rather than being ambiguous. This flag may be repeated. See
+  Associativity in github.com/pat42smith/glean/earley.Options.
+ -prec symbol=n
+  Give the terminal symbol, such as an operator, the precedence level n,
+  which must be positive. Of derivations applying different rules to the
+  same tokens, the one whose rule has the lower level is kept, so that with
+  -prec Plus=1 -prec Times=2, 1 + 2 * 3 parses as 1 + (2 * 3). This flag
+  may be repeated. See Precedence in github.com/pat42smith/glean/earley.Options.
+ -lookahead rule=symbol
+  Match the rule only where the next token is of the terminal symbol,
+  which is left to be matched by what follows. The rule never matches at
+  the end of the input. This flag may be repeated. See Lookahead in
+  github.com/pat42smith/glean/earley.Options.
+ -methods
+  Also take methods whose names begin with Rule or rule as rule functions,
+  named by the method; the receiver is not an item. Since such rules
+  cannot be called as functions, this suits -interface, with the
+  receiver's type implementing _glean_Reducer. See Methods in
+  github.com/pat42smith/glean.ScanOptions.
+ -tests
+  Also scan the *_test.go files of the directory, for a parser used only
+  in tests, and take an external test package such as foo_test to match
+  the package foo. See Tests in github.com/pat42smith/glean.ScanOptions.
+ -match-build
+  Scan only the files that the go command would build: those whose names
+  and //go:build constraints match the GOOS and GOARCH of the environment,
+  and the tags of -build-tags. Otherwise, every file is scanned. See
+  BuildContext in github.com/pat42smith/glean.ScanOptions.
+ -build-tags tags
+  Build tags (separated by commas) to treat as satisfied when matching
+  files to scan; implies -match-build.
+ -tags expression
+  Write a //go:build line with this build constraint expression, such as
+  !prod, after the generated-file marker, so the parser is compiled only
+  under matching build tags.
+ -stamp
+  Write a line after the generated-file marker giving the version of glean
+  and a SHA-256 hash of the grammar: of the target symbol and the sorted
+  rules, each given by its name, reducer, target and items. The hash does
+  not cover the other flags.
+ -check
+  Rather than generating a parser, check that the grammar hash written by
+  -stamp in the output file is that of the grammar scanned, failing if it
+  is not, so that a stale parser may be detected.
+ -warn-symbols
+  Also warn of nonterminals unreachable from the target, and of terminals
+  whose names are close to those of nonterminals, as Expr2 is to Expr, and
+  so probably misspelt. These are found by Check in
+  github.com/pat42smith/glean/earley. The warnings do not stop the parser
+  being written.
+ -w
+  After writing the parser, watch the scanned files, polling them twice a
+  second, and write the parser again whenever they change, reporting each
+  parser written, and any errors and warnings, on stderr. A burst of
+  changes is acted on once the files have been left alone for half a
+  second. Glean exits when interrupted. This cannot be used with source
+  read from stdin.
+ -v
+  List on stderr the rules found, each with its target and items, in the
+  order found, then the terminal and nonterminal symbols, sorted by name.
+  A function dropped with a warning, such as one with more than one
+  result, is missing from the list.
+ -lint
+  Rather than generating a parser, print a summary of the grammar's
+  problems, as found by Report in github.com/pat42smith/glean/earley: the
+  number of pairs of duplicate, and so ambiguous, rules, of symbols
+  unreachable from the target, of unproductive symbols, and of unit
+  cycles, each on a line of its own, followed by a line for each problem.
+  glean fails if a count is more than -lint-max allows.
+ -lint-max kind=n
+  With -lint, allow n problems of the kind, one of ambiguous, unreachable,
+  unproductive or cycles; by default, none are allowed. May be repeated.
+ -ebnf
+  Print the grammar in the EBNF of ISO/IEC 14977, rather than generating
+  a parser. Each nonterminal has one rule, listing its alternatives in the
+  order found, beginning with the target symbol. Terminals are quoted, an
+  empty alternative is written (* empty *), a list item []X is
+  written X, {X}, and an optional item *X is written [X]. In the names of
+  nonterminals, characters other than letters and digits, such as the dot
+  of a qualified name, are written as spaces. See WriteEBNF in
+  github.com/pat42smith/glean/earley.Grammar.
+ -bnf
+  Print the grammar in BNF, rather than generating a parser: a line for
+  each nonterminal, sorted by name, such as Sum ::= Sum Plus Term | Term,
+  whose alternatives are its rules in the order found. An empty rule is
+  written ε. See WriteBNF in github.com/pat42smith/glean/earley.
  -h
   Print some help information and exit.
 
@@ -30,7 +327,9 @@ Grammar rules are generated from functions meeting these conditions:
   The name of the function is at least 5 characters long.
   The name of the function begins "rule" or "Rule".
   The function returns exactly one result.
-  Every argument type and result type consists of a simple identifier.
+  Every argument type consists of a simple identifier, a slice of one,
+  or a pointer to one.
+  The result type consists of a simple identifier.
 
 The result type of such a function is the symbol produced by the grammar rule;
 the argument types are the symbols consumed. For example, the function
@@ -41,6 +340,60 @@ corresponds to the BNF rule
 
   <Expr> ::= <Expr> <Plus> <Expr>
 
+An argument whose type is a slice matches one or more consecutive
+occurrences of the element symbol, with no separator; the function
+
+  func RuleBlock(Open, []Statement, Close) Block
+
+corresponds to the EBNF rule
+
+  <Block> ::= <Open> <Statement>+ <Close>
+
+An argument whose type is a pointer matches an optional occurrence of the
+pointed-to symbol; the function receives nil if the symbol is absent. The
+function
+
+  func RuleIf(If, Expr, Block, *ElseClause) Statement
+
+corresponds to the EBNF rule
+
+  <Statement> ::= <If> <Expr> <Block> [<ElseClause>]
+
+A list that may be empty is written as a pointer to a slice, which is
+never nil but points to a nil slice when there are no elements:
+
+  func RuleBlock(Open, *[]Statement, Close) Block
+
+corresponds to the EBNF rule
+
+  <Block> ::= <Open> <Statement>* <Close>
+
+One function may serve several rules that differ in a single symbol, such
+as the operator of a binary expression, through an alias directive in its
+doc comment. The parameter of the named type stands for each of the listed
+symbols in turn; the functions
+
+  //glean:alias Operator Plus Minus
+  func RuleBinary(x Expr, op Operator, y Expr) Expr
+
+correspond to the BNF rules
+
+  <Expr> ::= <Expr> <Plus> <Expr>
+  <Expr> ::= <Expr> <Minus> <Expr>
+
+named RuleBinary_Plus and RuleBinary_Minus. Each is reduced by calling
+RuleBinary, so the tokens of each listed symbol must be assignable to the
+parameter type, typically an interface they implement. A function may have
+several alias directives, for different parameter types; a rule is then
+made for each combination of their symbols.
+
+A function that has the form of a rule function but is not meant as one,
+such as a helper, may be left out of the grammar with the directive
+
+  //glean:ignore
+
+in its doc comment. Glean then skips the function without a warning.
+
 By default, the parse function generated by glean has the signature
 
   func _glean_Parse(tokens []interface{}) (Target, error)
@@ -68,11 +421,16 @@ The target object returned by _glean_Parse is constructed by calling
 rule functions in the fashion corresponding to the way in which the
 tokens are parsed to find the target symbol.
 
+_glean_Parse keeps no state between calls, so it may be called from
+several goroutines at once, provided the rule functions allow that.
+
 The errors returned by _glean_Parse are defined in the package
-github.com/pat42smith/glean/gleanerrors. Compiling _glean_Parse
-requires access to this package and the Go standard library;
-no other packages are needed. Error reporting is rudimentary.
-In particular, only one error will be reported.
+github.com/pat42smith/glean/gleanerrors, or at the path given with
+-errors. Compiling _glean_Parse requires access to this package and the
+Go standard library; no other packages are needed. _glean_Parse stops
+at the first unexpected token, reporting the terminal symbols that could
+have appeared there, and reports all the ambiguities of an input together.
+To continue past unexpected tokens and report every one, use -recover.
 
 Glean generates an Earley parser. It can process any context-free grammar,
 even ambiguous ones. However, if _glean_Parse is given ambiguous input,
@@ -5,12 +5,26 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"errors"
 	"flag"
 	"fmt"
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
 	"io/fs"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pat42smith/glean"
 	"github.com/pat42smith/glean/earley"
@@ -43,10 +57,160 @@ const marker = "// Code generated by glean. DO NOT EDIT.\n\n"
 
 func main() {
 	pHelp := flag.Bool("h", false, "print this help information")
-	pOutFile := flag.String("o", "parse.go", "name of the Go file in which to write the parser")
+	pOutFile := flag.String("o", "parse.go", "name of the Go file in which to write the parser, or - for stdout")
 	pPrefix := flag.String("p", "_glean_", "prefix for file scope names in the parser code")
 	pPrint := flag.Bool("P", false, "print the grammar rules, do not generate a parser")
-	pTarget := flag.String("t", "Target", "target symbol, the result of the parse")
+	pMethods := flag.Bool("methods", false, "also take methods named as rule functions as rules, for use with -interface")
+	pTests := flag.Bool("tests", false, "also scan *_test.go files, for a parser used only in tests")
+	pMatchBuild := flag.Bool("match-build", false, "scan only the files whose build constraints match GOOS, GOARCH and -build-tags")
+	pBuildTags := flag.String("build-tags", "", "comma separated build tags satisfied when scanning; implies -match-build")
+	pTags := flag.String("tags", "", "build constraint expression, such as !prod, under which the parser is compiled")
+	pStamp := flag.Bool("stamp", false, "also write the glean version and a hash of the grammar in the parser file")
+	pCheck := flag.Bool("check", false, "check the grammar hash written by -stamp against the grammar, do not generate a parser")
+	pEBNF := flag.Bool("ebnf", false, "print the grammar in ISO/IEC 14977 EBNF, do not generate a parser")
+	pBNF := flag.Bool("bnf", false, "print the grammar in BNF, one line for each nonterminal, do not generate a parser")
+	pWatch := flag.Bool("w", false, "watch the scanned files, writing the parser again whenever they change, until interrupted")
+	pVerbose := flag.Bool("v", false, "list the rules found, and the terminal and nonterminal symbols, on stderr")
+	pWarnSymbols := flag.Bool("warn-symbols", false, "warn of symbols unreachable from the target, and of terminals named like nonterminals")
+	pLint := flag.Bool("lint", false, "print a summary of the grammar's problems, failing if there are more than -lint-max allows, do not generate a parser")
+	var targets []string
+	flag.Func("t", "target symbol, the result of the parse (default Target); repeat for a parser for each target, in one file", func(s string) error {
+		targets = append(targets, s)
+		return nil
+	})
+	pErrors := flag.String("errors", earley.DefaultErrorsImport, "import path of the gleanerrors package")
+	pScannerless := flag.Bool("scannerless", false, "parse positions with overlapping token options, not a token slice")
+	pRecover := flag.Bool("recover", false, "also write a parse function that recovers from errors")
+	pMaxErrors := flag.Int("max-errors", 0, "default error limit for the recovering parse function (0 for none)")
+	pErrorSink := flag.Bool("error-sink", false, "also write a recovering parse function passing each error to a sink as found; implies -recover")
+	pStepping := flag.Bool("stepping", false, "declare a parser type that finds matches one position at a time, for inspection")
+	pDebug := flag.Bool("debug", false, "declare a hook to observe the growth of the parse chart")
+	pGeneric := flag.Bool("generic", false, "keep symbol values in generic stacks (needs Go 1.18)")
+	pParseAs := flag.Bool("parse-as", false, "also write a generic parse function returning the target as a given type (needs Go 1.18)")
+	pLexer := flag.Bool("lexer", false, "also write a parse function taking its tokens from a Lexer interface")
+	pReusable := flag.Bool("reusable", false, "also write a Parser type reusing its memory from one parse to the next")
+	pGoVersion := flag.String("go", "", "Go release, such as 1.17, under which the parser must compile (default: from go.mod)")
+	pAmbiguity := flag.String("ambiguity", "error", "how to handle ambiguous input: error, leftmost, rightmost or longest")
+	pFirstRule := flag.Bool("first-rule", false, "where different rules match a symbol to the same tokens, choose the rule found first")
+	pResolutions := flag.Bool("resolutions", false, "also write a parse function listing the ambiguities resolved by -ambiguity")
+	pWarnings := flag.Bool("warnings", false, "also write a parse function returning the ambiguities resolved by -ambiguity as warnings")
+	pParseAll := flag.Bool("parse-all", false, "also write a parse function returning every parse of ambiguous input")
+	pParseTrace := flag.Bool("parse-trace", false, "also write a parse function returning the rules applied")
+	pTree := flag.Bool("tree", false, "also write functions returning the parse tree, as Go values and as JSON")
+	pIntern := flag.String("intern", "", "comma separated terminal symbols whose equal tokens are passed to rules as one")
+	pEvents := flag.Bool("events", false, "also write a parse function sending the rules applied on a channel, as events")
+	pConcurrent := flag.Bool("concurrent", false, "also write a parse function applying the rules on several goroutines; the rules must be safe to call concurrently")
+	pHidden := flag.String("hidden", "", "comma separated symbols to leave out of parse trees")
+	pValidPrefix := flag.Bool("valid-prefix", false, "also write a function finding the longest valid prefix of the input")
+	pExplain := flag.Bool("explain", false, "also write a function reporting why input is rejected")
+	pLongest := flag.Bool("longest-prefix", false, "also write a parse function for the longest prefix of the input that is a complete target")
+	pSequence := flag.Bool("sequence", false, "also write a parse function returning the input as a sequence of targets")
+	pPartial := flag.Bool("partial-input", false, "let the parse function parse the longest complete prefix of the input, returning the rest")
+	pComplete := flag.Bool("complete", false, "also write a function suggesting completions of partial input")
+	pEOF := flag.String("eof", "", "terminal symbol whose zero value is appended to the input as an end marker")
+	pClassifier := flag.Bool("classifier", false, "classify tokens with a function passed to the parser, not by type")
+	pAlternatives := flag.Bool("alternatives", false, "with -classifier, also pass a function giving further terminal symbols a token may match")
+	pKindFunc := flag.String("kind-func", "", "with -kind, function returning the kind of each token")
+	pTypeIds := flag.Bool("type-ids", false, "find the symbols of tokens from a TypeId() int method of their types, not by a type switch")
+	pTokenInterface := flag.String("token-interface", "", "interface type, with method TypeId() int, of the tokens passed to the parser")
+	pUnknownTokens := flag.Bool("unknown-tokens", false, "return tokens not of terminal types as gleanerrors.UnknownToken errors, rather than panicking")
+	pCatch := flag.Bool("catch-panics", false, "return panics during parsing as gleanerrors.Internal errors")
+	pPrefixType := flag.String("prefix-type", "", "name, after the prefix, of the parser's prefix id type (default _Prefix)")
+	pRuleType := flag.String("rule-type", "", "name, after the prefix, of the parser's rule id type (default _Rule)")
+	pSymbolType := flag.String("symbol-type", "", "name, after the prefix, of the parser's symbol id type (default _Symbol)")
+	pUnchecked := flag.Bool("unchecked", false, "drop checks of the tokens and functions supplied to the parser; for trusted input only")
+	pRegistry := flag.Bool("registry", false, "call reducers registered at run time, not the rule functions")
+	pInterface := flag.Bool("interface", false, "call the methods of a generated Reducer interface, not the rule functions")
+	pChart := flag.Bool("chart-store", false, "keep the chart of matches in a store passed to the parse functions")
+	pMaxItems := flag.Int("max-items", 0, "reject rules with more items than this (0 for no limit)")
+	pLongRule := flag.Int("long-rule-factor", 0, "warn of rules this many times longer than the median (0 for the default, -1 for none)")
+	displayNames := make(map[glean.Symbol]string)
+	flag.Func("display", "symbol=name: show tokens of the terminal symbol as name in errors (repeatable)", func(s string) error {
+		symbol, name, found := strings.Cut(s, "=")
+		if !found {
+			return errors.New("expected symbol=name")
+		}
+		displayNames[glean.Symbol(symbol)] = name
+		return nil
+	})
+	kinds := make(map[glean.Symbol]string)
+	flag.Func("kind", "symbol=expr: find tokens of the terminal symbol by the kind expr, with -kind-func, not by type (repeatable)", func(s string) error {
+		symbol, kind, found := strings.Cut(s, "=")
+		if !found {
+			return errors.New("expected symbol=expr")
+		}
+		kinds[glean.Symbol(symbol)] = kind
+		return nil
+	})
+	lookahead := make(map[string]glean.Symbol)
+	flag.Func("lookahead", "rule=symbol: match the rule only where the next token is of the terminal symbol, without consuming it (repeatable)", func(s string) error {
+		rule, symbol, found := strings.Cut(s, "=")
+		if !found {
+			return errors.New("expected rule=symbol")
+		}
+		lookahead[rule] = glean.Symbol(symbol)
+		return nil
+	})
+	associativity := make(map[glean.Symbol]earley.Ambiguity)
+	flag.Func("assoc", "symbol=left|right: make rules containing the terminal symbol left or right associative (repeatable)", func(s string) error {
+		symbol, dir, found := strings.Cut(s, "=")
+		if !found {
+			return errors.New("expected symbol=left or symbol=right")
+		}
+		switch dir {
+		case "left":
+			associativity[glean.Symbol(symbol)] = earley.AmbiguityLeftmost
+		case "right":
+			associativity[glean.Symbol(symbol)] = earley.AmbiguityRightmost
+		default:
+			return errors.New("associativity must be left or right")
+		}
+		return nil
+	})
+	precedence := make(map[glean.Symbol]int)
+	flag.Func("prec", "symbol=n: give the terminal symbol precedence level n, higher levels binding more tightly (repeatable)", func(s string) error {
+		symbol, n, found := strings.Cut(s, "=")
+		if !found {
+			return errors.New("expected symbol=n")
+		}
+		level, e := strconv.Atoi(n)
+		if e != nil {
+			return e
+		}
+		precedence[glean.Symbol(symbol)] = level
+		return nil
+	})
+	lintMax := make(map[string]int)
+	flag.Func("lint-max", "kind=n: with -lint, allow n problems of the kind: ambiguous, unreachable, unproductive or cycles (repeatable)", func(s string) error {
+		kind, n, found := strings.Cut(s, "=")
+		if !found {
+			return errors.New("expected kind=n")
+		}
+		switch kind {
+		case "ambiguous", "unreachable", "unproductive", "cycles":
+		default:
+			return errors.New("kind must be ambiguous, unreachable, unproductive or cycles")
+		}
+		max, e := strconv.Atoi(n)
+		if e != nil {
+			return e
+		}
+		lintMax[kind] = max
+		return nil
+	})
+	weights := make(map[string]int)
+	flag.Func("weight", "rule=n: give the rule weight n, choosing the least weight parse of ambiguous input (repeatable)", func(s string) error {
+		rule, n, found := strings.Cut(s, "=")
+		if !found {
+			return errors.New("expected rule=n")
+		}
+		w, e := strconv.Atoi(n)
+		if e != nil {
+			return e
+		}
+		weights[rule] = w
+		return nil
+	})
 
 	flag.CommandLine.Usage = usage
 	flag.Parse()
@@ -56,22 +220,57 @@ func main() {
 		return
 	}
 
+	if len(targets) == 0 {
+		targets = []string{"Target"}
+	}
+	for n, t := range targets {
+		for _, other := range targets[:n] {
+			if t == other {
+				die("error: target", t, "given twice")
+			}
+		}
+	}
+	// The flags concerned with a single target use the first; the grammar
+	// hash covers them all.
+	target := glean.Symbol(targets[0])
+	hashTarget := glean.Symbol(strings.Join(targets, " "))
+
+	var scanOptions glean.ScanOptions
+	scanOptions.Methods = *pMethods
+	scanOptions.Tests = *pTests
+	if *pMatchBuild || *pBuildTags != "" {
+		ctxt := build.Default
+		if *pBuildTags != "" {
+			ctxt.BuildTags = append(ctxt.BuildTags, strings.Split(*pBuildTags, ",")...)
+		}
+		scanOptions.BuildContext = &ctxt
+	}
+
 	var pkg string
-	getRules := func(g glean.RuleAdder) {
+	fromStdin := len(flag.Args()) == 1 && flag.Arg(0) == "-"
+	scanRules := func(g glean.RuleAdder) error {
 		args := flag.Args()
 		var warnings []error
 		var err error
 		if len(args) == 0 {
-			pkg, warnings, err = glean.ScanDir(g, ".")
+			pkg, warnings, err = glean.ScanDirWithOptions(g, scanOptions, ".")
+		} else if fromStdin {
+			pkg, warnings, err = glean.ScanReader(g, "<stdin>", os.Stdin)
 		} else {
-			pkg, warnings, err = glean.ScanFiles(g, args...)
+			pkg, warnings, err = glean.ScanFilesWithOptions(g, scanOptions, args...)
 		}
 		if err != nil {
-			die(err)
+			return err
 		}
 		for _, w := range warnings {
 			fmt.Fprintln(os.Stderr, w)
 		}
+		return nil
+	}
+	getRules := func(g glean.RuleAdder) {
+		if e := scanRules(g); e != nil {
+			die(e)
+		}
 	}
 
 	if *pPrint {
@@ -80,8 +279,334 @@ func main() {
 		gp.Print()
 		return
 	}
+	if *pEBNF {
+		g := new(earley.Grammar)
+		getRules(g)
+		if e := g.WriteEBNF(os.Stdout, target); e != nil {
+			die(e)
+		}
+		return
+	}
+
+	if *pBNF {
+		g := new(earley.Grammar)
+		getRules(g)
+		if e := g.WriteBNF(os.Stdout); e != nil {
+			die(e)
+		}
+		return
+	}
+
+	if *pLint {
+		g := new(earley.Grammar)
+		g.Options.MaxItems = *pMaxItems
+		getRules(g)
+		report := g.Report(target)
+		fmt.Print(report)
+		if e := checkLint(report, lintMax); e != nil {
+			die(e)
+		}
+		return
+	}
+
+	if *pTags != "" {
+		if _, e := constraint.Parse("//go:build " + *pTags); e != nil {
+			die("error: invalid -tags expression:", e)
+		}
+	}
+
+	if *pCheck {
+		rr := new(ruleRecorder)
+		getRules(rr)
+		if e := checkStamp(*pOutFile, rr.hash(hashTarget)); e != nil {
+			die(e)
+		}
+		return
+	}
 
 	outFile := *pOutFile
+	toStdout := outFile == "-"
+	if !toStdout {
+		checkOutFile(outFile)
+	}
+	if *pWatch && fromStdin {
+		die("error: -w cannot watch source read from stdin")
+	}
+
+	var ambiguity earley.Ambiguity
+	switch *pAmbiguity {
+	case "error":
+		ambiguity = earley.AmbiguityError
+	case "leftmost":
+		ambiguity = earley.AmbiguityLeftmost
+	case "rightmost":
+		ambiguity = earley.AmbiguityRightmost
+	case "longest":
+		ambiguity = earley.AmbiguityLongest
+	default:
+		die("error: unknown ambiguity policy", *pAmbiguity)
+	}
+
+	generate := func() error {
+		g := new(earley.Grammar)
+		g.Options.MaxItems = *pMaxItems
+		g.Options.LongRuleFactor = *pLongRule
+		g.Options.Registry = *pRegistry
+		g.Options.Interface = *pInterface
+		g.Options.ChartStore = *pChart
+		g.Options.ErrorsImport = *pErrors
+		g.Options.Scannerless = *pScannerless
+		g.Options.Recover = *pRecover || *pMaxErrors != 0 || *pErrorSink
+		g.Options.ErrorSink = *pErrorSink
+		g.Options.MaxErrors = *pMaxErrors
+		g.Options.GenericStacks = *pGeneric
+		g.Options.ParseAs = *pParseAs
+		g.Options.Lexer = *pLexer
+		g.Options.Reusable = *pReusable
+		g.Options.GoVersion = *pGoVersion
+		if g.Options.GoVersion == "" {
+			g.Options.GoVersion = goModVersion(filepath.Dir(outFile))
+		}
+		g.Options.Debug = *pDebug
+		g.Options.Stepping = *pStepping
+		g.Options.Tree = *pTree
+		g.Options.Events = *pEvents
+		g.Options.Concurrent = *pConcurrent
+		g.Options.Resolutions = *pResolutions
+		g.Options.Warnings = *pWarnings
+		g.Options.ParseAll = *pParseAll
+		g.Options.ParseTrace = *pParseTrace
+		if *pHidden != "" {
+			for _, h := range strings.Split(*pHidden, ",") {
+				g.Options.Hidden = append(g.Options.Hidden, glean.Symbol(h))
+			}
+		}
+		if *pIntern != "" {
+			for _, s := range strings.Split(*pIntern, ",") {
+				g.Options.Intern = append(g.Options.Intern, glean.Symbol(s))
+			}
+		}
+		g.Options.CatchPanics = *pCatch
+		g.Options.ValidPrefix = *pValidPrefix
+		g.Options.Explain = *pExplain
+		g.Options.LongestPrefix = *pLongest
+		g.Options.Sequence = *pSequence
+		g.Options.PartialInput = *pPartial
+		g.Options.Complete = *pComplete
+		g.Options.EndSymbol = glean.Symbol(*pEOF)
+		g.Options.Classifier = *pClassifier
+		g.Options.Alternatives = *pAlternatives
+		g.Options.Kinds = kinds
+		g.Options.KindFunc = *pKindFunc
+		g.Options.TypeIds = *pTypeIds
+		g.Options.TokenInterface = *pTokenInterface
+		g.Options.UnknownTokens = *pUnknownTokens
+		g.Options.Unchecked = *pUnchecked
+		g.Options.PrefixType = *pPrefixType
+		g.Options.RuleType = *pRuleType
+		g.Options.SymbolType = *pSymbolType
+		g.Options.DisplayNames = displayNames
+		g.Options.Weights = weights
+		g.Options.Associativity = associativity
+		g.Options.Precedence = precedence
+		g.Options.Lookahead = lookahead
+		g.Options.Ambiguity = ambiguity
+		g.Options.FirstRule = *pFirstRule
+		rr := &ruleRecorder{next: g}
+		if e := scanRules(rr); e != nil {
+			return e
+		}
+		if *pVerbose {
+			listGrammar(os.Stderr, rr, g)
+		}
+		warnings := g.Validate()
+		if *pWarnSymbols {
+			warnings = append(warnings, g.Check(target)...)
+		}
+		for _, w := range warnings {
+			fmt.Fprintln(os.Stderr, w)
+		}
+
+		var texts []string
+		for _, t := range targets {
+			prefix := *pPrefix
+			if len(targets) > 1 {
+				prefix += strings.ReplaceAll(t, ".", "_") + "_"
+			}
+			text, err := g.WriteParser(glean.Symbol(t), pkg, prefix)
+			if err != nil {
+				return err
+			}
+			texts = append(texts, text)
+		}
+		parserText, err := mergeParsers(texts)
+		if err != nil {
+			return err
+		}
+		if !toStdout {
+			if e := checkConflicts(outFile, pkg, parserText); e != nil {
+				return e
+			}
+		}
+		if *pStamp {
+			parserText = stamp(rr.hash(hashTarget)) + parserText
+		}
+		if *pTags != "" {
+			parserText = "//go:build " + *pTags + "\n\n" + parserText
+		}
+		parserText = marker + parserText
+
+		if toStdout {
+			_, e := os.Stdout.WriteString(parserText)
+			return e
+		}
+		return writeAtomic(outFile, parserText)
+	}
+
+	if !*pWatch {
+		if e := generate(); e != nil {
+			die(e)
+		}
+		return
+	}
+	watch(flag.Args(), outFile, generate)
+}
+
+// watchInterval is how often -w checks the scanned files for changes.
+const watchInterval = 500 * time.Millisecond
+
+// watch writes the parser with generate, then again whenever the Go files
+// named by args, or in the current directory if there are none, change,
+// until glean is interrupted. A change is acted on once the files have
+// been left alone for a whole interval, so a burst of writes, as by an
+// editor saving several files, writes the parser only once. Errors and
+// warnings are reported on stderr, and do not stop the watch.
+func watch(args []string, outFile string, generate func() error) {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	regenerate := func() {
+		if e := generate(); e != nil {
+			fmt.Fprintln(os.Stderr, e)
+		} else {
+			fmt.Fprintln(os.Stderr, "glean: wrote", outFile)
+		}
+	}
+	regenerate()
+	last := sourceState(args, outFile)
+	pending := false
+	for {
+		select {
+		case <-interrupt:
+			return
+		case <-ticker.C:
+		}
+		if state := sourceState(args, outFile); state != last {
+			last = state
+			pending = true
+		} else if pending {
+			pending = false
+			regenerate()
+		}
+	}
+}
+
+// sourceState describes the Go files named by args, or in the current
+// directory if there are none, other than outFile: their names, sizes
+// and modification times, which change when the files are edited.
+func sourceState(args []string, outFile string) string {
+	names := args
+	if len(names) == 0 {
+		entries, _ := os.ReadDir(".")
+		for _, entry := range entries {
+			name := entry.Name()
+			// Skip the parser's temporary files, too
+			if strings.HasSuffix(name, ".go") && !strings.HasPrefix(name, ".") && name != filepath.Clean(outFile) {
+				names = append(names, name)
+			}
+		}
+	}
+	var b strings.Builder
+	for _, name := range names {
+		if info, e := os.Stat(name); e == nil {
+			fmt.Fprintln(&b, name, info.Size(), info.ModTime().UnixNano())
+		} else {
+			fmt.Fprintln(&b, name, "missing")
+		}
+	}
+	return b.String()
+}
+
+// mergeParsers joins the texts of parsers for the same package, written for
+// different targets, into the text of a single file, which has the package
+// clause of the first and imports the packages imported by any of them.
+func mergeParsers(texts []string) (string, error) {
+	if len(texts) == 1 {
+		return texts[0], nil
+	}
+	var std, other []string
+	specs := make(map[string]bool)
+	var clause string
+	var bodies []string
+	for _, text := range texts {
+		fset := token.NewFileSet()
+		f, e := parser.ParseFile(fset, "", text, parser.ImportsOnly)
+		if e != nil {
+			return "", fmt.Errorf("bug: generated parser does not parse: %v", e)
+		}
+		offset := func(pos token.Pos) int {
+			return fset.Position(pos).Offset
+		}
+		end := offset(f.Name.End())
+		if clause == "" {
+			clause = text[:end]
+		}
+		for _, d := range f.Decls {
+			end = offset(d.End())
+		}
+		bodies = append(bodies, text[end:])
+
+		for _, i := range f.Imports {
+			spec := text[offset(i.Pos()):offset(i.End())]
+			if specs[spec] {
+				continue
+			}
+			specs[spec] = true
+			// Standard packages have no dot in their first element, and are
+			// imported first, as in the parsers themselves
+			path, _ := strconv.Unquote(i.Path.Value)
+			if first, _, _ := strings.Cut(path, "/"); strings.Contains(first, ".") {
+				other = append(other, spec)
+			} else {
+				std = append(std, spec)
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(clause + "\n\nimport (\n")
+	for _, group := range [][]string{std, other} {
+		for _, spec := range group {
+			b.WriteString("\t" + spec + "\n")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(")\n")
+	for _, body := range bodies {
+		b.WriteString(body)
+	}
+	merged, e := format.Source([]byte(b.String()))
+	if e != nil {
+		return "", fmt.Errorf("bug: merged parsers do not parse: %v", e)
+	}
+	return string(merged), nil
+}
+
+// checkOutFile exits with an error unless outFile is absent or is a file
+// written by glean, which may be replaced.
+func checkOutFile(outFile string) {
 	if info, e := os.Lstat(outFile); e == nil {
 		if !info.Mode().IsRegular() {
 			die("error:", outFile, "exists but is not a file.")
@@ -102,18 +627,195 @@ func main() {
 	} else if !errors.Is(e, fs.ErrNotExist) {
 		die(e)
 	}
+}
 
-	var g glean.Grammar = new(earley.Grammar)
-	getRules(g)
+// checkLint checks that each count in the report is no more than that
+// allowed by max, in which a missing kind allows none.
+func checkLint(report earley.Report, max map[string]int) error {
+	for _, c := range []struct {
+		kind  string
+		count int
+	}{
+		{"ambiguous", len(report.AmbiguousRules)},
+		{"unreachable", len(report.Unreachable)},
+		{"unproductive", len(report.Unproductive)},
+		{"cycles", len(report.UnitCycles)},
+	} {
+		if c.count > max[c.kind] {
+			return fmt.Errorf("error: %s count %d is more than the %d allowed by -lint-max", c.kind, c.count, max[c.kind])
+		}
+	}
+	return nil
+}
 
-	parserText, err := g.WriteParser(glean.Symbol(*pTarget), pkg, *pPrefix)
-	if err != nil {
-		die(err)
+// writeAtomic writes text to the file named path, by way of a temporary file
+// in the same directory which is renamed into place only once complete.
+// So if glean fails or is killed, path holds either its old contents or
+// the whole of text, never part of it. The temporary file's name begins
+// with a dot, so the go command ignores it if it is left behind.
+func writeAtomic(path, text string) error {
+	f, e := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if e != nil {
+		return e
+	}
+	tmp := f.Name()
+	_, e = f.WriteString(text)
+	if e == nil {
+		e = f.Chmod(0644)
+	}
+	if e == nil {
+		e = f.Sync()
+	}
+	if e2 := f.Close(); e == nil {
+		e = e2
+	}
+	if e == nil {
+		e = os.Rename(tmp, path)
 	}
-	parserText = marker + parserText
+	if e != nil {
+		os.Remove(tmp)
+	}
+	return e
+}
 
-	if e := os.WriteFile(outFile, []byte(parserText), 0644); e != nil {
-		die(e)
+// A ruleRecorder records the rules found, to compute the grammar hash
+// written by -stamp, and passes them on to another RuleAdder, if any.
+type ruleRecorder struct {
+	next  glean.SharedRuleAdder
+	rules []string
+}
+
+func (rr *ruleRecorder) AddRule(name string, target glean.Symbol, items []glean.Symbol) error {
+	return rr.AddSharedRule(name, name, target, items)
+}
+
+// AddImport passes the import path on, if the next RuleAdder wants it.
+func (rr *ruleRecorder) AddImport(name, path string) error {
+	if adder, ok := rr.next.(glean.ImportAdder); ok {
+		return adder.AddImport(name, path)
+	}
+	return nil
+}
+
+func (rr *ruleRecorder) AddSharedRule(name, reducer string, target glean.Symbol, items []glean.Symbol) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s =", name, reducer, target)
+	for _, i := range items {
+		b.WriteString(" ")
+		b.WriteString(string(i))
+	}
+	rr.rules = append(rr.rules, b.String())
+	if rr.next == nil {
+		return nil
+	}
+	return rr.next.AddSharedRule(name, reducer, target, items)
+}
+
+// listGrammar writes the rules recorded by rr, in the order found, and the
+// terminal and nonterminal symbols of g, for -v.
+func listGrammar(w io.Writer, rr *ruleRecorder, g *earley.Grammar) {
+	fmt.Fprintln(w, "rules:")
+	for _, r := range rr.rules {
+		// name reducer target = items
+		fields := strings.Fields(r)
+		fmt.Fprintf(w, "\t%s: %s", fields[0], strings.Join(fields[2:], " "))
+		if fields[1] != fields[0] {
+			fmt.Fprintf(w, " (reducer %s)", fields[1])
+		}
+		fmt.Fprintln(w)
+	}
+	for _, c := range []struct {
+		kind    string
+		symbols []glean.Symbol
+	}{
+		{"terminals", g.Terminals()},
+		{"nonterminals", g.Nonterminals()},
+	} {
+		fmt.Fprintf(w, "%s:", c.kind)
+		for _, s := range c.symbols {
+			fmt.Fprintf(w, " %s", s)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// hash returns the SHA-256 hash of the grammar: of the target symbol and
+// the rules, sorted, each on its own line. A rule's line holds its name,
+// its reducer, its target, and "=" followed by its items, separated by
+// spaces. The hash thus changes when the rules do, but not when the
+// functions defining them move.
+func (rr *ruleRecorder) hash(target glean.Symbol) [sha256.Size]byte {
+	rules := append([]string(nil), rr.rules...)
+	sort.Strings(rules)
+	return sha256.Sum256([]byte("target " + string(target) + "\n" + strings.Join(rules, "\n") + "\n"))
+}
+
+// stampPrefix begins the line written by -stamp, after marker.
+const stampPrefix = "// glean "
+
+// stamp returns the lines written by -stamp, giving the version of glean
+// and the grammar hash.
+func stamp(hash [sha256.Size]byte) string {
+	return fmt.Sprintf("%s%s, grammar sha256:%x\n\n", stampPrefix, version(), hash)
+}
+
+// version returns the version of glean, as recorded in its build information.
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(unknown)"
+}
+
+// checkStamp checks that the grammar hash written by -stamp in the file
+// named path is hash.
+func checkStamp(path string, hash [sha256.Size]byte) error {
+	text, e := os.ReadFile(path)
+	if e != nil {
+		return e
+	}
+	rest, found := strings.CutPrefix(string(text), marker)
+	if !found {
+		return fmt.Errorf("error: %s does not appear to have been produced by glean.", path)
+	}
+	line, rest, _ := strings.Cut(rest, "\n")
+	if strings.HasPrefix(line, "//go:build ") {
+		// Written by -tags
+		_, rest, _ = strings.Cut(rest, "\n")
+		line, _, _ = strings.Cut(rest, "\n")
+	}
+	_, written, found := strings.Cut(line, ", grammar sha256:")
+	if !strings.HasPrefix(line, stampPrefix) || !found {
+		return fmt.Errorf("error: %s has no grammar hash; generate it with -stamp.", path)
+	}
+	if written != fmt.Sprintf("%x", hash) {
+		return fmt.Errorf("error: %s is out of date; the grammar has changed.", path)
+	}
+	return nil
+}
+
+// goModVersion returns the Go version given by the go directive of the
+// go.mod file in dir or the nearest directory above it, or "" if there is
+// no such file or directive.
+func goModVersion(dir string) string {
+	dir, e := filepath.Abs(dir)
+	if e != nil {
+		return ""
+	}
+	for {
+		if text, e := os.ReadFile(filepath.Join(dir, "go.mod")); e == nil {
+			for _, line := range strings.Split(string(text), "\n") {
+				if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "go" {
+					return fields[1]
+				}
+			}
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
 	}
 }
 
@@ -135,8 +837,78 @@ func (gp grammarPrinter) AddRule(name string, target glean.Symbol, items []glean
 	return nil
 }
 
+func (gp grammarPrinter) AddSharedRule(name, reducer string, target glean.Symbol, items []glean.Symbol) error {
+	return gp.AddRule(name, target, items)
+}
+
 func (gp grammarPrinter) Print() {
 	for _, s := range gp {
 		fmt.Print(s)
 	}
 }
+
+// checkConflicts returns an error if a file scope name declared in the parser text
+// is also declared in another file of package pkg in the directory of outFile.
+//
+// Such a conflict would otherwise be reported only when the package is compiled.
+// Test files are checked too, since they are compiled with the package.
+func checkConflicts(outFile, pkg, parserText string) error {
+	fset := token.NewFileSet()
+	generated, e := parser.ParseFile(fset, outFile, parserText, 0)
+	if e != nil {
+		return fmt.Errorf("bug: generated parser does not parse: %v", e)
+	}
+	names := make(map[string]bool)
+	for _, id := range fileScopeNames(generated) {
+		names[id.Name] = true
+	}
+
+	notOutFile := func(info fs.FileInfo) bool {
+		return info.Name() != filepath.Base(outFile)
+	}
+	packages, e := parser.ParseDir(fset, filepath.Dir(outFile), notOutFile, 0)
+	if e != nil {
+		return e
+	}
+	if p := packages[pkg]; p != nil {
+		for _, f := range p.Files {
+			for _, id := range fileScopeNames(f) {
+				if names[id.Name] {
+					return fmt.Errorf("%s: %s is also declared in the generated parser; choose another prefix with -p",
+						fset.Position(id.Pos()), id.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fileScopeNames returns the identifiers declared at file scope in a Go file.
+func fileScopeNames(f *ast.File) []*ast.Ident {
+	var ids []*ast.Ident
+	add := func(id *ast.Ident) {
+		if id.Name != "_" {
+			ids = append(ids, id)
+		}
+	}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil && d.Name.Name != "init" {
+				add(d.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					add(sp.Name)
+				case *ast.ValueSpec:
+					for _, id := range sp.Names {
+						add(id)
+					}
+				}
+			}
+		}
+	}
+	return ids
+}
@@ -8,7 +8,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func runCommandIn(t *testing.T, dir string, cmd string, args ...string) []byte {
@@ -64,9 +66,15 @@ func TestUsage(t *testing.T) {
 	t.Run("Output", func(t2 *testing.T) {
 		tryOutput(t2, tmp, mainText)
 	})
+	t.Run("Stdin", func(t2 *testing.T) {
+		tryStdin(t2, tmp, mainText)
+	})
 	t.Run("Target", func(t2 *testing.T) {
 		tryTarget(t2, tmp, mainText)
 	})
+	t.Run("Targets", func(t2 *testing.T) {
+		tryTargets(t2, tmp, mainText)
+	})
 	t.Run("Prefix", func(t2 *testing.T) {
 		tryPrefix(t2, tmp, mainText)
 	})
@@ -76,12 +84,57 @@ func TestUsage(t *testing.T) {
 	t.Run("IgnoreTestFiles", func(t2 *testing.T) {
 		tryIgnoreTestFiles(t2, tmp, mainText)
 	})
+	t.Run("ErrorsImport", func(t2 *testing.T) {
+		tryErrorsImport(t2, tmp, mainText, geText)
+	})
+	t.Run("Conflict", func(t2 *testing.T) {
+		tryConflict(t2, tmp, mainText)
+	})
 	t.Run("Help", func(t2 *testing.T) {
 		tryHelp(t2, tmp)
 	})
 	t.Run("Print", func(t2 *testing.T) {
 		tryPrint(t2, tmp, mainText)
 	})
+	t.Run("EBNF", func(t2 *testing.T) {
+		tryEBNF(t2, tmp)
+	})
+	t.Run("BNF", func(t2 *testing.T) {
+		tryBNF(t2, tmp)
+	})
+	t.Run("Stamp", func(t2 *testing.T) {
+		tryStamp(t2, tmp, mainText)
+	})
+	t.Run("Tags", func(t2 *testing.T) {
+		tryTags(t2, tmp, mainText)
+	})
+	t.Run("GoVersion", func(t2 *testing.T) {
+		tryGoVersion(t2, tmp, mainText)
+	})
+	t.Run("Lint", func(t2 *testing.T) {
+		tryLint(t2, tmp, mainText)
+	})
+	t.Run("MatchBuild", func(t2 *testing.T) {
+		tryMatchBuild(t2, tmp, mainText)
+	})
+	t.Run("Methods", func(t2 *testing.T) {
+		tryMethods(t2, tmp)
+	})
+	t.Run("WarnSymbols", func(t2 *testing.T) {
+		tryWarnSymbols(t2, tmp, mainText)
+	})
+	t.Run("Watch", func(t2 *testing.T) {
+		tryWatch(t2, tmp, mainText)
+	})
+	t.Run("Verbose", func(t2 *testing.T) {
+		tryVerbose(t2, tmp, mainText)
+	})
+	t.Run("MaxErrors", func(t2 *testing.T) {
+		tryMaxErrors(t2, tmp)
+	})
+	t.Run("MaxItems", func(t2 *testing.T) {
+		tryMaxItems(t2, tmp)
+	})
 }
 
 func tryDefaults(t *testing.T, tmp string, mainText []byte) {
@@ -140,6 +193,41 @@ func tryOutput(t *testing.T, tmp string, mainText []byte) {
 	}
 }
 
+func tryStdin(t *testing.T, tmp string, mainText []byte) {
+	dir := filepath.Join(tmp, "stdin")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	// The source is read from stdin, so main.go is written only for the build.
+	glean := exec.Command("../glean", "-o", "-", "-")
+	glean.Dir = dir
+	glean.Stdin = bytes.NewReader(mainText)
+	var stderr bytes.Buffer
+	glean.Stderr = &stderr
+	parserText, e := glean.Output()
+	if e != nil || stderr.Len() > 0 {
+		t.Fatal(e, "with output:", stderr.String())
+	}
+	if _, e := os.Stat(filepath.Join(dir, "parse.go")); e == nil {
+		t.Error("parse.go written with -o -")
+	}
+
+	if e := os.WriteFile(filepath.Join(dir, "main.go"), mainText, 0444); e != nil {
+		t.Fatal(e)
+	}
+	if e := os.WriteFile(filepath.Join(dir, "parse.go"), parserText, 0444); e != nil {
+		t.Fatal(e)
+	}
+	if out := runCommandIn(t, dir, "go", "build"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	out := runCommandIn(t, dir, "./stdin", "3", "1", "2")
+	if string(out) != "[1 2 3]\n" {
+		t.Fatal(string(out))
+	}
+}
+
 func tryTarget(t *testing.T, tmp string, mainText []byte) {
 	dir := filepath.Join(tmp, "target")
 	if e := os.Mkdir(dir, 0700); e != nil {
@@ -163,6 +251,44 @@ func tryTarget(t *testing.T, tmp string, mainText []byte) {
 	}
 }
 
+func tryTargets(t *testing.T, tmp string, mainText []byte) {
+	dir := filepath.Join(tmp, "targets")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainText = bytes.Replace(mainText, []byte(`	if result, e := _glean_Parse(tokens); e != nil {
+		panic(e)
+	} else {
+		fmt.Println(result)
+	}`), []byte(`	fmt.Println(_glean_Target_Parse(tokens))
+	fmt.Println(_glean_Adder_Parse(tokens))`), 1)
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, mainText, 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	if out := runCommandIn(t, dir, "../glean", "-t", "Target", "-t", "Adder"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	if out := runCommandIn(t, dir, "gofmt", "-l", "parse.go"); len(out) > 0 {
+		t.Error("parse.go is not formatted")
+	}
+	if out := runCommandIn(t, dir, "go", "build"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	out := runCommandIn(t, dir, "./targets", "3", "1", "2")
+	if string(out) != "[1 2 3] <nil>\n6 <nil>\n" {
+		t.Fatal(string(out))
+	}
+
+	glean := exec.Command("../glean", "-t", "Adder", "-t", "Adder")
+	glean.Dir = dir
+	if out, e := glean.CombinedOutput(); e == nil || string(out) != "error: target Adder given twice\n" {
+		t.Error("wrong result for a repeated target:", e, string(out))
+	}
+}
+
 func tryPrefix(t *testing.T, tmp string, mainText []byte) {
 	dir := filepath.Join(tmp, "prefix")
 	if e := os.Mkdir(dir, 0700); e != nil {
@@ -236,6 +362,22 @@ func tryReplace(t *testing.T, tmp string, mainText []byte) {
 		t.Fatal(string(out))
 	}
 
+	// The file is written by way of a temporary file, which is not left behind.
+	if entries, e := os.ReadDir(dir); e != nil {
+		t.Fatal(e)
+	} else {
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), ".") {
+				t.Error("temporary file left behind:", entry.Name())
+			}
+		}
+	}
+	if info, e := os.Lstat(parseGo); e != nil {
+		t.Fatal(e)
+	} else if info.Mode().Perm() != 0644 {
+		t.Error("parse.go has mode", info.Mode())
+	}
+
 	// But a file not containing the "created by glean" marker is not replaced.
 	if e := os.WriteFile(parseGo, []byte("some randome text"), 0444); e != nil {
 		t.Fatal(e)
@@ -307,6 +449,85 @@ func tryIgnoreTestFiles(t *testing.T, tmp string, mainText []byte) {
 	}
 }
 
+// The gleanerrors package may be imported from elsewhere.
+func tryErrorsImport(t *testing.T, tmp string, mainText, geText []byte) {
+	dir := filepath.Join(tmp, "errorsimport")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, mainText, 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	// A copy of gleanerrors in a directory whose name differs from the package name.
+	geDir := filepath.Join(tmp, "forked", "errs")
+	if e := os.MkdirAll(geDir, 0700); e != nil {
+		t.Fatal(e)
+	}
+	if e := os.WriteFile(filepath.Join(geDir, "gleanerrors.go"), geText, 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	geImport := "github.com/pat42smith/glean/forked/errs"
+	if out := runCommandIn(t, dir, "../glean", "-errors", geImport); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	if parserText, e := os.ReadFile(filepath.Join(dir, "parse.go")); e != nil {
+		t.Fatal(e)
+	} else if !bytes.Contains(parserText, []byte(`gleanerrors "`+geImport+`"`)) {
+		t.Fatal("parse.go does not import", geImport)
+	}
+	if out := runCommandIn(t, dir, "go", "build"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	out := runCommandIn(t, dir, "./errorsimport", "3", "1", "2")
+	if string(out) != "[1 2 3]\n" {
+		t.Fatal(string(out))
+	}
+}
+
+// A name declared both in the package and in the parser is reported.
+func tryConflict(t *testing.T, tmp string, mainText []byte) {
+	dir := filepath.Join(tmp, "conflict")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, mainText, 0444); e != nil {
+		t.Fatal(e)
+	}
+	otherGo := filepath.Join(dir, "other.go")
+	if e := os.WriteFile(otherGo, []byte("package main\n\nfunc _glean_Parse() {}\n"), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	command := exec.Command("../glean")
+	command.Dir = dir
+	out, e := command.CombinedOutput()
+	if e == nil {
+		t.Fatal("no error for a name declared in both package and parser")
+	}
+	if !bytes.Contains(out, []byte("other.go:3:6: _glean_Parse is also declared in the generated parser")) {
+		t.Fatal("wrong error:", string(out))
+	}
+	if _, e := os.Lstat(filepath.Join(dir, "parse.go")); e == nil {
+		t.Fatal("parse.go was written despite the conflict")
+	}
+
+	// A parser written earlier does not conflict with its replacement.
+	if e := os.Remove(otherGo); e != nil {
+		t.Fatal(e)
+	}
+	for n := 0; n < 2; n++ {
+		if out := runCommandIn(t, dir, "../glean"); len(out) > 0 {
+			t.Fatal(string(out))
+		}
+	}
+}
+
 func tryHelp(t *testing.T, tmp string) {
 	out := runCommandIn(t, tmp, "./glean", "-h")
 	if !bytes.HasPrefix(out, []byte("\nUsage: ")) {
@@ -335,3 +556,501 @@ Adder = Adder int
 		t.Fatal("Wrong print output: \n", string(out))
 	}
 }
+
+func tryEBNF(t *testing.T, tmp string) {
+	dir := filepath.Join(tmp, "ebnf")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, []byte(`package main
+
+func RuleEmpty() Sorted
+func RuleAppend(Sorted, int) Sorted
+func RuleBlock(Open, []Sorted, Close) Block
+func RuleTarget(Sorted) Target
+`), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	out := runCommandIn(t, dir, "../glean", "-ebnf")
+	if string(out) != `(* Grammar written by glean, in ISO/IEC 14977 EBNF *)
+Target = Sorted ;
+Sorted = (* empty *) | Sorted, "int" ;
+Block = "Open", Sorted, {Sorted}, "Close" ;
+` {
+		t.Fatal("Wrong EBNF output: \n", string(out))
+	}
+	if _, e := os.Stat(filepath.Join(dir, "parse.go")); e == nil {
+		t.Error("parse.go written with -ebnf")
+	}
+}
+
+func tryBNF(t *testing.T, tmp string) {
+	dir := filepath.Join(tmp, "bnf")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, []byte(`package main
+
+func RuleEmpty() Sorted
+func RuleAppend(Sorted, int) Sorted
+func RuleBlock(Open, []Sorted, Close) Block
+func RuleTarget(Sorted) Target
+`), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	out := runCommandIn(t, dir, "../glean", "-bnf")
+	if string(out) != `Block ::= Open []Sorted Close
+Sorted ::= ε | Sorted int
+Target ::= Sorted
+[]Sorted ::= Sorted | []Sorted Sorted
+` {
+		t.Fatal("Wrong BNF output: \n", string(out))
+	}
+	if _, e := os.Stat(filepath.Join(dir, "parse.go")); e == nil {
+		t.Error("parse.go written with -bnf")
+	}
+}
+
+func tryStamp(t *testing.T, tmp string, mainText []byte) {
+	dir := filepath.Join(tmp, "stamp")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, mainText, 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	// Without -stamp, there is no hash to check.
+	if out := runCommandIn(t, dir, "../glean"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	check := exec.Command("../glean", "-check")
+	check.Dir = dir
+	if out, e := check.CombinedOutput(); e == nil || !strings.Contains(string(out), "no grammar hash") {
+		t.Fatal("wrong result checking a file without a hash:", e, string(out))
+	}
+
+	if out := runCommandIn(t, dir, "../glean", "-stamp"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	text, e := os.ReadFile(filepath.Join(dir, "parse.go"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	lines := strings.Split(string(text), "\n")
+	if lines[0] != "// Code generated by glean. DO NOT EDIT." || !strings.HasPrefix(lines[2], "// glean ") ||
+		!strings.Contains(lines[2], ", grammar sha256:") {
+		t.Fatal("wrong stamp:", lines[:3])
+	}
+	if out := runCommandIn(t, dir, "go", "build"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	if out := runCommandIn(t, dir, "../glean", "-check"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+
+	// The hash depends on the target symbol and on the rules.
+	check = exec.Command("../glean", "-check", "-t", "Adder")
+	check.Dir = dir
+	if out, e := check.CombinedOutput(); e == nil || !strings.Contains(string(out), "out of date") {
+		t.Fatal("wrong result checking with another target:", e, string(out))
+	}
+	extraGo := filepath.Join(dir, "extra.go")
+	if e := os.WriteFile(extraGo, []byte("package main\n\nfunc RuleSort2(s Sorted, _ string) Sorted { return s }\n"), 0444); e != nil {
+		t.Fatal(e)
+	}
+	check = exec.Command("../glean", "-check")
+	check.Dir = dir
+	if out, e := check.CombinedOutput(); e == nil || !strings.Contains(string(out), "out of date") {
+		t.Fatal("wrong result checking a changed grammar:", e, string(out))
+	}
+}
+
+func tryTags(t *testing.T, tmp string, mainText []byte) {
+	dir := filepath.Join(tmp, "tags")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, mainText, 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	bad := exec.Command("../glean", "-tags", "prod &&")
+	bad.Dir = dir
+	if out, e := bad.CombinedOutput(); e == nil || !strings.Contains(string(out), "invalid -tags") {
+		t.Fatal("invalid tags accepted:", e, string(out))
+	}
+
+	if out := runCommandIn(t, dir, "../glean", "-tags", "!prod", "-stamp"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	text, e := os.ReadFile(filepath.Join(dir, "parse.go"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if lines := strings.Split(string(text), "\n"); lines[2] != "//go:build !prod" || lines[3] != "" {
+		t.Fatal("wrong build constraint:", lines[:4])
+	}
+	if out := runCommandIn(t, dir, "gofmt", "-l", "parse.go"); len(out) > 0 {
+		t.Fatal("parse.go is not formatted")
+	}
+	if out := runCommandIn(t, dir, "../glean", "-check"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	if out := runCommandIn(t, dir, "go", "build"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+
+	// With the tag prod, the parser is left out, and main.go cannot be built.
+	build := exec.Command("go", "build", "-tags", "prod")
+	build.Dir = dir
+	if out, e := build.CombinedOutput(); e == nil || !strings.Contains(string(out), "undefined: _glean_Parse") {
+		t.Fatal("parser built with tag prod:", e, string(out))
+	}
+}
+
+func tryGoVersion(t *testing.T, tmp string, mainText []byte) {
+	dir := filepath.Join(tmp, "goversion")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, mainText, 0444); e != nil {
+		t.Fatal(e)
+	}
+	goMod := filepath.Join(dir, "go.mod")
+	if e := os.WriteFile(goMod, []byte("module example.com/goversion\n\ngo 1.17\n"), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	// The release comes from go.mod unless -go is given.
+	bad := exec.Command("../glean", "-generic")
+	bad.Dir = dir
+	if out, e := bad.CombinedOutput(); e == nil || !strings.Contains(string(out), "Go 1.18") {
+		t.Fatal("-generic accepted for Go 1.17:", e, string(out))
+	}
+	if out := runCommandIn(t, dir, "../glean", "-go", "1.18", "-generic"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	if _, e := os.Stat(filepath.Join(dir, "parse.go")); e != nil {
+		t.Fatal(e)
+	}
+
+	bad = exec.Command("../glean", "-go", "latest")
+	bad.Dir = dir
+	if out, e := bad.CombinedOutput(); e == nil || !strings.Contains(string(out), "invalid Go version") {
+		t.Fatal("invalid -go accepted:", e, string(out))
+	}
+}
+
+func tryLint(t *testing.T, tmp string, mainText []byte) {
+	dir := filepath.Join(tmp, "lint")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, mainText, 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	// The Adder rules are not used by the target.
+	expect := `goal Target
+ambiguous 0
+unreachable 1
+unproductive 0
+cycles 0
+unreachable Adder
+`
+	lint := exec.Command("../glean", "-lint")
+	lint.Dir = dir
+	var stdout, stderr bytes.Buffer
+	lint.Stdout, lint.Stderr = &stdout, &stderr
+	if e := lint.Run(); e == nil || !strings.Contains(stderr.String(), "unreachable count 1") {
+		t.Fatal("-lint did not fail:", e, stderr.String())
+	}
+	if stdout.String() != expect {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expect, stdout.String())
+	}
+
+	if out := runCommandIn(t, dir, "../glean", "-lint", "-lint-max", "unreachable=1"); string(out) != expect {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expect, out)
+	}
+	if _, e := os.Stat(filepath.Join(dir, "parse.go")); e == nil {
+		t.Fatal("-lint wrote a parser")
+	}
+
+	bad := exec.Command("../glean", "-lint", "-lint-max", "odd=1")
+	bad.Dir = dir
+	if out, e := bad.CombinedOutput(); e == nil || !strings.Contains(string(out), "kind must be") {
+		t.Fatal("invalid -lint-max accepted:", e, string(out))
+	}
+}
+
+func tryMatchBuild(t *testing.T, tmp string, mainText []byte) {
+	dir := filepath.Join(tmp, "matchbuild")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, mainText, 0444); e != nil {
+		t.Fatal(e)
+	}
+	extraGo := filepath.Join(dir, "extra.go")
+	extraText := "//go:build extra\n\npackage main\n\ntype Extra int\n\nfunc RuleExtra(i int) Extra { return Extra(i) }\n"
+	if e := os.WriteFile(extraGo, []byte(extraText), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	// The rules of extra.go add the unreachable symbol Extra, but only
+	// when its build constraint is ignored or satisfied.
+	for _, test := range []struct {
+		args  []string
+		extra bool
+	}{
+		{nil, true},
+		{[]string{"-match-build"}, false},
+		{[]string{"-build-tags", "other,extra"}, true},
+	} {
+		lint := exec.Command("../glean", append(test.args, "-lint", "-lint-max", "unreachable=2")...)
+		lint.Dir = dir
+		out, e := lint.Output()
+		if e != nil {
+			t.Fatal(test.args, e)
+		}
+		if got := strings.Contains(string(out), "unreachable Extra\n"); got != test.extra {
+			t.Errorf("%v: wrong rules scanned:\n%s", test.args, out)
+		}
+	}
+}
+
+func tryMethods(t *testing.T, tmp string) {
+	dir := filepath.Join(tmp, "methods")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, []byte(methodsMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+	if out := runCommandIn(t, dir, "../glean", "-t", "Sum", "-interface", "-methods"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	if out := runCommandIn(t, dir, "go", "run", "."); string(out) != "6 <nil>\n" {
+		t.Fatal("wrong output:", string(out))
+	}
+}
+
+var methodsMainText = `package main
+
+import "fmt"
+
+type Sum int
+type Plus struct{}
+
+type Builder struct{}
+
+func (Builder) RuleInt(i int) Sum                { return Sum(i) }
+func (Builder) RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	fmt.Println(_glean_Parse([]interface{}{1, Plus{}, 2, Plus{}, 3}, Builder{}))
+}
+`
+
+func tryWarnSymbols(t *testing.T, tmp string, mainText []byte) {
+	dir := filepath.Join(tmp, "warnsymbols")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, mainText, 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	// The Adder rules of lister.go serve the other target.
+	out := runCommandIn(t, dir, "../glean", "-warn-symbols")
+	if string(out) != "symbol 'Adder' is unreachable from goal 'Target'\n" {
+		t.Fatal("wrong warnings:", string(out))
+	}
+	if _, e := os.Stat(filepath.Join(dir, "parse.go")); e != nil {
+		t.Fatal("parser not written:", e)
+	}
+	if out := runCommandIn(t, dir, "../glean", "-t", "Adder", "-warn-symbols"); !strings.Contains(string(out), "symbol 'Sorted' is unreachable from goal 'Adder'") {
+		t.Fatal("wrong warnings for target Adder:", string(out))
+	}
+}
+
+func tryVerbose(t *testing.T, tmp string, mainText []byte) {
+	dir := filepath.Join(tmp, "verbose")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, mainText, 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	out := string(runCommandIn(t, dir, "../glean", "-v"))
+	for _, want := range []string{
+		"\tRuleSort: Sorted = Sorted int\n",
+		"\tRuleDefault: Target = Sorted\n",
+		"terminals: int\n",
+		"nonterminals: Adder Sorted Target\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("listing lacks %q:\n%s", want, out)
+		}
+	}
+	if _, e := os.Stat(filepath.Join(dir, "parse.go")); e != nil {
+		t.Fatal("parser not written:", e)
+	}
+}
+
+func tryWatch(t *testing.T, tmp string, mainText []byte) {
+	dir := filepath.Join(tmp, "watch")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, mainText, 0644); e != nil {
+		t.Fatal(e)
+	}
+	parseGo := filepath.Join(dir, "parse.go")
+
+	glean := exec.Command("../glean", "-w")
+	glean.Dir = dir
+	var stderr bytes.Buffer
+	glean.Stderr = &stderr
+	if e := glean.Start(); e != nil {
+		t.Fatal(e)
+	}
+	defer glean.Process.Kill()
+
+	// Wait for the parser to be written, or to change from old
+	waitFor := func(old []byte) []byte {
+		t.Helper()
+		for start := time.Now(); time.Since(start) < 20*time.Second; time.Sleep(50 * time.Millisecond) {
+			if text, e := os.ReadFile(parseGo); e == nil && len(text) > 0 && !bytes.Equal(text, old) {
+				return text
+			}
+		}
+		t.Fatal("parser not written")
+		return nil
+	}
+	first := waitFor(nil)
+
+	more := append(append([]byte(nil), mainText...), "\nfunc RuleNegative(s Sorted, _ Minus) Sorted { return s }\n\ntype Minus struct{}\n"...)
+	if e := os.WriteFile(mainGo, more, 0644); e != nil {
+		t.Fatal(e)
+	}
+	if second := waitFor(first); !bytes.Contains(second, []byte("RuleNegative")) {
+		t.Error("new rule missing from parser")
+	}
+
+	if e := glean.Process.Signal(os.Interrupt); e != nil {
+		t.Fatal(e)
+	}
+	if e := glean.Wait(); e != nil {
+		t.Fatal("glean -w did not exit cleanly:", e, stderr.String())
+	}
+	if out := stderr.String(); out != "glean: wrote parse.go\nglean: wrote parse.go\n" {
+		t.Error("wrong output:", out)
+	}
+}
+
+func tryMaxErrors(t *testing.T, tmp string) {
+	dir := filepath.Join(tmp, "maxerrors")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, []byte(maxErrorsMainText), 0444); e != nil {
+		t.Fatal(e)
+	}
+	if out := runCommandIn(t, dir, "../glean", "-t", "Sum", "-max-errors", "2"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	expect := `6
+unexpected token: main.Plus
+unexpected token: main.Plus
+too many errors
+`
+	if out := runCommandIn(t, dir, "go", "run", "."); string(out) != expect {
+		t.Fatal("wrong output:", string(out))
+	}
+}
+
+var maxErrorsMainText = `package main
+
+import "fmt"
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {
+	p := Plus{}
+	sum, errors := _glean_ParseRecover([]interface{}{1, p, p, 2, p, p, 3, p, p, 4}, _glean_MaxErrors)
+	fmt.Println(sum)
+	for _, e := range errors {
+		fmt.Println(e)
+	}
+}
+`
+
+func tryMaxItems(t *testing.T, tmp string) {
+	dir := filepath.Join(tmp, "maxitems")
+	if e := os.Mkdir(dir, 0700); e != nil {
+		t.Fatal(e)
+	}
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, []byte(`package main
+
+type Sum int
+type Plus struct{}
+
+func RuleInt(i int) Sum                { return Sum(i) }
+func RuleAdd(s Sum, _ Plus, i int) Sum { return s + Sum(i) }
+
+func main() {}
+`), 0444); e != nil {
+		t.Fatal(e)
+	}
+
+	bad := exec.Command("../glean", "-t", "Sum", "-max-items", "2")
+	bad.Dir = dir
+	if out, e := bad.CombinedOutput(); e == nil || !strings.Contains(string(out), "rule RuleAdd has 3 items, more than the limit of 2") {
+		t.Fatal("long rule accepted with -max-items 2:", e, string(out))
+	}
+	if _, e := os.Stat(filepath.Join(dir, "parse.go")); e == nil {
+		t.Error("parse.go written despite a rejected rule")
+	}
+
+	if out := runCommandIn(t, dir, "../glean", "-t", "Sum", "-max-items", "3"); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+	if out := runCommandIn(t, dir, "go", "vet", "."); len(out) > 0 {
+		t.Fatal(string(out))
+	}
+}
@@ -6,13 +6,49 @@
 // Although default error messages are provided, these will likely be unsatisfactory in practice.
 // A program using a glean parser can likely produce better messages based on the context in
 // which the parser is used.
+//
+// The Error methods return only these messages, meant for the users of a
+// parser. The GoString methods give all the fields of an error in Go syntax,
+// including the tokens involved, for the %#v verb of fmt; this is more
+// useful while debugging a grammar.
+//
+// The kind of an error may be tested with errors.Is and the sentinel values
+// ErrNoInput, ErrUnexpected, ErrUnknownToken and ErrAmbiguous, whatever its
+// details; or the error itself may be retrieved with errors.As.
+//
+// At Version 2, fields were added to Location, Unexpected and Ambiguous.
+// Parsers generated by earlier versions of glean build these types with
+// unkeyed composite literals, which no longer compile; such parsers must
+// be regenerated. Parsers generated now use keyed literals, so adding
+// fields to the types in this package will not break them.
 package gleanerrors
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 )
 
+// Version is raised whenever a change to this package requires existing
+// generated parsers to be regenerated.
+const Version = 2
+
+// Sentinel values matched by errors.Is for each kind of error.
+var (
+	// Matches NoInput.
+	ErrNoInput = errors.New("no tokens in parser input")
+
+	// Matches Unexpected.
+	ErrUnexpected = errors.New("unexpected token")
+
+	// Matches UnknownToken.
+	ErrUnknownToken = errors.New("unknown token")
+
+	// Matches Ambiguous and MultiAmbiguous.
+	ErrAmbiguous = errors.New("ambiguous input")
+)
+
 // The tokens slice passed to a parse function had length 0.
 type NoInput struct{}
 
@@ -21,6 +57,16 @@ func (_ NoInput) Error() string {
 	return "no tokens in parser input"
 }
 
+// GoString gives NoInput in Go syntax, for the %#v verb of fmt.
+func (_ NoInput) GoString() string {
+	return "gleanerrors.NoInput{}"
+}
+
+// Is reports whether target is ErrNoInput.
+func (_ NoInput) Is(target error) bool {
+	return target == ErrNoInput
+}
+
 // Location identifies a single token in the input passed to a parse function.
 type Location struct {
 	// The index of the token within the slice given to the parser.
@@ -28,6 +74,16 @@ type Location struct {
 
 	// The token itself.
 	Token interface{}
+
+	// The position of the token in its source, if the token implements
+	// Positioned; otherwise both zero.
+	Line, Column int
+}
+
+// Positioned may be implemented by tokens that know where they appear in
+// the source text, so errors can report the line and column.
+type Positioned interface {
+	Pos() (line, col int)
 }
 
 // MakeLocation returns the Location for a specific token.
@@ -35,9 +91,13 @@ type Location struct {
 // In edge cases, n might be -1 or len(tokens); if so, a nil token is used.
 func MakeLocation(tokens []interface{}, n int) Location {
 	if n < 0 || n >= len(tokens) {
-		return Location{n, nil}
+		return Location{Index: n}
+	}
+	l := Location{Index: n, Token: tokens[n]}
+	if p, ok := l.Token.(Positioned); ok {
+		l.Line, l.Column = p.Pos()
 	}
-	return Location{n, tokens[n]}
+	return l
 }
 
 // A token did not match any rule expected at its position in the input.
@@ -46,11 +106,19 @@ func MakeLocation(tokens []interface{}, n int) Location {
 // a valid match for the target symbol. In this case, Location.Index will be
 // the length of the input, and Location.Token will be nil.
 //
-// Currently, the list of symbols valid at this point in the input is not returned.
-// This may or may not be added in future.
+// The terminal symbols that could have appeared instead are listed in Expected.
 type Unexpected struct {
 	// The token found in the input.
 	Location
+
+	// The display name of the token's symbol, if one was given to the
+	// parser generator; otherwise empty.
+	Name string
+
+	// The names of the terminal symbols that would have continued some
+	// partial match at this point, in sorted order. The end of the input
+	// is not included, even where it would have been valid.
+	Expected []string
 }
 
 // Default error message for Unexpected.
@@ -58,7 +126,57 @@ func (e Unexpected) Error() string {
 	if e.Token == nil {
 		return "unexpected end of input"
 	}
-	return fmt.Sprintf("unexpected token: %#v", e.Token)
+	message := "unexpected token: " + describe(e.Token)
+	if e.Name != "" {
+		message = "unexpected token: " + e.Name
+	}
+	if e.Line > 0 {
+		message += fmt.Sprintf(" at line %d, column %d", e.Line, e.Column)
+	}
+	return message
+}
+
+// GoString gives the fields of an Unexpected error in Go syntax, for the %#v verb of fmt.
+func (e Unexpected) GoString() string {
+	type fields Unexpected
+	return goString("Unexpected", fields(e))
+}
+
+// describe gives a readable description of a token: the token itself if it is
+// a string, a number, a boolean or a fmt.Stringer; or otherwise its type.
+func describe(token interface{}) string {
+	if s, ok := token.(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch k := reflect.TypeOf(token).Kind(); {
+	case k == reflect.String:
+		return fmt.Sprintf("%q", token)
+	case k >= reflect.Bool && k <= reflect.Complex128:
+		return fmt.Sprint(token)
+	}
+	return fmt.Sprintf("%T", token)
+}
+
+// Is reports whether target is ErrUnexpected.
+func (_ Unexpected) Is(target error) bool {
+	return target == ErrUnexpected
+}
+
+// A token was not of the type of any terminal symbol, in a parser
+// generated to report this rather than panic.
+type UnknownToken struct {
+	// The token found in the input.
+	Location
+}
+
+// Default error message for UnknownToken.
+func (e UnknownToken) Error() string {
+	return fmt.Sprintf("input token (type %T) is not a terminal symbol", e.Token)
+}
+
+// Is reports whether target is ErrUnknownToken.
+func (_ UnknownToken) Is(target error) bool {
+	return target == ErrUnknownToken
 }
 
 // Rule represents a rule from the grammar being parsed.
@@ -95,6 +213,11 @@ type Ambiguous struct {
 	// subsequence of tokens inside the parser input; this subsequence may
 	// be larger than indicated in Range.
 	Rule1, Rule2 Rule
+
+	// The terminal symbols of the tokens matched by the rules, a concrete
+	// example of input for which their target is ambiguous. It is empty
+	// if the rules match no tokens, and nil from a Scannerless parser.
+	Example []string
 }
 
 // Default error message for Ambiguous.
@@ -103,3 +226,84 @@ func (e Ambiguous) Error() string {
 		e.Rule1.Name, strings.Join(e.Rule1.Items, " "),
 		e.Rule2.Name, strings.Join(e.Rule2.Items, " "))
 }
+
+// GoString gives the fields of an Ambiguous error in Go syntax, for the %#v verb of fmt.
+func (e Ambiguous) GoString() string {
+	type fields Ambiguous
+	return goString("Ambiguous", fields(e))
+}
+
+// Is reports whether target is ErrAmbiguous.
+func (_ Ambiguous) Is(target error) bool {
+	return target == ErrAmbiguous
+}
+
+// There were several places in the input with multiple matches.
+//
+// A parser returns an Ambiguous error if it finds only one such place,
+// and a MultiAmbiguous error if it finds more.
+type MultiAmbiguous struct {
+	// The ambiguities found, in the order the parser found them.
+	Ambiguities []Ambiguous
+}
+
+// Default error message for MultiAmbiguous.
+func (e MultiAmbiguous) Error() string {
+	messages := make([]string, len(e.Ambiguities))
+	for n, a := range e.Ambiguities {
+		messages[n] = a.Error()
+	}
+	return fmt.Sprintf("%d ambiguities:\n%s", len(e.Ambiguities), strings.Join(messages, "\n"))
+}
+
+// Is reports whether target is ErrAmbiguous.
+func (_ MultiAmbiguous) Is(target error) bool {
+	return target == ErrAmbiguous
+}
+
+// No reducer was supplied for a rule, in a parser that looks up its reducers at run time.
+type MissingReducer struct {
+	// The rule lacking a reducer.
+	Rule
+}
+
+// Default error message for MissingReducer.
+func (e MissingReducer) Error() string {
+	return fmt.Sprintf("no reducer for rule %s", e.Name)
+}
+
+// A parser recovering from errors stopped because it found more errors than its limit.
+type TooManyErrors struct {
+	// The maximum number of errors to be reported.
+	Limit int
+
+	// The token at which the parser stopped.
+	Location
+}
+
+// Default error message for TooManyErrors.
+func (e TooManyErrors) Error() string {
+	return "too many errors"
+}
+
+// A parser caught a panic, which indicates a bug in the parser or in a rule function.
+type Internal struct {
+	// The value passed to panic.
+	Recovered interface{}
+
+	// The tokens given to the parser, for reproducing the problem.
+	Tokens []interface{}
+}
+
+// Default error message for Internal.
+func (e Internal) Error() string {
+	return fmt.Sprintf("internal error in parser: %v", e.Recovered)
+}
+
+// goString formats the fields of an error in Go syntax, under the name of its type.
+// The fields are passed as a value of a type without a GoString method, so %#v
+// does not recurse.
+func goString(name string, fields interface{}) string {
+	s := fmt.Sprintf("%#v", fields)
+	return "gleanerrors." + name + s[strings.IndexByte(s, '{'):]
+}
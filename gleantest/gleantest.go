@@ -0,0 +1,279 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+// Package gleantest helps to test grammars by building programs that use
+// the parsers generated for them.
+//
+// A generated parser takes tokens of the types declared beside its rules,
+// so it cannot be loaded into a running test and called with arbitrary
+// values. Instead the parser is built into a program, with a main function
+// that gleantest writes. Compile builds that program once, and returns a
+// function that passes tokens to it and returns the result of the parse.
+//
+// Tests that need a main function of their own, for instance to call a
+// parse function other than the default one, can use Build instead, which
+// builds the program from the grammar source alone.
+package gleantest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean"
+	"github.com/pat42smith/glean/earley"
+)
+
+// Prefix is the prefix given to the names in the parsers written by Compile
+// and Build, so the parse function is _glean_Parse.
+const Prefix = "_glean_"
+
+// A Token is one token of the input to a ParseFunc. Symbol is the terminal
+// symbol, which must be a type declared in the grammar source or a
+// predeclared type such as int, and Value is the token's value; it is
+// passed to the parser by encoding it as JSON and decoding that into a
+// variable of the symbol's type. A nil Value gives the zero value.
+type Token struct {
+	Symbol glean.Symbol
+	Value  interface{}
+}
+
+// A ParseFunc parses tokens with a parser built by Compile, returning the
+// value of the goal symbol formatted with %v. If the parse fails, the error
+// is a *ParseError with the message of the parser's error; other errors
+// mean the program could not be run, or a rule function panicked.
+type ParseFunc func(tokens ...Token) (string, error)
+
+// A ParseError is the error of a failed parse, which is returned by a
+// ParseFunc as its message only, since it comes from another process.
+type ParseError struct {
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return e.Message
+}
+
+// Compile scans source for grammar rules, writes a parser for the goal
+// symbol with the given options, and builds a program from the two and a
+// main function that reads tokens and calls the parser.
+//
+// The source must be the text of a Go file in package main, with the rule
+// functions and the types of the symbols, but no main function. Options
+// that change the parameters of the parse function, such as Registry or
+// Scannerless, are not allowed. Terminal symbols qualified by a package
+// name cannot be used as tokens.
+// The files and the program are kept in a temporary directory of tb,
+// which is removed when the test ends. The program is built by the go
+// command in the current directory, so the packages it imports, such as
+// gleanerrors, are found as for the test itself.
+func Compile(tb testing.TB, source string, goal glean.Symbol, options earley.Options) (ParseFunc, error) {
+	tb.Helper()
+	for _, o := range []struct {
+		name string
+		used bool
+	}{
+		{"Registry", options.Registry},
+		{"Interface", options.Interface},
+		{"Scannerless", options.Scannerless},
+		{"Classifier", options.Classifier},
+		{"Alternatives", options.Alternatives},
+		{"ChartStore", options.ChartStore},
+		{"TokenInterface", options.TokenInterface != ""},
+	} {
+		if o.used {
+			return nil, fmt.Errorf("option %s cannot be used with Compile", o.name)
+		}
+	}
+
+	program, e := build(tb, source, goal, options, driver)
+	if e != nil {
+		return nil, e
+	}
+
+	return func(tokens ...Token) (string, error) {
+		input, e := json.Marshal(tokens)
+		if e != nil {
+			return "", e
+		}
+		cmd := exec.Command(program)
+		cmd.Stdin = bytes.NewReader(input)
+		out, e := cmd.Output()
+		if e != nil {
+			var exit *exec.ExitError
+			if errors.As(e, &exit) {
+				return "", fmt.Errorf("%v running parser: %s", e, exit.Stderr)
+			}
+			return "", e
+		}
+		var result struct {
+			Value string
+			Error *string
+		}
+		if e := json.Unmarshal(out, &result); e != nil {
+			return "", fmt.Errorf("bad output from parser: %v", e)
+		}
+		if result.Error != nil {
+			return result.Value, &ParseError{*result.Error}
+		}
+		return result.Value, nil
+	}, nil
+}
+
+// A RunFunc runs a program built by Build with the given command line
+// arguments, and returns its combined standard output and standard error.
+// The error is not nil if the program could not be run or did not exit
+// successfully.
+type RunFunc func(args ...string) (string, error)
+
+// Build is like Compile, except that the source must include a main
+// function, which makes its own tokens, for instance from its command line
+// arguments, calls the parser, and prints the result. Any options may be
+// used.
+func Build(tb testing.TB, source string, goal glean.Symbol, options earley.Options) (RunFunc, error) {
+	tb.Helper()
+	program, e := build(tb, source, goal, options, nil)
+	if e != nil {
+		return nil, e
+	}
+
+	return func(args ...string) (string, error) {
+		out, e := exec.Command(program, args...).CombinedOutput()
+		return string(out), e
+	}, nil
+}
+
+// Build a program from source and the parser written for it, for Compile
+// and Build, returning the path of the program. If writeMain is not nil, it
+// writes a further file of the program, given the grammar.
+func build(tb testing.TB, source string, goal glean.Symbol, options earley.Options, writeMain func(g *earley.Grammar) string) (string, error) {
+	tb.Helper()
+	dir := tb.TempDir()
+
+	mainGo := filepath.Join(dir, "main.go")
+	if e := os.WriteFile(mainGo, []byte(source), 0444); e != nil {
+		return "", e
+	}
+
+	var g earley.Grammar
+	g.Options = options
+	pkg, warnings, e := glean.ScanFiles(&g, mainGo)
+	if e != nil {
+		return "", e
+	}
+	for _, w := range warnings {
+		tb.Log(w)
+	}
+	if pkg != "main" {
+		return "", fmt.Errorf("source is in package %s, not main", pkg)
+	}
+	for _, w := range g.Validate() {
+		tb.Log(w)
+	}
+
+	parserText, e := g.WriteParser(goal, pkg, Prefix)
+	if e != nil {
+		return "", e
+	}
+	parserGo := filepath.Join(dir, "parse.go")
+	if e = os.WriteFile(parserGo, []byte(parserText), 0444); e != nil {
+		return "", e
+	}
+	files := []string{mainGo, parserGo}
+
+	if writeMain != nil {
+		driverGo := filepath.Join(dir, "driver.go")
+		if e = os.WriteFile(driverGo, []byte(writeMain(&g)), 0444); e != nil {
+			return "", e
+		}
+		files = append(files, driverGo)
+	}
+
+	program := filepath.Join(dir, "program")
+	args := append([]string{"build", "-o", program}, files...)
+	if out, e := exec.Command("go", args...).CombinedOutput(); e != nil {
+		return "", fmt.Errorf("%v building parser: %s", e, out)
+	}
+	return program, nil
+}
+
+// Write the main function of a program built by Compile. It reads the
+// tokens from standard input, as written by a ParseFunc, decodes each with
+// a function for its symbol's type, and writes the result of the parse to
+// standard output.
+func driver(g *earley.Grammar) string {
+	var b strings.Builder
+	b.WriteString(`// Code generated by gleantest. DO NOT EDIT.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func _gleantest_decode[T any](data json.RawMessage) (interface{}, error) {
+	var v T
+	e := json.Unmarshal(data, &v)
+	return v, e
+}
+
+var _gleantest_decoders = map[string]func(json.RawMessage) (interface{}, error){
+`)
+	for _, t := range g.Terminals() {
+		if glean.SymbolPackage(t) == "" {
+			fmt.Fprintf(&b, "\t%q: _gleantest_decode[%s],\n", t, t)
+		}
+	}
+	b.WriteString(`}
+
+func main() {
+	var input []struct {
+		Symbol string
+		Value  json.RawMessage
+	}
+	if e := json.NewDecoder(os.Stdin).Decode(&input); e != nil {
+		fmt.Fprintln(os.Stderr, "reading tokens:", e)
+		os.Exit(1)
+	}
+	tokens := make([]interface{}, len(input))
+	for n, t := range input {
+		decode := _gleantest_decoders[t.Symbol]
+		if decode == nil {
+			fmt.Fprintf(os.Stderr, "token %d: unknown terminal symbol %s\n", n, t.Symbol)
+			os.Exit(1)
+		}
+		v, e := decode(t.Value)
+		if e != nil {
+			fmt.Fprintf(os.Stderr, "token %d: %v\n", n, e)
+			os.Exit(1)
+		}
+		tokens[n] = v
+	}
+
+	var result struct {
+		Value string
+		Error *string
+	}
+	value, e := _glean_Parse(tokens)
+	if e != nil {
+		msg := e.Error()
+		result.Error = &msg
+	} else {
+		result.Value = fmt.Sprint(value)
+	}
+	if e := json.NewEncoder(os.Stdout).Encode(result); e != nil {
+		fmt.Fprintln(os.Stderr, "writing result:", e)
+		os.Exit(1)
+	}
+}
+`)
+	return b.String()
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+package gleantest_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pat42smith/glean/earley"
+	"github.com/pat42smith/glean/gleantest"
+)
+
+func TestBuild(t *testing.T) {
+	run, e := gleantest.Build(t, productMain, "Product", earley.Options{})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	for _, c := range []struct {
+		args   string
+		expect string
+	}{
+		{"2 * 3 * 7", "42 <nil>\n"},
+		{"5", "5 <nil>\n"},
+		{"2 * * 3", "0 unexpected token: main.Times\n"},
+	} {
+		if out, e := run(strings.Split(c.args, " ")...); e != nil {
+			t.Error(e, out)
+		} else if out != c.expect {
+			t.Errorf("%s: expected %q, got %q", c.args, c.expect, out)
+		}
+	}
+
+	if _, e := gleantest.Build(t, productMain, "Quotient", earley.Options{}); e == nil {
+		t.Error("no error for unknown goal")
+	}
+	broken := strings.Replace(productMain, "func main() {", "func main() { undefined()", 1)
+	if _, e := gleantest.Build(t, broken, "Product", earley.Options{}); e == nil {
+		t.Error("no error for a program that does not build")
+	}
+}
+
+func TestCompile(t *testing.T) {
+	parse, e := gleantest.Compile(t, productRules, "Product", earley.Options{})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	times := gleantest.Token{Symbol: "Times"}
+	for _, c := range []struct {
+		tokens []gleantest.Token
+		value  string
+		err    string
+	}{
+		{[]gleantest.Token{{"int", 2}, times, {"int", 3}, times, {"int", 7}}, "42", ""},
+		{[]gleantest.Token{{"int", 5}}, "5", ""},
+		{[]gleantest.Token{{"int", 2}, times, times, {"int", 3}}, "", "unexpected token: main.Times"},
+		{nil, "", "no tokens in parser input"},
+	} {
+		value, e := parse(c.tokens...)
+		var pe *gleantest.ParseError
+		switch {
+		case c.err == "" && e != nil:
+			t.Errorf("%v: unexpected error: %v", c.tokens, e)
+		case c.err != "" && !errors.As(e, &pe):
+			t.Errorf("%v: expected a ParseError, got %v", c.tokens, e)
+		case c.err != "" && !strings.Contains(pe.Message, c.err):
+			t.Errorf("%v: expected error %q, got %q", c.tokens, c.err, pe.Message)
+		case value != c.value:
+			t.Errorf("%v: expected %q, got %q", c.tokens, c.value, value)
+		}
+	}
+
+	var pe *gleantest.ParseError
+	if _, e := parse(gleantest.Token{Symbol: "Divide"}); e == nil || errors.As(e, &pe) {
+		t.Error("wrong error for an unknown symbol:", e)
+	}
+	if _, e := parse(gleantest.Token{Symbol: "int", Value: "two"}); e == nil || errors.As(e, &pe) {
+		t.Error("wrong error for a token of the wrong type:", e)
+	}
+	if _, e := gleantest.Compile(t, productRules, "Product", earley.Options{Registry: true}); e == nil {
+		t.Error("no error for option Registry")
+	}
+}
+
+var productRules = `
+package main
+
+type Product int
+type Times struct{}
+
+func RuleInt(i int) Product { return Product(i) }
+func RuleTimes(p Product, _ Times, i int) Product { return p * Product(i) }
+`
+
+var productMain = `
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+type Product int
+type Times struct{}
+
+func RuleInt(i int) Product { return Product(i) }
+func RuleTimes(p Product, _ Times, i int) Product { return p * Product(i) }
+
+func main() {
+	var tokens []interface{}
+	for _, a := range os.Args[1:] {
+		if a == "*" {
+			tokens = append(tokens, Times{})
+		} else if i, e := strconv.Atoi(a); e == nil {
+			tokens = append(tokens, i)
+		} else {
+			panic(e)
+		}
+	}
+	fmt.Println(_glean_Parse(tokens))
+}
+`
@@ -3,11 +3,70 @@
 
 package glean
 
+import "go/token"
+
 // A Symbol is a grammar symbol. Symbols returned by the scanner included with
 // glean will be valid Go identifiers, as should be the Symbols given to the
-// glean parser generator.
+// glean parser generator, except for list symbols, and for the symbols
+// qualified by a package name, such as "ast.Statement", that the scanner
+// returns when allowed to scan multiple packages.
+//
+// A list symbol, such as "[]Statement", matches one or more consecutive
+// matches of its element symbol, here Statement, with no separator between
+// them; its value is the slice of their values. The scanner returns a list
+// symbol for a rule function parameter whose type is a slice of a simple
+// identifier, or of such a slice; the element of a list symbol may be a list
+// symbol itself, as in "[][]Token", matching one or more lists of tokens.
+// List symbols may appear only as rule items, not as targets.
+// A list with separators may be written with ordinary rules, for example
+//
+//	func RuleArgs(first Expr, rest []CommaExpr) Args
+//	func RuleCommaExpr(_ Comma, e Expr) CommaExpr
+//
+// An optional symbol, such as "*Else", matches either a match of its
+// element symbol, here Else, or nothing; its value is a pointer to the
+// element's value, or nil if there was no match. The scanner returns an
+// optional symbol for a rule function parameter whose type is a pointer
+// to a simple or qualified identifier, so one function such as
+//
+//	func RuleIf(_ If, cond Expr, body Block, els *ElseBlock) Statement
+//
+// serves for an if statement with or without an else block. A list that
+// may be empty is written as an optional list, such as "*[]Statement",
+// from a parameter whose type is a pointer to a slice; it matches zero or
+// more Statements, and its value is never nil, but points to a nil slice
+// if there were none. Like list symbols, optional symbols may appear only
+// as rule items.
 type Symbol string
 
+// ListOf returns the list symbol whose element is s.
+func ListOf(s Symbol) Symbol {
+	return "[]" + s
+}
+
+// ListElement returns the element of the list symbol s,
+// or the empty symbol if s is not a list symbol.
+func ListElement(s Symbol) Symbol {
+	if len(s) > 2 && s[:2] == "[]" {
+		return s[2:]
+	}
+	return ""
+}
+
+// OptionalOf returns the optional symbol whose element is s.
+func OptionalOf(s Symbol) Symbol {
+	return "*" + s
+}
+
+// OptionalElement returns the element of the optional symbol s,
+// or the empty symbol if s is not an optional symbol.
+func OptionalElement(s Symbol) Symbol {
+	if len(s) > 1 && s[0] == '*' {
+		return s[1:]
+	}
+	return ""
+}
+
 // A RuleAdder can have grammar rules added to it.
 //
 // If the intent is to write a parser for the grammar, then the
@@ -20,6 +79,56 @@ type RuleAdder interface {
 	AddRule(name string, target Symbol, items []Symbol) error
 }
 
+// A SharedRuleAdder can also have rules added whose values are computed by
+// the function of another name. The scanner uses this for rule functions
+// with alias directives, so one function serves several rules.
+type SharedRuleAdder interface {
+	RuleAdder
+
+	// AddSharedRule adds one rule to the grammar, whose value is computed
+	// by the function named reducer, rather than by a function of the
+	// rule's own name. The items of the rule need not be the parameter
+	// types of the function, but their values must be assignable to them.
+	AddSharedRule(name, reducer string, target Symbol, items []Symbol) error
+}
+
+// A PositionedRuleAdder also learns where each rule was found, so that
+// a tool may point to a rule function in its own messages. The scanner
+// calls AddRuleAt in place of AddRule, with the position of the function
+// declaration.
+type PositionedRuleAdder interface {
+	RuleAdder
+
+	// AddRuleAt adds one rule to the grammar, as does AddRule, recording
+	// that it was declared at pos.
+	AddRuleAt(name string, pos token.Position, target Symbol, items []Symbol) error
+}
+
+// A PositionedSharedRuleAdder also learns where each shared rule was
+// found. The scanner calls AddSharedRuleAt in place of AddSharedRule,
+// with the position of the declaration of the reducer, whose alias
+// directives made the rule.
+type PositionedSharedRuleAdder interface {
+	SharedRuleAdder
+
+	// AddSharedRuleAt adds one rule to the grammar, as does
+	// AddSharedRule, recording that it was declared at pos.
+	AddSharedRuleAt(name, reducer string, pos token.Position, target Symbol, items []Symbol) error
+}
+
+// An ImportAdder also learns the import paths of the packages named in
+// qualified symbols, such as "ast" in "ast.Node", so that a parser may
+// import them. The scanner calls AddImport for each package so named in a
+// rule, with the path imported under that name by the file holding the
+// rule function, before adding the rule; it may do so several times for
+// the same package. For an import without an explicit name, the name is
+// taken to be the last element of the path.
+type ImportAdder interface {
+	// AddImport records that the package named name has the import path
+	// path. It should return an error if name has another path.
+	AddImport(name, path string) error
+}
+
 // A ParserWriter can write a parser (in Go) for a grammar.
 type ParserWriter interface {
 	// ParserWriter writes a grammar parser in Go.
@@ -7,64 +7,289 @@ package glean
 import (
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"io"
 	"io/fs"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 // ScanFiles searches one or more files for grammar rules.
 //
-// For each rule found, rules.AddRule is called. All the files must belong
-// to the same package; the name of that package is the first returned value.
+// For each rule found, rules.AddRule is called, or for the rules made by the
+// alias directives of a function, rules.AddSharedRule, which requires rules
+// to be a SharedRuleAdder. All the files must belong to the same package;
+// the name of that package is the first returned value. If adding a rule
+// fails, scanning stops and the error is returned, with the position and
+// name of the rule's function.
+//
+// A parameter or result type may be a qualified identifier such as
+// token.Pos, naming a type of an imported package, which gives the symbol
+// "token.Pos". If rules is an ImportAdder, it is told the import path of
+// each package so used.
+//
+// A function whose doc comment has the line "//glean:ignore" is not a
+// rule, whatever its name and type, and is skipped without a warning.
 func ScanFiles(rules RuleAdder, filenames ...string) (pkg string, warnings []error, err error) {
+	return ScanFilesWithOptions(rules, ScanOptions{}, filenames...)
+}
+
+// ScanOptions modify the scanning done by ScanFilesWithOptions and
+// ScanDirWithOptions.
+type ScanOptions struct {
+	// If AllowMultiplePackages is true, the files may belong to different
+	// packages, for a parser that will live in yet another package. Every
+	// rule name and every symbol other than a predeclared Go type is then
+	// qualified by the name of the package of the file in which it was
+	// found, as in "ast.RuleIf" and "ast.Statement", or "[]ast.Statement"
+	// for a list symbol; SymbolPackage recovers the package name. This is
+	// so even when all the files are in one package.
+	//
+	// Since the symbols are qualified, a type name found in two packages
+	// gives two distinct symbols, and a rule function name found in two
+	// packages gives two distinct rules. Packages are known only by their
+	// names, so files in different directories must not have the same
+	// package name. Rule functions and symbol types that are not exported
+	// cannot be used from another package; rule functions using them are
+	// ignored, with a warning.
+	//
+	// The first result of ScanFilesWithOptions is the name of the package
+	// if all the files belong to one package, and otherwise empty.
+	AllowMultiplePackages bool
+
+	// Only, if not empty, names exactly the functions to be taken as rule
+	// functions, in place of those whose names begin with Rule or rule.
+	// This suits files holding helper functions that follow the naming
+	// convention by chance. A name may be qualified by its package, as in
+	// "ast.RuleIf", to pick out a function of one package when names are
+	// qualified. A function named in Only is treated as any rule function,
+	// so one of an unsuitable type is ignored with a warning. If a name in
+	// Only matches no function in the files, an error is returned.
+	Only []string
+
+	// If Methods is true, methods are scanned as well as functions, so
+	// that the actions of a grammar may be kept together on one type. A
+	// method is a rule function if its name would make a function one;
+	// the rule is named by the method, and its receiver is not an item.
+	// The receiver's type is not recorded, so two methods of the same name
+	// on different types conflict, as do a method and a function. Such
+	// rules cannot be called as functions; they suit a parser that applies
+	// rules through an interface, as with the Interface option of package
+	// github.com/pat42smith/glean/earley, which the receiver's type may
+	// then implement. Otherwise, methods are ignored.
+	Methods bool
+
+	// BuildContext, if not nil, selects the files to be scanned, as the go
+	// command does when building: a file is skipped unless
+	// BuildContext.MatchFile accepts it, by its name and its //go:build
+	// constraints, for the GOOS, GOARCH and tags of the context. So a rule
+	// behind a constraint such as //go:build windows is not found when
+	// scanning for Linux. A file listed for ScanFilesWithOptions is skipped
+	// in the same way; it is an error if none is left. With a nil
+	// BuildContext, every file is scanned, whatever its constraints.
+	BuildContext *build.Context
+
+	// Prefixes, if not empty, replaces Rule and rule as the prefixes
+	// marking the names of rule functions, for code in which those names
+	// are taken by other functions. A function is a rule function if its
+	// name begins with one of Prefixes, as with []string{"Grammar_"} for
+	// rules such as Grammar_Add. A prefix may not be empty, and Prefixes
+	// cannot be combined with Only.
+	Prefixes []string
+
+	// If Tests is true, rules are also taken from test files, for a parser
+	// used only in tests: ScanDirWithOptions scans the *_test.go files of
+	// the directory along with the others, and the package of an external
+	// test, such as foo_test, is taken to match the package foo it tests.
+	// The package name returned is then foo. The rule functions of foo_test
+	// cannot be called from foo, nor the unexported ones of foo from
+	// foo_test, so a parser mixing the two may not compile; rules for a
+	// parser in foo are best kept in test files of package foo. Tests
+	// cannot be combined with AllowMultiplePackages.
+	Tests bool
+}
+
+// The prefixes of the names of rule functions, unless ScanOptions.Prefixes
+// gives others
+var defaultPrefixes = []string{"Rule", "rule"}
+
+// ScanFilesWithOptions searches one or more files for grammar rules, as does
+// ScanFiles, but as modified by options.
+func ScanFilesWithOptions(rules RuleAdder, options ScanOptions, filenames ...string) (pkg string, warnings []error, err error) {
 	if len(filenames) == 0 {
 		panic("ScanFiles: no files listed")
 	}
 
 	var s scanner
 	s.init(rules)
+	if e := s.setOptions(options); e != nil {
+		return "", nil, e
+	}
+	dirs := make(map[string]string)
 
+	n := 0
 	for _, fname := range filenames {
-		file, e := parser.ParseFile(s.fset, fname, nil, 0)
+		if options.BuildContext != nil {
+			match, e := options.BuildContext.MatchFile(filepath.Split(fname))
+			if e != nil {
+				return "", nil, e
+			}
+			if !match {
+				continue
+			}
+		}
+		file, e := parser.ParseFile(s.fset, fname, nil, parser.ParseComments)
 		if e != nil {
 			return "", nil, e
 		}
-		if pkg == "" {
-			pkg = file.Name.Name
-		} else if pkg != file.Name.Name {
-			return "", nil, fmt.Errorf("different package names found: %s and %s", pkg, file.Name.Name)
+		name := file.Name.Name
+		if options.Tests {
+			name = strings.TrimSuffix(name, "_test")
+		}
+		if !options.AllowMultiplePackages {
+			if pkg == "" {
+				pkg = name
+			} else if pkg != name {
+				return "", nil, fmt.Errorf("different package names found: %s and %s", pkg, name)
+			}
+		} else {
+			dir := filepath.Dir(fname)
+			if other, have := dirs[name]; have && other != dir {
+				return "", nil, fmt.Errorf("package name %s found in directories %s and %s", name, other, dir)
+			}
+			dirs[name] = dir
+			if n == 0 {
+				pkg = name
+			} else if pkg != name {
+				pkg = ""
+			}
 		}
 
 		e = s.scanFile(file)
 		if e != nil {
 			return "", nil, e
 		}
+		n++
+	}
+	if n == 0 {
+		return "", nil, fmt.Errorf("none of the files listed match the build context")
 	}
 
+	if e := s.checkOnly(options); e != nil {
+		return "", nil, e
+	}
 	return pkg, s.warnings, nil
 }
 
+// ScanReader searches Go source read from src for grammar rules, as does
+// ScanFiles for a single file, for source that is generated in memory
+// rather than written to a file. The filename is used only in the
+// positions of errors and warnings; no file is opened. Source with no
+// package clause, including empty source, is an error, as it is for
+// ScanFiles.
+func ScanReader(rules RuleAdder, filename string, src io.Reader) (pkg string, warnings []error, err error) {
+	if src == nil {
+		// parser.ParseFile would read the file instead
+		panic("ScanReader: nil reader")
+	}
+
+	var s scanner
+	s.init(rules)
+	file, e := parser.ParseFile(s.fset, filename, src, parser.ParseComments)
+	if e != nil {
+		return "", nil, e
+	}
+	if e = s.scanFile(file); e != nil {
+		return "", nil, e
+	}
+	return file.Name.Name, s.warnings, nil
+}
+
+// SymbolPackage returns the package name qualifying the symbol s, or the
+// innermost element of s if it is a list or optional symbol, as in the
+// symbols returned by ScanFilesWithOptions with AllowMultiplePackages. It
+// returns the empty string if the symbol is not qualified.
+func SymbolPackage(s Symbol) string {
+	if e := OptionalElement(s); e != "" {
+		s = e
+	}
+	for ListElement(s) != "" {
+		s = ListElement(s)
+	}
+	if dot := strings.IndexByte(string(s), '.'); dot >= 0 {
+		return string(s[:dot])
+	}
+	return ""
+}
+
 // ScanDir searches for grammar rules in the .go files in a directory
 //
-// Files named *_test.go are ignored.
-// For each rule found, rules.AddRule is called. All the files must belong
-// to the same package; the name of that package is the first returned value.
+// Files named *_test.go are ignored, unless ScanOptions.Tests is given
+// to ScanDirWithOptions.
+// For each rule found, rules.AddRule is called, or for the rules made by the
+// alias directives of a function, rules.AddSharedRule, which requires rules
+// to be a SharedRuleAdder. All the files must belong to the same package;
+// the name of that package is the first returned value.
 func ScanDir(rules RuleAdder, dirname string) (pkg string, warnings []error, err error) {
+	return ScanDirWithOptions(rules, ScanOptions{}, dirname)
+}
+
+// ScanDirWithOptions searches for grammar rules in the .go files in a
+// directory, as does ScanDir, but as modified by options.
+func ScanDirWithOptions(rules RuleAdder, options ScanOptions, dirname string) (pkg string, warnings []error, err error) {
 	var s scanner
 	s.init(rules)
+	if e := s.setOptions(options); e != nil {
+		return "", nil, e
+	}
 
-	notTest := func(info fs.FileInfo) bool {
-		return !strings.HasSuffix(info.Name(), "_test.go")
+	var matchErr error
+	include := func(info fs.FileInfo) bool {
+		if strings.HasSuffix(info.Name(), "_test.go") && !options.Tests {
+			return false
+		}
+		if options.BuildContext == nil {
+			return true
+		}
+		match, e := options.BuildContext.MatchFile(dirname, info.Name())
+		if e != nil && matchErr == nil {
+			matchErr = e
+		}
+		return match
 	}
 
-	packages, e := parser.ParseDir(s.fset, dirname, notTest, 0)
+	packages, e := parser.ParseDir(s.fset, dirname, include, parser.ParseComments)
+	if e == nil {
+		e = matchErr
+	}
 	if e != nil {
 		return "", nil, e
 	}
 	if len(packages) == 0 {
 		return "", nil, fmt.Errorf("no Go files found in directory %s", dirname)
 	}
+	if options.Tests {
+		// Merge an external test package with the package it tests.
+		for name, p := range packages {
+			if base := strings.TrimSuffix(name, "_test"); base != name {
+				if q := packages[base]; q != nil {
+					for fname, file := range p.Files {
+						q.Files[fname] = file
+					}
+				} else {
+					p.Name = base
+					packages[base] = p
+				}
+				delete(packages, name)
+			}
+		}
+	}
 	if len(packages) > 1 {
 		names := ""
 		for p := range packages {
@@ -81,7 +306,7 @@ func ScanDir(rules RuleAdder, dirname string) (pkg string, warnings []error, err
 
 	for _, p := range packages {
 		for _, file := range p.Files {
-			if file.Name.Name != pkg {
+			if name := file.Name.Name; name != pkg && !(options.Tests && name == pkg+"_test") {
 				return "", nil, fmt.Errorf("Inconsistency from Go parser: package names %s and %s differ", pkg, file.Name.Name)
 			}
 			e = s.scanFile(file)
@@ -90,15 +315,91 @@ func ScanDir(rules RuleAdder, dirname string) (pkg string, warnings []error, err
 			}
 		}
 	}
+	if e := s.checkOnly(options); e != nil {
+		return "", nil, e
+	}
 	return pkg, s.warnings, nil
 }
 
+// A ScannedPackage holds the rules found by ScanTree in one directory.
+type ScannedPackage struct {
+	// The name of the package of the files in the directory.
+	Name string
+
+	// The RuleAdder, made for the directory, to which the rules were added.
+	Rules RuleAdder
+}
+
+// ScanTree searches for grammar rules in every directory of the tree
+// rooted at dirname, for a project with several packages, each holding a
+// grammar. For each directory with .go files other than *_test.go,
+// newRules is called with the directory's path, and the rules found are
+// added to the RuleAdder it returns, as by ScanDir. The results are keyed
+// by each directory's path relative to dirname, in slash-separated form,
+// such as "." or "lang/sql"; directories with no such files are omitted.
+// As with the go command, directories named testdata, or with names
+// beginning with . or _, are skipped, along with their subdirectories.
+// The warnings from all the directories are returned together; the scan
+// stops at the first error.
+func ScanTree(newRules func(dir string) RuleAdder, dirname string) (packages map[string]ScannedPackage, warnings []error, err error) {
+	packages = make(map[string]ScannedPackage)
+	err = filepath.WalkDir(dirname, func(path string, d fs.DirEntry, e error) error {
+		if e != nil {
+			return e
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if name := d.Name(); path != dirname && (name == "testdata" || name[0] == '.' || name[0] == '_') {
+			return filepath.SkipDir
+		}
+		entries, e := os.ReadDir(path)
+		if e != nil {
+			return e
+		}
+		hasGo := false
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.IsDir() && strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+				hasGo = true
+				break
+			}
+		}
+		if !hasGo {
+			return nil
+		}
+
+		rel, e := filepath.Rel(dirname, path)
+		if e != nil {
+			return e
+		}
+		rules := newRules(path)
+		pkg, w, e := ScanDir(rules, path)
+		if e != nil {
+			return e
+		}
+		warnings = append(warnings, w...)
+		packages[filepath.ToSlash(rel)] = ScannedPackage{pkg, rules}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return packages, warnings, nil
+}
+
 // A scanner contains the machinery with which to scan Go files for grammar rules
 type scanner struct {
-	rules    RuleAdder
-	fset     *token.FileSet
-	warnings []error
-	funcPos  map[string]token.Pos
+	rules     RuleAdder
+	fset      *token.FileSet
+	warnings  []error
+	funcPos   map[string]token.Pos
+	qualified bool              // Qualify names by their package
+	pkg       string            // The package of the file being scanned
+	only      map[string]bool   // If not nil, the rule functions, and whether each was found
+	prefixes  []string          // The prefixes of the names of rule functions
+	methods   bool              // Scan methods as well as functions
+	imports   map[string]string // The import paths of the file being scanned, by package name
 }
 
 // init initializes a scanner
@@ -107,28 +408,88 @@ func (s *scanner) init(rules RuleAdder) {
 	s.fset = token.NewFileSet()
 	s.warnings = nil
 	s.funcPos = make(map[string]token.Pos)
+	s.prefixes = defaultPrefixes
+}
+
+// setOptions applies the options to a scanner
+func (s *scanner) setOptions(options ScanOptions) error {
+	s.qualified = options.AllowMultiplePackages
+	s.methods = options.Methods
+	if options.Tests && options.AllowMultiplePackages {
+		return fmt.Errorf("ScanOptions Tests and AllowMultiplePackages cannot be combined")
+	}
+	if len(options.Only) > 0 {
+		if len(options.Prefixes) > 0 {
+			return fmt.Errorf("ScanOptions Only and Prefixes cannot be combined")
+		}
+		s.only = make(map[string]bool)
+		for _, name := range options.Only {
+			s.only[name] = false
+		}
+	}
+	if len(options.Prefixes) > 0 {
+		for _, prefix := range options.Prefixes {
+			if prefix == "" {
+				return fmt.Errorf("empty prefix in ScanOptions.Prefixes")
+			}
+		}
+		s.prefixes = options.Prefixes
+	}
+	return nil
+}
+
+// checkOnly checks that every function named in ScanOptions.Only was found
+func (s *scanner) checkOnly(options ScanOptions) error {
+	for _, name := range options.Only {
+		if !s.only[name] {
+			return fmt.Errorf("function %s, named in ScanOptions.Only, was not found", name)
+		}
+	}
+	return nil
 }
 
 // scanFile scans a file for grammar rules.
 func (s *scanner) scanFile(f *ast.File) error {
+	s.pkg = f.Name.Name
+	s.imports = make(map[string]string)
+	for _, spec := range f.Imports {
+		path, e := strconv.Unquote(spec.Path.Value)
+		if e != nil {
+			continue
+		}
+		name := pathpkg.Base(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		s.imports[name] = path
+	}
 	for _, d := range f.Decls {
-		if funcd, ok := d.(*ast.FuncDecl); ok && funcd.Name != nil {
+		// Methods cannot be called as rule functions, so are not rules, even
+		// those implementing a generated Reducer interface, unless wanted.
+		if funcd, ok := d.(*ast.FuncDecl); ok && funcd.Name != nil && (funcd.Recv == nil || s.methods) {
 			funcname := funcd.Name.Name
-			if len(funcname) < 4 || funcname[:4] != "Rule" && funcname[:4] != "rule" {
+			if s.only != nil {
+				if !s.pick(funcname) {
+					continue
+				}
+			} else if !s.hasPrefix(funcname) {
+				continue
+			}
+			if ignored(funcd) {
 				continue
 			}
 			functype := funcd.Type
 			if functype == nil {
 				continue
 			}
-			paramTypes, errpos := typeList(functype.Params, s.fset)
+			paramTypes, errpos := typeList(functype.Params, s.fset, true)
 			if errpos != token.NoPos {
 				where := s.fset.Position(errpos)
 				s.warnings = append(s.warnings,
 					fmt.Errorf("%s: warning: ignoring %s: parameter type is not an identifier", where, funcname))
 				continue
 			}
-			resultTypes, errpos := typeList(functype.Results, s.fset)
+			resultTypes, errpos := typeList(functype.Results, s.fset, false)
 			if errpos != token.NoPos {
 				where := s.fset.Position(errpos)
 				s.warnings = append(s.warnings,
@@ -146,21 +507,250 @@ func (s *scanner) scanFile(f *ast.File) error {
 					fmt.Errorf("%s: warning: ignoring %s: number of results is not 1", where, funcname))
 				continue
 			}
-			if prevPos, seen := s.funcPos[funcname]; seen {
+			if s.qualified {
+				if name := s.unexported(funcname, resultTypes, paramTypes); name != "" {
+					s.warnings = append(s.warnings, fmt.Errorf("%s: warning: ignoring %s: %s is not exported",
+						s.fset.Position(funcd.Pos()), funcname, name))
+					continue
+				}
+			}
+			if prevPos, seen := s.funcPos[s.qualify(funcname)]; seen {
 				return fmt.Errorf("%s: %s previously declared at %s",
 					s.fset.Position(funcd.Pos()), funcname, s.fset.Position(prevPos))
 			}
-			s.funcPos[funcname] = funcd.Pos()
-			s.rules.AddRule(funcname, resultTypes[0], paramTypes)
+			s.funcPos[s.qualify(funcname)] = funcd.Pos()
+			expansions, e := s.expandAliases(funcd, paramTypes)
+			if e != nil {
+				return e
+			}
+			target := Symbol(s.qualify(string(resultTypes[0])))
+			pos := s.fset.Position(funcd.Pos())
+			if e := s.addImports(pos, append(resultTypes, paramTypes...)); e != nil {
+				return e
+			}
+			if expansions == nil {
+				if positioned, ok := s.rules.(PositionedRuleAdder); ok {
+					e = positioned.AddRuleAt(s.qualify(funcname), pos, target, s.qualifyAll(paramTypes))
+				} else {
+					e = s.rules.AddRule(s.qualify(funcname), target, s.qualifyAll(paramTypes))
+				}
+				if e != nil {
+					return fmt.Errorf("%s: %s: %v", pos, funcname, e)
+				}
+				continue
+			}
+			shared, ok := s.rules.(SharedRuleAdder)
+			if !ok {
+				return fmt.Errorf("%s: %s has alias directives, which are not supported here", pos, funcname)
+			}
+			positioned, _ := s.rules.(PositionedSharedRuleAdder)
+			for _, x := range expansions {
+				name, reducer, items := s.qualify(funcname+x.suffix), s.qualify(funcname), s.qualifyAll(x.items)
+				if positioned != nil {
+					e = positioned.AddSharedRuleAt(name, reducer, pos, target, items)
+				} else {
+					e = shared.AddSharedRule(name, reducer, target, items)
+				}
+				if e != nil {
+					return fmt.Errorf("%s: %s: %v", pos, funcname, e)
+				}
+			}
 		}
 	}
 	return nil
 }
 
+// addImports passes the import paths of the packages qualifying the
+// symbols of a rule to the RuleAdder, if it is an ImportAdder. Packages
+// not imported by the file, such as those of the scanned files whose
+// names qualify their own symbols, are left out.
+func (s *scanner) addImports(pos token.Position, symbols []Symbol) error {
+	adder, ok := s.rules.(ImportAdder)
+	if !ok {
+		return nil
+	}
+	for _, sym := range symbols {
+		name := SymbolPackage(sym)
+		if path, imported := s.imports[name]; imported {
+			if e := adder.AddImport(name, path); e != nil {
+				return fmt.Errorf("%s: %v", pos, e)
+			}
+		}
+	}
+	return nil
+}
+
+// hasPrefix tells whether a function's name begins with one of the
+// prefixes of rule functions.
+func (s *scanner) hasPrefix(funcname string) bool {
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(funcname, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// pick tells whether a function is named in ScanOptions.Only, by its own
+// name or its qualified one, and records that it was found.
+func (s *scanner) pick(funcname string) bool {
+	picked := false
+	for _, name := range []string{funcname, s.pkg + "." + funcname} {
+		if _, listed := s.only[name]; listed {
+			s.only[name] = true
+			picked = true
+		}
+	}
+	return picked
+}
+
+// qualify returns a rule or symbol name, qualified by the package of
+// the file being scanned if names are to be qualified. Predeclared types
+// and the element of a list or optional symbol are handled specially.
+func (s *scanner) qualify(name string) string {
+	if !s.qualified {
+		return name
+	}
+	if e := ListElement(Symbol(name)); e != "" {
+		return string(ListOf(Symbol(s.qualify(string(e)))))
+	}
+	if e := OptionalElement(Symbol(name)); e != "" {
+		return string(OptionalOf(Symbol(s.qualify(string(e)))))
+	}
+	if _, ok := types.Universe.Lookup(name).(*types.TypeName); ok || strings.Contains(name, ".") {
+		return name
+	}
+	return s.pkg + "." + name
+}
+
+// qualifyAll qualifies a list of symbols.
+func (s *scanner) qualifyAll(symbols []Symbol) []Symbol {
+	q := make([]Symbol, len(symbols))
+	for n, sym := range symbols {
+		q[n] = Symbol(s.qualify(string(sym)))
+	}
+	return q
+}
+
+// unexported returns the first name, among a rule function name and its
+// types, that cannot be used from another package, or "" if there is none.
+func (s *scanner) unexported(funcname string, results, params []Symbol) string {
+	if !token.IsExported(funcname) {
+		return funcname
+	}
+	for _, sym := range append(results[:len(results):len(results)], params...) {
+		if e := OptionalElement(sym); e != "" {
+			sym = e
+		}
+		for ListElement(sym) != "" {
+			sym = ListElement(sym)
+		}
+		name := string(sym)
+		if dot := strings.IndexByte(name, '.'); dot >= 0 {
+			name = name[dot+1:]
+		}
+		if _, ok := types.Universe.Lookup(name).(*types.TypeName); !ok && !token.IsExported(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// The directive in the doc comment of a function that is not a rule
+const ignoreDirective = "//glean:ignore"
+
+// ignored tells whether a function's doc comment has an ignore directive.
+func ignored(funcd *ast.FuncDecl) bool {
+	if funcd.Doc == nil {
+		return false
+	}
+	for _, c := range funcd.Doc.List {
+		if strings.TrimRight(c.Text, " \t") == ignoreDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// The prefix of an alias directive in the doc comment of a rule function
+const aliasDirective = "//glean:alias "
+
+// An expansion is one of the rules made from a rule function with alias directives
+type expansion struct {
+	suffix string // Appended to the function name to make the rule name
+	items  []Symbol
+}
+
+// expandAliases applies the alias directives of a rule function to its
+// parameter types. It returns nil if there are no directives.
+//
+// A directive "//glean:alias Item S1 S2 ..." makes a rule for each
+// symbol Si, in which every parameter of type Item is replaced by Si.
+// The rule's name is the function name followed by "_" and Si. With
+// several directives, a rule is made for each combination of symbols.
+func (s *scanner) expandAliases(funcd *ast.FuncDecl, items []Symbol) ([]expansion, error) {
+	if funcd.Doc == nil {
+		return nil, nil
+	}
+	var expansions []expansion
+	seen := make(map[Symbol]bool)
+	for _, c := range funcd.Doc.List {
+		if !strings.HasPrefix(c.Text, aliasDirective) {
+			continue
+		}
+		where := s.fset.Position(c.Pos())
+		fields := strings.Fields(strings.TrimPrefix(c.Text, aliasDirective))
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s: alias directive needs an item and at least one symbol", where)
+		}
+		for _, f := range fields {
+			if !token.IsIdentifier(f) {
+				return nil, fmt.Errorf("%s: alias directive: '%s' is not a valid Go identifier", where, f)
+			}
+		}
+		item := Symbol(fields[0])
+		if seen[item] {
+			return nil, fmt.Errorf("%s: second alias directive for %s", where, item)
+		}
+		seen[item] = true
+		found := false
+		for _, i := range items {
+			found = found || i == item
+		}
+		if !found {
+			return nil, fmt.Errorf("%s: alias directive: %s is not a parameter type of %s", where, item, funcd.Name.Name)
+		}
+
+		if expansions == nil {
+			expansions = []expansion{{"", items}}
+		}
+		var next []expansion
+		for _, x := range expansions {
+			for _, alias := range fields[1:] {
+				replaced := make([]Symbol, len(x.items))
+				for n, i := range x.items {
+					if i == item {
+						replaced[n] = Symbol(alias)
+					} else {
+						replaced[n] = i
+					}
+				}
+				next = append(next, expansion{x.suffix + "_" + alias, replaced})
+			}
+		}
+		expansions = next
+	}
+	return expansions, nil
+}
+
 // typeList returns the types from a parameter list or result list.
 // If the second result is not NoPos, then it indicates the position
-// of the first type that is not a simple identifier.
-func typeList(fl *ast.FieldList, fset *token.FileSet) ([]Symbol, token.Pos) {
+// of the first type that is not a simple identifier or a type qualified
+// by a package name, such as ast.Expr. If slices is true, a slice of an
+// accepted type, or of such a slice, as in [][]Token, is also accepted,
+// as a list symbol, as is a pointer to a simple or qualified identifier,
+// or to a slice accepted as a list symbol, as an optional symbol.
+func typeList(fl *ast.FieldList, fset *token.FileSet, slices bool) ([]Symbol, token.Pos) {
 	if fl == nil {
 		return nil, token.NoPos
 	}
@@ -170,11 +760,36 @@ func typeList(fl *ast.FieldList, fset *token.FileSet) ([]Symbol, token.Pos) {
 		if count == 0 {
 			count = 1
 		}
-		typeId, isId := field.Type.(*ast.Ident)
-		if !isId {
+		var name func(t ast.Expr) Symbol
+		name = func(t ast.Expr) Symbol {
+			switch t := t.(type) {
+			case *ast.Ident:
+				return Symbol(t.Name)
+			case *ast.SelectorExpr:
+				if pkg, isId := t.X.(*ast.Ident); isId {
+					return Symbol(pkg.Name + "." + t.Sel.Name)
+				}
+			case *ast.ArrayType:
+				if slices && t.Len == nil {
+					if elem := name(t.Elt); elem != "" {
+						return ListOf(elem)
+					}
+				}
+			}
+			return ""
+		}
+		typeName := name(field.Type)
+		if star, isStar := field.Type.(*ast.StarExpr); isStar && slices {
+			switch star.X.(type) {
+			case *ast.Ident, *ast.SelectorExpr, *ast.ArrayType:
+				if elem := name(star.X); elem != "" {
+					typeName = OptionalOf(elem)
+				}
+			}
+		}
+		if typeName == "" {
 			return nil, field.Type.Pos()
 		}
-		typeName := Symbol(typeId.Name)
 		for i := 0; i < count; i++ {
 			types = append(types, typeName)
 		}
@@ -5,6 +5,8 @@ package glean
 
 import (
 	"fmt"
+	"go/build"
+	"go/token"
 	"os"
 	"path/filepath"
 	"sort"
@@ -126,6 +128,55 @@ func Rule(list Exprs, extra Expr) Exprs
 	expectGrammar(t, &rs, "Rule Exprs [Exprs Expr]\nrule Exprs []")
 }
 
+func TestSlices(t *testing.T) {
+	tmp := t.TempDir()
+	f := tmp + "/list.go"
+	writeFile(f, `package list
+func RuleBlock(open Open, stmts []Statement, close Close) Block
+func RuleArray(a [3]Statement) Block
+func RulePointers(p []*Statement) Block
+func RuleSplit(s Statement) []Statement
+func RuleRows(rows [][]Cell) Table
+func RuleFixedRows(rows [][2]Cell) Table
+`)
+
+	var rs ruleStringer
+	p, w, e := ScanFiles(&rs, f)
+	if e != nil {
+		t.Error("Unexpected error:", e)
+	}
+	expectPackage(t, p, "list")
+	expectGrammar(t, &rs, "RuleBlock Block [Open []Statement Close]\nRuleRows Table [[][]Cell]")
+	expectWarnings(t, w,
+		"ignoring RuleArray: parameter type is not an identifier",
+		"ignoring RuleFixedRows: parameter type is not an identifier",
+		"ignoring RulePointers: parameter type is not an identifier",
+		"ignoring RuleSplit: result type is not an identifier")
+}
+
+func TestPointers(t *testing.T) {
+	tmp := t.TempDir()
+	f := tmp + "/optional.go"
+	writeFile(f, `package optional
+func RuleIf(_ If, cond Expr, body Block, els *Else) Statement
+func RuleReturn(_ Return, e *ast.Expr) Statement
+func RuleStars(s **Statement) Block
+func RuleOptionalList(s *[]Statement) Block
+func RuleNew(s Statement) *Block
+`)
+
+	var rs ruleStringer
+	p, w, e := ScanFiles(&rs, f)
+	if e != nil {
+		t.Error("Unexpected error:", e)
+	}
+	expectPackage(t, p, "optional")
+	expectGrammar(t, &rs, "RuleIf Statement [If Expr Block *Else]\nRuleOptionalList Block [*[]Statement]\nRuleReturn Statement [Return *ast.Expr]")
+	expectWarnings(t, w,
+		"ignoring RuleNew: result type is not an identifier",
+		"ignoring RuleStars: parameter type is not an identifier")
+}
+
 func TestMultipleFiles(t *testing.T) {
 	tmp := t.TempDir()
 	f1 := tmp + "/alpha.go"
@@ -192,7 +243,7 @@ func TestWarnings(t *testing.T) {
 	f2 := tmp + "/beeper.go"
 
 	writeFile(f1, `package alert
-func RuleDereference(p *Foo) Bar
+func RuleDereference(p **Foo) Bar
 func RuleConcat(a, b, c int) []int
 `)
 	writeFile(f2, `package alert
@@ -334,3 +385,587 @@ func RuleChoke(Pit) Inedible`)
 	}
 	expectGrammar(t, &rs, "RuleBite Snack [Peach]")
 }
+
+func TestIncludeTestFiles(t *testing.T) {
+	tmp := t.TempDir()
+	f1 := filepath.Join(tmp, "peach.go")
+	f2 := filepath.Join(tmp, "peach_test.go")
+	f3 := filepath.Join(tmp, "stone_test.go")
+	writeFile(f1, `package peach
+func RuleBite(Peach) Snack`)
+	writeFile(f2, `package peach_test
+func RuleChoke(Pit) Inedible`)
+	writeFile(f3, `package peach
+func RuleSpit(Pit) Snack`)
+
+	options := ScanOptions{Tests: true}
+	var rs ruleStringer
+	p, w, e := ScanDirWithOptions(&rs, options, tmp)
+	expectNoWarnings(t, w, e)
+	expectPackage(t, p, "peach")
+	expectGrammar(t, &rs, "RuleBite Snack [Peach]\nRuleChoke Inedible [Pit]\nRuleSpit Snack [Pit]")
+
+	rs = nil
+	p, w, e = ScanFilesWithOptions(&rs, options, f2, f1)
+	expectNoWarnings(t, w, e)
+	expectPackage(t, p, "peach")
+	expectGrammar(t, &rs, "RuleBite Snack [Peach]\nRuleChoke Inedible [Pit]")
+
+	// Only the test files, in the external test package
+	rs = nil
+	p, w, e = ScanFilesWithOptions(&rs, options, f2)
+	expectNoWarnings(t, w, e)
+	expectPackage(t, p, "peach")
+
+	if _, _, e = ScanFiles(&rs, f1, f2); e == nil {
+		t.Error("no error for test package without Tests")
+	}
+	options.AllowMultiplePackages = true
+	if _, _, e = ScanDirWithOptions(&rs, options, tmp); e == nil || !strings.Contains(e.Error(), "cannot be combined") {
+		t.Error("wrong error for Tests with AllowMultiplePackages:", e)
+	}
+}
+
+// sharedStringer is a ruleStringer that also accepts shared rules,
+// recording the reducer of each.
+type sharedStringer struct {
+	ruleStringer
+}
+
+func (r *sharedStringer) AddSharedRule(name, reducer string, target Symbol, items []Symbol) error {
+	return r.AddRule(name+"("+reducer+")", target, items)
+}
+
+// positionStringer records rules with the positions of their declarations.
+type positionStringer struct {
+	sharedStringer
+}
+
+func (r *positionStringer) AddRuleAt(name string, pos token.Position, target Symbol, items []Symbol) error {
+	return r.AddRule(fmt.Sprintf("%s@%s:%d", name, filepath.Base(pos.Filename), pos.Line), target, items)
+}
+
+func (r *positionStringer) AddSharedRuleAt(name, reducer string, pos token.Position, target Symbol, items []Symbol) error {
+	return r.AddSharedRule(fmt.Sprintf("%s@%s:%d", name, filepath.Base(pos.Filename), pos.Line), reducer, target, items)
+}
+
+func TestPositions(t *testing.T) {
+	tmp := t.TempDir()
+	f := tmp + "/pos.go"
+	writeFile(f, `package pos
+
+// RuleAdd adds.
+func RuleAdd(Expr, Plus, Expr) Expr { return nil }
+
+//glean:alias Open LParen LBracket
+func RuleParen(Open, Expr) Expr
+`)
+
+	var rs positionStringer
+	_, w, e := ScanFiles(&rs, f)
+	expectNoWarnings(t, w, e)
+	expectGrammar(t, &rs.ruleStringer, `RuleAdd@pos.go:4 Expr [Expr Plus Expr]
+RuleParen_LBracket@pos.go:7(RuleParen) Expr [LBracket Expr]
+RuleParen_LParen@pos.go:7(RuleParen) Expr [LParen Expr]`)
+
+	// Without the positional methods, the rules are added as before.
+	var shared sharedStringer
+	_, w, e = ScanFiles(&shared, f)
+	expectNoWarnings(t, w, e)
+	expectGrammar(t, &shared.ruleStringer, `RuleAdd Expr [Expr Plus Expr]
+RuleParen_LBracket(RuleParen) Expr [LBracket Expr]
+RuleParen_LParen(RuleParen) Expr [LParen Expr]`)
+}
+
+func TestAliases(t *testing.T) {
+	tmp := t.TempDir()
+	f := tmp + "/alias.go"
+	writeFile(f, `package alias
+
+//glean:alias Operator Plus Minus
+func RuleBinary(x Expr, op Operator, y Expr) Expr
+
+// RuleMixed has two directives.
+//
+//glean:alias Open LParen LBracket
+//glean:alias Close RParen RBracket
+func RuleMixed(Open, Expr, Close, Open) Expr
+
+// glean:alias Operator Times (not a directive, because of the space)
+func RuleInt(int) Expr
+`)
+
+	var rs sharedStringer
+	p, w, e := ScanFiles(&rs, f)
+	expectNoWarnings(t, w, e)
+	expectPackage(t, p, "alias")
+	expectGrammar(t, &rs.ruleStringer, `RuleBinary_Minus(RuleBinary) Expr [Expr Minus Expr]
+RuleBinary_Plus(RuleBinary) Expr [Expr Plus Expr]
+RuleInt Expr [int]
+RuleMixed_LBracket_RBracket(RuleMixed) Expr [LBracket Expr RBracket LBracket]
+RuleMixed_LBracket_RParen(RuleMixed) Expr [LBracket Expr RParen LBracket]
+RuleMixed_LParen_RBracket(RuleMixed) Expr [LParen Expr RBracket LParen]
+RuleMixed_LParen_RParen(RuleMixed) Expr [LParen Expr RParen LParen]`)
+
+	var plain ruleStringer
+	if _, _, e = ScanFiles(&plain, f); e == nil || !strings.Contains(e.Error(), "not supported") {
+		t.Error("Expected error for alias directives without AddSharedRule; got", e)
+	}
+
+	for n, bad := range []struct{ directive, message string }{
+		{"//glean:alias Operator", "needs an item and at least one symbol"},
+		{"//glean:alias Operator Plus 2", "'2' is not a valid Go identifier"},
+		{"//glean:alias Oper Plus", "Oper is not a parameter type of RuleBinary"},
+		{"//glean:alias Operator Plus\n//glean:alias Operator Minus", "second alias directive for Operator"},
+	} {
+		bf := fmt.Sprintf("%s/bad%d.go", tmp, n)
+		writeFile(bf, "package alias\n\n"+bad.directive+"\nfunc RuleBinary(x Expr, op Operator, y Expr) Expr\n")
+		rs = sharedStringer{}
+		if _, _, e = ScanFiles(&rs, bf); e == nil || !strings.Contains(e.Error(), bad.message) {
+			t.Errorf("Expected error containing %q for %q; got %v", bad.message, bad.directive, e)
+		}
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	tmp := t.TempDir()
+	f := tmp + "/ignore.go"
+	writeFile(f, `package ignore
+
+func RuleSum(a Expr, _ Plus, b Expr) Expr
+
+// RuleHelper fits the pattern, but is not a rule.
+//
+//glean:ignore
+func RuleHelper(a Expr) Expr
+
+//glean:ignore
+func RulePointer(**Expr) Expr
+
+// glean:ignore (not a directive, because of the space)
+func RuleInt(int) Expr
+`)
+
+	var rs ruleStringer
+	p, w, e := ScanFiles(&rs, f)
+	expectNoWarnings(t, w, e)
+	expectPackage(t, p, "ignore")
+	expectGrammar(t, &rs, "RuleInt Expr [int]\nRuleSum Expr [Expr Plus Expr]")
+}
+
+func TestMultiplePackages(t *testing.T) {
+	tmp := t.TempDir()
+	if e := os.Mkdir(tmp+"/omega", 0700); e != nil {
+		t.Fatal(e)
+	}
+	f1 := tmp + "/alpha.go"
+	f2 := tmp + "/omega/omega.go"
+	writeFile(f1, `package alpha
+func RuleAdd(Expr, Plus, Expr) Expr
+func RuleInt(int) Expr
+func RuleLower(expr) Expr
+func ruleHidden(Expr) Expr
+`)
+	writeFile(f2, `package omega
+import "alpha"
+func RuleAdd(Expr, Times, []Expr) Expr
+func RuleAlpha(alpha.Expr) Expr
+func RuleAlphas([]alpha.Expr) Expr
+func RuleBlock(s [][]Stmt) Block
+func RuleTable([][]alpha.Expr) Expr
+func RuleBad(**alpha.Expr) Expr
+func RuleRows([][]row) Expr
+`)
+
+	var rs ruleStringer
+	p, w, e := ScanFilesWithOptions(&rs, ScanOptions{AllowMultiplePackages: true}, f1, f2)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expectPackage(t, p, "")
+	expectGrammar(t, &rs, `alpha.RuleAdd alpha.Expr [alpha.Expr alpha.Plus alpha.Expr]
+alpha.RuleInt alpha.Expr [int]
+omega.RuleAdd omega.Expr [omega.Expr omega.Times []omega.Expr]
+omega.RuleAlpha omega.Expr [alpha.Expr]
+omega.RuleAlphas omega.Expr [[]alpha.Expr]
+omega.RuleBlock omega.Block [[][]omega.Stmt]
+omega.RuleTable omega.Expr [[][]alpha.Expr]`)
+	expectWarnings(t, w,
+		"ignoring RuleBad: parameter type is not an identifier",
+		"ignoring RuleLower: expr is not exported",
+		"ignoring RuleRows: row is not exported",
+		"ignoring ruleHidden: ruleHidden is not exported")
+
+	rs = nil
+	p, _, e = ScanFilesWithOptions(&rs, ScanOptions{AllowMultiplePackages: true}, f2)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expectPackage(t, p, "omega")
+	expectGrammar(t, &rs, `omega.RuleAdd omega.Expr [omega.Expr omega.Times []omega.Expr]
+omega.RuleAlpha omega.Expr [alpha.Expr]
+omega.RuleAlphas omega.Expr [[]alpha.Expr]
+omega.RuleBlock omega.Block [[][]omega.Stmt]
+omega.RuleTable omega.Expr [[][]alpha.Expr]`)
+
+	f3 := tmp + "/omega2.go"
+	writeFile(f3, "package omega\n")
+	rs = nil
+	if _, _, e = ScanFilesWithOptions(&rs, ScanOptions{AllowMultiplePackages: true}, f2, f3); e == nil {
+		t.Error("no error for one package name in two directories")
+	}
+
+	for _, test := range []struct {
+		symbol Symbol
+		pkg    string
+	}{
+		{"alpha.Expr", "alpha"},
+		{"[]omega.Expr", "omega"},
+		{"Expr", ""},
+		{"[]int", ""},
+	} {
+		if pkg := SymbolPackage(test.symbol); pkg != test.pkg {
+			t.Errorf("SymbolPackage(%s) is %q, not %q", test.symbol, pkg, test.pkg)
+		}
+	}
+}
+
+func TestOnly(t *testing.T) {
+	tmp := t.TempDir()
+	f := tmp + "/foo.go"
+	writeFile(f, `package foo
+func RuleAdd(Expr, Plus, Expr) Expr { return nil }
+func RuleHelper(Expr) string { return "" }
+func makeInt(int) Expr { return nil }
+func badRule(**Expr) Expr { return nil }
+`)
+
+	var rs ruleStringer
+	_, w, e := ScanFilesWithOptions(&rs, ScanOptions{Only: []string{"RuleAdd", "foo.makeInt", "badRule"}}, f)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expectGrammar(t, &rs, "RuleAdd Expr [Expr Plus Expr]\nmakeInt Expr [int]")
+	expectWarnings(t, w, "ignoring badRule: parameter type is not an identifier")
+
+	rs = nil
+	_, _, e = ScanFilesWithOptions(&rs, ScanOptions{Only: []string{"RuleAdd", "RuleMissing"}}, f)
+	if e == nil || e.Error() != "function RuleMissing, named in ScanOptions.Only, was not found" {
+		t.Error("wrong error for missing function:", e)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	tmp := t.TempDir()
+	f := tmp + "/foo.go"
+	writeFile(f, `package foo
+func RuleAdd(Expr, Plus, Expr) Expr { return nil }
+func (eval) RuleAdd(Expr, Plus, Expr) Expr { return nil }
+func (*eval) RuleInt(int) Expr { return nil }
+`)
+
+	var rs ruleStringer
+	_, w, e := ScanFiles(&rs, f)
+	expectNoWarnings(t, w, e)
+	expectGrammar(t, &rs, "RuleAdd Expr [Expr Plus Expr]")
+}
+
+func TestMethodRules(t *testing.T) {
+	tmp := t.TempDir()
+	f := tmp + "/foo.go"
+	writeFile(f, `package foo
+func (b Builder) RuleAdd(Expr, Plus, Expr) Expr { return nil }
+func (b *Builder) RuleInt(int) Expr { return nil }
+func (Builder) NotARule(Tiger, Lion) Liger { return nil }
+`)
+
+	options := ScanOptions{Methods: true}
+	var rs ruleStringer
+	p, w, e := ScanFilesWithOptions(&rs, options, f)
+	expectNoWarnings(t, w, e)
+	expectPackage(t, p, "foo")
+	expectGrammar(t, &rs, "RuleAdd Expr [Expr Plus Expr]\nRuleInt Expr [int]")
+
+	rs = nil
+	p, w, e = ScanDirWithOptions(&rs, options, tmp)
+	expectNoWarnings(t, w, e)
+	expectPackage(t, p, "foo")
+	expectGrammar(t, &rs, "RuleAdd Expr [Expr Plus Expr]\nRuleInt Expr [int]")
+
+	// A method conflicts with a function of the same name.
+	writeFile(tmp+"/bar.go", `package foo
+func RuleInt(int) Expr { return nil }
+`)
+	rs = nil
+	_, _, e = ScanDirWithOptions(&rs, options, tmp)
+	if e == nil || !strings.Contains(e.Error(), "RuleInt previously declared") {
+		t.Error("wrong error for a method and function of the same name:", e)
+	}
+}
+
+func TestScanReader(t *testing.T) {
+	var rs ruleStringer
+	p, w, e := ScanReader(&rs, "generated.go", strings.NewReader(`package gen
+func RuleAdd(Expr, Plus, Expr) Expr { return nil }
+func RuleZap(alpha, beta, gamma)
+`))
+	if e != nil {
+		t.Fatal(e)
+	}
+	expectPackage(t, p, "gen")
+	expectGrammar(t, &rs, "RuleAdd Expr [Expr Plus Expr]")
+	expectWarnings(t, w, "ignoring RuleZap: number of results is not 1")
+	if len(w) == 1 && !strings.HasPrefix(w[0].Error(), "generated.go:3:") {
+		t.Error("wrong position in warning:", w[0])
+	}
+
+	// Empty source fails as an empty file does.
+	tmp := t.TempDir()
+	f := tmp + "/empty.go"
+	writeFile(f, "")
+	_, _, fileErr := ScanFiles(&rs, f)
+	_, _, e = ScanReader(&rs, f, strings.NewReader(""))
+	if e == nil || fileErr == nil || e.Error() != fileErr.Error() {
+		t.Errorf("wrong error for empty source: %v, not %v", e, fileErr)
+	}
+
+	_, _, e = ScanReader(&rs, "broken.go", strings.NewReader("package crash\nfunc filter - ( int ) * { return 0 }\n"))
+	if e == nil || !strings.HasPrefix(e.Error(), "broken.go:2:") {
+		t.Error("wrong error for unparsable source:", e)
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	tmp := t.TempDir()
+	f := tmp + "/foo.go"
+	writeFile(f, `package foo
+func Grammar_Add(Expr, Plus, Expr) Expr { return nil }
+func ParseInt(int) Expr { return nil }
+func Parse(Open, Expr, Close) Expr { return nil }
+func RuleHelper(Expr) string { return "" }
+`)
+
+	var rs ruleStringer
+	_, w, e := ScanFilesWithOptions(&rs, ScanOptions{Prefixes: []string{"Grammar_", "Parse"}}, f)
+	expectNoWarnings(t, w, e)
+	expectGrammar(t, &rs, "Grammar_Add Expr [Expr Plus Expr]\nParse Expr [Open Expr Close]\nParseInt Expr [int]")
+
+	rs = nil
+	p, w, e := ScanDirWithOptions(&rs, ScanOptions{Prefixes: []string{"Parse"}}, tmp)
+	expectNoWarnings(t, w, e)
+	expectPackage(t, p, "foo")
+	expectGrammar(t, &rs, "Parse Expr [Open Expr Close]\nParseInt Expr [int]")
+
+	rs = nil
+	_, w, e = ScanDir(&rs, tmp)
+	expectNoWarnings(t, w, e)
+	expectGrammar(t, &rs, "RuleHelper string [Expr]")
+
+	for _, options := range []ScanOptions{
+		{Prefixes: []string{"Parse", ""}},
+		{Prefixes: []string{"Parse"}, Only: []string{"ParseInt"}},
+	} {
+		if _, _, e := ScanFilesWithOptions(&rs, options, f); e == nil {
+			t.Errorf("no error for options %v", options)
+		}
+	}
+}
+
+func TestScanTree(t *testing.T) {
+	tmp := t.TempDir()
+	for _, dir := range []string{"empty", "lang/sql", "lang/calc", "testdata", ".hidden", "only_tests"} {
+		if e := os.MkdirAll(filepath.Join(tmp, dir), 0700); e != nil {
+			t.Fatal(e)
+		}
+	}
+	writeFile(filepath.Join(tmp, "top.go"), `package top
+func RuleTop(Expr) Top
+`)
+	writeFile(filepath.Join(tmp, "lang/sql/sql.go"), `package sql
+func RuleSelect(Select, Columns) Query
+func RuleZap(alpha, beta, gamma)
+`)
+	writeFile(filepath.Join(tmp, "lang/sql/sql_test.go"), `package sql
+func RuleTest(Test) Query
+`)
+	writeFile(filepath.Join(tmp, "lang/calc/calc.go"), `package calc
+func RuleAdd(Expr, Plus, Expr) Expr
+`)
+	writeFile(filepath.Join(tmp, "lang/README"), "not Go\n")
+	writeFile(filepath.Join(tmp, "testdata/data.go"), `package data
+func RuleData(int) Data
+`)
+	writeFile(filepath.Join(tmp, ".hidden/hidden.go"), `package hidden
+func RuleHidden(int) Hidden
+`)
+	writeFile(filepath.Join(tmp, "only_tests/x_test.go"), `package x
+func RuleX(int) X
+`)
+
+	var dirs []string
+	newRules := func(dir string) RuleAdder {
+		dirs = append(dirs, dir)
+		return new(ruleStringer)
+	}
+	packages, w, e := ScanTree(newRules, tmp)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expectWarnings(t, w, "ignoring RuleZap: number of results is not 1")
+	if len(dirs) != 3 || len(packages) != 3 {
+		t.Fatal("wrong directories scanned:", dirs, packages)
+	}
+	for _, x := range []struct{ dir, pkg, grammar string }{
+		{".", "top", "RuleTop Top [Expr]"},
+		{"lang/sql", "sql", "RuleSelect Query [Select Columns]"},
+		{"lang/calc", "calc", "RuleAdd Expr [Expr Plus Expr]"},
+	} {
+		p, found := packages[x.dir]
+		if !found {
+			t.Error("directory not scanned:", x.dir)
+			continue
+		}
+		expectPackage(t, p.Name, x.pkg)
+		expectGrammar(t, p.Rules.(*ruleStringer), x.grammar)
+	}
+
+	writeFile(filepath.Join(tmp, "lang/calc/broken.go"), `package calc
+func filter - ( int ) * { return 0 }
+`)
+	if _, _, e := ScanTree(newRules, tmp); e == nil {
+		t.Error("no error for an unparsable file")
+	}
+}
+
+func TestBuildContext(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(tmp+"/common.go", `package foo
+func RuleAdd(Expr, Plus, Expr) Expr
+`)
+	writeFile(tmp+"/windows.go", `//go:build windows
+
+package foo
+func RuleWindows(Path) Expr
+`)
+	writeFile(tmp+"/extra.go", `//go:build extra
+
+package foo
+func RuleExtra(Extra) Expr
+`)
+	writeFile(tmp+"/foo_plan9.go", `package foo
+func RulePlan9(Plan9) Expr
+`)
+
+	ctxt := build.Default
+	ctxt.GOOS = "linux"
+	var rs ruleStringer
+	_, w, e := ScanDirWithOptions(&rs, ScanOptions{BuildContext: &ctxt}, tmp)
+	expectNoWarnings(t, w, e)
+	expectGrammar(t, &rs, "RuleAdd Expr [Expr Plus Expr]")
+
+	ctxt.GOOS = "windows"
+	ctxt.BuildTags = []string{"extra"}
+	rs = nil
+	_, w, e = ScanDirWithOptions(&rs, ScanOptions{BuildContext: &ctxt}, tmp)
+	expectNoWarnings(t, w, e)
+	expectGrammar(t, &rs, "RuleAdd Expr [Expr Plus Expr]\nRuleExtra Expr [Extra]\nRuleWindows Expr [Path]")
+
+	ctxt.GOOS = "plan9"
+	ctxt.BuildTags = nil
+	rs = nil
+	_, w, e = ScanFilesWithOptions(&rs, ScanOptions{BuildContext: &ctxt}, tmp+"/common.go", tmp+"/windows.go", tmp+"/foo_plan9.go")
+	expectNoWarnings(t, w, e)
+	expectGrammar(t, &rs, "RuleAdd Expr [Expr Plus Expr]\nRulePlan9 Expr [Plan9]")
+
+	rs = nil
+	_, w, e = ScanFiles(&rs, tmp+"/windows.go", tmp+"/extra.go")
+	expectNoWarnings(t, w, e)
+	expectGrammar(t, &rs, "RuleExtra Expr [Extra]\nRuleWindows Expr [Path]")
+
+	_, _, e = ScanFilesWithOptions(&rs, ScanOptions{BuildContext: &ctxt}, tmp+"/windows.go", tmp+"/extra.go")
+	if e == nil {
+		t.Error("no error when no listed file matches the build context")
+	}
+}
+
+// importStringer records the import paths it is told of.
+type importStringer struct {
+	ruleStringer
+	imports []string
+}
+
+func (r *importStringer) AddImport(name, path string) error {
+	for _, i := range r.imports {
+		if n, p, _ := strings.Cut(i, "="); n == name && p != path {
+			return fmt.Errorf("package %s has import paths %s and %s", name, p, path)
+		}
+	}
+	r.imports = append(r.imports, name+"="+path)
+	return nil
+}
+
+func TestImportedTypes(t *testing.T) {
+	tmp := t.TempDir()
+	f1 := tmp + "/one.go"
+	f2 := tmp + "/two.go"
+	writeFile(f1, `package foo
+import (
+	"go/token"
+	tk "text/scanner"
+)
+func RulePos(p token.Pos) Expr
+func RuleList([]tk.Position) Expr
+func RulePtr(**token.Pos) Expr
+`)
+	writeFile(f2, `package foo
+import "go/ast"
+func RuleNode(ast.Node) Expr
+func RuleUnknown(other.Thing) Expr
+`)
+
+	var rs importStringer
+	_, w, e := ScanFiles(&rs, f1, f2)
+	if e != nil {
+		t.Fatal(e)
+	}
+	expectGrammar(t, &rs.ruleStringer, `RuleList Expr [[]tk.Position]
+RuleNode Expr [ast.Node]
+RulePos Expr [token.Pos]
+RuleUnknown Expr [other.Thing]`)
+	expectWarnings(t, w, "ignoring RulePtr: parameter type is not an identifier")
+	sort.Strings(rs.imports)
+	if got := strings.Join(rs.imports, " "); got != "ast=go/ast tk=text/scanner token=go/token" {
+		t.Error("wrong imports:", got)
+	}
+
+	// A name imported with two paths is an error.
+	writeFile(f2, `package foo
+import token "example.com/token"
+func RuleOther(token.Pos) Expr
+`)
+	rs = importStringer{}
+	if _, _, e = ScanFiles(&rs, f1, f2); e == nil || !strings.Contains(e.Error(), "import paths go/token and example.com/token") {
+		t.Error("wrong error for conflicting imports:", e)
+	}
+}
+
+// A RuleAdder rejecting rules with more than two items
+type shortRules struct{ ruleStringer }
+
+func (r *shortRules) AddRule(name string, target Symbol, items []Symbol) error {
+	if len(items) > 2 {
+		return fmt.Errorf("rule %s is too long", name)
+	}
+	return r.ruleStringer.AddRule(name, target, items)
+}
+
+func TestAddRuleError(t *testing.T) {
+	var rs shortRules
+	_, _, e := ScanReader(&rs, "long.go", strings.NewReader(`package long
+func RuleInt(int) Expr
+func RuleAdd(Expr, Plus, Expr) Expr
+`))
+	if e == nil || e.Error() != "long.go:3:1: RuleAdd: rule RuleAdd is too long" {
+		t.Error("wrong error for a rejected rule:", e)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+// Benchmarks for two ways of reaching the chart of matches, as in the
+// parsers generated by glean:
+// Direct: the parser holds a slice of maps, one for each position
+// Interface: the parser calls the At method of a Chart, as with the
+// ChartStore option, here the default in-memory Chart
+//
+// Each fills a chart for input of the same length, adding matches to each
+// position and looking up matches at earlier positions, much as the
+// parser's completion step does.
+
+package main
+
+import "testing"
+
+type chartMatch struct {
+	prefix, start int
+}
+
+const chartLength = 1000
+const chartPrefixes = 20
+
+type Chart interface {
+	Reset(length int)
+	At(end int) map[int][]*chartMatch
+}
+
+type MemoryChart struct {
+	positions []map[int][]*chartMatch
+}
+
+func (c *MemoryChart) Reset(length int) {
+	c.positions = make([]map[int][]*chartMatch, length+1)
+	for end := range c.positions {
+		c.positions[end] = make(map[int][]*chartMatch)
+	}
+}
+
+func (c *MemoryChart) At(end int) map[int][]*chartMatch {
+	return c.positions[end]
+}
+
+type directParser struct {
+	matches []map[int][]*chartMatch
+}
+
+func (parser *directParser) at(end int) map[int][]*chartMatch {
+	return parser.matches[end]
+}
+
+func (parser *directParser) fill() int {
+	parser.matches = make([]map[int][]*chartMatch, chartLength+1)
+	for end := range parser.matches {
+		parser.matches[end] = make(map[int][]*chartMatch)
+	}
+	found := 0
+	for end := 0; end <= chartLength; end++ {
+		for p := 0; p < chartPrefixes; p++ {
+			start := end - p%8
+			if start < 0 {
+				start = 0
+			}
+			found += len(parser.at(start)[p])
+			parser.at(end)[p] = append(parser.at(end)[p], &chartMatch{p, start})
+		}
+	}
+	return found
+}
+
+type interfaceParser struct {
+	chart Chart
+}
+
+func (parser *interfaceParser) at(end int) map[int][]*chartMatch {
+	return parser.chart.At(end)
+}
+
+func (parser *interfaceParser) fill() int {
+	parser.chart.Reset(chartLength)
+	found := 0
+	for end := 0; end <= chartLength; end++ {
+		for p := 0; p < chartPrefixes; p++ {
+			start := end - p%8
+			if start < 0 {
+				start = 0
+			}
+			found += len(parser.at(start)[p])
+			parser.at(end)[p] = append(parser.at(end)[p], &chartMatch{p, start})
+		}
+	}
+	return found
+}
+
+func BenchmarkDirectChart(b *testing.B) {
+	var parser directParser
+	for n := 0; n < b.N; n++ {
+		parser.fill()
+	}
+}
+
+func BenchmarkInterfaceChart(b *testing.B) {
+	parser := interfaceParser{new(MemoryChart)}
+	for n := 0; n < b.N; n++ {
+		parser.fill()
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+// Benchmarks for finding the symbol ids of tokens with and without the checks
+// that glean's generated parsers drop with the Unchecked option:
+// Checked: a type switch whose default case panics, and a classify function
+// whose results are checked to be in range
+// Unchecked: a type switch whose last case is the default, and a classify
+// function whose results are trusted
+//
+// Each finds the ids of the tokens of source.
+
+package main
+
+import "testing"
+
+func CheckedTokenType(t interface{}) int {
+	switch t.(type) {
+	case Plus:
+		return PlusId
+	case Minus:
+		return MinusId
+	case Times:
+		return TimesId
+	case Open:
+		return OpenId
+	case Close:
+		return CloseId
+	case Literal:
+		return LiteralId
+	default:
+		panic("not a terminal")
+	}
+}
+
+func UncheckedTokenType(t interface{}) int {
+	switch t.(type) {
+	case Plus:
+		return PlusId
+	case Minus:
+		return MinusId
+	case Times:
+		return TimesId
+	case Open:
+		return OpenId
+	case Close:
+		return CloseId
+	default: // Literal
+		return LiteralId
+	}
+}
+
+func classify(t interface{}) int {
+	return t.(Typer).TypeId()
+}
+
+func CheckedClassify(t interface{}) int {
+	if id := classify(t); id >= 0 && id <= LiteralId {
+		return id
+	}
+	return LiteralId + 1
+}
+
+func UncheckedClassify(t interface{}) int {
+	return classify(t)
+}
+
+func benchmarkIds(b *testing.B, tokenType func(interface{}) int) {
+	tokens := ITokenize()
+	counts := make([]int, LiteralId+2)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, t := range tokens {
+			counts[tokenType(t)]++
+		}
+	}
+}
+
+func BenchmarkCheckedTypeSwitch(b *testing.B)   { benchmarkIds(b, CheckedTokenType) }
+func BenchmarkUncheckedTypeSwitch(b *testing.B) { benchmarkIds(b, UncheckedTokenType) }
+func BenchmarkCheckedClassify(b *testing.B)     { benchmarkIds(b, CheckedClassify) }
+func BenchmarkUncheckedClassify(b *testing.B)   { benchmarkIds(b, UncheckedClassify) }
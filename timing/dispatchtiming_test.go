@@ -0,0 +1,394 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+// Benchmarks for finding the symbol ids of tokens of a grammar with many
+// terminal symbols, as glean's generated parsers do:
+// Switch: a type switch with a case for each terminal type, as by default
+// TypeId: a method of each terminal type returning its id, with the TypeIds
+// option
+//
+// Each finds the ids of a sequence of tokens of 80 types.
+
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+type (
+	T00 struct{}
+	T01 struct{}
+	T02 struct{}
+	T03 struct{}
+	T04 struct{}
+	T05 struct{}
+	T06 struct{}
+	T07 struct{}
+	T08 struct{}
+	T09 struct{}
+	T10 struct{}
+	T11 struct{}
+	T12 struct{}
+	T13 struct{}
+	T14 struct{}
+	T15 struct{}
+	T16 struct{}
+	T17 struct{}
+	T18 struct{}
+	T19 struct{}
+	T20 struct{}
+	T21 struct{}
+	T22 struct{}
+	T23 struct{}
+	T24 struct{}
+	T25 struct{}
+	T26 struct{}
+	T27 struct{}
+	T28 struct{}
+	T29 struct{}
+	T30 struct{}
+	T31 struct{}
+	T32 struct{}
+	T33 struct{}
+	T34 struct{}
+	T35 struct{}
+	T36 struct{}
+	T37 struct{}
+	T38 struct{}
+	T39 struct{}
+	T40 struct{}
+	T41 struct{}
+	T42 struct{}
+	T43 struct{}
+	T44 struct{}
+	T45 struct{}
+	T46 struct{}
+	T47 struct{}
+	T48 struct{}
+	T49 struct{}
+	T50 struct{}
+	T51 struct{}
+	T52 struct{}
+	T53 struct{}
+	T54 struct{}
+	T55 struct{}
+	T56 struct{}
+	T57 struct{}
+	T58 struct{}
+	T59 struct{}
+	T60 struct{}
+	T61 struct{}
+	T62 struct{}
+	T63 struct{}
+	T64 struct{}
+	T65 struct{}
+	T66 struct{}
+	T67 struct{}
+	T68 struct{}
+	T69 struct{}
+	T70 struct{}
+	T71 struct{}
+	T72 struct{}
+	T73 struct{}
+	T74 struct{}
+	T75 struct{}
+	T76 struct{}
+	T77 struct{}
+	T78 struct{}
+	T79 struct{}
+)
+
+func (T00) TypeId() int { return 0 }
+func (T01) TypeId() int { return 1 }
+func (T02) TypeId() int { return 2 }
+func (T03) TypeId() int { return 3 }
+func (T04) TypeId() int { return 4 }
+func (T05) TypeId() int { return 5 }
+func (T06) TypeId() int { return 6 }
+func (T07) TypeId() int { return 7 }
+func (T08) TypeId() int { return 8 }
+func (T09) TypeId() int { return 9 }
+func (T10) TypeId() int { return 10 }
+func (T11) TypeId() int { return 11 }
+func (T12) TypeId() int { return 12 }
+func (T13) TypeId() int { return 13 }
+func (T14) TypeId() int { return 14 }
+func (T15) TypeId() int { return 15 }
+func (T16) TypeId() int { return 16 }
+func (T17) TypeId() int { return 17 }
+func (T18) TypeId() int { return 18 }
+func (T19) TypeId() int { return 19 }
+func (T20) TypeId() int { return 20 }
+func (T21) TypeId() int { return 21 }
+func (T22) TypeId() int { return 22 }
+func (T23) TypeId() int { return 23 }
+func (T24) TypeId() int { return 24 }
+func (T25) TypeId() int { return 25 }
+func (T26) TypeId() int { return 26 }
+func (T27) TypeId() int { return 27 }
+func (T28) TypeId() int { return 28 }
+func (T29) TypeId() int { return 29 }
+func (T30) TypeId() int { return 30 }
+func (T31) TypeId() int { return 31 }
+func (T32) TypeId() int { return 32 }
+func (T33) TypeId() int { return 33 }
+func (T34) TypeId() int { return 34 }
+func (T35) TypeId() int { return 35 }
+func (T36) TypeId() int { return 36 }
+func (T37) TypeId() int { return 37 }
+func (T38) TypeId() int { return 38 }
+func (T39) TypeId() int { return 39 }
+func (T40) TypeId() int { return 40 }
+func (T41) TypeId() int { return 41 }
+func (T42) TypeId() int { return 42 }
+func (T43) TypeId() int { return 43 }
+func (T44) TypeId() int { return 44 }
+func (T45) TypeId() int { return 45 }
+func (T46) TypeId() int { return 46 }
+func (T47) TypeId() int { return 47 }
+func (T48) TypeId() int { return 48 }
+func (T49) TypeId() int { return 49 }
+func (T50) TypeId() int { return 50 }
+func (T51) TypeId() int { return 51 }
+func (T52) TypeId() int { return 52 }
+func (T53) TypeId() int { return 53 }
+func (T54) TypeId() int { return 54 }
+func (T55) TypeId() int { return 55 }
+func (T56) TypeId() int { return 56 }
+func (T57) TypeId() int { return 57 }
+func (T58) TypeId() int { return 58 }
+func (T59) TypeId() int { return 59 }
+func (T60) TypeId() int { return 60 }
+func (T61) TypeId() int { return 61 }
+func (T62) TypeId() int { return 62 }
+func (T63) TypeId() int { return 63 }
+func (T64) TypeId() int { return 64 }
+func (T65) TypeId() int { return 65 }
+func (T66) TypeId() int { return 66 }
+func (T67) TypeId() int { return 67 }
+func (T68) TypeId() int { return 68 }
+func (T69) TypeId() int { return 69 }
+func (T70) TypeId() int { return 70 }
+func (T71) TypeId() int { return 71 }
+func (T72) TypeId() int { return 72 }
+func (T73) TypeId() int { return 73 }
+func (T74) TypeId() int { return 74 }
+func (T75) TypeId() int { return 75 }
+func (T76) TypeId() int { return 76 }
+func (T77) TypeId() int { return 77 }
+func (T78) TypeId() int { return 78 }
+func (T79) TypeId() int { return 79 }
+
+func SwitchTokenType(t interface{}) int {
+	switch t.(type) {
+	case T00:
+		return 0
+	case T01:
+		return 1
+	case T02:
+		return 2
+	case T03:
+		return 3
+	case T04:
+		return 4
+	case T05:
+		return 5
+	case T06:
+		return 6
+	case T07:
+		return 7
+	case T08:
+		return 8
+	case T09:
+		return 9
+	case T10:
+		return 10
+	case T11:
+		return 11
+	case T12:
+		return 12
+	case T13:
+		return 13
+	case T14:
+		return 14
+	case T15:
+		return 15
+	case T16:
+		return 16
+	case T17:
+		return 17
+	case T18:
+		return 18
+	case T19:
+		return 19
+	case T20:
+		return 20
+	case T21:
+		return 21
+	case T22:
+		return 22
+	case T23:
+		return 23
+	case T24:
+		return 24
+	case T25:
+		return 25
+	case T26:
+		return 26
+	case T27:
+		return 27
+	case T28:
+		return 28
+	case T29:
+		return 29
+	case T30:
+		return 30
+	case T31:
+		return 31
+	case T32:
+		return 32
+	case T33:
+		return 33
+	case T34:
+		return 34
+	case T35:
+		return 35
+	case T36:
+		return 36
+	case T37:
+		return 37
+	case T38:
+		return 38
+	case T39:
+		return 39
+	case T40:
+		return 40
+	case T41:
+		return 41
+	case T42:
+		return 42
+	case T43:
+		return 43
+	case T44:
+		return 44
+	case T45:
+		return 45
+	case T46:
+		return 46
+	case T47:
+		return 47
+	case T48:
+		return 48
+	case T49:
+		return 49
+	case T50:
+		return 50
+	case T51:
+		return 51
+	case T52:
+		return 52
+	case T53:
+		return 53
+	case T54:
+		return 54
+	case T55:
+		return 55
+	case T56:
+		return 56
+	case T57:
+		return 57
+	case T58:
+		return 58
+	case T59:
+		return 59
+	case T60:
+		return 60
+	case T61:
+		return 61
+	case T62:
+		return 62
+	case T63:
+		return 63
+	case T64:
+		return 64
+	case T65:
+		return 65
+	case T66:
+		return 66
+	case T67:
+		return 67
+	case T68:
+		return 68
+	case T69:
+		return 69
+	case T70:
+		return 70
+	case T71:
+		return 71
+	case T72:
+		return 72
+	case T73:
+		return 73
+	case T74:
+		return 74
+	case T75:
+		return 75
+	case T76:
+		return 76
+	case T77:
+		return 77
+	case T78:
+		return 78
+	case T79:
+		return 79
+	default:
+		panic("not a terminal")
+	}
+}
+
+func TypeIdTokenType(t interface{}) int {
+	if token, ok := t.(interface{ TypeId() int }); ok {
+		if id := token.TypeId(); id >= 0 && id < 80 {
+			return id
+		}
+	}
+	panic("not a terminal")
+}
+
+// A token of each type, and a random sequence of them
+var manyTypes = []interface{}{
+	T00{}, T01{}, T02{}, T03{}, T04{}, T05{}, T06{}, T07{},
+	T08{}, T09{}, T10{}, T11{}, T12{}, T13{}, T14{}, T15{},
+	T16{}, T17{}, T18{}, T19{}, T20{}, T21{}, T22{}, T23{},
+	T24{}, T25{}, T26{}, T27{}, T28{}, T29{}, T30{}, T31{},
+	T32{}, T33{}, T34{}, T35{}, T36{}, T37{}, T38{}, T39{},
+	T40{}, T41{}, T42{}, T43{}, T44{}, T45{}, T46{}, T47{},
+	T48{}, T49{}, T50{}, T51{}, T52{}, T53{}, T54{}, T55{},
+	T56{}, T57{}, T58{}, T59{}, T60{}, T61{}, T62{}, T63{},
+	T64{}, T65{}, T66{}, T67{}, T68{}, T69{}, T70{}, T71{},
+	T72{}, T73{}, T74{}, T75{}, T76{}, T77{}, T78{}, T79{},
+}
+
+func manyTokens() []interface{} {
+	r := rand.New(rand.NewSource(1))
+	tokens := make([]interface{}, 10000)
+	for n := range tokens {
+		tokens[n] = manyTypes[r.Intn(len(manyTypes))]
+	}
+	return tokens
+}
+
+func benchmarkManyIds(b *testing.B, tokenType func(interface{}) int) {
+	tokens := manyTokens()
+	counts := make([]int, len(manyTypes))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for _, t := range tokens {
+			counts[tokenType(t)]++
+		}
+	}
+}
+
+func BenchmarkManySwitch(b *testing.B) { benchmarkManyIds(b, SwitchTokenType) }
+func BenchmarkManyTypeId(b *testing.B) { benchmarkManyIds(b, TypeIdTokenType) }
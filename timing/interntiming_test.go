@@ -0,0 +1,76 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+// Benchmarks for two ways of keeping the values of identifier tokens while
+// applying a parse trace, as in the parsers generated by glean:
+// Plain: each value is kept as given
+// Interned: each value is replaced by the first equal value, as with the
+// Intern option
+//
+// The input is dominated by a few identifiers, repeated, each held in its
+// own memory as if read separately. Besides the time, each benchmark reports
+// the heap memory retained by the values kept once the tokens are dropped.
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+type Ident string
+
+// Make the identifier tokens, each with its own copy of its text.
+func makeIdents() []interface{} {
+	tokens := make([]interface{}, 1e5)
+	for n := range tokens {
+		tokens[n] = Ident([]byte(fmt.Sprintf("identifier%d", n%50)))
+	}
+	return tokens
+}
+
+func keepPlain(tokens []interface{}) []Ident {
+	var stack []Ident
+	for _, t := range tokens {
+		stack = append(stack, t.(Ident))
+	}
+	return stack
+}
+
+func keepInterned(tokens []interface{}) []Ident {
+	var stack []Ident
+	pool := make(map[Ident]Ident)
+	for _, t := range tokens {
+		id := t.(Ident)
+		if u, have := pool[id]; have {
+			id = u
+		} else {
+			pool[id] = id
+		}
+		stack = append(stack, id)
+	}
+	return stack
+}
+
+// Run keep b.N times, then report the heap memory its result retains.
+func benchmarkKeep(b *testing.B, keep func([]interface{}) []Ident) {
+	tokens := makeIdents()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		keep(tokens)
+	}
+	b.StopTimer()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	kept := keep(makeIdents())
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	b.ReportMetric(float64(after.HeapAlloc)-float64(before.HeapAlloc), "retained-B")
+	runtime.KeepAlive(kept)
+}
+
+func BenchmarkPlainIdents(b *testing.B)    { benchmarkKeep(b, keepPlain) }
+func BenchmarkInternedIdents(b *testing.B) { benchmarkKeep(b, keepInterned) }
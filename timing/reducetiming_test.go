@@ -0,0 +1,147 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+// Benchmarks for two ways of applying the rules of a parse, as in the
+// parsers generated by glean:
+// Sequential: run the trace backwards on one goroutine, as the parse
+// function does
+// Concurrent: schedule each rule once its items are done, on a pool of
+// goroutines, as the parse function written with the Concurrent option does
+//
+// The trace is that of a balanced binary tree, whose rules are made slow
+// by a loop of arithmetic standing in for I/O or heavy computation.
+
+package main
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+const reduceDepth = 10
+const reduceWork = 20000
+
+// A step of the trace: a leaf token, or a rule combining the two values
+// before it
+type reduceStep bool
+
+const reduceLeaf, reducePair reduceStep = false, true
+
+// Make the trace of a tree of the given depth, in the order in which it
+// is applied
+func reduceTrace(depth int, steps []reduceStep) []reduceStep {
+	if depth == 0 {
+		return append(steps, reduceLeaf)
+	}
+	steps = reduceTrace(depth-1, steps)
+	steps = reduceTrace(depth-1, steps)
+	return append(steps, reducePair)
+}
+
+// A slow rule
+func reduceRule(a, b int) int {
+	x := a ^ b
+	for n := 0; n < reduceWork; n++ {
+		x = x*1103515245 + 12345
+	}
+	return a + b + x&1
+}
+
+func reduceSequential(steps []reduceStep) int {
+	var stack []int
+	for _, step := range steps {
+		if step == reduceLeaf {
+			stack = append(stack, 1)
+			continue
+		}
+		a, b := stack[len(stack)-2], stack[len(stack)-1]
+		stack = stack[:len(stack)-2]
+		stack = append(stack, reduceRule(a, b))
+	}
+	return stack[0]
+}
+
+type reduceTask struct {
+	args    [2]int
+	value   int
+	parent  *reduceTask
+	index   int
+	pending int32
+}
+
+func reduceConcurrent(steps []reduceStep, workers int) int {
+	type entry struct {
+		task  *reduceTask
+		token int
+	}
+	var stack []entry
+	var ready []*reduceTask
+	for _, step := range steps {
+		if step == reduceLeaf {
+			stack = append(stack, entry{token: 1})
+			continue
+		}
+		items := stack[len(stack)-2:]
+		stack = stack[:len(stack)-2]
+		task := new(reduceTask)
+		for i, item := range items {
+			if item.task == nil {
+				task.args[i] = item.token
+			} else {
+				item.task.parent = task
+				item.task.index = i
+				task.pending++
+			}
+		}
+		if task.pending == 0 {
+			ready = append(ready, task)
+		}
+		stack = append(stack, entry{task: task})
+	}
+	root := stack[0].task
+
+	queue := make(chan *reduceTask, len(ready))
+	for _, task := range ready {
+		queue <- task
+	}
+	close(queue)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range queue {
+				for task != nil {
+					task.value = reduceRule(task.args[0], task.args[1])
+					next := task.parent
+					if next != nil {
+						next.args[task.index] = task.value
+						if atomic.AddInt32(&next.pending, -1) != 0 {
+							next = nil
+						}
+					}
+					task = next
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return root.value
+}
+
+func BenchmarkSequentialReduce(b *testing.B) {
+	steps := reduceTrace(reduceDepth, nil)
+	for n := 0; n < b.N; n++ {
+		reduceSequential(steps)
+	}
+}
+
+func BenchmarkConcurrentReduce(b *testing.B) {
+	steps := reduceTrace(reduceDepth, nil)
+	workers := runtime.GOMAXPROCS(0)
+	for n := 0; n < b.N; n++ {
+		reduceConcurrent(steps, workers)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 Patrick Smith
+// Use of this source code is subject to the MIT-style license in the LICENSE file.
+
+// Benchmarks for two ways of keeping the values of grammar symbols while applying
+// a parse trace, as in the parsers generated by glean:
+// Slice: a plain slice for each symbol, with the pushes and pops written out
+// Generic: a generic stack type for each symbol, with push and pop methods
+//
+// Both evaluate the expression in source, after converting it to postfix form.
+
+package main
+
+import "testing"
+
+// One step in the postfix form of source: a literal, or an operator.
+type Step struct {
+	op      byte
+	literal Literal
+}
+
+// The postfix form of source.
+var postfix []Step
+
+// makePostfix sets postfix. It cannot be an init function, as source is set
+// by the init function in another file.
+func makePostfix() {
+	if postfix != nil {
+		return
+	}
+	var ops []byte
+	popOps := func(above int) {
+		for len(ops) > 0 && precedence(ops[len(ops)-1]) >= above {
+			postfix = append(postfix, Step{op: ops[len(ops)-1]})
+			ops = ops[:len(ops)-1]
+		}
+	}
+	for n := 0; n < len(source); n++ {
+		switch c := source[n]; c {
+		case '(':
+			ops = append(ops, c)
+		case ')':
+			popOps(1)
+			ops = ops[:len(ops)-1]
+		case '+', '-', '*':
+			popOps(precedence(c))
+			ops = append(ops, c)
+		default:
+			postfix = append(postfix, Step{literal: Literal(c - '0')})
+		}
+	}
+	popOps(1)
+}
+
+func precedence(op byte) int {
+	switch op {
+	case '+', '-':
+		return 1
+	case '*':
+		return 2
+	}
+	return 0
+}
+
+// The stacks as kept in a generated parser, using slices.
+type SliceStacks struct {
+	stackLiteral []Literal
+	stackExpr    []Expr
+}
+
+func BenchmarkSliceStack(b *testing.B) {
+	makePostfix()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		p := new(SliceStacks)
+		for _, s := range postfix {
+			if s.op == 0 {
+				p.stackLiteral = append(p.stackLiteral, s.literal)
+				x0 := p.stackLiteral[len(p.stackLiteral)-1]
+				p.stackLiteral = p.stackLiteral[:len(p.stackLiteral)-1]
+				p.stackExpr = append(p.stackExpr, Expr(x0))
+				continue
+			}
+			x1 := p.stackExpr[len(p.stackExpr)-1]
+			p.stackExpr = p.stackExpr[:len(p.stackExpr)-1]
+			x0 := p.stackExpr[len(p.stackExpr)-1]
+			p.stackExpr = p.stackExpr[:len(p.stackExpr)-1]
+			p.stackExpr = append(p.stackExpr, apply(s.op, x0, x1))
+		}
+		if len(p.stackExpr) != 1 {
+			panic("bug")
+		}
+	}
+}
+
+type Stack[T any] []T
+
+func (s *Stack[T]) push(x T) {
+	*s = append(*s, x)
+}
+
+func (s *Stack[T]) pop() T {
+	x := (*s)[len(*s)-1]
+	*s = (*s)[:len(*s)-1]
+	return x
+}
+
+// The stacks as kept in a generated parser, using the generic type.
+type GenericStacks struct {
+	stackLiteral Stack[Literal]
+	stackExpr    Stack[Expr]
+}
+
+func BenchmarkGenericStack(b *testing.B) {
+	makePostfix()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		p := new(GenericStacks)
+		for _, s := range postfix {
+			if s.op == 0 {
+				p.stackLiteral.push(s.literal)
+				x0 := p.stackLiteral.pop()
+				p.stackExpr.push(Expr(x0))
+				continue
+			}
+			x1 := p.stackExpr.pop()
+			x0 := p.stackExpr.pop()
+			p.stackExpr.push(apply(s.op, x0, x1))
+		}
+		if len(p.stackExpr) != 1 {
+			panic("bug")
+		}
+	}
+}
+
+func apply(op byte, x, y Expr) Expr {
+	switch op {
+	case '+':
+		return x + y
+	case '-':
+		return x - y
+	}
+	return x * y
+}